@@ -0,0 +1,121 @@
+// Copyright 2018 The ZikiChombo Authors. All rights reserved.  Use of this source
+// code is governed by a license that can be found in the License file.
+
+package plug
+
+import (
+	"sync/atomic"
+
+	"zikichombo.org/sound"
+)
+
+// AB runs the same input through two processors, "A" and "B", and provides a
+// monitored output which can be switched between the two as well as a
+// difference (null-test) output giving A - B.
+//
+// AB is useful for validating that a modified processor, such as an
+// optimized version of an existing one, does not change the audio.  For this
+// to make sense, A and B should have the same shape: the same channel mode and
+// the same NextFrames() values.
+type AB struct {
+	g     Graph
+	cmp   IO
+	oForm sound.Form
+	sel   int32
+}
+
+// NewAB creates a new A/B comparison of the processors a and b, which map
+// input of form iForm to output of form oForm.  The input is taken from src.
+func NewAB(src sound.Source, iForm, oForm sound.Form, a, b Processor) (*AB, error) {
+	res := &AB{oForm: oForm}
+	split := res.g.New(iForm, iForm, PassThrough)
+	if err := split.SetInput(src); err != nil {
+		return nil, err
+	}
+	ua := res.g.New(iForm, oForm, a)
+	ub := res.g.New(iForm, oForm, b)
+	if err := ua.SetInput(split.Output()); err != nil {
+		return nil, err
+	}
+	if err := ub.SetInput(split.Output()); err != nil {
+		return nil, err
+	}
+	nC := oForm.Channels()
+	cForm := sound.NewForm(oForm.SampleRate(), 2*nC)
+	res.cmp = res.g.New(cForm, cForm, NewProcessor(FullMode, res.compare))
+	if err := res.cmp.SetInput(ua.Output(), chanRange(0, nC)...); err != nil {
+		return nil, err
+	}
+	if err := res.cmp.SetInput(ub.Output(), chanRange(nC, 2*nC)...); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// SelectB selects which processor is monitored: B if b is true, A otherwise.
+// SelectB may be called while the comparison is running, and takes effect
+// at the next block.
+func (ab *AB) SelectB(b bool) {
+	var v int32
+	if b {
+		v = 1
+	}
+	atomic.StoreInt32(&ab.sel, v)
+}
+
+// IsB returns whether B is being monitored.
+func (ab *AB) IsB() bool {
+	return atomic.LoadInt32(&ab.sel) == 1
+}
+
+// Monitor returns a source giving the output of the selected processor.
+// Monitor must be called before Run.
+func (ab *AB) Monitor() sound.Source {
+	return ab.cmp.Output(chanRange(0, ab.oForm.Channels())...)
+}
+
+// Difference returns a source giving the output of A minus the output of B.
+// Difference must be called before Run.
+func (ab *AB) Difference() sound.Source {
+	nC := ab.oForm.Channels()
+	return ab.cmp.Output(chanRange(nC, 2*nC)...)
+}
+
+// Run runs the comparison, returning the first error encountered by
+// any of its nodes.
+func (ab *AB) Run() error {
+	var err error
+	for e := range ab.g.Run() {
+		if err == nil {
+			err = e
+		}
+	}
+	return err
+}
+
+func (ab *AB) compare(dst, src *Block) error {
+	nC := src.Channels / 2
+	N := src.Frames
+	sel := 0
+	if ab.IsB() {
+		sel = nC * N
+	}
+	copy(dst.Samples[:nC*N], src.Samples[sel:sel+nC*N])
+	diff := dst.Samples[nC*N : 2*nC*N]
+	as := src.Samples[:nC*N]
+	bs := src.Samples[nC*N : 2*nC*N]
+	for i := range diff {
+		diff[i] = as[i] - bs[i]
+	}
+	dst.Frames = N
+	return nil
+}
+
+// chanRange gives the channels [start..end).
+func chanRange(start, end int) []int {
+	res := make([]int, end-start)
+	for i := range res {
+		res[i] = start + i
+	}
+	return res
+}
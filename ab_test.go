@@ -0,0 +1,147 @@
+// Copyright 2018 The ZikiChombo Authors. All rights reserved.  Use of this source
+// code is governed by a license that can be found in the License file.
+
+package plug
+
+import (
+	"io"
+	"testing"
+
+	"zikichombo.org/sound"
+	"zikichombo.org/sound/gen"
+	"zikichombo.org/sound/ops"
+)
+
+func TestABNull(t *testing.T) {
+	valve := sound.MonoCd()
+	ab, err := NewAB(ops.Limit(gen.Noise(), 44100), valve, valve, PassThrough, PassThrough)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mon := ab.Monitor()
+	diff := ab.Difference()
+	go ab.Run()
+	go func() {
+		buf := make([]float64, 1024)
+		for {
+			if _, err := mon.Receive(buf); err != nil {
+				return
+			}
+		}
+	}()
+	buf := make([]float64, 1024)
+	ttl := 0
+	for {
+		n, err := diff.Receive(buf)
+		for i := 0; i < n; i++ {
+			if buf[i] != 0 {
+				t.Fatalf("frame %d got difference %f", ttl+i, buf[i])
+			}
+		}
+		ttl += n
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if ttl != 44100 {
+		t.Errorf("got %d not 44100", ttl)
+	}
+}
+
+func TestABSelect(t *testing.T) {
+	mono := sound.MonoCd()
+	neg := NewProcessor(FullMode, func(dst, src *Block) error {
+		for i, v := range src.Samples[:src.Frames] {
+			dst.Samples[i] = -v
+		}
+		dst.Frames = src.Frames
+		return nil
+	})
+	ab, err := NewAB(&ramp{Form: mono, n: 100000}, mono, mono, PassThrough, neg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mon := ab.Monitor()
+	diff := ab.Difference()
+	go ab.Run()
+	go func() {
+		buf := make([]float64, 1024)
+		for {
+			if _, err := diff.Receive(buf); err != nil {
+				return
+			}
+		}
+	}()
+	// the monitor switches at a block boundary some time after SelectB,
+	// and must then stay on the selected processor.
+	const (
+		initA = iota
+		toB
+		onB
+		toA
+		onA
+	)
+	state := initA
+	nB := 0
+	buf := make([]float64, 256)
+	ttl := 0
+	for {
+		n, err := mon.Receive(buf)
+		for i := 0; i < n; i++ {
+			f := float64(ttl + i)
+			v := buf[i]
+			if f == 0 {
+				continue
+			}
+			isB := v == -f
+			if !isB && v != f {
+				t.Fatalf("frame %d: got %f", ttl+i, v)
+			}
+			switch state {
+			case toB:
+				if isB {
+					state = onB
+				}
+			case toA:
+				if !isB {
+					state = onA
+				}
+			case onB:
+				if !isB {
+					t.Fatalf("frame %d: monitored A while B is selected", ttl+i)
+				}
+			default:
+				if isB {
+					t.Fatalf("frame %d: monitored B while A is selected", ttl+i)
+				}
+			}
+			if isB {
+				nB++
+			}
+		}
+		ttl += n
+		switch {
+		case state == initA && ttl >= 10000:
+			ab.SelectB(true)
+			state = toB
+		case state == onB && nB >= 10000:
+			ab.SelectB(false)
+			state = toA
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if ttl != 100000 {
+		t.Errorf("got %d not 100000", ttl)
+	}
+	if state != onA {
+		t.Errorf("ended in state %d, not back on A", state)
+	}
+}
@@ -0,0 +1,133 @@
+// Copyright 2018 The ZikiChombo Authors. All rights reserved.  Use of this source
+// code is governed by a license that can be found in the License file.
+
+package plug
+
+import (
+	"fmt"
+	"time"
+)
+
+// BlockSizer is implemented by processors whose block size may be changed
+// between blocks, as ProcessorController.SetAdaptation does.
+type BlockSizer interface {
+	// BlockSizes returns the minimum and maximum number of input frames
+	// per block.
+	BlockSizes() (min, max int)
+
+	// SetBlockSize sets the number of input frames of the next blocks,
+	// within BlockSizes, as returned by NextFrames.
+	SetBlockSize(frames int)
+}
+
+type resizable struct {
+	Processor
+	min, max, size int
+}
+
+// Resizable makes p, which must process any number of frames up to the
+// number returned by its NextFrames and output as many frames as it
+// inputs, a BlockSizer with block sizes from min to max frames.  The
+// initial block size is that of p, within min and max.
+func Resizable(p Processor, min, max int) Processor {
+	r := &resizable{Processor: p, min: min, max: max}
+	r.size, _ = p.NextFrames()
+	r.SetBlockSize(r.size)
+	return r
+}
+
+func (r *resizable) NextFrames() (int, int) {
+	return r.size, r.size
+}
+
+func (r *resizable) BlockSizes() (int, int) {
+	return r.min, r.max
+}
+
+func (r *resizable) SetBlockSize(frames int) {
+	if frames < r.min {
+		frames = r.min
+	}
+	if frames > r.max {
+		frames = r.max
+	}
+	r.size = frames
+}
+
+// Adaptation gives how an IO adapts the block size of its processor to
+// the measured headroom, the proportion of the duration of a block not
+// spent processing it.  When the headroom stays above High, the block size
+// is halved to reduce latency.  When it stays below Low, the block size is
+// doubled to reduce overhead.  The headroom must stay beyond a threshold
+// for Hold consecutive blocks before the block size changes, so that it
+// does not oscillate.
+type Adaptation struct {
+	Low, High float64
+	Hold      int
+}
+
+// BlockSizeChanged is the event delivered when an IO has adapted the
+// block size of its processor.
+type BlockSizeChanged struct {
+	Old, New int // input frames per block
+	Headroom float64
+}
+
+func (e *BlockSizeChanged) String() string {
+	return fmt.Sprintf("block size changed from %d to %d frames at headroom %.2f", e.Old, e.New, e.Headroom)
+}
+
+// adapter is the state of the adaptation of the block size of a node.
+type adapter struct {
+	Adaptation
+	low, high int // consecutive blocks below Low and above High
+}
+
+// SetAdaptation implements ProcessorController.
+func (n *node) SetAdaptation(a *Adaptation) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if a == nil {
+		n.adapt = nil
+		return
+	}
+	n.adapt = &adapter{Adaptation: *a}
+}
+
+// adaptBlocks adapts the block size of proc after it took d to process a
+// block of nFrms frames, of the iFrms requested.  n.mu must be held.
+func (n *node) adaptBlocks(proc Processor, d time.Duration, iFrms, nFrms int) {
+	a := n.adapt
+	bs, ok := proc.(BlockSizer)
+	if a == nil || !ok || nFrms == 0 {
+		return
+	}
+	dur := time.Duration(float64(nFrms) / n.iForm.SampleRate().Float64() * float64(time.Second))
+	headroom := 1 - float64(d)/float64(dur)
+	switch {
+	case headroom < a.Low:
+		a.low++
+		a.high = 0
+	case headroom > a.High:
+		a.high++
+		a.low = 0
+	default:
+		a.low, a.high = 0, 0
+	}
+	old := iFrms
+	size := old
+	switch {
+	case a.low >= a.Hold:
+		size *= 2
+	case a.high >= a.Hold:
+		size /= 2
+	default:
+		return
+	}
+	a.low, a.high = 0, 0
+	bs.SetBlockSize(size)
+	n.hasNext = false
+	if cur, _ := n.nextFrames(); cur != old {
+		n.emit(&BlockSizeChanged{Old: old, New: cur, Headroom: headroom})
+	}
+}
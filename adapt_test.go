@@ -0,0 +1,38 @@
+// Copyright 2018 The ZikiChombo Authors. All rights reserved.  Use of this source
+// code is governed by a license that can be found in the License file.
+
+package plug
+
+import (
+	"testing"
+
+	"zikichombo.org/sound"
+)
+
+func TestIOAdaptation(t *testing.T) {
+	mono := sound.MonoCd()
+	p := Resizable(PassThrough, 256, 4096)
+	u := New(mono, mono, p)
+	u.SetAdaptation(&Adaptation{Low: 0.1, High: 0.5, Hold: 2})
+	var changes []*BlockSizeChanged
+	u.OnEvent(func(e Event) {
+		if c, ok := e.(*BlockSizeChanged); ok {
+			changes = append(changes, c)
+		}
+	})
+	u.SetInput(&ramp{Form: mono, n: 16384})
+	snk := &capture{Form: mono}
+	u.AddOutput(snk)
+	if err := u.Run(); err != nil {
+		t.Fatal(err)
+	}
+	if snk.frames != 16384 {
+		t.Errorf("got %d frames", snk.frames)
+	}
+	if n, _ := p.NextFrames(); n != 256 {
+		t.Errorf("got block size %d", n)
+	}
+	if len(changes) != 2 || changes[0].Old != 1024 || changes[1].New != 256 {
+		t.Errorf("got changes %v", changes)
+	}
+}
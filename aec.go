@@ -0,0 +1,112 @@
+// Copyright 2018 The ZikiChombo Authors. All rights reserved.  Use of this source
+// code is governed by a license that can be found in the License file.
+
+package plug
+
+import (
+	"fmt"
+	"sync"
+)
+
+// EchoCanceller is a FullMode acoustic echo cancellation processor based on
+// a normalized least mean squares (NLMS) adaptive filter.
+//
+// The input of an EchoCanceller with C input channels consists of C-1
+// microphone channels followed by one far-end reference channel, which
+// carries what is being played back to the speakers.  The output has C-1
+// channels, each being the corresponding microphone channel with the
+// estimated echo of the reference removed.  For example, for a mono
+// microphone:
+//
+//  ec := g.New(sound.NewForm(rate, 2), sound.NewForm(rate, 1), NewEchoCanceller(512))
+//  ec.SetInput(mic, 0)
+//  ec.SetInput(farEnd, 1)
+//
+// The reference must be time aligned with, or earlier than, its echo in the
+// microphone signal and the echo path must fit in the number of filter taps.
+type EchoCanceller struct {
+	mu   sync.Mutex
+	taps int
+	mu0  float64
+	ref  []float64 // reference history, twice taps long for linear access
+	pos  int
+	pow  float64
+	ws   [][]float64
+}
+
+// NewEchoCanceller creates a new echo canceller with an adaptive filter of
+// taps taps.
+func NewEchoCanceller(taps int) *EchoCanceller {
+	return &EchoCanceller{
+		taps: taps,
+		mu0:  0.5,
+		ref:  make([]float64, 2*taps)}
+}
+
+// SetStep sets the NLMS step size, which should be in (0, 2).  Larger
+// values adapt faster and smaller values are more robust to near-end
+// speech.  The default is 0.5.
+func (e *EchoCanceller) SetStep(mu float64) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.mu0 = mu
+}
+
+// ChannelMode implements Processor.
+func (e *EchoCanceller) ChannelMode() ChannelMode {
+	return FullMode
+}
+
+// NextFrames implements Processor.
+func (e *EchoCanceller) NextFrames() (int, int) {
+	return DefaultInFrames, DefaultInFrames
+}
+
+// Process implements Processor.
+func (e *EchoCanceller) Process(dst, src *Block) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	nM := src.Channels - 1
+	if nM < 1 || dst.Channels != nM {
+		return fmt.Errorf("echo canceller: %d input channels cannot map to %d output channels", src.Channels, dst.Channels)
+	}
+	for len(e.ws) < nM {
+		e.ws = append(e.ws, make([]float64, e.taps))
+	}
+	N := src.Frames
+	T := e.taps
+	ref := src.Samples[nM*N : (nM+1)*N]
+	const eps = 1e-6
+	for f, r := range ref {
+		// update the reference history and its power.
+		old := e.ref[e.pos]
+		e.pow += r*r - old*old
+		if e.pow < 0 {
+			e.pow = 0
+		}
+		e.ref[e.pos] = r
+		e.ref[e.pos+T] = r
+		// x[0] is the newest reference sample.
+		x := e.ref[e.pos : e.pos+T]
+		e.pos--
+		if e.pos < 0 {
+			e.pos = T - 1
+		}
+		g := e.mu0 / (eps + e.pow)
+		for c := 0; c < nM; c++ {
+			w := e.ws[c]
+			est := 0.0
+			for i, v := range x {
+				est += w[i] * v
+			}
+			err := src.Samples[c*N+f] - est
+			dst.Samples[c*N+f] = err
+			ge := g * err
+			for i, v := range x {
+				w[i] += ge * v
+			}
+		}
+	}
+	dst.Frames = N
+	return nil
+}
@@ -0,0 +1,57 @@
+// Copyright 2018 The ZikiChombo Authors. All rights reserved.  Use of this source
+// code is governed by a license that can be found in the License file.
+
+package plug
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+
+	"zikichombo.org/sound/freq"
+)
+
+func TestEchoCanceller(t *testing.T) {
+	sr := 48000 * freq.Hertz
+	N, total := 1024, 48000*2
+	rnd := rand.New(rand.NewSource(1))
+	far := make([]float64, total)
+	for i := range far {
+		far[i] = 0.3 * rnd.NormFloat64()
+	}
+	// the echo path delays and attenuates the far end.
+	mic := make([]float64, total)
+	for i := range mic {
+		if i >= 100 {
+			mic[i] += 0.6 * far[i-100]
+		}
+		if i >= 250 {
+			mic[i] -= 0.2 * far[i-250]
+		}
+	}
+	e := NewEchoCanceller(512)
+	var in, out float64
+	for off := 0; off+N <= total; off += N {
+		src := &Block{SampleRate: sr, Channels: 2, Frames: N, Samples: make([]float64, 2*N)}
+		copy(src.Samples, mic[off:off+N])
+		copy(src.Samples[N:], far[off:off+N])
+		dst := &Block{SampleRate: sr, Channels: 1, Samples: make([]float64, N)}
+		if err := e.Process(dst, src); err != nil {
+			t.Fatal(err)
+		}
+		if off < total/2 {
+			continue
+		}
+		for f := 0; f < N; f++ {
+			in += src.Samples[f] * src.Samples[f]
+			out += dst.Samples[f] * dst.Samples[f]
+		}
+	}
+	if erle := 10 * math.Log10(in/out); erle < 30 {
+		t.Errorf("echo attenuated by %.1fdB", erle)
+	}
+	src := &Block{SampleRate: sr, Channels: 2, Frames: N, Samples: make([]float64, 2*N)}
+	if err := e.Process(&Block{SampleRate: sr, Channels: 2, Samples: make([]float64, 2*N)}, src); err == nil {
+		t.Error("processed 2 input channels to 2 output channels")
+	}
+}
@@ -0,0 +1,86 @@
+// Copyright 2018 The ZikiChombo Authors. All rights reserved.  Use of this source
+// code is governed by a license that can be found in the License file.
+
+package plug
+
+import "fmt"
+
+// delayLine delays a signal by a fixed number of frames.
+type delayLine struct {
+	line []float64
+}
+
+func newDelayLine(d int) *delayLine {
+	return &delayLine{line: make([]float64, d)}
+}
+
+// run puts src delayed into dst, which must have the same length.
+func (l *delayLine) run(dst, src []float64) {
+	if len(l.line) == 0 {
+		copy(dst, src)
+		return
+	}
+	N := len(src)
+	line := append(l.line, src...)
+	copy(dst, line[:N])
+	l.line = line[:copy(line, line[N:])]
+}
+
+type aligner struct {
+	lines []*delayLine
+}
+
+// NewAligner creates a FullMode processor which aligns the outputs of
+// the nodes branches of g, whose latencies differ.  The latency of every
+// branch is that computed by Latency, and the channels of the outputs of
+// the branches, in order, are the input channels of the processor.  Each
+// channel is delayed by the difference between the maximum latency and
+// that of its branch, so that parallel branches may be summed coherently
+// downstream.  As for Latency, a branch may be a composite added to g, but
+// not another implementation of IO.
+//
+// The outputs of the branches must have the same sample rate.  The
+// resulting processor expects as many input and output channels as the
+// branches have in all and does not change the number of frames.  Unlike
+// CompensateLatency, which delays the inputs of every node of g, the
+// aligner delays only where it is inserted.
+func (g *Graph) NewAligner(branches ...IO) (Processor, error) {
+	var lats []int
+	max := 0
+	for i, b := range branches {
+		if b.OutForm().SampleRate() != branches[0].OutForm().SampleRate() {
+			return nil, fmt.Errorf("aligner: branch %d: frequency mismatch", i)
+		}
+		l, err := g.Latency(b)
+		if err != nil {
+			return nil, fmt.Errorf("aligner: branch %d: %s", i, err)
+		}
+		if l > max {
+			max = l
+		}
+		for c := 0; c < b.OutForm().Channels(); c++ {
+			lats = append(lats, l)
+		}
+	}
+	res := &aligner{lines: make([]*delayLine, len(lats))}
+	for c, l := range lats {
+		res.lines[c] = newDelayLine(max - l)
+	}
+	return res, nil
+}
+
+func (a *aligner) ChannelMode() ChannelMode {
+	return FullMode
+}
+
+func (a *aligner) NextFrames() (int, int) {
+	return DefaultInFrames, DefaultInFrames
+}
+
+func (a *aligner) Process(dst, src *Block) error {
+	dst.Frames = src.Frames
+	for c, l := range a.lines {
+		l.run(dst.Channel(c), src.Channel(c))
+	}
+	return nil
+}
@@ -0,0 +1,96 @@
+// Copyright 2018 The ZikiChombo Authors. All rights reserved.  Use of this source
+// code is governed by a license that can be found in the License file.
+
+package plug
+
+import (
+	"testing"
+
+	"zikichombo.org/sound"
+)
+
+func TestGraphAligner(t *testing.T) {
+	mono := sound.MonoCd()
+	stereo := sound.StereoCd()
+	var g Graph
+	u0 := g.New(mono, mono, PassThrough)
+	a := g.New(mono, mono, &lag{delayLine: newDelayLine(1500), d: 1500})
+	b := g.New(mono, mono, PassThrough)
+	g.Connect(u0, nil, a, nil)
+	g.Connect(u0, nil, b, nil)
+	al, err := g.NewAligner(a, b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	u1 := g.New(stereo, stereo, al)
+	g.Connect(a, nil, u1, []int{0})
+	g.Connect(b, nil, u1, []int{1})
+	u0.SetInput(&ramp{Form: mono, n: 10000})
+	rec := &record{Form: stereo}
+	u1.AddOutput(rec)
+	for err := range g.Run() {
+		t.Fatal(err)
+	}
+	if len(rec.chs[0]) != 10000 {
+		t.Fatalf("got %d frames", len(rec.chs[0]))
+	}
+	for i, v := range rec.chs[1] {
+		exp := 0.0
+		if i >= 1500 {
+			exp = float64(i - 1500)
+		}
+		if v != exp || rec.chs[0][i] != exp {
+			t.Fatalf("frame %d: got %g %g not %g", i, rec.chs[0][i], v, exp)
+		}
+	}
+	if _, err := g.NewAligner(a, New(mono, mono, PassThrough)); err == nil {
+		t.Error("aligned a node outside the graph")
+	}
+}
+
+func TestGraphAlignerComposite(t *testing.T) {
+	mono := sound.MonoCd()
+	stereo := sound.StereoCd()
+	var sub Graph
+	l := sub.New(mono, mono, &lag{delayLine: newDelayLine(1500), d: 1500})
+	a, err := sub.Compose([]IO{l}, []IO{l})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var g Graph
+	u0 := g.New(mono, mono, PassThrough)
+	g.Add(a)
+	b := g.New(mono, mono, PassThrough)
+	g.Connect(u0, nil, a, nil)
+	g.Connect(u0, nil, b, nil)
+	al, err := g.NewAligner(a, b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	u1 := g.New(stereo, stereo, al)
+	g.Connect(a, nil, u1, []int{0})
+	g.Connect(b, nil, u1, []int{1})
+	u0.SetInput(&ramp{Form: mono, n: 10000})
+	rec := &record{Form: stereo}
+	u1.AddOutput(rec)
+	for err := range g.Run() {
+		t.Fatal(err)
+	}
+	if len(rec.chs[0]) != 10000 {
+		t.Fatalf("got %d frames", len(rec.chs[0]))
+	}
+	for i, v := range rec.chs[1] {
+		exp := 0.0
+		if i >= 1500 {
+			exp = float64(i - 1500)
+		}
+		if v != exp || rec.chs[0][i] != exp {
+			t.Fatalf("frame %d: got %g %g not %g", i, rec.chs[0][i], v, exp)
+		}
+	}
+	other := struct{ IO }{New(mono, mono, PassThrough)}
+	g.Add(other)
+	if _, err := g.NewAligner(a, other); err == nil {
+		t.Error("aligned an unknown IO")
+	}
+}
@@ -0,0 +1,136 @@
+// Copyright 2018 The ZikiChombo Authors. All rights reserved.  Use of this source
+// code is governed by a license that can be found in the License file.
+
+package plug
+
+import (
+	"sort"
+	"sync"
+)
+
+// annotationBacklog is the maximum number of blocks of annotations kept
+// for an output created by Output which is not read by an IO.
+const annotationBacklog = 64
+
+// Annotation annotates a frame of a Block with a key and value, for
+// example a segment boundary found by a detector or a channel label, for
+// IOs downstream.
+//
+// Annotations are passed from IO to IO with the frames they annotate, by
+// the outputs created by Output and connections, and to sinks which
+// implement AnnotationSink.
+type Annotation struct {
+	Offset int // frame of the block annotated
+	Key    string
+	Value  string
+}
+
+// AnnotationSink is implemented by sinks which receive annotations.
+// Annotate is called before each Send with the annotations of the frames
+// sent, if any, offsets being relative to the frames sent.
+type AnnotationSink interface {
+	Annotate(anns []Annotation)
+}
+
+// annRec gives the annotations of frames frames sent to an output.
+type annRec struct {
+	frames int
+	anns   []Annotation
+}
+
+// annSlot carries the annotations of the blocks sent to an output created
+// by Output to the node reading it.
+type annSlot struct {
+	mu   sync.Mutex
+	recs []annRec
+	off  int // frames of recs[0] already read
+}
+
+func (s *annSlot) push(frames int, anns []Annotation) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.recs) == annotationBacklog {
+		s.recs = s.recs[:copy(s.recs, s.recs[1:])]
+		s.off = 0
+	}
+	s.recs = append(s.recs, annRec{frames: frames, anns: anns})
+}
+
+// pop appends to dst the annotations of the next frames frames read, with
+// offsets relative to the first of them.
+func (s *annSlot) pop(dst []Annotation, frames int) []Annotation {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	at := 0
+	for frames > 0 && len(s.recs) > 0 {
+		r := &s.recs[0]
+		m := r.frames - s.off
+		end := s.off + m
+		if m > frames {
+			end = s.off + frames
+		}
+		for _, a := range r.anns {
+			if a.Offset >= s.off && a.Offset < end {
+				a.Offset += at - s.off
+				dst = append(dst, a)
+			}
+		}
+		if m > frames {
+			s.off += frames
+			break
+		}
+		frames -= m
+		at += m
+		s.off = 0
+		s.recs = s.recs[:copy(s.recs, s.recs[1:])]
+	}
+	return dst
+}
+
+// annotate sets the annotations of iBlock, of nFrms frames from the
+// inputs, and starts those of oBlock with the annotations carried over and
+// those of iBlock.  n.mu must be held.
+func (n *node) annotate(iBlock, oBlock *Block, nFrms int) {
+	iBlock.Annotations = iBlock.Annotations[:0]
+	for i := range n.iPkts {
+		if ns, ok := n.iPkts[i].src.(*nodeSource); ok && ns.ann != nil {
+			iBlock.Annotations = ns.ann.pop(iBlock.Annotations, nFrms)
+		}
+	}
+	sort.SliceStable(iBlock.Annotations, func(i, j int) bool {
+		return iBlock.Annotations[i].Offset < iBlock.Annotations[j].Offset
+	})
+	oBlock.Annotations = append(append(oBlock.Annotations[:0], n.anns...), iBlock.Annotations...)
+	n.anns = n.anns[:0]
+}
+
+// carryAnnotations carries the annotations of oBlock past its frames over
+// to the next block.  n.mu must be held.
+func (n *node) carryAnnotations(oBlock *Block) {
+	anns := oBlock.Annotations[:0]
+	for _, a := range oBlock.Annotations {
+		if a.Offset < oBlock.Frames {
+			anns = append(anns, a)
+			continue
+		}
+		a.Offset -= oBlock.Frames
+		n.anns = append(n.anns, a)
+	}
+	oBlock.Annotations = anns
+}
+
+// sendAnnotations passes the annotations of oBlock to output pkt.  n.mu
+// must be held.
+func (n *node) sendAnnotations(pkt *packet, oBlock *Block) {
+	if pkt.ann != nil {
+		var anns []Annotation
+		if len(oBlock.Annotations) > 0 {
+			anns = append(anns, oBlock.Annotations...)
+		}
+		pkt.ann.push(pkt.n, anns)
+		return
+	}
+	if as, ok := pkt.snk.(AnnotationSink); ok && len(oBlock.Annotations) > 0 {
+		as.Annotate(oBlock.Annotations)
+	}
+}
@@ -0,0 +1,58 @@
+// Copyright 2018 The ZikiChombo Authors. All rights reserved.  Use of this source
+// code is governed by a license that can be found in the License file.
+
+package plug
+
+import (
+	"fmt"
+	"testing"
+
+	"zikichombo.org/sound"
+)
+
+// annCapture records the frames annotated.
+type annCapture struct {
+	capture
+	frames []string
+}
+
+func (c *annCapture) Annotate(anns []Annotation) {
+	for _, a := range anns {
+		c.frames = append(c.frames, fmt.Sprintf("%s=%d", a.Value, c.capture.frames+int64(a.Offset)))
+	}
+}
+
+func TestAnnotations(t *testing.T) {
+	mono := sound.MonoCd()
+	for _, seq := range []bool{false, true} {
+		var g Graph
+		u := g.New(mono, mono, NewProcessorFrames(FullMode, func(dst, src *Block) error {
+			N := src.Frames
+			copy(dst.Samples, src.Samples[:N])
+			dst.Frames = N
+			if N > 10 {
+				dst.Annotations = append(dst.Annotations, Annotation{Offset: 10, Key: "segment", Value: fmt.Sprint(src.StartFrame + 10)})
+			}
+			return nil
+		}, 1000, 1000))
+		v := g.New(mono, mono, PassThrough)
+		u.SetInput(&ramp{Form: mono, n: 3000})
+		if err := g.Connect(u, nil, v, nil); err != nil {
+			t.Fatal(err)
+		}
+		snk := &annCapture{capture: capture{Form: mono}}
+		v.AddOutput(snk)
+		var err error
+		if seq {
+			err = g.RunSequential()
+		} else {
+			err = <-g.Run()
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := fmt.Sprint(snk.frames); got != "[10=10 1010=1010 2010=2010]" {
+			t.Errorf("seq=%t: got %s", seq, got)
+		}
+	}
+}
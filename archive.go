@@ -0,0 +1,249 @@
+// Copyright 2018 The ZikiChombo Authors. All rights reserved.  Use of this source
+// code is governed by a license that can be found in the License file.
+
+package plug
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"zikichombo.org/sound"
+)
+
+// ArchiveConfig gives where an ArchiveSink writes its files, when it
+// rotates them and how long it retains them.
+type ArchiveConfig struct {
+	Dir    string // directory of the files.
+	Prefix string // prefix of the file names.
+
+	// A file is rotated once it reaches MaxBytes bytes or MaxDuration of
+	// audio, if not 0.
+	MaxBytes    int64
+	MaxDuration time.Duration
+
+	// Once a file is rotated, only the Keep newest files are retained, and
+	// files older than MaxAge are removed, if not 0.
+	Keep   int
+	MaxAge time.Duration
+}
+
+// ArchiveSink is a sound.Sink which records its input to rotating files,
+// for example to log the output of a live graph with AddSecondaryOutput.
+// The files are 16 bit PCM WAV files named by the prefix, the UTC time at
+// which they were opened and a sequence number, so that they sort in the
+// order they were written.  Rotation is that of a RotatingSink.
+type ArchiveSink struct {
+	sound.Form
+	cfg ArchiveConfig
+	r   *RotatingSink
+}
+
+const wavHeader = 44
+
+// archiveTime is the layout of the times in the names of archive files.
+const archiveTime = "20060102T150405.000000"
+
+// NewArchiveSink creates a new ArchiveSink of form f configured by cfg.
+func NewArchiveSink(f sound.Form, cfg ArchiveConfig) (*ArchiveSink, error) {
+	fi, err := os.Stat(cfg.Dir)
+	if err != nil {
+		return nil, err
+	}
+	if !fi.IsDir() {
+		return nil, fmt.Errorf("archive: %s is not a directory", cfg.Dir)
+	}
+	var limit int64 // frames per file, or 0
+	if cfg.MaxBytes > 0 {
+		limit = (cfg.MaxBytes - wavHeader) / int64(2*f.Channels())
+		if limit < 1 {
+			limit = 1
+		}
+	}
+	if cfg.MaxDuration > 0 {
+		d := int64(cfg.MaxDuration.Seconds() * f.SampleRate().Float64())
+		if d < 1 {
+			d = 1
+		}
+		if limit == 0 || d < limit {
+			limit = d
+		}
+	}
+	a := &ArchiveSink{Form: f, cfg: cfg}
+	a.r = NewRotatingSink(f, int(limit), a.open)
+	return a, nil
+}
+
+// Send implements sound.Sink.
+func (a *ArchiveSink) Send(d []float64) error {
+	return a.r.Send(d)
+}
+
+// Close implements sound.Sink, finishing the current file.
+func (a *ArchiveSink) Close() error {
+	return a.r.Close()
+}
+
+// open opens the i'th file, pruning the files beyond retention.
+func (a *ArchiveSink) open(i int) (sound.Sink, error) {
+	name := fmt.Sprintf("%s%s-%06d.wav", a.cfg.Prefix, time.Now().UTC().Format(archiveTime), i)
+	w, err := createWav(a.Form, filepath.Join(a.cfg.Dir, name))
+	if err != nil {
+		return nil, err
+	}
+	if err := a.prune(name); err != nil {
+		w.Close()
+		return nil, err
+	}
+	return w, nil
+}
+
+// prune removes the files beyond retention, except the current one, cur.
+func (a *ArchiveSink) prune(cur string) error {
+	if a.cfg.Keep <= 0 && a.cfg.MaxAge <= 0 {
+		return nil
+	}
+	fis, err := os.ReadDir(a.cfg.Dir)
+	if err != nil {
+		return err
+	}
+	var names []string
+	for _, fi := range fis {
+		n := fi.Name()
+		if !fi.IsDir() && a.owns(n) {
+			names = append(names, n)
+		}
+	}
+	sort.Strings(names)
+	for i, n := range names {
+		if n == cur {
+			continue
+		}
+		old := a.cfg.Keep > 0 && i < len(names)-a.cfg.Keep
+		if !old && a.cfg.MaxAge > 0 {
+			fi, err := os.Stat(filepath.Join(a.cfg.Dir, n))
+			if err != nil {
+				continue
+			}
+			old = time.Since(fi.ModTime()) > a.cfg.MaxAge
+		}
+		if !old {
+			continue
+		}
+		if err := os.Remove(filepath.Join(a.cfg.Dir, n)); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+// owns returns whether the file name is that of a file opened by an
+// ArchiveSink with the prefix of a, rather than a file merely starting with
+// the prefix, such as those of an archive whose prefix extends it.
+func (a *ArchiveSink) owns(name string) bool {
+	if !strings.HasPrefix(name, a.cfg.Prefix) || !strings.HasSuffix(name, ".wav") {
+		return false
+	}
+	rest := strings.TrimSuffix(name[len(a.cfg.Prefix):], ".wav")
+	i := strings.LastIndexByte(rest, '-')
+	if i < 0 || len(rest)-i-1 < 6 {
+		return false
+	}
+	for _, r := range rest[i+1:] {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	_, err := time.Parse(archiveTime, rest[:i])
+	return err == nil
+}
+
+// wavFile is a sound.Sink writing a 16 bit PCM WAV file, whose header is
+// completed on Close.
+type wavFile struct {
+	sound.Form
+	f      *os.File
+	w      *bufio.Writer
+	frames int64
+	buf    []byte
+}
+
+// createWav creates the file name, writing a header to be completed by
+// Close.
+func createWav(form sound.Form, name string) (*wavFile, error) {
+	f, err := os.Create(name)
+	if err != nil {
+		return nil, err
+	}
+	w := &wavFile{Form: form, f: f, w: bufio.NewWriter(f)}
+	if _, err := w.w.Write(w.header(0)); err != nil {
+		f.Close()
+		return nil, err
+	}
+	return w, nil
+}
+
+// Send implements sound.Sink.
+func (w *wavFile) Send(d []float64) error {
+	C := w.Channels()
+	N := len(d) / C
+	w.buf = w.buf[:0]
+	for i := 0; i < N; i++ {
+		for c := 0; c < C; c++ {
+			v := math.Max(-1, math.Min(1, d[c*N+i]))
+			w.buf = append(w.buf, 0, 0)
+			binary.LittleEndian.PutUint16(w.buf[len(w.buf)-2:], uint16(int16(math.Round(v*32767))))
+		}
+	}
+	if _, err := w.w.Write(w.buf); err != nil {
+		return err
+	}
+	w.frames += int64(N)
+	return nil
+}
+
+// header returns the WAV header of a file of dataBytes bytes of samples.
+func (w *wavFile) header(dataBytes uint32) []byte {
+	C := w.Channels()
+	rate := uint32(w.SampleRate().Float64())
+	h := make([]byte, wavHeader)
+	le := binary.LittleEndian
+	copy(h[0:], "RIFF")
+	le.PutUint32(h[4:], 36+dataBytes)
+	copy(h[8:], "WAVEfmt ")
+	le.PutUint32(h[16:], 16)
+	le.PutUint16(h[20:], 1) // PCM
+	le.PutUint16(h[22:], uint16(C))
+	le.PutUint32(h[24:], rate)
+	le.PutUint32(h[28:], rate*uint32(2*C))
+	le.PutUint16(h[32:], uint16(2*C))
+	le.PutUint16(h[34:], 16)
+	copy(h[36:], "data")
+	le.PutUint32(h[40:], dataBytes)
+	return h
+}
+
+// Close implements sound.Sink, completing the header and closing the file.
+func (w *wavFile) Close() error {
+	f := w.f
+	if err := w.w.Flush(); err != nil {
+		f.Close()
+		return err
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		f.Close()
+		return err
+	}
+	if _, err := f.Write(w.header(uint32(w.frames * int64(2*w.Channels())))); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
@@ -0,0 +1,79 @@
+// Copyright 2018 The ZikiChombo Authors. All rights reserved.  Use of this source
+// code is governed by a license that can be found in the License file.
+
+package plug
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"zikichombo.org/sound"
+)
+
+func TestArchiveSink(t *testing.T) {
+	mono := sound.MonoCd()
+	dir := t.TempDir()
+	a, err := NewArchiveSink(mono, ArchiveConfig{Dir: dir, Prefix: "out-", MaxBytes: 2044, Keep: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	u := New(mono, mono, PassThrough)
+	u.SetInput(&ramp{Form: mono, n: 3500})
+	u.AddOutput(a)
+	if err := u.Run(); err != nil {
+		t.Fatal(err)
+	}
+	names, err := filepath.Glob(filepath.Join(dir, "out-*.wav"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	sort.Strings(names)
+	if len(names) != 2 || !strings.HasSuffix(names[0], "-000002.wav") {
+		t.Fatalf("got files %v", names)
+	}
+	for i, size := range []int64{2044, 1044} {
+		fi, err := os.Stat(names[i])
+		if err != nil {
+			t.Fatal(err)
+		}
+		if fi.Size() != size {
+			t.Errorf("%s: got %d bytes not %d", names[i], fi.Size(), size)
+		}
+	}
+}
+
+func TestArchiveSinkPruneOwnFiles(t *testing.T) {
+	mono := sound.MonoCd()
+	dir := t.TempDir()
+	others := []string{"take2" + "20260101T000000.000000-000001.wav", "take-notes.wav", "takeover.wav"}
+	for _, n := range others {
+		if err := os.WriteFile(filepath.Join(dir, n), nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	a, err := NewArchiveSink(mono, ArchiveConfig{Dir: dir, Prefix: "take", MaxBytes: 2044, Keep: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	u := New(mono, mono, PassThrough)
+	u.SetInput(&ramp{Form: mono, n: 3500})
+	u.AddOutput(a)
+	if err := u.Run(); err != nil {
+		t.Fatal(err)
+	}
+	for _, n := range others {
+		if _, err := os.Stat(filepath.Join(dir, n)); err != nil {
+			t.Errorf("removed %s: %v", n, err)
+		}
+	}
+	fis, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(fis) != len(others)+1 {
+		t.Errorf("got %d files, not the kept one and the others", len(fis))
+	}
+}
@@ -0,0 +1,81 @@
+// Copyright 2018 The ZikiChombo Authors. All rights reserved.  Use of this source
+// code is governed by a license that can be found in the License file.
+
+package plug
+
+import "fmt"
+
+// ckAttach checks that an input or output may be attached to n.  n.mu must
+// be held.
+func (n *node) ckAttach() error {
+	if n.finished {
+		return fmt.Errorf("IO has ended")
+	}
+	if n.seq != nil {
+		return fmt.Errorf("cannot attach to an IO run sequentially")
+	}
+	return nil
+}
+
+// startIn starts serving input i if n is running, so that it is received
+// from with the next block.  n.mu must be held.
+func (n *node) startIn(i int) {
+	if !n.running {
+		return
+	}
+	n.ins[i].retry = n.retry
+	go n.ins[i].serve()
+}
+
+// startOut starts serving output i if n is running, so that it is sent the
+// next block, queued as per SetDepth if need be.  If the queue does not
+// fit in the budget, the output is removed.  n.mu must be held.
+func (n *node) startOut(i int) error {
+	if !n.running {
+		return nil
+	}
+	pkt := &n.oPkts[i]
+	if n.depth == 0 {
+		n.outs[i].retry = n.retry
+		go n.outs[i].serve()
+		return nil
+	}
+	q := newOutQueue(pkt, n.depth, true, n.doneC)
+	if n.budget != nil {
+		_, oFrms := n.nextFrames()
+		if err := n.reserveQueue(q, oFrms, i); err != nil {
+			pkt.off = true
+			pkt.removed = true
+			return err
+		}
+	}
+	pkt.q = q
+	n.wmu.Lock()
+	n.queues = append(n.queues, q)
+	n.wmu.Unlock()
+	q.run = true
+	go q.serve()
+	return nil
+}
+
+// startQueue starts serving the queue q of a secondary output, or of a
+// monitor if input is set, if n is running, so that it is sent the next
+// block.  The blocks of q are reserved in the budget, if any.  n.mu must be
+// held.
+func (n *node) startQueue(q *outQueue, input bool) error {
+	if !n.running {
+		return nil
+	}
+	if n.budget != nil {
+		frms, oFrms := n.nextFrames()
+		if !input {
+			frms = oFrms
+		}
+		if err := n.reserveQueue(q, frms, -1); err != nil {
+			return err
+		}
+	}
+	q.run = true
+	go q.serve()
+	return nil
+}
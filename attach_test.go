@@ -0,0 +1,106 @@
+// Copyright 2018 The ZikiChombo Authors. All rights reserved.  Use of this source
+// code is governed by a license that can be found in the License file.
+
+package plug
+
+import (
+	"context"
+	"testing"
+
+	"zikichombo.org/sound"
+	"zikichombo.org/sound/gen"
+)
+
+// firstSink closes first upon its first Send.
+type firstSink struct {
+	countSink
+	first chan struct{}
+}
+
+func (s *firstSink) Send(d []float64) error {
+	if s.n == 0 {
+		close(s.first)
+	}
+	return s.countSink.Send(d)
+}
+
+func TestIOAttachRunning(t *testing.T) {
+	mono := sound.MonoCd()
+	u := New(mono, mono, PassThrough)
+	u.SetInput(gen.Noise())
+	a := &countSink{Form: mono}
+	u.AddOutput(a)
+	errC := make(chan error)
+	go func() {
+		errC <- u.Run()
+	}()
+	b := &firstSink{countSink: countSink{Form: mono}, first: make(chan struct{})}
+	if err := u.AddOutput(b); err != nil {
+		t.Fatal(err)
+	}
+	<-b.first
+	u.Stop()
+	if err := <-errC; err != nil {
+		t.Fatal(err)
+	}
+	if b.n == 0 || a.n < b.n {
+		t.Errorf("got %d and %d frames", a.n, b.n)
+	}
+	if err := u.AddOutput(b); err == nil {
+		t.Errorf("attached to ended IO")
+	}
+}
+
+func TestIOAttachAbort(t *testing.T) {
+	mono := sound.MonoCd()
+	u := New(mono, mono, PassThrough)
+	u.SetInput(gen.Noise())
+	u.AddOutput(&countSink{Form: mono})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	errC := make(chan error)
+	go func() {
+		errC <- u.RunContext(ctx)
+	}()
+	for i := 0; i < 16; i++ {
+		if i == 8 {
+			// abort reads the outputs while they are being attached.
+			go cancel()
+		}
+		u.AddOutput(&countSink{Form: mono})
+	}
+	if err := <-errC; err != context.Canceled {
+		t.Errorf("got %v, expected context.Canceled", err)
+	}
+}
+
+func TestIOAttachSecondaryRunning(t *testing.T) {
+	mono := sound.MonoCd()
+	u := New(mono, mono, PassThrough)
+	u.SetInput(gen.Noise())
+	u.AddOutput(&countSink{Form: mono})
+	errC := make(chan error)
+	go func() {
+		errC <- u.Run()
+	}()
+	s := &firstSink{countSink: countSink{Form: mono}, first: make(chan struct{})}
+	if _, err := u.AddSecondaryOutput(s, 4); err != nil {
+		t.Fatal(err)
+	}
+	m := &firstSink{countSink: countSink{Form: mono}, first: make(chan struct{})}
+	if _, err := u.AddMonitor(m); err != nil {
+		t.Fatal(err)
+	}
+	<-s.first
+	<-m.first
+	u.Stop()
+	if err := <-errC; err != nil {
+		t.Fatal(err)
+	}
+	if _, err := u.AddSecondaryOutput(s, 4); err == nil {
+		t.Errorf("attached secondary output to ended IO")
+	}
+	if _, err := u.AddMonitor(m); err == nil {
+		t.Errorf("attached monitor to ended IO")
+	}
+}
@@ -0,0 +1,46 @@
+// Copyright 2018 The ZikiChombo Authors. All rights reserved.  Use of this source
+// code is governed by a license that can be found in the License file.
+
+package plug
+
+import "sort"
+
+// ApplyAt implements Parameterized.
+func (n *node) ApplyAt(frame int64, name string, v float64, set func(float64)) {
+	n.pmu.Lock()
+	defer n.pmu.Unlock()
+	i := sort.Search(len(n.sched), func(i int) bool { return n.sched[i].frame > frame })
+	n.sched = append(n.sched, pendingChange{})
+	copy(n.sched[i+1:], n.sched[i:])
+	n.sched[i] = pendingChange{name: name, value: v, set: set, frame: frame}
+}
+
+// applyScheduled applies and journals the scheduled changes which are due
+// at the current position.  n.mu and n.pmu must be held.
+func (n *node) applyScheduled() {
+	i := 0
+	for ; i < len(n.sched) && n.sched[i].frame <= n.pos; i++ {
+		c := &n.sched[i]
+		c.set(c.value)
+		if n.journal != nil {
+			n.journal.Record(ParamChange{Frame: n.pos, Name: c.name, Value: c.value})
+		}
+	}
+	n.version += int64(i)
+	n.sched = n.sched[:copy(n.sched, n.sched[i:])]
+}
+
+// until returns the number of input frames, at most iFrms, to process
+// before the next scheduled change, so that blocks are split at scheduled
+// changes.  n.mu must be held.
+func (n *node) until(iFrms int) int {
+	n.pmu.Lock()
+	defer n.pmu.Unlock()
+	if len(n.sched) == 0 {
+		return iFrms
+	}
+	if d := n.sched[0].frame - n.pos; d > 0 && d < int64(iFrms) {
+		return int(d)
+	}
+	return iFrms
+}
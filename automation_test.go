@@ -0,0 +1,46 @@
+// Copyright 2018 The ZikiChombo Authors. All rights reserved.  Use of this source
+// code is governed by a license that can be found in the License file.
+
+package plug
+
+import (
+	"testing"
+
+	"zikichombo.org/sound"
+)
+
+func TestIOApplyAt(t *testing.T) {
+	mono := sound.MonoCd()
+	g := 1.0
+	p := NewProcessor(FullMode, func(dst, src *Block) error {
+		for i, v := range src.Samples[:src.Frames] {
+			dst.Samples[i] = g * v
+		}
+		dst.Frames = src.Frames
+		return nil
+	})
+	u := New(mono, mono, p)
+	j := NewJournal()
+	u.SetJournal(j)
+	set := func(v float64) { g = v }
+	u.ApplyAt(2500, "gain", 3, set)
+	u.ApplyAt(1500, "gain", 2, set)
+	u.SetInput(&ramp{Form: mono, n: 3000})
+	snk := &capture{Form: mono}
+	u.AddOutput(snk)
+	if err := u.Run(); err != nil {
+		t.Fatal(err)
+	}
+	if snk.frames != 3000 {
+		t.Errorf("got %d frames", snk.frames)
+	}
+	for _, e := range [][2]float64{{1499, 1499}, {1500, 3000}, {2499, 2 * 2499}, {2500, 7500}} {
+		if v := snk.mix[int(e[0])]; v != e[1] {
+			t.Errorf("frame %d: got %f not %f", int(e[0]), v, e[1])
+		}
+	}
+	cs := j.Changes()
+	if len(cs) != 2 || cs[0].Frame != 1500 || cs[1].Frame != 2500 {
+		t.Errorf("got changes %v", cs)
+	}
+}
@@ -0,0 +1,90 @@
+// Copyright 2018 The ZikiChombo Authors. All rights reserved.  Use of this source
+// code is governed by a license that can be found in the License file.
+
+package plug
+
+import (
+	"fmt"
+	"time"
+)
+
+// BackpressureMode gives what an output does when its sink does not keep
+// up with the IO.
+type BackpressureMode int
+
+const (
+	// WaitForSink makes the IO wait for the sink, the behavior of outputs
+	// without a policy.
+	WaitForSink BackpressureMode = iota
+	// DropOldest discards the oldest queued block to make room for the
+	// new one.
+	DropOldest
+	// WaitTimeout makes the IO wait for the sink at most a given time,
+	// after which the new block is discarded.
+	WaitTimeout
+)
+
+// Backpressure is the policy of an output whose sink may not keep up with
+// the IO, such as a recorder on a slow disk next to a live playback
+// output.  Blocks are queued for the sink, so that other outputs are not
+// delayed while it catches up.
+type Backpressure struct {
+	Mode    BackpressureMode
+	Queue   int           // number of blocks queued, at least 1.
+	Timeout time.Duration // maximum wait in WaitTimeout mode.
+}
+
+// OutputDropped is the event delivered after a block in which an output
+// has discarded blocks as per its Backpressure policy.
+type OutputDropped struct {
+	Output  int   // index of the output in the order of calls to AddOutput and Output.
+	Dropped int64 // total number of blocks discarded by the output.
+}
+
+func (e *OutputDropped) String() string {
+	return fmt.Sprintf("output %d dropped %d blocks", e.Output, e.Dropped)
+}
+
+// SetBackpressure implements OutputController.
+func (n *node) SetBackpressure(i int, p Backpressure) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if n.running || n.finished {
+		return fmt.Errorf("backpressure must be set before Run")
+	}
+	if i < 0 || i >= len(n.oPkts) {
+		return fmt.Errorf("no output %d", i)
+	}
+	if p.Mode < WaitForSink || p.Mode > WaitTimeout {
+		return fmt.Errorf("unknown backpressure mode %d", p.Mode)
+	}
+	n.oPkts[i].bp = &p
+	return nil
+}
+
+// newPolicyQueue creates the queue of an output with a backpressure
+// policy.
+func newPolicyQueue(pkt *packet, doneC chan struct{}) *outQueue {
+	bp := pkt.bp
+	q := newOutQueue(pkt, bp.Queue, bp.Mode == WaitForSink, doneC)
+	q.oldest = bp.Mode == DropOldest
+	q.timeout = bp.Timeout
+	q.timed = bp.Mode == WaitTimeout
+	return q
+}
+
+// reportDrops emits an OutputDropped event if output i, served by q, has
+// dropped blocks since the last report.  n.mu must be held.
+func (n *node) reportDrops(q *outQueue, i int) {
+	if i < 0 || n.oPkts[i].bp == nil {
+		return
+	}
+	q.mu.Lock()
+	d := q.dropped
+	q.mu.Unlock()
+	if d == q.reported {
+		return
+	}
+	q.reported = d
+	n.emit(&OutputDropped{Output: i, Dropped: d})
+}
@@ -0,0 +1,60 @@
+// Copyright 2018 The ZikiChombo Authors. All rights reserved.  Use of this source
+// code is governed by a license that can be found in the License file.
+
+package plug
+
+import (
+	"testing"
+	"time"
+
+	"zikichombo.org/sound"
+	"zikichombo.org/sound/gen"
+	"zikichombo.org/sound/ops"
+)
+
+// slowSink is a countSink taking a while to send, which closes closed
+// when closed.
+type slowSink struct {
+	countSink
+	d      time.Duration
+	closed chan struct{}
+}
+
+func (s *slowSink) Close() error {
+	close(s.closed)
+	return nil
+}
+
+func (s *slowSink) Send(d []float64) error {
+	time.Sleep(s.d)
+	return s.countSink.Send(d)
+}
+
+func TestIOBackpressure(t *testing.T) {
+	mono := sound.MonoCd()
+	u := New(mono, mono, PassThrough)
+	u.SetInput(ops.Limit(gen.Noise(), 10240))
+	slow := &slowSink{countSink: countSink{Form: mono}, d: 5 * time.Millisecond, closed: make(chan struct{})}
+	fast := &countSink{Form: mono}
+	u.AddOutput(slow)
+	u.AddOutput(fast)
+	if err := u.SetBackpressure(0, Backpressure{Mode: DropOldest, Queue: 2}); err != nil {
+		t.Fatal(err)
+	}
+	var dropped int64
+	u.OnEvent(func(e Event) {
+		if d, ok := e.(*OutputDropped); ok {
+			dropped = d.Dropped
+		}
+	})
+	if err := u.Run(); err != nil {
+		t.Fatal(err)
+	}
+	if fast.n != 10240 {
+		t.Errorf("fast sink got %d frames", fast.n)
+	}
+	<-slow.closed
+	if dropped == 0 || int64(slow.n)+1024*dropped != 10240 {
+		t.Errorf("slow sink got %d frames, %d blocks dropped", slow.n, dropped)
+	}
+}
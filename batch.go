@@ -0,0 +1,180 @@
+// Copyright 2018 The ZikiChombo Authors. All rights reserved.  Use of this source
+// code is governed by a license that can be found in the License file.
+
+package plug
+
+// BatchProcessor is implemented by processors which process many blocks at
+// once, for example machine learning models running on a GPU or another
+// accelerator, for which dispatching single blocks is too costly.
+type BatchProcessor interface {
+	// NextFrames returns the number of input and output frames of every
+	// block in a batch.  It is called once.
+	NextFrames() (int, int)
+
+	// BatchSize returns the number of blocks in a batch.  It is called
+	// once.
+	BatchSize() int
+
+	// ProcessBatch processes the blocks src into dst, which have the same
+	// length, as Processor.Process in FullMode for each pair of blocks.
+	// ProcessBatch may be called from several goroutines at once, each
+	// with a distinct batch.
+	ProcessBatch(dst, src []*Block) error
+}
+
+// batchJob is a batch dispatched to a BatchProcessor.
+type batchJob struct {
+	src, dst []*Block
+	done     chan struct{}
+	err      error
+}
+
+type batch struct {
+	p            BatchProcessor
+	size         int
+	iFrms, oFrms int
+	lag          int // number of blocks of latency
+	n            int // number of blocks received
+	fill         []*Block
+	jobs         []*batchJob
+	out          int // index of the next output block in jobs[0]
+	free         []*batchJob
+}
+
+// Batch creates a FullMode processor which accumulates blocks into
+// batches for p, dispatches each batch asynchronously as soon as it is
+// full, and returns the results in order.  Up to inflight batches may be
+// processed at once, so that slow batches do not stall the IO; results
+// completing out of order are reordered.
+//
+// Accumulating batches adds latency: the output lags the input by
+// BatchSize() * inflight blocks, during which Batch outputs silence.  The
+// latency is reported by the Latency method, and a run-out (see
+// InputController.SetRunOut) of that many frames flushes the last batches.
+// When the IO stops, Batch waits for the batches in flight.
+func Batch(p BatchProcessor, inflight int) Processor {
+	if inflight < 1 {
+		inflight = 1
+	}
+	iFrms, oFrms := p.NextFrames()
+	size := p.BatchSize()
+	if size < 1 {
+		size = 1
+	}
+	return &batch{
+		p:     p,
+		size:  size,
+		iFrms: iFrms,
+		oFrms: oFrms,
+		lag:   size * inflight}
+}
+
+func (b *batch) ChannelMode() ChannelMode {
+	return FullMode
+}
+
+func (b *batch) NextFrames() (int, int) {
+	return b.iFrms, b.oFrms
+}
+
+// Latency returns the latency of the processor in output frames.
+func (b *batch) Latency() int {
+	return b.lag * b.oFrms
+}
+
+func (b *batch) Process(dst, src *Block) error {
+	if b.fill == nil {
+		b.fill = b.newJob(src, dst).src[:0]
+	}
+	k := len(b.fill)
+	b.fill = b.fill[:k+1]
+	blk := b.fill[k]
+	blk.Frames = src.Frames
+	copy(blk.Samples, src.Samples)
+	if len(b.fill) == b.size {
+		b.dispatch()
+	}
+	b.n++
+	if b.n <= b.lag {
+		zero(dst.Samples)
+		dst.Frames = b.oFrms
+		return nil
+	}
+	job := b.jobs[0]
+	<-job.done
+	if job.err != nil {
+		return job.err
+	}
+	res := job.dst[b.out]
+	copy(dst.Samples, res.Samples)
+	dst.Frames = res.Frames
+	b.out++
+	if b.out == b.size {
+		b.jobs = b.jobs[1:]
+		b.free = append(b.free, job)
+		b.out = 0
+	}
+	return nil
+}
+
+// Close implements ProcessorCloser, waiting for the batches in flight and
+// discarding their results, so that p is no longer called once the IO has
+// stopped.
+func (b *batch) Close() error {
+	var res error
+	for i, job := range b.jobs {
+		if b.fill != nil && i == len(b.jobs)-1 {
+			// not dispatched.
+			break
+		}
+		<-job.done
+		if job.err != nil && res == nil {
+			res = job.err
+		}
+	}
+	b.free = append(b.free, b.jobs...)
+	b.jobs, b.fill = nil, nil
+	b.n, b.out = 0, 0
+	return res
+}
+
+// newJob returns a job with blocks shaped like src and dst, reusing a
+// finished one if possible.  The job's src blocks are also b.fill's
+// storage.
+func (b *batch) newJob(src, dst *Block) *batchJob {
+	var job *batchJob
+	if n := len(b.free); n > 0 {
+		job = b.free[n-1]
+		b.free = b.free[:n-1]
+	} else {
+		job = &batchJob{src: make([]*Block, b.size), dst: make([]*Block, b.size)}
+		for i := range job.src {
+			job.src[i] = &Block{
+				Samples:    make([]float64, src.Channels*b.iFrms),
+				Channels:   src.Channels,
+				SampleRate: src.SampleRate}
+			job.dst[i] = &Block{
+				Samples:    make([]float64, dst.Channels*b.oFrms),
+				Channels:   dst.Channels,
+				SampleRate: dst.SampleRate}
+		}
+	}
+	job.done = make(chan struct{})
+	job.err = nil
+	b.jobs = append(b.jobs, job)
+	return job
+}
+
+// dispatch sends the filled batch, which is the last job, to the batch
+// processor.
+func (b *batch) dispatch() {
+	job := b.jobs[len(b.jobs)-1]
+	for _, d := range job.dst {
+		d.Frames = b.oFrms
+	}
+	go func() {
+		job.err = b.p.ProcessBatch(job.dst, job.src)
+		close(job.done)
+	}()
+	b.fill = nil
+}
@@ -0,0 +1,108 @@
+// Copyright 2018 The ZikiChombo Authors. All rights reserved.  Use of this source
+// code is governed by a license that can be found in the License file.
+
+package plug
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"zikichombo.org/sound"
+)
+
+// doubler is a BatchProcessor which doubles its input, taking longer for
+// every other batch so that batches complete out of order.
+type doubler struct {
+	mu      sync.Mutex
+	batches int
+	sizes   []int
+	max     int // most batches processed at once
+	cur     int
+}
+
+func (d *doubler) NextFrames() (int, int) { return 256, 256 }
+func (d *doubler) BatchSize() int         { return 4 }
+
+func (d *doubler) ProcessBatch(dst, src []*Block) error {
+	d.mu.Lock()
+	slow := d.batches%2 == 0
+	d.batches++
+	d.sizes = append(d.sizes, len(src))
+	d.cur++
+	if d.cur > d.max {
+		d.max = d.cur
+	}
+	d.mu.Unlock()
+	if slow {
+		time.Sleep(5 * time.Millisecond)
+	}
+	for i, s := range src {
+		for j, v := range s.Samples[:s.Channels*s.Frames] {
+			dst[i].Samples[j] = 2 * v
+		}
+		dst[i].Frames = s.Frames
+	}
+	d.mu.Lock()
+	d.cur--
+	d.mu.Unlock()
+	return nil
+}
+
+func TestBatch(t *testing.T) {
+	mono := sound.MonoCd()
+	d := &doubler{}
+	b := Batch(d, 2)
+	lat := latency(b)
+	if lat != 4*2*256 {
+		t.Fatalf("got latency %d", lat)
+	}
+	u := New(mono, mono, b)
+	u.SetInput(&ramp{Form: mono, n: 10240})
+	u.SetRunOut(lat)
+	rec := &record{Form: mono}
+	u.AddOutput(rec)
+	if err := u.Run(); err != nil {
+		t.Fatal(err)
+	}
+	out := rec.chs[0]
+	if len(out) != 10240+lat {
+		t.Fatalf("got %d frames not %d", len(out), 10240+lat)
+	}
+	for i, v := range out {
+		exp := 0.0
+		if i >= lat {
+			exp = 2 * float64(i-lat)
+		}
+		if v != exp {
+			t.Fatalf("frame %d: got %g not %g", i, v, exp)
+		}
+	}
+	for _, n := range d.sizes {
+		if n != 4 {
+			t.Fatalf("got batches of %v", d.sizes)
+		}
+	}
+	if d.max > 2 {
+		t.Errorf("processed %d batches at once", d.max)
+	}
+}
+
+func TestBatchError(t *testing.T) {
+	mono := sound.MonoCd()
+	u := New(mono, mono, Batch(&failBatch{}, 1))
+	u.SetInput(&ramp{Form: mono, n: 10240})
+	u.AddOutput(&countSink{Form: mono})
+	if err := u.Run(); err != ErrInjected {
+		t.Errorf("got %v not %v", err, ErrInjected)
+	}
+}
+
+type failBatch struct{}
+
+func (f *failBatch) NextFrames() (int, int) { return 256, 256 }
+func (f *failBatch) BatchSize() int         { return 2 }
+
+func (f *failBatch) ProcessBatch(dst, src []*Block) error {
+	return ErrInjected
+}
@@ -0,0 +1,113 @@
+// Copyright 2018 The ZikiChombo Authors. All rights reserved.  Use of this source
+// code is governed by a license that can be found in the License file.
+
+package plug
+
+import "math"
+
+// biquad holds normalized (a0 == 1) second order filter coefficients.
+type biquad struct {
+	b0, b1, b2 float64
+	a1, a2     float64
+}
+
+// bqState holds the state of one channel filtered by a biquad, in
+// transposed direct form II.
+type bqState struct {
+	z1, z2 float64
+}
+
+func (b *biquad) run(st *bqState, x float64) float64 {
+	y := b.b0*x + st.z1
+	st.z1 = b.b1*x - b.a1*y + st.z2
+	st.z2 = b.b2*x - b.a2*y
+	return y
+}
+
+func (b *biquad) runSlice(st *bqState, dst, src []float64) {
+	for i, x := range src {
+		dst[i] = b.run(st, x)
+	}
+}
+
+// The following design biquads after the RBJ audio EQ cookbook; f is in Hz
+// and rate is the sample rate in Hz.
+
+func lowpassBQ(rate, f, q float64) biquad {
+	_, cw, alpha := rbjPrelim(rate, f, q)
+	return normBQ(
+		(1-cw)/2, 1-cw, (1-cw)/2,
+		1+alpha, -2*cw, 1-alpha)
+}
+
+func highpassBQ(rate, f, q float64) biquad {
+	_, cw, alpha := rbjPrelim(rate, f, q)
+	return normBQ(
+		(1+cw)/2, -(1 + cw), (1+cw)/2,
+		1+alpha, -2*cw, 1-alpha)
+}
+
+func allpassBQ(rate, f, q float64) biquad {
+	_, cw, alpha := rbjPrelim(rate, f, q)
+	return normBQ(
+		1-alpha, -2*cw, 1+alpha,
+		1+alpha, -2*cw, 1-alpha)
+}
+
+func rbjPrelim(rate, f, q float64) (w, cw, alpha float64) {
+	w = 2 * math.Pi * f / rate
+	cw = math.Cos(w)
+	alpha = math.Sin(w) / (2 * q)
+	return
+}
+
+func normBQ(b0, b1, b2, a0, a1, a2 float64) biquad {
+	return biquad{
+		b0: b0 / a0,
+		b1: b1 / a0,
+		b2: b2 / a0,
+		a1: a1 / a0,
+		a2: a2 / a0}
+}
+
+func bandpassBQ(rate, f, q float64) biquad {
+	_, cw, alpha := rbjPrelim(rate, f, q)
+	return normBQ(
+		alpha, 0, -alpha,
+		1+alpha, -2*cw, 1-alpha)
+}
+
+func notchBQ(rate, f, q float64) biquad {
+	_, cw, alpha := rbjPrelim(rate, f, q)
+	return normBQ(
+		1, -2*cw, 1,
+		1+alpha, -2*cw, 1-alpha)
+}
+
+// peakingBQ, lowShelfBQ and highShelfBQ take a gain g in dB.
+
+func peakingBQ(rate, f, q, g float64) biquad {
+	_, cw, alpha := rbjPrelim(rate, f, q)
+	A := math.Pow(10, g/40)
+	return normBQ(
+		1+alpha*A, -2*cw, 1-alpha*A,
+		1+alpha/A, -2*cw, 1-alpha/A)
+}
+
+func lowShelfBQ(rate, f, q, g float64) biquad {
+	_, cw, alpha := rbjPrelim(rate, f, q)
+	A := math.Pow(10, g/40)
+	sq := 2 * math.Sqrt(A) * alpha
+	return normBQ(
+		A*((A+1)-(A-1)*cw+sq), 2*A*((A-1)-(A+1)*cw), A*((A+1)-(A-1)*cw-sq),
+		(A+1)+(A-1)*cw+sq, -2*((A-1)+(A+1)*cw), (A+1)+(A-1)*cw-sq)
+}
+
+func highShelfBQ(rate, f, q, g float64) biquad {
+	_, cw, alpha := rbjPrelim(rate, f, q)
+	A := math.Pow(10, g/40)
+	sq := 2 * math.Sqrt(A) * alpha
+	return normBQ(
+		A*((A+1)+(A-1)*cw+sq), -2*A*((A-1)+(A+1)*cw), A*((A+1)+(A-1)*cw-sq),
+		(A+1)-(A-1)*cw+sq, 2*((A-1)-(A+1)*cw), (A+1)-(A-1)*cw-sq)
+}
@@ -3,12 +3,63 @@
 
 package plug
 
-import "zikichombo.org/sound/freq"
+import (
+	"time"
+
+	"zikichombo.org/sound/freq"
+)
 
 // Block represents one block of data.
+//
+// Samples are in channel deinterleaved format, channel c at
+// Samples[c*Frames:(c+1)*Frames], unless Interleaved is true, in which
+// case frame f is at Samples[f*Channels:(f+1)*Channels].  Blocks are only
+// interleaved for processors implementing Interleaver.
+//
+// StartFrame and Time are maintained by the IO running the processor, so
+// that processors may implement time synchronized behavior, such as tempo
+// synced modulation, without counting frames themselves.
 type Block struct {
-	Samples    []float64
-	Frames     int    // setable by processor
-	Channels   int    // read only, static w.r.t. IO lifecycle
-	SampleRate freq.T // read only, static w.r.t. IO lifecycle
+	Samples     []float64
+	Frames      int    // setable by processor
+	Channels    int    // read only, static w.r.t. IO lifecycle
+	SampleRate  freq.T // read only, static w.r.t. IO lifecycle
+	Interleaved bool   // read only, static w.r.t. IO lifecycle
+
+	// StartFrame is the number of frames of the stream before the block:
+	// input frames received for src, output frames produced for dst.
+	StartFrame int64
+
+	// Time is the wall time of the first frame of src, for the IO
+	// advancing the clock of a graph (see Graph.SetClockMaster), and zero
+	// otherwise.
+	Time time.Time
+
+	// Annotations annotates frames of the block, sorted by offset.  For
+	// src, they are those sent by the IOs upstream.  dst starts with those
+	// of src, which processors may change or add to, and annotations
+	// past the frames produced are carried to the next block.
+	Annotations []Annotation
+}
+
+// Channel returns the b.Frames samples of channel c of the block, which
+// must not be interleaved: Channel panics for an Interleaved block.  The
+// channels are planar, channel c starting at sample c*b.Frames, so a
+// processor writing to dst through Channel must set dst.Frames to the
+// number of frames it outputs first: lowering dst.Frames afterwards would
+// leave the output channels at the wrong positions, while Process requires
+// them to be contiguous.
+func (b *Block) Channel(c int) []float64 {
+	if b.Interleaved {
+		panic("plug: Channel of interleaved block")
+	}
+	return b.Samples[c*b.Frames : (c+1)*b.Frames]
+}
+
+// EachChannel calls fn with each channel c of the block and its samples,
+// as returned by Channel, in order.
+func (b *Block) EachChannel(fn func(c int, samples []float64)) {
+	for c := 0; c < b.Channels; c++ {
+		fn(c, b.Channel(c))
+	}
 }
@@ -0,0 +1,25 @@
+// Copyright 2018 The ZikiChombo Authors. All rights reserved.  Use of this source
+// code is governed by a license that can be found in the License file.
+
+package plug
+
+import (
+	"testing"
+)
+
+func TestBlockChannel(t *testing.T) {
+	b := &Block{Samples: []float64{0, 1, 2, 10, 11, 12, 99}, Frames: 3, Channels: 2}
+	if d := b.Channel(1); len(d) != 3 || d[0] != 10 || d[2] != 12 {
+		t.Errorf("channel 1: %v", d)
+	}
+	n := 0
+	b.EachChannel(func(c int, d []float64) {
+		if c != n || len(d) != b.Frames || d[0] != float64(10*c) {
+			t.Errorf("channel %d: %v", c, d)
+		}
+		n++
+	})
+	if n != 2 {
+		t.Errorf("got %d channels, want 2", n)
+	}
+}
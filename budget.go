@@ -0,0 +1,171 @@
+// Copyright 2018 The ZikiChombo Authors. All rights reserved.  Use of this source
+// code is governed by a license that can be found in the License file.
+
+package plug
+
+import (
+	"fmt"
+	"sync"
+)
+
+// Budget is a memory budget shared by a set of IOs, for deployments where
+// memory is scarce.  IOs reserve the memory for their sample buffers from
+// the budget.  When the budget does not allow the requested queue sizes
+// (see OutputController.SetDepth, OutputController.AddSecondaryOutput and OutputController.AddMonitor), queues are
+// shortened, so that processing blocks or drops sooner, and a
+// BudgetLimited event is delivered.  When it does not allow the buffers
+// needed to process a block, the IO fails.
+//
+// Buffers are reserved with the headroom they are allocated with, so that
+// growing blocks by a few frames does not reallocate.  The temporary
+// blocks which processors wrapping other processors take from a pool
+// shared by all IOs are outside any budget.
+type Budget struct {
+	mu      sync.Mutex
+	limit   int64
+	used    int64
+	blocked int64
+	dropped int64
+}
+
+// NewBudget creates a new budget of the given number of bytes.
+func NewBudget(bytes int64) *Budget {
+	return &Budget{limit: bytes}
+}
+
+// Limit returns the size of the budget in bytes.
+func (b *Budget) Limit() int64 {
+	return b.limit
+}
+
+// Used returns the number of bytes reserved from the budget.
+func (b *Budget) Used() int64 {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.used
+}
+
+// Blocked returns the number of times an IO waited on an output queue
+// which had been shortened by the budget.
+func (b *Budget) Blocked() int64 {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.blocked
+}
+
+// Dropped returns the number of blocks dropped by secondary and monitor
+// outputs whose queues had been shortened by the budget.
+func (b *Budget) Dropped() int64 {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.dropped
+}
+
+func (b *Budget) reserve(n int64) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.used+n > b.limit {
+		return false
+	}
+	b.used += n
+	return true
+}
+
+func (b *Budget) release(n int64) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.used -= n
+}
+
+func (b *Budget) count(blocked, dropped int64) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.blocked += blocked
+	b.dropped += dropped
+}
+
+// BudgetLimited is the event delivered when an output queue has been
+// shortened to respect a memory budget.
+type BudgetLimited struct {
+	Output    int  // index of the output, or -1 for a secondary or monitor output.
+	Blocking  bool // whether the queue blocks rather than drops when full.
+	Requested int  // the requested number of blocks.
+	Granted   int  // the number of blocks granted.
+}
+
+func (e *BudgetLimited) String() string {
+	what := "drops"
+	if e.Blocking {
+		what = "blocks"
+	}
+	return fmt.Sprintf("output %d queue limited to %d of %d blocks by memory budget, %s when full",
+		e.Output, e.Granted, e.Requested, what)
+}
+
+// SetBudget implements Instrumented.
+func (n *node) SetBudget(b *Budget) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.budget = b
+}
+
+// reserveBlocks reserves the memory for the input and output blocks and
+// packets of a processing block of iFrms input and oFrms output frames,
+// as allocated by buffer.
+func (n *node) reserveBlocks(iFrms, oFrms int) error {
+	if n.budget == nil {
+		return nil
+	}
+	frms := n.iForm.Channels()*iFrms + n.oForm.Channels()*oFrms
+	for i := range n.iPkts {
+		frms += n.iPkts[i].nC * iFrms
+	}
+	for i := range n.oPkts {
+		if n.oPkts[i].q == nil {
+			frms += n.oPkts[i].nC * oFrms
+		}
+	}
+	need := int64(8 * ((5 * frms) / 3))
+	if need <= n.rBlocks {
+		return nil
+	}
+	if !n.budget.reserve(need - n.rBlocks) {
+		return fmt.Errorf("memory budget of %d bytes exceeded: %d more bytes needed", n.budget.Limit(), need-n.rBlocks)
+	}
+	n.rBlocks = need
+	return nil
+}
+
+// reserveQueue shortens q so that its slots of frms frames fit the budget.
+// output is the index of the output served by q, or -1.
+func (n *node) reserveQueue(q *outQueue, frms, output int) error {
+	per := int64(8 * ((5 * q.slots[0].nC * frms) / 3))
+	size := len(q.slots)
+	k := size
+	for k > 0 && !n.budget.reserve(int64(k)*per) {
+		k--
+	}
+	if k == 0 {
+		return fmt.Errorf("memory budget of %d bytes exceeded: no room for output queue", n.budget.Limit())
+	}
+	n.rQueues += int64(k) * per
+	if k == size {
+		return nil
+	}
+	q.limit(k, n.budget)
+	n.emit(&BudgetLimited{Output: output, Blocking: q.block, Requested: size, Granted: k})
+	return nil
+}
+
+// SetMemoryBudget creates a budget of the given number of bytes and sets it
+// on every node in the graph implementing Instrumented, as per
+// Instrumented.SetBudget.
+func (g *Graph) SetMemoryBudget(bytes int64) *Budget {
+	b := NewBudget(bytes)
+	for _, n := range g.nodes {
+		if in, ok := n.(Instrumented); ok {
+			in.SetBudget(b)
+		}
+	}
+	return b
+}
@@ -0,0 +1,104 @@
+// Copyright 2018 The ZikiChombo Authors. All rights reserved.  Use of this source
+// code is governed by a license that can be found in the License file.
+
+package plug
+
+import (
+	"testing"
+
+	"zikichombo.org/sound"
+)
+
+// usedSink is a countSink recording the most bytes used from a budget
+// when sent blocks, which closes closed when closed.
+type usedSink struct {
+	countSink
+	b      *Budget
+	used   int64
+	closed chan struct{}
+}
+
+func newUsedSink(f sound.Form, b *Budget) *usedSink {
+	return &usedSink{countSink: countSink{Form: f}, b: b, closed: make(chan struct{})}
+}
+
+func (s *usedSink) Close() error {
+	close(s.closed)
+	return nil
+}
+
+func (s *usedSink) Send(d []float64) error {
+	if u := s.b.Used(); u > s.used {
+		s.used = u
+	}
+	return s.countSink.Send(d)
+}
+
+func TestBudgetBlocksAndQueues(t *testing.T) {
+	mono := sound.MonoCd()
+	b := NewBudget(1 << 20)
+	u := New(mono, mono, PassThrough)
+	u.SetBudget(b)
+	u.SetDepth(2)
+	u.SetInput(&ramp{Form: mono, n: 3000})
+	snk := newUsedSink(mono, b)
+	u.AddOutput(snk)
+	if err := u.Run(); err != nil {
+		t.Fatal(err)
+	}
+	<-snk.closed
+	// blocks: input and output blocks and the input packet, 1024 frames
+	// each; queue: 2 slots of 1024 frames; all with headroom.
+	blocks := int64(8 * (5 * 3 * 1024) / 3)
+	queue := int64(2 * 8 * ((5 * 1024) / 3))
+	if snk.used != blocks+queue {
+		t.Errorf("used %d while running, not %d", snk.used, blocks+queue)
+	}
+	if used := b.Used(); used != 0 {
+		t.Errorf("used %d after Run", used)
+	}
+}
+
+func TestBudgetExceeded(t *testing.T) {
+	mono := sound.MonoCd()
+	b := NewBudget(1024)
+	u := New(mono, mono, PassThrough)
+	u.SetBudget(b)
+	u.SetInput(&ramp{Form: mono, n: 3000})
+	u.AddOutput(&countSink{Form: mono})
+	if err := u.Run(); err == nil {
+		t.Errorf("ran within %d bytes", b.Limit())
+	}
+	if used := b.Used(); used != 0 {
+		t.Errorf("used %d after Run", used)
+	}
+}
+
+func TestBudgetLimitsQueue(t *testing.T) {
+	mono := sound.MonoCd()
+	blocks := int64(8 * (5 * 3 * 1024) / 3)
+	slot := int64(8 * ((5 * 1024) / 3))
+	b := NewBudget(blocks + 2*slot)
+	u := New(mono, mono, PassThrough)
+	u.SetBudget(b)
+	u.SetDepth(8)
+	var lim *BudgetLimited
+	u.OnEvent(func(e Event) {
+		if bl, ok := e.(*BudgetLimited); ok {
+			lim = bl
+		}
+	})
+	u.SetInput(&ramp{Form: mono, n: 3000})
+	snk := newUsedSink(mono, b)
+	u.AddOutput(snk)
+	if err := u.Run(); err != nil {
+		t.Fatal(err)
+	}
+	<-snk.closed
+	if snk.n != 3000 {
+		t.Errorf("sent %d frames not 3000", snk.n)
+	}
+	if lim == nil || lim.Requested != 8 || lim.Granted != 2 || !lim.Blocking {
+		t.Errorf("got limit %v", lim)
+	}
+}
@@ -0,0 +1,143 @@
+// Copyright 2018 The ZikiChombo Authors. All rights reserved.  Use of this source
+// code is governed by a license that can be found in the License file.
+
+package plug
+
+import "fmt"
+
+// Layout names the arrangement of the channels of a bus.
+type Layout string
+
+// Common layouts.  The channels of a layout are in the order given.
+const (
+	Mono     Layout = "mono"     // C
+	Stereo   Layout = "stereo"   // L R
+	Quad     Layout = "quad"     // L R Ls Rs
+	Surround Layout = "5.1"      // L R C LFE Ls Rs
+	Discrete Layout = "discrete" // any number of unrelated channels
+)
+
+// Channels returns the number of channels of the layout l, or 0 if l is
+// Discrete or unknown.
+func (l Layout) Channels() int {
+	switch l {
+	case Mono:
+		return 1
+	case Stereo:
+		return 2
+	case Quad:
+		return 4
+	case Surround:
+		return 6
+	}
+	return 0
+}
+
+// Bus is a named group of the input or output channels of an IO, such as
+// the main stereo input and the sidechain input of a compressor.
+type Bus struct {
+	Name     string
+	Layout   Layout
+	Channels []int // channels of the IO, in layout order
+}
+
+// ckBus checks the bus b of an IO with nC channels, given its existing
+// buses.
+func ckBus(b Bus, nC int, buses []Bus) error {
+	if b.Name == "" {
+		return fmt.Errorf("bus has no name")
+	}
+	for i := range buses {
+		if buses[i].Name == b.Name {
+			return fmt.Errorf("bus %q already defined", b.Name)
+		}
+	}
+	if len(b.Channels) == 0 {
+		return fmt.Errorf("bus %q has no channels", b.Name)
+	}
+	if m := b.Layout.Channels(); m != 0 && m != len(b.Channels) {
+		return fmt.Errorf("bus %q has %d channels, layout %s has %d", b.Name, len(b.Channels), b.Layout, m)
+	}
+	if err := ckChans(b.Channels, nC); err != nil {
+		return fmt.Errorf("bus %q: %s", b.Name, err)
+	}
+	return nil
+}
+
+// findBus returns the bus named name in buses.
+func findBus(buses []Bus, name string) (Bus, bool) {
+	for _, b := range buses {
+		if b.Name == name {
+			return b, true
+		}
+	}
+	return Bus{}, false
+}
+
+// AddInputBus implements BusConnector.
+func (n *node) AddInputBus(b Bus) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if err := ckBus(b, n.iForm.Channels(), n.iBuses); err != nil {
+		return err
+	}
+	b.Channels = append([]int(nil), b.Channels...)
+	n.iBuses = append(n.iBuses, b)
+	return nil
+}
+
+// AddOutputBus implements BusConnector.
+func (n *node) AddOutputBus(b Bus) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if err := ckBus(b, n.oForm.Channels(), n.oBuses); err != nil {
+		return err
+	}
+	b.Channels = append([]int(nil), b.Channels...)
+	n.oBuses = append(n.oBuses, b)
+	return nil
+}
+
+// InputBus implements BusConnector.
+func (n *node) InputBus(name string) (Bus, bool) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return findBus(n.iBuses, name)
+}
+
+// OutputBus implements BusConnector.
+func (n *node) OutputBus(name string) (Bus, bool) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return findBus(n.oBuses, name)
+}
+
+// ConnectBus connects the output bus fromBus of from to the input bus
+// toBus of to, as per Connect.  The buses must have the same number of
+// channels, and the same layout unless one of them is Discrete.  from
+// and to must implement BusConnector.
+func (g *Graph) ConnectBus(from IO, fromBus string, to IO, toBus string) error {
+	fbc, ok := from.(BusConnector)
+	if !ok {
+		return fmt.Errorf("connect: no output bus %q", fromBus)
+	}
+	fb, ok := fbc.OutputBus(fromBus)
+	if !ok {
+		return fmt.Errorf("connect: no output bus %q", fromBus)
+	}
+	tbc, ok := to.(BusConnector)
+	if !ok {
+		return fmt.Errorf("connect: no input bus %q", toBus)
+	}
+	tb, ok := tbc.InputBus(toBus)
+	if !ok {
+		return fmt.Errorf("connect: no input bus %q", toBus)
+	}
+	if len(fb.Channels) != len(tb.Channels) {
+		return fmt.Errorf("connect: bus %q has %d channels, bus %q has %d", fromBus, len(fb.Channels), toBus, len(tb.Channels))
+	}
+	if fb.Layout != tb.Layout && fb.Layout != Discrete && tb.Layout != Discrete {
+		return fmt.Errorf("connect: bus %q is %s, bus %q is %s", fromBus, fb.Layout, toBus, tb.Layout)
+	}
+	return g.Connect(from, fb.Channels, to, tb.Channels)
+}
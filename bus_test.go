@@ -0,0 +1,41 @@
+// Copyright 2018 The ZikiChombo Authors. All rights reserved.  Use of this source
+// code is governed by a license that can be found in the License file.
+
+package plug
+
+import (
+	"testing"
+
+	"zikichombo.org/sound"
+	"zikichombo.org/sound/freq"
+)
+
+func TestGraphConnectBus(t *testing.T) {
+	quad := sound.NewForm(44100*freq.Hertz, 4)
+	stereo := sound.StereoCd()
+	var g Graph
+	u0 := g.New(quad, quad, PassThrough)
+	u1 := g.New(stereo, stereo, PassThrough)
+	if err := u0.AddOutputBus(Bus{Name: "rear", Layout: Stereo, Channels: []int{2, 3}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := u0.AddOutputBus(Bus{Name: "rear", Layout: Stereo, Channels: []int{0, 1}}); err == nil {
+		t.Errorf("defined bus twice")
+	}
+	if err := u0.AddOutputBus(Bus{Name: "c", Layout: Stereo, Channels: []int{0}}); err == nil {
+		t.Errorf("defined stereo bus with 1 channel")
+	}
+	if err := u0.AddOutputBus(Bus{Name: "front", Layout: Discrete, Channels: []int{0, 1, 2}}); err != nil {
+		t.Fatal(err)
+	}
+	u1.AddInputBus(Bus{Name: "in", Layout: Stereo, Channels: []int{0, 1}})
+	if err := g.ConnectBus(u0, "front", u1, "in"); err == nil {
+		t.Errorf("connected 3 channels to 2")
+	}
+	if err := g.ConnectBus(u0, "rear", u1, "in"); err != nil {
+		t.Fatal(err)
+	}
+	if e := g.Edges()[0]; len(e.FromChans) != 2 || e.FromChans[0] != 2 {
+		t.Errorf("got edge from %v", e.FromChans)
+	}
+}
@@ -0,0 +1,46 @@
+// Copyright 2018 The ZikiChombo Authors. All rights reserved.  Use of this source
+// code is governed by a license that can be found in the License file.
+
+package plug
+
+// SetBypass implements ProcessorController.
+func (n *node) SetBypass(v bool) {
+	n.pmu.Lock()
+	defer n.pmu.Unlock()
+	n.bypassNext = v
+}
+
+// bypassBlock routes the nFrms input frames in iBlock, of a block of shape
+// iFrms, oFrms, to oBlock.  The output has as many frames as the processor
+// would output in proportion to NextFrames, and the input frames are
+// resampled to it by the nearest frame.  Channels are adapted as
+// documented by ProcessorController.SetBypass.
+func bypassBlock(iBlock, oBlock *Block, nFrms, iFrms, oFrms int) {
+	m := oFrms * nFrms / iFrms
+	iC, oC := iBlock.Channels, oBlock.Channels
+	in := func(c, f int) float64 {
+		return iBlock.Samples[c*nFrms+f*nFrms/m]
+	}
+	for c := 0; c < oC; c++ {
+		out := oBlock.Samples[c*m : (c+1)*m]
+		for f := range out {
+			switch {
+			case iC == oC:
+				out[f] = in(c, f)
+			case iC == 1:
+				out[f] = in(0, f)
+			case oC == 1:
+				s := 0.0
+				for ic := 0; ic < iC; ic++ {
+					s += in(ic, f)
+				}
+				out[f] = s / float64(iC)
+			case c < iC:
+				out[f] = in(c, f)
+			default:
+				out[f] = 0
+			}
+		}
+	}
+	oBlock.Frames = m
+}
@@ -0,0 +1,35 @@
+// Copyright 2018 The ZikiChombo Authors. All rights reserved.  Use of this source
+// code is governed by a license that can be found in the License file.
+
+package plug
+
+import (
+	"testing"
+
+	"zikichombo.org/sound"
+)
+
+func TestIOSetBypass(t *testing.T) {
+	mono := sound.MonoCd()
+	stereo := sound.StereoCd()
+	var calls int
+	p := NewProcessor(FullMode, func(dst, src *Block) error {
+		calls++
+		zero(dst.Samples)
+		return nil
+	})
+	u := New(mono, stereo, p)
+	u.SetBypass(true)
+	u.SetInput(&ramp{Form: mono, n: 3000})
+	snk := &capture{Form: stereo}
+	u.AddOutput(snk)
+	if err := u.Run(); err != nil {
+		t.Fatal(err)
+	}
+	if calls != 0 || snk.frames != 3000 {
+		t.Errorf("got %d calls, %d frames", calls, snk.frames)
+	}
+	if snk.mix[0] != 0 || snk.mix[2999] != 2999 {
+		t.Errorf("got %f, %f", snk.mix[0], snk.mix[2999])
+	}
+}
@@ -0,0 +1,147 @@
+// Copyright 2018 The ZikiChombo Authors. All rights reserved.  Use of this source
+// code is governed by a license that can be found in the License file.
+
+package plug
+
+import (
+	"errors"
+	"math"
+	"math/rand"
+	"sync"
+	"time"
+
+	"zikichombo.org/sound"
+)
+
+// ErrInjected is the transient error injected by Chaos.
+var ErrInjected = errors.New("plug: injected fault")
+
+// ChaosConfig gives the rates, as probabilities per call to Receive or
+// Send, at which Chaos injects each kind of fault.
+type ChaosConfig struct {
+	Slow      float64       // rate of calls delayed by SlowDelay.
+	SlowDelay time.Duration // delay of slow calls.
+	Drop      float64       // rate of dropped blocks.
+	Error     float64       // rate of calls failing with ErrInjected.
+	NaN       float64       // rate of blocks with a sample set to NaN.
+	Seed      int64         // seed of the random faults, for reproducibility.
+}
+
+// ChaosStats counts the faults injected by Chaos.
+type ChaosStats struct {
+	Slowed, Dropped, Errors, NaNs int
+}
+
+// Chaos injects faults into the sources and sinks it wraps, so that
+// tests and staging setups can verify that retry policies, timeouts, gap
+// concealment and failover behave as intended.  Chaos is opt-in: the
+// sources and sinks of a graph are only affected when wrapped.
+//
+// A dropped block is reported by a source as a *GapError, and silently
+// discarded by a sink.  Injected errors happen before the wrapped source
+// or sink is called, so that retrying loses no audio.
+type Chaos struct {
+	cfg   ChaosConfig
+	mu    sync.Mutex
+	rnd   *rand.Rand
+	stats ChaosStats
+}
+
+// NewChaos creates a new fault injector.
+func NewChaos(cfg ChaosConfig) *Chaos {
+	return &Chaos{cfg: cfg, rnd: rand.New(rand.NewSource(cfg.Seed))}
+}
+
+// Stats returns the counts of the faults injected so far.
+func (c *Chaos) Stats() ChaosStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.stats
+}
+
+// Source wraps src to inject faults into its Receive.
+func (c *Chaos) Source(src sound.Source) sound.Source {
+	return &chaosSource{Source: src, c: c}
+}
+
+// Sink wraps snk to inject faults into its Send.
+func (c *Chaos) Sink(snk sound.Sink) sound.Sink {
+	return &chaosSink{Sink: snk, c: c}
+}
+
+// roll returns whether a fault of the given rate happens, counting it in
+// *count.
+func (c *Chaos) roll(rate float64, count *int) bool {
+	if rate <= 0 {
+		return false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.rnd.Float64() >= rate {
+		return false
+	}
+	*count++
+	return true
+}
+
+// before injects the faults happening before a call, returning
+// ErrInjected if the call fails.
+func (c *Chaos) before() error {
+	if c.roll(c.cfg.Slow, &c.stats.Slowed) {
+		time.Sleep(c.cfg.SlowDelay)
+	}
+	if c.roll(c.cfg.Error, &c.stats.Errors) {
+		return ErrInjected
+	}
+	return nil
+}
+
+// poison sets a random sample of d to NaN.
+func (c *Chaos) poison(d []float64) {
+	if len(d) == 0 || !c.roll(c.cfg.NaN, &c.stats.NaNs) {
+		return
+	}
+	c.mu.Lock()
+	i := c.rnd.Intn(len(d))
+	c.mu.Unlock()
+	d[i] = math.NaN()
+}
+
+type chaosSource struct {
+	sound.Source
+	c *Chaos
+}
+
+func (s *chaosSource) Receive(dst []float64) (int, error) {
+	if err := s.c.before(); err != nil {
+		return 0, err
+	}
+	n, err := s.Source.Receive(dst)
+	if n > 0 && s.c.roll(s.c.cfg.Drop, &s.c.stats.Dropped) {
+		return 0, &GapError{Frames: n}
+	}
+	s.c.poison(dst[:n*s.Channels()])
+	return n, err
+}
+
+type chaosSink struct {
+	sound.Sink
+	c   *Chaos
+	buf []float64
+}
+
+func (s *chaosSink) Send(src []float64) error {
+	if err := s.c.before(); err != nil {
+		return err
+	}
+	if s.c.roll(s.c.cfg.Drop, &s.c.stats.Dropped) {
+		return nil
+	}
+	if s.c.cfg.NaN > 0 {
+		// the caller's samples are not ours to poison.
+		s.buf = append(s.buf[:0], src...)
+		src = s.buf
+		s.c.poison(src)
+	}
+	return s.Sink.Send(src)
+}
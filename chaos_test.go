@@ -0,0 +1,29 @@
+// Copyright 2018 The ZikiChombo Authors. All rights reserved.  Use of this source
+// code is governed by a license that can be found in the License file.
+
+package plug
+
+import (
+	"testing"
+
+	"zikichombo.org/sound/gen"
+)
+
+func TestChaos(t *testing.T) {
+	c := NewChaos(ChaosConfig{Drop: 1})
+	src := NewConcealer(c.Source(gen.Noise()), 1024)
+	d := make([]float64, 512)
+	for i := 0; i < 4; i++ {
+		n, err := src.Receive(d)
+		if err != nil || n != 512 {
+			t.Fatalf("got %d, %v", n, err)
+		}
+	}
+	if st := c.Stats(); st.Dropped != 4 {
+		t.Errorf("got %d dropped blocks", st.Dropped)
+	}
+	c = NewChaos(ChaosConfig{Error: 1})
+	if _, err := c.Source(gen.Noise()).Receive(d); err != ErrInjected {
+		t.Errorf("got %v not %v", err, ErrInjected)
+	}
+}
@@ -0,0 +1,258 @@
+// Copyright 2018 The ZikiChombo Authors. All rights reserved.  Use of this source
+// code is governed by a license that can be found in the License file.
+
+package plug
+
+import (
+	"errors"
+	"fmt"
+)
+
+// StateSaver is implemented by processors whose state may be saved in and
+// restored from a checkpoint.
+type StateSaver interface {
+	// SaveState returns an encoding of the state of the processor.
+	SaveState() ([]byte, error)
+	// RestoreState restores the processor to a state returned by SaveState.
+	RestoreState(state []byte) error
+}
+
+// NodeState is the state of an IO captured in a checkpoint.
+type NodeState struct {
+	// Frames is the number of input frames the IO has received.
+	Frames int64
+	// Buffered holds input frames received but not yet processed, in channel
+	// deinterleaved format, and BufferedFrames their number of frames.
+	Buffered       []float64
+	BufferedFrames int
+	// Partial holds, per input, the frames received from another IO for
+	// the block being received, in channel deinterleaved format.  The IO
+	// sending them counted them as output, so they are received again
+	// first once restored.  Partial is nil for other inputs.
+	Partial [][]float64
+	// Processor holds the state of the processor if it implements
+	// StateSaver.
+	Processor []byte
+}
+
+// Checkpoint holds the state of all the nodes of a graph, in the order in
+// which the nodes were created.
+type Checkpoint struct {
+	Nodes []NodeState
+}
+
+var errNotQuiesced = errors.New("not quiesced")
+
+// enter marks the start of processing a block after having received add
+// input frames, waiting while the node is quiesced.  n.mu must be held,
+// and is released while waiting, so that n may be configured while
+// quiesced.
+func (n *node) enter(add int) {
+	n.qmu.Lock()
+	n.pos += int64(add)
+	n.held = true
+	for i := range n.iPkts {
+		if p := inPipe(n.iPkts[i].src); p != nil {
+			p.reset()
+		}
+	}
+	for n.quiesced {
+		n.qmu.Unlock()
+		n.mu.Unlock()
+		n.qmu.Lock()
+		for n.quiesced {
+			n.qc.Wait()
+		}
+		n.qmu.Unlock()
+		n.mu.Lock()
+		n.qmu.Lock()
+	}
+	n.held = false
+	n.inProc = true
+	n.qmu.Unlock()
+}
+
+// leave marks the end of processing a block, once it has been sent.
+func (n *node) leave() {
+	n.qmu.Lock()
+	defer n.qmu.Unlock()
+	n.inProc = false
+	n.qc.Broadcast()
+}
+
+// takeCarry puts the buffered frames of a restored checkpoint into b.
+func (n *node) takeCarry(b *Block) int {
+	b.Samples = buffer(b.Samples, b.Channels, n.carryFrames)
+	copy(b.Samples, n.carry)
+	b.Frames = n.carryFrames
+	n.carry = nil
+	return b.Frames
+}
+
+// Quiesce implements Pauser.
+func (n *node) Quiesce() {
+	n.qmu.Lock()
+	n.quiesced = true
+	for n.inProc {
+		n.qc.Wait()
+	}
+	n.qmu.Unlock()
+	n.wmu.Lock()
+	qs := append([]*outQueue(nil), n.queues...)
+	n.wmu.Unlock()
+	for _, q := range qs {
+		q.busy.Wait()
+	}
+}
+
+// setProc sets the processor of n to p.  n.mu must be held, and n.qmu is
+// taken as Checkpoint saves the state of the processor without n.mu.
+func (n *node) setProc(p Processor) {
+	n.qmu.Lock()
+	defer n.qmu.Unlock()
+	n.proc = p
+	n.hasNext = false
+}
+
+// Resume implements Pauser.
+func (n *node) Resume() {
+	n.qmu.Lock()
+	defer n.qmu.Unlock()
+	n.quiesced = false
+	n.paused = false
+	n.qc.Broadcast()
+	n.wakeSeq()
+}
+
+// Checkpoint implements Checkpointer.
+func (n *node) Checkpoint() (*NodeState, error) {
+	n.qmu.Lock()
+	defer n.qmu.Unlock()
+	if !n.quiesced {
+		return nil, errNotQuiesced
+	}
+	res := &NodeState{Frames: n.pos}
+	if n.held {
+		b := n.iBlock
+		res.BufferedFrames = b.Frames
+		res.Buffered = append([]float64(nil), b.Samples[:b.Channels*b.Frames]...)
+	} else {
+		n.wmu.Lock()
+		for i := range n.iPkts {
+			p := inPipe(n.iPkts[i].src)
+			if p == nil {
+				continue
+			}
+			if res.Partial == nil {
+				res.Partial = make([][]float64, len(n.iPkts))
+			}
+			res.Partial[i] = p.partial()
+		}
+		n.wmu.Unlock()
+	}
+	if ss, ok := n.proc.(StateSaver); ok {
+		st, err := ss.SaveState()
+		if err != nil {
+			return nil, err
+		}
+		res.Processor = st
+	}
+	return res, nil
+}
+
+// Restore implements Checkpointer.
+func (n *node) Restore(st *NodeState) error {
+	n.qmu.Lock()
+	defer n.qmu.Unlock()
+	if st.Processor != nil {
+		ss, ok := n.proc.(StateSaver)
+		if !ok {
+			return fmt.Errorf("processor cannot restore state")
+		}
+		if err := ss.RestoreState(st.Processor); err != nil {
+			return err
+		}
+	}
+	n.pos = st.Frames
+	if st.BufferedFrames > 0 {
+		n.carry = append([]float64(nil), st.Buffered...)
+		n.carryFrames = st.BufferedFrames
+	}
+	n.wmu.Lock()
+	defer n.wmu.Unlock()
+	for i, pre := range st.Partial {
+		if len(pre) == 0 {
+			continue
+		}
+		if i >= len(n.iPkts) || inPipe(n.iPkts[i].src) == nil {
+			return fmt.Errorf("input %d is not read from an IO", i)
+		}
+		inPipe(n.iPkts[i].src).restore(append([]float64(nil), pre...))
+	}
+	return nil
+}
+
+// Quiesce quiesces all the nodes of the graph implementing Pauser, in
+// dependency order so that the blocks nodes send to one another are
+// received before quiescing the nodes receiving them.
+func (g *Graph) Quiesce() {
+	ns, err := g.sorted()
+	if err != nil {
+		// the graph has nodes other than those created by New.
+		for _, n := range g.nodes {
+			if p, ok := n.(Pauser); ok {
+				p.Quiesce()
+			}
+		}
+		return
+	}
+	for _, n := range ns {
+		n.Quiesce()
+	}
+}
+
+// Resume resumes all the nodes of the graph implementing Pauser.
+func (g *Graph) Resume() {
+	for _, n := range g.nodes {
+		if p, ok := n.(Pauser); ok {
+			p.Resume()
+		}
+	}
+}
+
+// Checkpoint captures the state of all the nodes of the graph, which must
+// be quiesced and implement Checkpointer.
+func (g *Graph) Checkpoint() (*Checkpoint, error) {
+	res := &Checkpoint{Nodes: make([]NodeState, len(g.nodes))}
+	for i, n := range g.nodes {
+		cp, ok := n.(Checkpointer)
+		if !ok {
+			return nil, fmt.Errorf("node %d: cannot be checkpointed", i)
+		}
+		st, err := cp.Checkpoint()
+		if err != nil {
+			return nil, fmt.Errorf("node %d: %s", i, err)
+		}
+		res.Nodes[i] = *st
+	}
+	return res, nil
+}
+
+// Restore restores the state of all the nodes of the graph from cp, which
+// must come from a graph with the same structure.  Restore should be called
+// before Run.
+func (g *Graph) Restore(cp *Checkpoint) error {
+	if len(cp.Nodes) != len(g.nodes) {
+		return fmt.Errorf("checkpoint has %d nodes, graph has %d", len(cp.Nodes), len(g.nodes))
+	}
+	for i, n := range g.nodes {
+		r, ok := n.(Checkpointer)
+		if !ok {
+			return fmt.Errorf("node %d: cannot be restored", i)
+		}
+		if err := r.Restore(&cp.Nodes[i]); err != nil {
+			return fmt.Errorf("node %d: %s", i, err)
+		}
+	}
+	return nil
+}
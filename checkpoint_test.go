@@ -0,0 +1,227 @@
+// Copyright 2018 The ZikiChombo Authors. All rights reserved.  Use of this source
+// code is governed by a license that can be found in the License file.
+
+package plug
+
+import (
+	"encoding/binary"
+	"io"
+	"math"
+	"sync"
+	"testing"
+	"time"
+
+	"zikichombo.org/sound"
+	"zikichombo.org/sound/gen"
+)
+
+// integrator outputs the running sum of its input, in blocks of 300
+// frames, and saves the sum as its state.
+type integrator struct {
+	sum float64
+}
+
+func (p *integrator) ChannelMode() ChannelMode { return MonoMode }
+func (p *integrator) NextFrames() (int, int)   { return 300, 300 }
+
+func (p *integrator) Process(dst, src *Block) error {
+	for i, v := range src.Samples[:src.Frames] {
+		p.sum += v
+		dst.Samples[i] = p.sum
+	}
+	dst.Frames = src.Frames
+	return nil
+}
+
+func (p *integrator) SaveState() ([]byte, error) {
+	var b [8]byte
+	binary.BigEndian.PutUint64(b[:], math.Float64bits(p.sum))
+	return b[:], nil
+}
+
+func (p *integrator) RestoreState(st []byte) error {
+	p.sum = math.Float64frombits(binary.BigEndian.Uint64(st))
+	return nil
+}
+
+// gated is a ramp which stops at stop frames until opened.
+type gated struct {
+	ramp
+	stop int64
+	open chan struct{}
+}
+
+func (g *gated) Receive(d []float64) (int, error) {
+	if g.pos == g.stop {
+		<-g.open
+	}
+	if n := g.stop - g.pos; n > 0 && int64(len(d)) > n {
+		d = d[:n]
+	}
+	return g.ramp.Receive(d)
+}
+
+// record is a sink keeping all its audio.
+type record struct {
+	sound.Form
+	mu  sync.Mutex
+	chs [][]float64
+}
+
+func (r *record) Close() error { return nil }
+
+func (r *record) Send(d []float64) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	nC := r.Channels()
+	if r.chs == nil {
+		r.chs = make([][]float64, nC)
+	}
+	n := len(d) / nC
+	for c := 0; c < nC; c++ {
+		r.chs[c] = append(r.chs[c], d[c*n:(c+1)*n]...)
+	}
+	return nil
+}
+
+func (r *record) frames() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.chs == nil {
+		return 0
+	}
+	return len(r.chs[0])
+}
+
+// diamond builds a graph sending src through an integrator and a pass
+// through node, and joining them in stereo into out.
+func diamond(src sound.Source, out sound.Sink) (*Graph, IO) {
+	mono := sound.MonoCd()
+	stereo := sound.StereoCd()
+	g := &Graph{}
+	u0 := g.New(mono, mono, NewProcessorFrames(MonoMode, PassThrough.Process, 300, 300))
+	ua := g.New(mono, mono, &integrator{})
+	ub := g.New(mono, mono, NewProcessorFrames(MonoMode, PassThrough.Process, 300, 300))
+	u1 := g.New(stereo, stereo, PassThrough)
+	u0.SetInput(src)
+	ua.SetInput(u0.Output())
+	ub.SetInput(u0.Output())
+	u1.SetInput(ua.Output(), 0)
+	u1.SetInput(ub.Output(), 1)
+	u1.AddOutput(out)
+	return g, u1
+}
+
+func TestGraphCheckpoint(t *testing.T) {
+	mono := sound.MonoCd()
+	const N = 10000
+	src := &gated{ramp: ramp{Form: mono, n: N}, stop: 1500, open: make(chan struct{})}
+	rec := &record{Form: sound.StereoCd()}
+	g, u1 := diamond(src, rec)
+	errs := g.Run()
+	// the source stalls after 5 blocks of 300 frames, which u1 receives in
+	// a block of 1024 frames and 476 frames in flight.
+	in := inPipe(u1.(*node).iPkts[0].src)
+	for rec.frames() < 1024 || len(in.partial()) < 476 {
+		time.Sleep(time.Millisecond)
+	}
+	g.Quiesce()
+	cp, err := g.Checkpoint()
+	if err != nil {
+		t.Fatal(err)
+	}
+	at := rec.frames()
+	if st := cp.Nodes[3]; len(st.Partial) != 2 || len(st.Partial[0]) != 476 || len(st.Partial[1]) != 476 {
+		t.Errorf("got partial inputs %v", st.Partial)
+	}
+	close(src.open)
+	g.Resume()
+	for err := range errs {
+		t.Fatal(err)
+	}
+
+	// restore into a new graph, reading from the checkpointed position.
+	rec2 := &record{Form: sound.StereoCd()}
+	g2, _ := diamond(&ramp{Form: mono, pos: cp.Nodes[0].Frames, n: N}, rec2)
+	if err := g2.Restore(cp); err != nil {
+		t.Fatal(err)
+	}
+	for err := range g2.Run() {
+		t.Fatal(err)
+	}
+	if len(rec.chs[0]) != N || len(rec2.chs[0]) != N-at {
+		t.Fatalf("got %d frames, %d frames after restoring at %d", len(rec.chs[0]), len(rec2.chs[0]), at)
+	}
+	for c := range rec.chs {
+		for i, v := range rec2.chs[c] {
+			if v != rec.chs[c][at+i] {
+				t.Fatalf("channel %d frame %d: got %g not %g", c, at+i, v, rec.chs[c][at+i])
+			}
+		}
+	}
+}
+
+func TestGraphQuiesceDiamond(t *testing.T) {
+	mono := sound.MonoCd()
+	const N = 44100
+	rec := &record{Form: sound.StereoCd()}
+	g, _ := diamond(&ramp{Form: mono, n: N}, rec)
+	errs := g.Run()
+	for i := 0; i < 8; i++ {
+		g.Quiesce()
+		if _, err := g.Checkpoint(); err != nil {
+			t.Fatal(err)
+		}
+		g.Resume()
+	}
+	for err := range errs {
+		t.Fatal(err)
+	}
+	if len(rec.chs[1]) != N {
+		t.Fatalf("got %d frames not %d", len(rec.chs[1]), N)
+	}
+	for i, v := range rec.chs[1] {
+		if v != float64(i) {
+			t.Fatalf("frame %d: got %g", i, v)
+		}
+	}
+}
+
+func TestIOQuiesceDropOldest(t *testing.T) {
+	mono := sound.MonoCd()
+	u := New(mono, mono, PassThrough)
+	u.SetInput(gen.Noise())
+	slow := &slowSink{countSink: countSink{Form: mono}, d: 5 * time.Millisecond, closed: make(chan struct{})}
+	u.AddOutput(slow)
+	if err := u.SetBackpressure(0, Backpressure{Mode: DropOldest, Queue: 2}); err != nil {
+		t.Fatal(err)
+	}
+	dropped := make(chan struct{})
+	var once sync.Once
+	u.OnEvent(func(e Event) {
+		if _, ok := e.(*OutputDropped); ok {
+			once.Do(func() { close(dropped) })
+		}
+	})
+	errC := make(chan error)
+	go func() {
+		errC <- u.Run()
+	}()
+	<-dropped
+	done := make(chan struct{})
+	go func() {
+		u.Quiesce()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("Quiesce did not return")
+	}
+	u.Resume()
+	u.Stop()
+	if err := <-errC; err != nil && err != io.EOF {
+		t.Fatal(err)
+	}
+	<-slow.closed
+}
@@ -0,0 +1,121 @@
+// Copyright 2018 The ZikiChombo Authors. All rights reserved.  Use of this source
+// code is governed by a license that can be found in the License file.
+
+package plug
+
+import (
+	"sync"
+	"time"
+
+	"zikichombo.org/sound/freq"
+)
+
+// Clock gives the canonical frame position of a graph, conversions between
+// frame positions and time, and notifications of seeks.  A Clock is
+// advanced by one IO, its master (see Instrumented.SetClock), and may be consulted
+// by any goroutine, for example by transports, schedulers or processors
+// which need to know where the graph is.
+type Clock struct {
+	mu      sync.Mutex
+	rate    freq.T
+	pos     int64
+	anchor  time.Time // wall time at which pos was reached
+	onSeek  []func(int64)
+	running bool
+}
+
+// NewClock creates a new Clock counting frames at sample rate rate.
+func NewClock(rate freq.T) *Clock {
+	return &Clock{rate: rate}
+}
+
+// SampleRate returns the sample rate of the clock.
+func (c *Clock) SampleRate() freq.T {
+	return c.rate
+}
+
+// Position returns the current frame position.
+func (c *Clock) Position() int64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.pos
+}
+
+// Duration converts the frame position pos to a duration from position 0.
+func (c *Clock) Duration(pos int64) time.Duration {
+	return time.Duration(float64(pos) / c.rate.Float64() * float64(time.Second))
+}
+
+// Frames converts a duration from position 0 to a frame position.
+func (c *Clock) Frames(d time.Duration) int64 {
+	return int64(d.Seconds() * c.rate.Float64())
+}
+
+// Wall estimates the wall time at which frame position pos was, or will be,
+// reached, extrapolating from the last time the clock was advanced.  If the
+// clock has not been advanced yet, Wall extrapolates from now.
+func (c *Clock) Wall(pos int64) time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	anchor := c.anchor
+	if !c.running {
+		anchor = time.Now()
+	}
+	return anchor.Add(c.Duration(pos - c.pos))
+}
+
+// SeekTo sets the frame position to pos, and calls the functions registered
+// with OnSeek.  SeekTo does not itself reposition any source; it is meant to
+// be called by whatever does.
+func (c *Clock) SeekTo(pos int64) {
+	c.mu.Lock()
+	c.pos = pos
+	c.anchor = time.Now()
+	fns := make([]func(int64), len(c.onSeek))
+	copy(fns, c.onSeek)
+	c.mu.Unlock()
+	for _, fn := range fns {
+		fn(pos)
+	}
+}
+
+// OnSeek registers fn to be called with the new position after every seek.
+func (c *Clock) OnSeek(fn func(pos int64)) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.onSeek = append(c.onSeek, fn)
+}
+
+// advance advances the clock by n frames.
+func (c *Clock) advance(n int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.pos += int64(n)
+	c.anchor = time.Now()
+	c.running = true
+}
+
+// SetClock implements Instrumented.
+func (n *node) SetClock(c *Clock) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.clock = c
+}
+
+// Clock returns the clock of the graph, or nil if none has been set by
+// SetClockMaster.
+func (g *Graph) Clock() *Clock {
+	return g.clock
+}
+
+// SetClockMaster creates a clock for the graph at the input sample rate of
+// master, and makes master advance it.  Typically, the master is the node
+// closest to a playback or capture device.  master must implement
+// Instrumented for the clock to advance.
+func (g *Graph) SetClockMaster(master IO) *Clock {
+	g.clock = NewClock(master.InForm().SampleRate())
+	if in, ok := master.(Instrumented); ok {
+		in.SetClock(g.clock)
+	}
+	return g.clock
+}
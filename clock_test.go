@@ -0,0 +1,67 @@
+// Copyright 2018 The ZikiChombo Authors. All rights reserved.  Use of this source
+// code is governed by a license that can be found in the License file.
+
+package plug
+
+import (
+	"testing"
+	"time"
+
+	"zikichombo.org/sound"
+	"zikichombo.org/sound/freq"
+)
+
+func TestClock(t *testing.T) {
+	c := NewClock(48000 * freq.Hertz)
+	if c.SampleRate() != 48000*freq.Hertz {
+		t.Errorf("got sample rate %s", c.SampleRate())
+	}
+	if d := c.Duration(72000); d != 1500*time.Millisecond {
+		t.Errorf("got duration %s not 1.5s", d)
+	}
+	if n := c.Frames(1500 * time.Millisecond); n != 72000 {
+		t.Errorf("got %d frames not 72000", n)
+	}
+	// before the clock runs, positions are extrapolated from now.
+	before := time.Now()
+	if w := c.Wall(48000); w.Before(before.Add(time.Second)) || w.After(time.Now().Add(time.Second)) {
+		t.Errorf("got wall time %s for 1s from %s", w, before)
+	}
+}
+
+func TestGraphClock(t *testing.T) {
+	mono := sound.MonoCd()
+	var g Graph
+	if g.Clock() != nil {
+		t.Fatal("got a clock without a master")
+	}
+	u0 := g.New(mono, mono, PassThrough)
+	u1 := g.New(mono, mono, PassThrough)
+	g.Connect(u0, nil, u1, nil)
+	c := g.SetClockMaster(u1)
+	if g.Clock() != c || c.SampleRate() != mono.SampleRate() {
+		t.Fatalf("got clock %v", g.Clock())
+	}
+	var seeks []int64
+	c.OnSeek(func(pos int64) { seeks = append(seeks, pos) })
+	c.OnSeek(func(int64) { g.Reset() })
+	u0.SetInput(&ramp{Form: mono, n: 10000})
+	u1.AddOutput(&countSink{Form: mono})
+	for err := range g.Run() {
+		t.Fatal(err)
+	}
+	if p := c.Position(); p != 10000 {
+		t.Errorf("got position %d not 10000", p)
+	}
+	// once running, positions are extrapolated from the last advance.
+	if w, now := c.Wall(10000+44100), time.Now(); w.After(now.Add(time.Second)) || w.Before(now.Add(time.Second-time.Minute)) {
+		t.Errorf("got wall time %s for 1s after the last advance, now is %s", w, now)
+	}
+	c.SeekTo(500)
+	if p := c.Position(); p != 500 {
+		t.Errorf("got position %d after seeking to 500", p)
+	}
+	if len(seeks) != 1 || seeks[0] != 500 {
+		t.Errorf("got seeks %v", seeks)
+	}
+}
@@ -0,0 +1,147 @@
+// Copyright 2018 The ZikiChombo Authors. All rights reserved.  Use of this source
+// code is governed by a license that can be found in the License file.
+
+package plug
+
+import "fmt"
+
+// LatencyReporter is implemented by processors which delay their input, for
+// example because they need lookahead or process in frames of fixed size.
+type LatencyReporter interface {
+	// Latency returns the delay, in frames, between the input and the
+	// output of the processor.
+	Latency() int
+}
+
+// latency returns the latency of p, which is 0 unless p implements
+// LatencyReporter.
+func latency(p Processor) int {
+	if l, ok := p.(LatencyReporter); ok {
+		return l.Latency()
+	}
+	return 0
+}
+
+// CompensateLatency aligns the parallel branches of the graph: every input
+// of every node is delayed so that all inputs of a node have the same
+// latency w.r.t. the sources of the graph, as reported by the processors
+// implementing LatencyReporter.  Without compensation, branches with
+// different latencies, such as those of a diamond with a lookahead
+// limiter on one side, are recombined out of phase.
+//
+// Sources outside the graph are assumed to have no latency.  The delays
+// are applied by delay lines on the inputs of the nodes, and replace any
+// previous compensation.  CompensateLatency should be called once the graph is
+// connected, before Run or RunSequential; it does not apply to ReadAt.
+//
+// The nodes of composites added to g are compensated as nodes of g, so
+// that paths through a composite have the latency of the path through its
+// graph.  Other implementations of IO cannot be compensated, and
+// CompensateLatency returns an error for graphs holding them.
+func (g *Graph) CompensateLatency() error {
+	order, err := g.sorted()
+	if err != nil {
+		return fmt.Errorf("compensate: %s", err)
+	}
+	// out[n] is the latency of the output of n, in output frames of n.
+	out := make(map[*node]int, len(order))
+	for _, n := range order {
+		n.mu.Lock()
+		lats, max := g.inLatencies(n, out)
+		for i := range n.iPkts {
+			pkt := &n.iPkts[i]
+			pkt.lines = nil
+			if d := max - lats[i]; d > 0 {
+				pkt.lines = make([]*delayLine, pkt.nC)
+				for c := range pkt.lines {
+					pkt.lines[c] = newDelayLine(d)
+				}
+			}
+		}
+		out[n] = n.outLatency(max)
+		n.mu.Unlock()
+	}
+	return nil
+}
+
+// Latency returns the latency of the output of the node io of g w.r.t.
+// the sources of the graph, in output frames of io: the greatest sum of
+// the latencies of the processors along the paths leading to io, as they
+// are aligned by CompensateLatency.  Sources outside the graph are assumed
+// to have no latency.  Latency lets applications trim or align the output
+// of a graph with lookahead limiters or FFT processors.
+//
+// io may be a composite added to g, whose latency is that of the paths
+// through its graph.  Like CompensateLatency, Latency returns an error for
+// graphs holding other implementations of IO.
+func (g *Graph) Latency(io IO) (int, error) {
+	if !g.has(io) {
+		return 0, fmt.Errorf("latency: node not in graph")
+	}
+	var last *node
+	switch n := io.(type) {
+	case *node:
+		last = n
+	case *composite:
+		last = n.out.(*node)
+	default:
+		return 0, fmt.Errorf("latency: cannot compute the latency of %T", io)
+	}
+	order, err := g.sorted()
+	if err != nil {
+		return 0, fmt.Errorf("latency: %s", err)
+	}
+	out := make(map[*node]int, len(order))
+	for _, n := range order {
+		n.mu.Lock()
+		_, max := g.inLatencies(n, out)
+		out[n] = n.outLatency(max)
+		n.mu.Unlock()
+		if n == last {
+			break
+		}
+	}
+	return out[last], nil
+}
+
+// inLatencies returns the latencies of the inputs of n given the
+// latencies out of the nodes preceding n, and their maximum.  n.mu must be
+// held.
+func (g *Graph) inLatencies(n *node, out map[*node]int) ([]int, int) {
+	lats := make([]int, len(n.iPkts))
+	max := 0
+	for i := range n.iPkts {
+		if ns, ok := n.iPkts[i].src.(*nodeSource); ok {
+			// nodes outside the graph are not in out.
+			lats[i] = out[ns.n]
+		}
+		if lats[i] > max {
+			max = lats[i]
+		}
+	}
+	return lats, max
+}
+
+// outLatency returns the latency of the output of n given the latency in
+// of its input, converting input frames to output frames.  n.mu must be
+// held.
+func (n *node) outLatency(in int) int {
+	l := in + n.latency()
+	return int(float64(l) * n.oForm.SampleRate().Float64() / n.iForm.SampleRate().Float64())
+}
+
+// latency returns the latency of the processor of n, or of the processor
+// being faded in.  n.mu must be held.
+func (n *node) latency() int {
+	if sf, ok := n.proc.(*swapFade); ok {
+		return latency(sf.b)
+	}
+	return latency(n.proc)
+}
+
+// Latency implements ProcessorController.
+func (n *node) Latency() int {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return n.latency()
+}
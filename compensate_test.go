@@ -0,0 +1,162 @@
+// Copyright 2018 The ZikiChombo Authors. All rights reserved.  Use of this source
+// code is governed by a license that can be found in the License file.
+
+package plug
+
+import (
+	"testing"
+
+	"zikichombo.org/sound"
+)
+
+func TestGraphCompensateLatency(t *testing.T) {
+	mono := sound.MonoCd()
+	stereo := sound.StereoCd()
+	for _, seq := range []bool{false, true} {
+		var g Graph
+		u0 := g.New(mono, mono, PassThrough)
+		a := g.New(mono, mono, &lag{delayLine: newDelayLine(1500), d: 1500})
+		b := g.New(mono, mono, PassThrough)
+		u1 := g.New(stereo, stereo, PassThrough)
+		u0.SetInput(&ramp{Form: mono, n: 10000})
+		g.Connect(u0, []int{0}, a, nil)
+		g.Connect(u0, []int{0}, b, nil)
+		g.Connect(a, nil, u1, []int{0})
+		g.Connect(b, nil, u1, []int{1})
+		if err := g.CompensateLatency(); err != nil {
+			t.Fatal(err)
+		}
+		var got []float64
+		src, snk := sound.Pipe(stereo)
+		u1.AddOutput(snk)
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			buf := make([]float64, 2048)
+			for {
+				n, err := src.Receive(buf)
+				for i := 0; i < n; i++ {
+					if buf[i] != buf[n+i] {
+						t.Errorf("seq=%t: frame %d: %f != %f", seq, len(got), buf[i], buf[n+i])
+						return
+					}
+					got = append(got, buf[i])
+				}
+				if err != nil {
+					return
+				}
+			}
+		}()
+		if seq {
+			if err := g.RunSequential(); err != nil {
+				t.Fatal(err)
+			}
+		} else {
+			for err := range g.Run() {
+				t.Fatal(err)
+			}
+		}
+		<-done
+		if len(got) < 10000 || got[1500] != 0 || got[1501] != 1 {
+			t.Errorf("seq=%t: got %d frames", seq, len(got))
+		}
+	}
+}
+
+func TestGraphLatency(t *testing.T) {
+	mono := sound.MonoCd()
+	stereo := sound.StereoCd()
+	var g Graph
+	u0 := g.New(mono, mono, PassThrough)
+	a := g.New(mono, mono, &lag{delayLine: newDelayLine(1500), d: 1500})
+	c := g.New(mono, mono, WithHeadroom(&lag{delayLine: newDelayLine(100), d: 100}, 6))
+	b := g.New(mono, mono, PassThrough)
+	u1 := g.New(stereo, stereo, PassThrough)
+	g.Connect(u0, []int{0}, a, nil)
+	g.Connect(a, nil, c, nil)
+	g.Connect(u0, []int{0}, b, nil)
+	g.Connect(c, nil, u1, []int{0})
+	g.Connect(b, nil, u1, []int{1})
+	if l := a.Latency(); l != 1500 {
+		t.Errorf("got node latency %d", l)
+	}
+	for _, e := range []struct {
+		n IO
+		l int
+	}{{u0, 0}, {a, 1500}, {b, 0}, {c, 1600}, {u1, 1600}} {
+		l, err := g.Latency(e.n)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if l != e.l {
+			t.Errorf("got path latency %d not %d", l, e.l)
+		}
+	}
+	if _, err := g.Latency(New(mono, mono, PassThrough)); err == nil {
+		t.Errorf("got latency of node outside graph")
+	}
+}
+
+func TestGraphCompensateLatencyComposite(t *testing.T) {
+	mono := sound.MonoCd()
+	stereo := sound.StereoCd()
+	var sub Graph
+	l0 := sub.New(mono, mono, &lag{delayLine: newDelayLine(1000), d: 1000})
+	l1 := sub.New(mono, mono, &lag{delayLine: newDelayLine(500), d: 500})
+	sub.Connect(l0, nil, l1, nil)
+	a, err := sub.Compose([]IO{l0}, []IO{l1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var g Graph
+	u0 := g.New(mono, mono, PassThrough)
+	g.Add(a)
+	b := g.New(mono, mono, PassThrough)
+	u1 := g.New(stereo, stereo, PassThrough)
+	u0.SetInput(&ramp{Form: mono, n: 10000})
+	g.Connect(u0, []int{0}, a, nil)
+	g.Connect(u0, []int{0}, b, nil)
+	g.Connect(a, nil, u1, []int{0})
+	g.Connect(b, nil, u1, []int{1})
+	for _, e := range []struct {
+		n IO
+		l int
+	}{{a, 1500}, {b, 0}, {u1, 1500}} {
+		l, err := g.Latency(e.n)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if l != e.l {
+			t.Errorf("got path latency %d not %d", l, e.l)
+		}
+	}
+	if err := g.CompensateLatency(); err != nil {
+		t.Fatal(err)
+	}
+	rec := &record{Form: stereo}
+	u1.AddOutput(rec)
+	for err := range g.Run() {
+		t.Fatal(err)
+	}
+	if len(rec.chs[0]) != 10000 {
+		t.Fatalf("got %d frames", len(rec.chs[0]))
+	}
+	for i, v := range rec.chs[0] {
+		if v != rec.chs[1][i] {
+			t.Fatalf("frame %d: %f != %f", i, v, rec.chs[1][i])
+		}
+	}
+	if rec.chs[0][1500] != 0 || rec.chs[0][1501] != 1 {
+		t.Errorf("got %f, %f", rec.chs[0][1500], rec.chs[0][1501])
+	}
+
+	var h Graph
+	other := struct{ IO }{New(mono, mono, PassThrough)}
+	h.Add(other)
+	if _, err := h.Latency(other); err == nil {
+		t.Error("got the latency of an unknown IO")
+	}
+	if err := h.CompensateLatency(); err == nil {
+		t.Error("compensated an unknown IO")
+	}
+}
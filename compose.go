@@ -0,0 +1,155 @@
+// Copyright 2018 The ZikiChombo Authors. All rights reserved.  Use of this source
+// code is governed by a license that can be found in the License file.
+
+package plug
+
+import (
+	"fmt"
+	"math"
+)
+
+type compose struct {
+	procs  []Processor
+	shapes [][2]int // last NextFrames of every stage
+	fifos  []*fifo  // output of every stage
+	src    Block
+	dst    Block
+}
+
+// Compose creates a FullMode processor chaining ps inside a single IO: the
+// output of ps[i] is the input of ps[i+1].  Composing trivial stages saves
+// the overhead of running an IO for each.
+//
+// The stages may have different block sizes: the output of every stage is
+// buffered until it fills a block of the next one, and the tail buffered
+// in the stages is flushed when the input ends.  The stages should not
+// change the number of channels nor the sample rate, and the latency of
+// the result is the sum of their latencies.
+func Compose(ps ...Processor) Processor {
+	c := &compose{procs: ps, shapes: make([][2]int, len(ps))}
+	for i, p := range ps[1:] {
+		in, out := p.NextFrames()
+		c.shapes[i+1] = [2]int{in, out}
+	}
+	return c
+}
+
+func (c *compose) ChannelMode() ChannelMode {
+	return FullMode
+}
+
+func (c *compose) NextFrames() (int, int) {
+	in, out := c.procs[0].NextFrames()
+	c.shapes[0] = [2]int{in, out}
+	// bound the output by the buffered frames and the expected output of
+	// in input frames.
+	r := 1.0
+	for _, s := range c.shapes {
+		r *= float64(s[1]) / float64(s[0])
+	}
+	last := len(c.procs) - 1
+	M := int(math.Ceil(float64(in)*r)) + c.shapes[last][1]
+	if c.fifos != nil {
+		M += c.fifos[last].frames()
+	}
+	return in, M
+}
+
+func (c *compose) Latency() int {
+	l := 0
+	for _, p := range c.procs {
+		l += latency(p)
+	}
+	return l
+}
+
+// Tail implements Tailer, flushing the frames buffered between stages
+// and the tails of the stages.
+func (c *compose) Tail() bool {
+	if c.fifos == nil {
+		return false
+	}
+	for i, p := range c.procs {
+		if i > 0 && c.fifos[i-1].frames() > 0 {
+			return true
+		}
+		if t, ok := p.(Tailer); ok && p.ChannelMode() == FullMode && t.Tail() {
+			return true
+		}
+	}
+	return c.fifos[len(c.fifos)-1].frames() > 0
+}
+
+func (c *compose) Process(dst, src *Block) error {
+	nC := src.Channels
+	if dst.Channels != nC {
+		return fmt.Errorf("compose: cannot map %d channels to %d", nC, dst.Channels)
+	}
+	if c.fifos == nil {
+		c.fifos = make([]*fifo, len(c.procs))
+		for i := range c.fifos {
+			c.fifos[i] = newFifo(nC)
+		}
+	}
+	flush := src.Frames == 0
+	if err := c.run(0, src, c.shapes[0][1], flush); err != nil {
+		return err
+	}
+	for i := 1; i < len(c.procs); i++ {
+		in := c.fifos[i-1]
+		for {
+			N := c.shapes[i][0]
+			n := N
+			if in.frames() < n {
+				n = in.frames()
+			}
+			// run full blocks, and when flushing, what remains and the tail.
+			if n < N && !(flush && (n > 0 || c.tail(i))) {
+				break
+			}
+			c.src.SampleRate, c.src.Channels, c.src.Frames = src.SampleRate, nC, n
+			c.src.Samples = buffer(c.src.Samples, nC, N)
+			in.pop(c.src.Samples, n)
+			if err := c.run(i, &c.src, c.shapes[i][1], flush); err != nil {
+				return err
+			}
+			in, out := c.procs[i].NextFrames()
+			c.shapes[i] = [2]int{in, out}
+			if n == 0 {
+				break
+			}
+		}
+	}
+	last := c.fifos[len(c.fifos)-1]
+	m := dst.Frames
+	if avail := last.frames(); avail < m {
+		m = avail
+	}
+	last.pop(dst.Samples, m)
+	dst.Frames = m
+	return nil
+}
+
+// tail returns whether stage i has a tail to flush.
+func (c *compose) tail(i int) bool {
+	p := c.procs[i]
+	t, ok := p.(Tailer)
+	return ok && p.ChannelMode() == FullMode && t.Tail()
+}
+
+// run processes src with stage i, whose output block has M frames,
+// buffering the output.  Without input, the stage is run only to flush its
+// tail.
+func (c *compose) run(i int, src *Block, M int, flush bool) error {
+	if src.Frames == 0 && !(flush && c.tail(i)) {
+		return nil
+	}
+	nC := src.Channels
+	c.dst.SampleRate, c.dst.Channels, c.dst.Frames = src.SampleRate, nC, M
+	c.dst.Samples = buffer(c.dst.Samples, nC, M)
+	if err := runFull(c.procs[i], &c.dst, src); err != nil {
+		return err
+	}
+	c.fifos[i].push(c.dst.Samples, c.dst.Frames)
+	return nil
+}
@@ -0,0 +1,46 @@
+// Copyright 2018 The ZikiChombo Authors. All rights reserved.  Use of this source
+// code is governed by a license that can be found in the License file.
+
+package plug
+
+import (
+	"testing"
+
+	"zikichombo.org/sound"
+)
+
+func TestCompose(t *testing.T) {
+	mono := sound.MonoCd()
+	double := NewProcessorFrames(MonoMode, func(dst, src *Block) error {
+		for i, v := range src.Samples[:src.Frames] {
+			dst.Samples[i] = 2 * v
+		}
+		dst.Frames = src.Frames
+		return nil
+	}, 512, 512)
+	odd := NewProcessorFrames(FullMode, func(dst, src *Block) error {
+		copy(dst.Samples, src.Samples[:src.Frames])
+		dst.Frames = src.Frames
+		return nil
+	}, 300, 300)
+	e := &echo{tail: 2}
+	p := Compose(double, odd, e, WithMix(&lag{delayLine: newDelayLine(10), d: 10}, 1))
+	if l := latency(p); l != 10 {
+		t.Errorf("got latency %d", l)
+	}
+	u := New(mono, mono, p)
+	u.SetInput(&ramp{Form: mono, n: 3000})
+	snk := &capture{Form: mono}
+	u.AddOutput(snk)
+	if err := u.Run(); err != nil {
+		t.Fatal(err)
+	}
+	if snk.frames != 3000+2048 || e.tail != 0 {
+		t.Errorf("got %d frames, tail %d", snk.frames, e.tail)
+	}
+	for _, x := range [][2]float64{{9, 0}, {10, 0}, {3009, 2 * 2999}, {3010, 1}, {3000 + 2047, 1}} {
+		if v := snk.mix[int(x[0])]; v != x[1] {
+			t.Errorf("frame %d: got %f not %f", int(x[0]), v, x[1])
+		}
+	}
+}
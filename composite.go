@@ -0,0 +1,306 @@
+// Copyright 2018 The ZikiChombo Authors. All rights reserved.  Use of this source
+// code is governed by a license that can be found in the License file.
+
+package plug
+
+import (
+	"context"
+	"fmt"
+
+	"zikichombo.org/sound"
+)
+
+// composite is an IO made of a graph.  Its input is fanned out to the
+// input nodes by the node in, and the output nodes are gathered by the
+// node out.
+type composite struct {
+	g       *Graph
+	in, out Plug
+}
+
+// Compose wraps the nodes of g into a single IO, so that a chain of nodes
+// may be reused and nested in other graphs as one.  The input channels of
+// the result are the input channels of the nodes inputs, in order, and its
+// output channels the output channels of the nodes outputs, in order.  The
+// input nodes must have no inputs, and the other nodes must be connected.
+//
+// Compose adds a node at each boundary of g, and the result runs, stops,
+// quiesces and validates all the nodes of g, so g should hold only the
+// nodes of the composite.  The result implements the optional interfaces
+// of IO except Checkpointer and Parameterized: the state of a composite
+// is captured by Graph.Checkpoint on g, and the parameters of its
+// processors are those of the nodes of g.
+func (g *Graph) Compose(inputs, outputs []IO) (IO, error) {
+	if len(inputs) == 0 || len(outputs) == 0 {
+		return nil, fmt.Errorf("compose: need inputs and outputs")
+	}
+	iForm, err := sumForms(inputs, IO.InForm)
+	if err != nil {
+		return nil, fmt.Errorf("compose: inputs: %s", err)
+	}
+	oForm, err := sumForms(outputs, IO.OutForm)
+	if err != nil {
+		return nil, fmt.Errorf("compose: outputs: %s", err)
+	}
+	in := g.New(iForm, iForm, PassThrough)
+	out := g.New(oForm, oForm, PassThrough)
+	c := 0
+	for _, n := range inputs {
+		nC := n.InForm().Channels()
+		if err := g.Connect(in, chanRange(c, c+nC), n, nil); err != nil {
+			return nil, err
+		}
+		c += nC
+	}
+	c = 0
+	for _, n := range outputs {
+		nC := n.OutForm().Channels()
+		if err := g.Connect(n, nil, out, chanRange(c, c+nC)); err != nil {
+			return nil, err
+		}
+		c += nC
+	}
+	return &composite{g: g, in: in, out: out}, nil
+}
+
+// sumForms returns the form with the sample rate of the forms of ios, which
+// must all be equal, and the sum of their channels.
+func sumForms(ios []IO, form func(IO) sound.Form) (sound.Form, error) {
+	rate := form(ios[0]).SampleRate()
+	nC := 0
+	for _, n := range ios {
+		f := form(n)
+		if f.SampleRate() != rate {
+			return nil, fmt.Errorf("frequency mismatch: %s and %s", rate, f.SampleRate())
+		}
+		nC += f.Channels()
+	}
+	return sound.NewForm(rate, nC), nil
+}
+
+// Add adds the IO n, created outside the graph, for example by Compose, to
+// the graph.
+func (g *Graph) Add(n IO) {
+	g.nodes = append(g.nodes, n)
+}
+
+func (c *composite) InForm() sound.Form {
+	return c.in.InForm()
+}
+
+func (c *composite) OutForm() sound.Form {
+	return c.out.OutForm()
+}
+
+func (c *composite) SetInput(s sound.Source, cs ...int) error {
+	return c.in.SetInput(s, cs...)
+}
+
+func (c *composite) AddOutput(d sound.Sink, cs ...int) error {
+	return c.out.AddOutput(d, cs...)
+}
+
+func (c *composite) AddOutputWith(d sound.Sink, opts OutputOptions) error {
+	return c.out.AddOutputWith(d, opts)
+}
+
+func (c *composite) Output(cs ...int) sound.Source {
+	return c.out.Output(cs...)
+}
+
+func (c *composite) ReadAt(frame int64, dst *Block) error {
+	return c.out.ReadAt(frame, dst)
+}
+
+func (c *composite) AddSecondaryOutput(d sound.Sink, queue int, cs ...int) (*SecondaryOutput, error) {
+	return c.out.AddSecondaryOutput(d, queue, cs...)
+}
+
+func (c *composite) AddMonitor(d sound.Sink, cs ...int) (*SecondaryOutput, error) {
+	return c.in.AddMonitor(d, cs...)
+}
+
+// SwapProcessor swaps the processor of the node gathering the outputs,
+// which is initially a pass-through, so that a processor may be appended to
+// the composite.  The processors of the composed nodes are swapped by
+// calling SwapProcessor on them.
+func (c *composite) SwapProcessor(p Processor, fade int) error {
+	return c.out.SwapProcessor(p, fade)
+}
+
+func (c *composite) SetDepth(n int) {
+	c.g.SetDepth(n)
+}
+
+func (c *composite) SetBudget(b *Budget) {
+	for _, n := range c.g.nodes {
+		if x, ok := n.(Instrumented); ok {
+			x.SetBudget(b)
+		}
+	}
+}
+
+func (c *composite) SetClock(clk *Clock) {
+	c.in.SetClock(clk)
+}
+
+func (c *composite) SetRunOut(frames int) {
+	c.in.SetRunOut(frames)
+}
+
+func (c *composite) SetDetachOnError(v bool) {
+	c.out.SetDetachOnError(v)
+}
+
+func (c *composite) ReplaceOutput(i int, d sound.Sink) error {
+	return c.out.ReplaceOutput(i, d)
+}
+
+func (c *composite) RemoveOutput(d sound.Sink) error {
+	return c.out.RemoveOutput(d)
+}
+
+func (c *composite) RemoveOutputSource(s sound.Source) error {
+	return c.out.RemoveOutputSource(s)
+}
+
+// Stats reports the input of the inner input IO and the output, blocks
+// and state of the inner output IO.
+func (c *composite) Stats() Stats {
+	in, out := c.in.Stats(), c.out.Stats()
+	out.FramesIn = in.FramesIn
+	out.InFrames = in.InFrames
+	return out
+}
+
+func (c *composite) SetAdaptation(a *Adaptation) {
+	for _, n := range c.g.nodes {
+		if x, ok := n.(ProcessorController); ok {
+			x.SetAdaptation(a)
+		}
+	}
+}
+
+func (c *composite) SetSilenceFill(cs ...int) {
+	c.in.SetSilenceFill(cs...)
+}
+
+func (c *composite) SetBackpressure(i int, p Backpressure) error {
+	return c.out.SetBackpressure(i, p)
+}
+
+func (c *composite) SetMixPolicy(p MixPolicy) {
+	c.in.SetMixPolicy(p)
+}
+
+func (c *composite) AddInputBus(b Bus) error {
+	return c.in.AddInputBus(b)
+}
+
+func (c *composite) AddOutputBus(b Bus) error {
+	return c.out.AddOutputBus(b)
+}
+
+func (c *composite) InputBus(name string) (Bus, bool) {
+	return c.in.InputBus(name)
+}
+
+func (c *composite) OutputBus(name string) (Bus, bool) {
+	return c.out.OutputBus(name)
+}
+
+func (c *composite) TrackProvenance(name string) {
+	c.out.TrackProvenance(name)
+}
+
+func (c *composite) Validate() error {
+	return c.g.Validate()
+}
+
+func (c *composite) SetTrace(t *Trace) {
+	c.in.SetTrace(t)
+}
+
+func (c *composite) Quiesce() {
+	c.g.Quiesce()
+}
+
+func (c *composite) Pause() {
+	c.g.Pause()
+}
+
+func (c *composite) Resume() {
+	c.g.Resume()
+}
+
+func (c *composite) SetRetryPolicy(p RetryPolicy) {
+	for _, n := range c.g.nodes {
+		if x, ok := n.(InputController); ok {
+			x.SetRetryPolicy(p)
+		}
+	}
+}
+
+func (c *composite) OnEvent(fn func(Event)) {
+	for _, n := range c.g.nodes {
+		if x, ok := n.(Notifier); ok {
+			x.OnEvent(fn)
+		}
+	}
+}
+
+// Latency reports the latency of the output of the inner graph, in input
+// frames of the composite.
+func (c *composite) Latency() int {
+	l, err := c.g.Latency(c.out)
+	if err != nil {
+		return 0
+	}
+	return int(float64(l) * c.in.InForm().SampleRate().Float64() / c.out.OutForm().SampleRate().Float64())
+}
+
+func (c *composite) SetBypass(v bool) {
+	for _, n := range c.g.nodes {
+		if x, ok := n.(ProcessorController); ok {
+			x.SetBypass(v)
+		}
+	}
+}
+
+func (c *composite) Reset() {
+	c.g.Reset()
+}
+
+func (c *composite) OnError(fn func(error)) {
+	for _, n := range c.g.nodes {
+		if x, ok := n.(Notifier); ok {
+			x.OnError(fn)
+		}
+	}
+}
+
+func (c *composite) Stop() {
+	c.g.Stop()
+}
+
+// Run runs all the nodes of the graph, returning the first error.
+func (c *composite) Run() error {
+	var res error
+	for err := range c.g.Run() {
+		if res == nil {
+			res = err
+		}
+	}
+	return res
+}
+
+// RunContext runs all the nodes of the graph as per Stopper.RunContext.
+func (c *composite) RunContext(ctx context.Context) error {
+	return runContext(ctx, c.Run, func() {
+		for _, n := range c.g.nodes {
+			if nd, ok := n.(*node); ok {
+				nd.abort()
+			}
+		}
+	})
+}
@@ -0,0 +1,102 @@
+// Copyright 2018 The ZikiChombo Authors. All rights reserved.  Use of this source
+// code is governed by a license that can be found in the License file.
+
+package plug
+
+import (
+	"io"
+	"testing"
+
+	"zikichombo.org/sound"
+	"zikichombo.org/sound/gen"
+	"zikichombo.org/sound/ops"
+)
+
+func TestGraphCompose(t *testing.T) {
+	stereo := sound.StereoCd()
+	mono := sound.MonoCd()
+	var sub Graph
+	u0 := sub.New(stereo, stereo, PassThrough)
+	u1 := sub.New(stereo, mono, ToMono)
+	if err := sub.Connect(u0, nil, u1, nil); err != nil {
+		t.Fatal(err)
+	}
+	chain, err := sub.Compose([]IO{u0}, []IO{u1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var g Graph
+	g.Add(chain)
+	u2 := g.New(mono, mono, PassThrough)
+	chain.SetInput(ops.Limit(gen.Noise(), 44100), 0)
+	chain.SetInput(ops.Limit(gen.Noise(), 44100), 1)
+	if err := g.Connect(chain, nil, u2, nil); err != nil {
+		t.Fatal(err)
+	}
+	out := u2.Output()
+	ec := g.Run()
+	buf := make([]float64, 1024)
+	ttl := 0
+	for {
+		n, err := out.Receive(buf)
+		ttl += n
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	for err := range ec {
+		t.Error(err)
+	}
+	if ttl != 44100 {
+		t.Errorf("got %d not 44100", ttl)
+	}
+}
+
+func TestGraphComposeInterfaces(t *testing.T) {
+	mono := sound.MonoCd()
+	var sub Graph
+	u0 := sub.New(mono, mono, PassThrough)
+	chain, err := sub.Compose([]IO{u0}, []IO{u0})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := chain.(Pauser); !ok {
+		t.Error("composite is not a Pauser")
+	}
+	if _, ok := chain.(Stopper); !ok {
+		t.Error("composite is not a Stopper")
+	}
+	if _, ok := chain.(Checkpointer); ok {
+		t.Error("composite is a Checkpointer")
+	}
+	var g Graph
+	g.Add(chain)
+	g.Quiesce()
+	if _, err := g.Checkpoint(); err == nil {
+		t.Error("checkpointed a composite")
+	}
+	g.Resume()
+}
+
+func TestGraphComposeConnectivity(t *testing.T) {
+	mono := sound.MonoCd()
+	var sub Graph
+	u0 := sub.New(mono, mono, PassThrough)
+	chain, err := sub.Compose([]IO{u0}, []IO{u0})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var g Graph
+	g.Add(chain)
+	if err := g.CheckConnectivity(); err == nil {
+		t.Error("unconnected composite passed")
+	}
+	chain.SetInput(ops.Limit(gen.Noise(), 1024), 0)
+	chain.Output()
+	if err := g.CheckConnectivity(); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,154 @@
+// Copyright 2018 The ZikiChombo Authors. All rights reserved.  Use of this source
+// code is governed by a license that can be found in the License file.
+
+package plug
+
+import (
+	"fmt"
+	"sync"
+
+	"zikichombo.org/sound"
+)
+
+// concealPeriod is the maximum length of the segment repeated to conceal a
+// gap.
+const concealPeriod = 256
+
+// GapError may be returned by the Receive method of a source, such as a
+// network stream, to indicate that Frames frames have been lost at the
+// current position.  Any frames returned alongside a GapError precede the
+// gap.
+type GapError struct {
+	Frames int
+}
+
+func (g *GapError) Error() string {
+	return fmt.Sprintf("gap of %d frames", g.Frames)
+}
+
+// ConcealStats gives gap concealment statistics.
+type ConcealStats struct {
+	Gaps      int   // number of gaps encountered.
+	Concealed int64 // frames synthesized by waveform substitution.
+	Silenced  int64 // frames of gaps too long to conceal, replaced by silence.
+}
+
+// Concealer is a sound.Source which conceals short gaps in the source it
+// wraps, so that brief transport hiccups do not result in clicks.  Gaps are
+// reported by the wrapped source by returning a *GapError.
+//
+// Gaps up to a maximum length are filled by repeating the most recent
+// received waveform while fading it out.  Longer gaps are filled with
+// silence.
+//
+// A Concealer is typically placed between a network or otherwise flaky
+// source and IO.SetInput.
+type Concealer struct {
+	sound.Source
+	maxGap  int
+	hist    []float64 // per channel, concealPeriod frames
+	histN   int
+	pending int
+	pos     int
+	long    bool
+
+	mu    sync.Mutex
+	stats ConcealStats
+}
+
+// NewConcealer creates a new Concealer reading from src and concealing gaps
+// of at most maxGap frames.
+func NewConcealer(src sound.Source, maxGap int) *Concealer {
+	return &Concealer{
+		Source: src,
+		maxGap: maxGap,
+		hist:   make([]float64, concealPeriod*src.Channels())}
+}
+
+// Stats returns the concealment statistics so far.
+func (c *Concealer) Stats() ConcealStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.stats
+}
+
+// Receive implements sound.Source.
+func (c *Concealer) Receive(dst []float64) (int, error) {
+	if c.pending > 0 {
+		return c.fill(dst), nil
+	}
+	n, err := c.Source.Receive(dst)
+	if n > 0 {
+		c.remember(dst, n)
+	}
+	if g, ok := err.(*GapError); ok {
+		c.mu.Lock()
+		c.stats.Gaps++
+		c.mu.Unlock()
+		c.pending = g.Frames
+		c.pos = 0
+		c.long = g.Frames > c.maxGap || c.histN == 0
+		if n == 0 {
+			return c.fill(dst), nil
+		}
+		return n, nil
+	}
+	return n, err
+}
+
+// remember keeps the last frames of the n frames in d.
+func (c *Concealer) remember(d []float64, n int) {
+	nC := c.Channels()
+	P := concealPeriod
+	if n >= P {
+		for ch := 0; ch < nC; ch++ {
+			copy(c.hist[ch*P:(ch+1)*P], d[ch*n+n-P:(ch+1)*n])
+		}
+		c.histN = P
+		return
+	}
+	for ch := 0; ch < nC; ch++ {
+		h := c.hist[ch*P : (ch+1)*P]
+		copy(h, h[n:])
+		copy(h[P-n:], d[ch*n:(ch+1)*n])
+	}
+	c.histN += n
+	if c.histN > P {
+		c.histN = P
+	}
+}
+
+// fill synthesizes frames of the pending gap into dst.
+func (c *Concealer) fill(dst []float64) int {
+	nC := c.Channels()
+	n := len(dst) / nC
+	if n > c.pending {
+		n = c.pending
+	}
+	P := concealPeriod
+	L := c.histN
+	total := c.pos + c.pending
+	for ch := 0; ch < nC; ch++ {
+		d := dst[ch*n : (ch+1)*n]
+		if c.long {
+			zero(d)
+			continue
+		}
+		h := c.hist[ch*P+P-L : (ch+1)*P]
+		for i := range d {
+			j := c.pos + i
+			g := 1 - float64(j)/float64(total)
+			d[i] = h[j%L] * g
+		}
+	}
+	c.pos += n
+	c.pending -= n
+	c.mu.Lock()
+	if c.long {
+		c.stats.Silenced += int64(n)
+	} else {
+		c.stats.Concealed += int64(n)
+	}
+	c.mu.Unlock()
+	return n
+}
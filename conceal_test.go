@@ -0,0 +1,99 @@
+// Copyright 2018 The ZikiChombo Authors. All rights reserved.  Use of this source
+// code is governed by a license that can be found in the License file.
+
+package plug
+
+import (
+	"io"
+	"testing"
+
+	"zikichombo.org/sound"
+)
+
+// gappy is a mono source following a script: each step gives a number of
+// frames of a ramp continuing from the previous step, followed by a gap of
+// gap frames if gap > 0.
+type gappy struct {
+	sound.Form
+	steps [][2]int
+	pos   int
+}
+
+func (s *gappy) Close() error { return nil }
+
+func (s *gappy) Receive(d []float64) (int, error) {
+	if len(s.steps) == 0 {
+		return 0, io.EOF
+	}
+	st := &s.steps[0]
+	n := st[0]
+	if n > len(d) {
+		n = len(d)
+	}
+	for i := 0; i < n; i++ {
+		d[i] = float64(s.pos + i)
+	}
+	s.pos += n
+	st[0] -= n
+	if st[0] > 0 {
+		return n, nil
+	}
+	s.steps = s.steps[1:]
+	if st[1] > 0 {
+		return n, &GapError{Frames: st[1]}
+	}
+	return n, nil
+}
+
+func TestConcealer(t *testing.T) {
+	mono := sound.MonoCd()
+	src := &gappy{Form: mono, steps: [][2]int{{512, 100}, {300, 0}, {0, 50}, {100, 1000}, {100, 0}}}
+	c := NewConcealer(src, 200)
+	var got []float64
+	buf := make([]float64, 128)
+	for {
+		n, err := c.Receive(buf)
+		got = append(got, buf[:n]...)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(got) != 512+100+300+50+100+1000+100 {
+		t.Fatalf("got %d frames", len(got))
+	}
+	// the short gap repeats the last 256 frames received, fading out.
+	for j := 0; j < 100; j++ {
+		exp := float64(256+j) * (1 - float64(j)/100)
+		if v := got[512+j]; v != exp {
+			t.Fatalf("short gap frame %d: got %g not %g", j, v, exp)
+		}
+	}
+	for i := 0; i < 300; i++ {
+		if v := got[612+i]; v != float64(512+i) {
+			t.Fatalf("frame %d: got %g not %d", 612+i, v, 512+i)
+		}
+	}
+	// a gap reported without frames is concealed as well.
+	for j := 0; j < 50; j++ {
+		exp := float64(556+j) * (1 - float64(j)/50)
+		if v := got[912+j]; v != exp {
+			t.Fatalf("second gap frame %d: got %g not %g", j, v, exp)
+		}
+	}
+	// the long gap is silent.
+	for j := 0; j < 1000; j++ {
+		if v := got[1062+j]; v != 0 {
+			t.Fatalf("long gap frame %d: got %g not 0", j, v)
+		}
+	}
+	if v := got[2062]; v != 912 {
+		t.Errorf("after the long gap: got %g not 912", v)
+	}
+	st := c.Stats()
+	if st != (ConcealStats{Gaps: 3, Concealed: 150, Silenced: 1000}) {
+		t.Errorf("got stats %+v", st)
+	}
+}
@@ -7,6 +7,8 @@ type conn struct {
 	iC    chan *packet
 	oC    chan *packet
 	doneC chan struct{}
+	quit  chan struct{} // closed when the output is removed
+	retry RetryPolicy
 }
 
 func newConn(iC, oC chan *packet, doneC chan struct{}) *conn {
@@ -14,6 +16,7 @@ func newConn(iC, oC chan *packet, doneC chan struct{}) *conn {
 	res.iC = iC
 	res.oC = oC
 	res.doneC = doneC
+	res.quit = make(chan struct{})
 	return res
 }
 
@@ -27,11 +30,26 @@ func (c *conn) serve() {
 		select {
 		case <-doneC:
 			return
+		case <-c.quit:
+			return
 		case pkt := <-iC:
 			if pkt.snk == nil {
-				m, err = pkt.src.Receive(pkt.samples)
+				err = c.retry.do(doneC, func() error {
+					m, err = pkt.src.Receive(pkt.samples)
+					return err
+				})
+				if c.retry.Silence && c.retry.retryable(err) {
+					zero(pkt.samples)
+					m = len(pkt.samples) / pkt.nC
+					err = nil
+				}
 			} else {
-				err = pkt.snk.Send(pkt.samples)
+				err = c.retry.do(doneC, func() error {
+					return pkt.snk.Send(pkt.samples)
+				})
+				if c.retry.Silence && c.retry.retryable(err) {
+					err = nil
+				}
 			}
 			pkt.n = m
 			pkt.err = err
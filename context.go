@@ -0,0 +1,44 @@
+// Copyright 2018 The ZikiChombo Authors. All rights reserved.  Use of this source
+// code is governed by a license that can be found in the License file.
+
+package plug
+
+import "context"
+
+// RunContext implements Stopper.
+func (n *node) RunContext(ctx context.Context) error {
+	return runContext(ctx, n.Run, n.abort)
+}
+
+// abort stops n and closes its sources and sinks, so that a Receive or Send
+// blocking Run returns.  n.mu may be held by process while it blocks, so
+// abort only takes n.wmu, as inputs and outputs may be attached while n
+// runs.
+func (n *node) abort() {
+	n.Stop()
+	n.wmu.Lock()
+	defer n.wmu.Unlock()
+	for i := range n.iPkts {
+		n.iPkts[i].src.Close()
+	}
+	for i := range n.oPkts {
+		n.oPkts[i].snk.Close()
+	}
+}
+
+// runContext calls run, calling abort if ctx is done before run returns,
+// in which case it waits for run and returns ctx.Err().
+func runContext(ctx context.Context, run func() error, abort func()) error {
+	errC := make(chan error, 1)
+	go func() {
+		errC <- run()
+	}()
+	select {
+	case err := <-errC:
+		return err
+	case <-ctx.Done():
+	}
+	abort()
+	<-errC
+	return ctx.Err()
+}
@@ -0,0 +1,24 @@
+// Copyright 2018 The ZikiChombo Authors. All rights reserved.  Use of this source
+// code is governed by a license that can be found in the License file.
+
+package plug
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"zikichombo.org/sound"
+)
+
+func TestIORunContext(t *testing.T) {
+	src, _ := sound.Pipe(sound.MonoCd())
+	u := New(sound.MonoCd(), sound.MonoCd(), PassThrough)
+	u.SetInput(src)
+	u.AddOutput(&countSink{Form: sound.MonoCd()})
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := u.RunContext(ctx); err != context.DeadlineExceeded {
+		t.Errorf("got %v not %v", err, context.DeadlineExceeded)
+	}
+}
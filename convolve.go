@@ -0,0 +1,201 @@
+// Copyright 2018 The ZikiChombo Authors. All rights reserved.  Use of this source
+// code is governed by a license that can be found in the License file.
+
+package plug
+
+import (
+	"fmt"
+	"io"
+
+	"zikichombo.org/sound"
+)
+
+// Convolver is a FullMode processor which convolves its input with an
+// impulse response, as for reverb or cabinet simulation.
+//
+// The convolution is uniformly partitioned: the impulse response is split
+// into partitions of a fixed number of frames whose spectra are multiplied
+// with those of the past input blocks of the same size and overlap-added.
+// The Convolver thus has a latency of one partition, and once its input
+// has ended it outputs the rest of the response as its tail.
+type Convolver struct {
+	B, L int              // partition size and response length, in frames
+	h    [][][]complex128 // partition spectra by response channel
+	chs  []*convChannel
+	k    int // position in the current partition
+	buf  []complex128
+	left int // frames of tail left, or -1 before the input ends
+}
+
+// convChannel holds the convolution state of one channel.
+type convChannel struct {
+	in, out, over []float64
+	fdl           [][]complex128 // spectra of past input partitions
+	pos           int            // of the latest spectrum in fdl
+}
+
+// NewConvolver creates a new Convolver with impulse response ir, given by
+// channel, and partition size partition, a power of 2.  ir may have one
+// channel, which is then applied to every channel of the input, or as many
+// channels as the input.
+//
+// Smaller partitions give less latency at the cost of more computation.
+func NewConvolver(ir [][]float64, partition int) (*Convolver, error) {
+	if !isPow2(partition) {
+		return nil, fmt.Errorf("convolver: partition size %d not a power of 2", partition)
+	}
+	if len(ir) == 0 || len(ir[0]) == 0 {
+		return nil, fmt.Errorf("convolver: empty impulse response")
+	}
+	L := len(ir[0])
+	B := partition
+	P := (L + B - 1) / B
+	c := &Convolver{B: B, L: L, buf: make([]complex128, 2*B), left: -1}
+	for i, chn := range ir {
+		if len(chn) != L {
+			return nil, fmt.Errorf("convolver: response channel %d has %d frames not %d", i, len(chn), L)
+		}
+		parts := make([][]complex128, P)
+		for j := range parts {
+			part := make([]complex128, 2*B)
+			for k := 0; k < B && j*B+k < L; k++ {
+				part[k] = complex(chn[j*B+k], 0)
+			}
+			fft(part, false)
+			parts[j] = part
+		}
+		c.h = append(c.h, parts)
+	}
+	return c, nil
+}
+
+// ReadIR reads an impulse response from src until io.EOF, giving it by
+// channel for NewConvolver.
+func ReadIR(src sound.Source) ([][]float64, error) {
+	nC := src.Channels()
+	ir := make([][]float64, nC)
+	d := make([]float64, nC*DefaultInFrames)
+	for {
+		n, err := src.Receive(d)
+		for c := range ir {
+			ir[c] = append(ir[c], d[c*n:(c+1)*n]...)
+		}
+		if err == io.EOF {
+			return ir, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+}
+
+// Latency implements LatencyReporter.
+func (c *Convolver) Latency() int {
+	return c.B
+}
+
+// Tail implements Tailer.
+func (c *Convolver) Tail() bool {
+	return c.left != 0
+}
+
+// Reset implements Resetter.
+func (c *Convolver) Reset() {
+	c.chs = nil
+	c.k = 0
+	c.left = -1
+}
+
+// ChannelMode implements Processor.
+func (c *Convolver) ChannelMode() ChannelMode {
+	return FullMode
+}
+
+// NextFrames implements Processor.
+func (c *Convolver) NextFrames() (int, int) {
+	return DefaultInFrames, DefaultInFrames
+}
+
+// Process implements Processor.
+func (c *Convolver) Process(dst, src *Block) error {
+	nC := src.Channels
+	if nC != dst.Channels || (len(c.h) != 1 && len(c.h) != nC) {
+		return fmt.Errorf("convolver: cannot map %d channels to %d with a %d channel response", nC, dst.Channels, len(c.h))
+	}
+	for len(c.chs) < nC {
+		P := len(c.h[0])
+		ch := &convChannel{
+			in:   make([]float64, c.B),
+			out:  make([]float64, c.B),
+			over: make([]float64, c.B),
+			fdl:  make([][]complex128, P)}
+		for j := range ch.fdl {
+			ch.fdl[j] = make([]complex128, 2*c.B)
+		}
+		c.chs = append(c.chs, ch)
+	}
+	N := src.Frames
+	in := src.Samples
+	if N == 0 {
+		if c.left < 0 {
+			c.left = c.B + c.L - 1
+		}
+		N = dst.Frames
+		if N > c.left {
+			N = c.left
+		}
+		c.left -= N
+		in = dst.Samples[:nC*N]
+		zero(in)
+	}
+	k := c.k
+	for i, ch := range c.chs[:nC] {
+		h := c.h[0]
+		if len(c.h) > 1 {
+			h = c.h[i]
+		}
+		k = c.k
+		d := dst.Samples[i*N : (i+1)*N]
+		for j, x := range in[i*N : (i+1)*N] {
+			ch.in[k] = x
+			d[j] = ch.out[k]
+			k++
+			if k == c.B {
+				c.partition(ch, h)
+				k = 0
+			}
+		}
+	}
+	c.k = k
+	dst.Frames = N
+	return nil
+}
+
+// partition convolves the input partition of ch with the response h.
+func (c *Convolver) partition(ch *convChannel, h [][]complex128) {
+	P := len(h)
+	ch.pos = (ch.pos + 1) % P
+	X := ch.fdl[ch.pos]
+	for i, x := range ch.in {
+		X[i] = complex(x, 0)
+	}
+	for i := c.B; i < len(X); i++ {
+		X[i] = 0
+	}
+	fft(X, false)
+	acc := c.buf
+	for i := range acc {
+		acc[i] = 0
+	}
+	for j, H := range h {
+		X := ch.fdl[(ch.pos-j+P)%P]
+		for i := range acc {
+			acc[i] += X[i] * H[i]
+		}
+	}
+	fft(acc, true)
+	for i := range ch.out {
+		ch.out[i] = real(acc[i]) + ch.over[i]
+		ch.over[i] = real(acc[c.B+i])
+	}
+}
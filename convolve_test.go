@@ -0,0 +1,52 @@
+// Copyright 2018 The ZikiChombo Authors. All rights reserved.  Use of this source
+// code is governed by a license that can be found in the License file.
+
+package plug
+
+import (
+	"math"
+	"testing"
+
+	"zikichombo.org/sound"
+)
+
+func TestConvolver(t *testing.T) {
+	if _, err := NewConvolver([][]float64{{1}}, 100); err == nil {
+		t.Errorf("partition of 100 frames accepted")
+	}
+	mono := sound.MonoCd()
+	ir := make([]float64, 300)
+	for i := range ir {
+		ir[i] = math.Sin(float64(i)) / float64(i+1)
+	}
+	B := 64
+	c, err := NewConvolver([][]float64{ir}, B)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var g Graph
+	u := g.New(mono, mono, c)
+	u.SetInput(&ramp{Form: mono, n: 1500})
+	snk := &capture{Form: mono}
+	u.AddOutput(snk)
+	if err := u.Run(); err != nil {
+		t.Fatal(err)
+	}
+	if lat, err := g.Latency(u); err != nil || lat != B {
+		t.Errorf("got latency %d, %v", lat, err)
+	}
+	if snk.frames != int64(1500+B+len(ir)-1) {
+		t.Fatalf("got %d frames", snk.frames)
+	}
+	for n := 0; n < 1500+len(ir)-1; n++ {
+		exp := 0.0
+		for k, h := range ir {
+			if m := n - k; m >= 0 && m < 1500 {
+				exp += h * float64(m)
+			}
+		}
+		if got := snk.mix[n+B]; math.Abs(got-exp) > 1e-6 {
+			t.Fatalf("frame %d: got %f not %f", n, got, exp)
+		}
+	}
+}
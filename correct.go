@@ -0,0 +1,170 @@
+// Copyright 2018 The ZikiChombo Authors. All rights reserved.  Use of this source
+// code is governed by a license that can be found in the License file.
+
+package plug
+
+import (
+	"math"
+	"sync"
+)
+
+const (
+	// maximum deviation of the ratio of a DriftCorrector from 1.
+	maxDrift = 0.01
+	// per frame smoothing of the ratio of a DriftCorrector.
+	driftSmooth = 1e-4
+)
+
+// DriftCorrector is a FullMode processor which resamples its input by a
+// continuously variable ratio close to 1, to keep a long running capture
+// locked to a master clock.  The ratio is the number of input frames
+// consumed per output frame, as estimated by a DriftEstimator, and is
+// limited to 1±0.01.
+//
+// Changes of the ratio are smoothed over a few thousand frames and the
+// input is interpolated with cubic splines, so that corrections are not
+// audible.
+//
+// The input and output forms of a node running a DriftCorrector should
+// have the same number of channels.
+type DriftCorrector struct {
+	mu     sync.Mutex
+	target float64
+	ratio  float64
+	follow <-chan float64
+	pa     float64
+	in     [][]float64
+}
+
+// NewDriftCorrector creates a new DriftCorrector with initial ratio ratio.
+func NewDriftCorrector(ratio float64) *DriftCorrector {
+	r := clampDrift(ratio)
+	return &DriftCorrector{target: r, ratio: r, pa: 1}
+}
+
+func clampDrift(r float64) float64 {
+	return math.Max(1-maxDrift, math.Min(1+maxDrift, r))
+}
+
+// SetRatio sets the ratio towards which the current ratio moves.
+func (d *DriftCorrector) SetRatio(r float64) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.target = clampDrift(r)
+}
+
+// Ratio returns the current ratio.
+func (d *DriftCorrector) Ratio() float64 {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.ratio
+}
+
+// Follow makes d take its ratio from the latest value received on c
+// before processing every block, for example
+//
+//	d.Follow(estimator.Ratios())
+//
+// If c is nil, d stops following.
+func (d *DriftCorrector) Follow(c <-chan float64) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.follow = c
+}
+
+// Reset implements Resetter, discarding the buffered input.  The ratio is
+// kept.
+func (d *DriftCorrector) Reset() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.in = nil
+	d.pa = 1
+}
+
+// ChannelMode implements Processor.
+func (d *DriftCorrector) ChannelMode() ChannelMode {
+	return FullMode
+}
+
+// NextFrames implements Processor.
+func (d *DriftCorrector) NextFrames() (int, int) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	have := 0
+	if len(d.in) > 0 {
+		have = len(d.in[0])
+	}
+	n := int(math.Ceil(d.pa+DefaultOutFrames*(1+maxDrift))) + 3 - have
+	if n < 1 {
+		n = 1
+	}
+	return n, DefaultOutFrames
+}
+
+// Process implements Processor.
+func (d *DriftCorrector) Process(dst, src *Block) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.poll()
+	nC := src.Channels
+	for len(d.in) < nC {
+		// one frame of silence precedes the input for interpolation.
+		d.in = append(d.in, []float64{0})
+	}
+	N := src.Frames
+	for c := 0; c < nC; c++ {
+		d.in[c] = append(d.in[c], src.Samples[c*N:(c+1)*N]...)
+	}
+	M := dst.Frames
+	avail := len(d.in[0])
+	m := 0
+	for ; m < M; m++ {
+		i := int(d.pa)
+		if i+2 >= avail {
+			break
+		}
+		f := d.pa - float64(i)
+		for c := 0; c < nC; c++ {
+			x := d.in[c]
+			dst.Samples[c*M+m] = cubic(x[i-1], x[i], x[i+1], x[i+2], f)
+		}
+		d.ratio += (d.target - d.ratio) * driftSmooth
+		d.pa += d.ratio
+	}
+	if m < M {
+		for c := 1; c < nC; c++ {
+			copy(dst.Samples[c*m:(c+1)*m], dst.Samples[c*M:c*M+m])
+		}
+	}
+	dst.Frames = m
+
+	// discard input which is no longer needed.
+	if cut := int(d.pa) - 1; cut > 0 {
+		for c, x := range d.in {
+			d.in[c] = x[:copy(x, x[cut:])]
+		}
+		d.pa -= float64(cut)
+	}
+	return nil
+}
+
+// poll takes the latest ratio from the followed channel, if any.
+func (d *DriftCorrector) poll() {
+	for {
+		select {
+		case r := <-d.follow:
+			d.target = clampDrift(r)
+		default:
+			return
+		}
+	}
+}
+
+// cubic interpolates between y1 and y2 at fraction f with a Catmull-Rom
+// spline through y0, y1, y2 and y3.
+func cubic(y0, y1, y2, y3, f float64) float64 {
+	a := -0.5*y0 + 1.5*y1 - 1.5*y2 + 0.5*y3
+	b := y0 - 2.5*y1 + 2*y2 - 0.5*y3
+	c := -0.5*y0 + 0.5*y2
+	return ((a*f+b)*f+c)*f + y1
+}
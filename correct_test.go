@@ -0,0 +1,43 @@
+// Copyright 2018 The ZikiChombo Authors. All rights reserved.  Use of this source
+// code is governed by a license that can be found in the License file.
+
+package plug
+
+import (
+	"math"
+	"testing"
+
+	"zikichombo.org/sound"
+)
+
+func TestDriftCorrector(t *testing.T) {
+	mono := sound.MonoCd()
+	d := NewDriftCorrector(1.002)
+	u := New(mono, mono, d)
+	u.SetInput(&ramp{Form: mono, n: 30000})
+	snk := &capture{Form: mono}
+	u.AddOutput(snk)
+	if err := u.Run(); err != nil {
+		t.Fatal(err)
+	}
+	if n := int64(29940); snk.frames < n-4 || snk.frames > n+1 {
+		t.Errorf("got %d frames not about %d", snk.frames, n)
+	}
+	for _, k := range []int{0, 1000, 20000} {
+		if v := snk.mix[k]; math.Abs(v-1.002*float64(k)) > 1e-6 {
+			t.Errorf("frame %d: got %f not %f", k, v, 1.002*float64(k))
+		}
+	}
+	c := make(chan float64, 1)
+	c <- 0.5
+	d.Follow(c)
+	u = New(mono, mono, d)
+	u.SetInput(&ramp{Form: mono, n: 100000})
+	u.AddOutput(&capture{Form: mono})
+	if err := u.Run(); err != nil {
+		t.Fatal(err)
+	}
+	if r := d.Ratio(); math.Abs(r-0.99) > 1e-3 {
+		t.Errorf("got ratio %f following 0.5, limited to 0.99", r)
+	}
+}
@@ -0,0 +1,124 @@
+// Copyright 2018 The ZikiChombo Authors. All rights reserved.  Use of this source
+// code is governed by a license that can be found in the License file.
+
+package plug
+
+import (
+	"fmt"
+	"math"
+	"sync"
+
+	"zikichombo.org/sound"
+)
+
+// Crossfade is an IO which outputs one of two inputs, "A" and "B", of the
+// same form, and crossfades from one to the other when triggered, for
+// example to switch between live inputs without clicks.  The crossfade is
+// equal power, as suits uncorrelated inputs.
+//
+// The input form of a Crossfade has the channels of A followed by those of
+// B, and its output form is that of A and B.  Initially, A is output.
+type Crossfade struct {
+	IO
+	xf *xfade
+}
+
+// NewCrossfade creates a new Crossfade in g with inputs a and b which
+// crossfades over fade frames.
+func (g *Graph) NewCrossfade(a, b sound.Source, fade int) (*Crossfade, error) {
+	if a.Channels() != b.Channels() || a.SampleRate() != b.SampleRate() {
+		return nil, fmt.Errorf("crossfade: form mismatch: %d channels at %s and %d channels at %s",
+			a.Channels(), a.SampleRate(), b.Channels(), b.SampleRate())
+	}
+	nC := a.Channels()
+	xf := &xfade{fade: fade}
+	u := g.New(sound.NewForm(a.SampleRate(), 2*nC), a, xf)
+	if err := u.SetInput(a, chanRange(0, nC)...); err != nil {
+		return nil, err
+	}
+	if err := u.SetInput(b, chanRange(nC, 2*nC)...); err != nil {
+		return nil, err
+	}
+	return &Crossfade{IO: u, xf: xf}, nil
+}
+
+// SelectB crossfades to B if b is true, to A otherwise.  SelectB may be
+// called while the graph is running.  Selecting the input being faded from
+// during a crossfade reverses it from where it is.
+func (c *Crossfade) SelectB(b bool) {
+	c.xf.mu.Lock()
+	defer c.xf.mu.Unlock()
+	c.xf.toB = b
+}
+
+// IsB returns whether B is selected.
+func (c *Crossfade) IsB() bool {
+	c.xf.mu.Lock()
+	defer c.xf.mu.Unlock()
+	return c.xf.toB
+}
+
+// SetFade sets the number of frames of subsequent crossfades.
+func (c *Crossfade) SetFade(fade int) {
+	c.xf.mu.Lock()
+	defer c.xf.mu.Unlock()
+	c.xf.fade = fade
+}
+
+// Fading returns whether a crossfade is in progress.
+func (c *Crossfade) Fading() bool {
+	c.xf.mu.Lock()
+	defer c.xf.mu.Unlock()
+	return c.xf.pos != c.xf.target()
+}
+
+// xfade is the processor of a Crossfade.
+type xfade struct {
+	mu   sync.Mutex
+	fade int
+	toB  bool
+	pos  float64 // of the fade, from 0 (A) to 1 (B)
+}
+
+func (x *xfade) target() float64 {
+	if x.toB {
+		return 1
+	}
+	return 0
+}
+
+func (x *xfade) ChannelMode() ChannelMode {
+	return FullMode
+}
+
+func (x *xfade) NextFrames() (int, int) {
+	return DefaultInFrames, DefaultInFrames
+}
+
+func (x *xfade) Process(dst, src *Block) error {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	nC := dst.Channels
+	if src.Channels != 2*nC {
+		return fmt.Errorf("crossfade: cannot map %d channels to %d", src.Channels, nC)
+	}
+	N := src.Frames
+	to := x.target()
+	step := 1.0
+	if x.fade > 0 {
+		step = 1 / float64(x.fade)
+	}
+	for f := 0; f < N; f++ {
+		if x.pos < to {
+			x.pos = math.Min(to, x.pos+step)
+		} else if x.pos > to {
+			x.pos = math.Max(to, x.pos-step)
+		}
+		ga, gb := math.Cos(x.pos*math.Pi/2), math.Sin(x.pos*math.Pi/2)
+		for c := 0; c < nC; c++ {
+			dst.Samples[c*N+f] = ga*src.Samples[c*N+f] + gb*src.Samples[(nC+c)*N+f]
+		}
+	}
+	dst.Frames = N
+	return nil
+}
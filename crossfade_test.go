@@ -0,0 +1,44 @@
+// Copyright 2018 The ZikiChombo Authors. All rights reserved.  Use of this source
+// code is governed by a license that can be found in the License file.
+
+package plug
+
+import (
+	"math"
+	"testing"
+
+	"zikichombo.org/sound"
+)
+
+func TestCrossfade(t *testing.T) {
+	mono := sound.MonoCd()
+	var g Graph
+	if _, err := g.NewCrossfade(&ramp{Form: mono}, &ramp{Form: sound.StereoCd()}, 10); err == nil {
+		t.Errorf("crossfaded mono and stereo")
+	}
+	x, err := g.NewCrossfade(&ramp{Form: mono, n: 3000}, &ramp{Form: mono, pos: 1000, n: 4000}, 1000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if x.OutForm().Channels() != 1 {
+		t.Errorf("got %d output channels", x.OutForm().Channels())
+	}
+	x.SelectB(true)
+	snk := &capture{Form: mono}
+	x.AddOutput(snk)
+	if err := x.Run(); err != nil {
+		t.Fatal(err)
+	}
+	if snk.frames != 3000 || !x.IsB() || x.Fading() {
+		t.Fatalf("got %d frames, b %t, fading %t", snk.frames, x.IsB(), x.Fading())
+	}
+	a := math.Pi / 2 * 501 / 1000
+	for _, e := range []struct {
+		i int
+		v float64
+	}{{500, math.Cos(a)*500 + math.Sin(a)*1500}, {999, 1999}, {2000, 3000}} {
+		if math.Abs(snk.mix[e.i]-e.v) > 1e-9 {
+			t.Errorf("frame %d: got %f not %f", e.i, snk.mix[e.i], e.v)
+		}
+	}
+}
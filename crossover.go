@@ -0,0 +1,107 @@
+// Copyright 2018 The ZikiChombo Authors. All rights reserved.  Use of this source
+// code is governed by a license that can be found in the License file.
+
+package plug
+
+import (
+	"fmt"
+	"math"
+	"sort"
+)
+
+// Crossover is a FullMode processor which splits its input into frequency
+// bands using 4th order Linkwitz-Riley filters.  The bands sum back to an
+// allpass filtered version of the input, so that band-wise processing
+// followed by summing is phase coherent.
+//
+// For an input with C channels and a crossover with B bands, the output has
+// B*C channels, and channel c of band b is output channel b*C + c.  Bands may
+// be routed to distinct destinations by passing BandChannels to IO.Output or
+// IO.AddOutput.
+type Crossover struct {
+	freqs []float64
+	nC    int
+	lp    []biquad // per split
+	hp    []biquad // per split
+	ap    []biquad // per split
+	st    [][]bqState
+}
+
+// NewCrossover creates a new crossover with split frequencies freqs, in Hz,
+// giving len(freqs)+1 bands.
+func NewCrossover(freqs ...float64) *Crossover {
+	fs := append([]float64(nil), freqs...)
+	sort.Float64s(fs)
+	return &Crossover{freqs: fs}
+}
+
+// Bands returns the number of bands.
+func (x *Crossover) Bands() int {
+	return len(x.freqs) + 1
+}
+
+// BandChannels returns the output channels of band b for an input with nC
+// channels.
+func (x *Crossover) BandChannels(b, nC int) []int {
+	return chanRange(b*nC, (b+1)*nC)
+}
+
+// ChannelMode implements Processor.
+func (x *Crossover) ChannelMode() ChannelMode {
+	return FullMode
+}
+
+// NextFrames implements Processor.
+func (x *Crossover) NextFrames() (int, int) {
+	return DefaultInFrames, DefaultInFrames
+}
+
+func (x *Crossover) init(b *Block) {
+	rate := b.SampleRate.Float64()
+	q := 1 / math.Sqrt2
+	x.nC = b.Channels
+	for _, f := range x.freqs {
+		x.lp = append(x.lp, lowpassBQ(rate, f, q))
+		x.hp = append(x.hp, highpassBQ(rate, f, q))
+		x.ap = append(x.ap, allpassBQ(rate, f, q))
+	}
+	// per channel: for each split, 2 lowpass, 2 highpass and 1 allpass state
+	// for each band below the split.
+	x.st = make([][]bqState, x.nC)
+	for c := range x.st {
+		x.st[c] = make([]bqState, len(x.freqs)*(4+len(x.freqs)))
+	}
+}
+
+// Process implements Processor.
+func (x *Crossover) Process(dst, src *Block) error {
+	if x.st == nil {
+		x.init(src)
+	}
+	nB := x.Bands()
+	if dst.Channels != nB*src.Channels {
+		return fmt.Errorf("crossover: %d bands of %d channels need %d output channels, got %d", nB, src.Channels, nB*src.Channels, dst.Channels)
+	}
+	N := src.Frames
+	nC := src.Channels
+	nS := len(x.freqs)
+	for c := 0; c < nC; c++ {
+		st := x.st[c]
+		for f := 0; f < N; f++ {
+			rest := src.Samples[c*N+f]
+			for j := 0; j < nS; j++ {
+				jst := st[j*(4+nS):]
+				lo := x.lp[j].run(&jst[0], x.lp[j].run(&jst[1], rest))
+				rest = x.hp[j].run(&jst[2], x.hp[j].run(&jst[3], rest))
+				// align band j with the later splits.
+				for k := j + 1; k < nS; k++ {
+					lo = x.ap[k].run(&jst[4+k], lo)
+				}
+				dst.Samples[(j*nC+c)*N+f] = lo
+			}
+			dst.Samples[(nS*nC+c)*N+f] = rest
+		}
+	}
+	dst.Frames = N
+	return nil
+}
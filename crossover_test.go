@@ -0,0 +1,45 @@
+// Copyright 2018 The ZikiChombo Authors. All rights reserved.  Use of this source
+// code is governed by a license that can be found in the License file.
+
+package plug
+
+import (
+	"math"
+	"math/cmplx"
+	"testing"
+
+	"zikichombo.org/sound/freq"
+)
+
+func TestCrossoverFlatSum(t *testing.T) {
+	sr := 48000 * freq.Hertz
+	N := 16384
+	x := NewCrossover(3000, 200, 800)
+	src := &Block{SampleRate: sr, Channels: 1, Frames: N, Samples: make([]float64, N)}
+	src.Samples[0] = 1
+	dst := &Block{SampleRate: sr, Channels: x.Bands(), Samples: make([]float64, x.Bands()*N)}
+	if err := x.Process(dst, src); err != nil {
+		t.Fatal(err)
+	}
+	// the impulse response of the sum of the bands.
+	h := make([]float64, N)
+	for b := 0; b < x.Bands(); b++ {
+		for i, v := range dst.Samples[b*N : (b+1)*N] {
+			h[i] += v
+		}
+	}
+	for _, hz := range []float64{20, 100, 200, 500, 800, 1000, 3000, 5000, 10000, 20000} {
+		w := 2 * math.Pi * hz / sr.Float64()
+		var H complex128
+		for i, v := range h {
+			H += complex(v, 0) * cmplx.Exp(complex(0, -w*float64(i)))
+		}
+		if db := 20 * math.Log10(cmplx.Abs(H)); math.Abs(db) > 0.01 {
+			t.Errorf("%gHz: sum of bands is %.3fdB", hz, db)
+		}
+	}
+	bad := &Block{SampleRate: sr, Channels: 1, Samples: make([]float64, N)}
+	if err := x.Process(bad, src); err == nil {
+		t.Error("processed into too few channels")
+	}
+}
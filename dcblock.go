@@ -0,0 +1,69 @@
+// Copyright 2018 The ZikiChombo Authors. All rights reserved.  Use of this source
+// code is governed by a license that can be found in the License file.
+
+package plug
+
+import "math"
+
+// DCBlock is a FullMode processor removing any DC offset from its input,
+// as capture devices frequently have, with a one pole high pass filter
+// with a separate state for every channel.
+//
+// The cutoff frequency in Hz is the parameter "freq".
+type DCBlock struct {
+	ParamTable
+	freq   float64
+	rate   float64 // of r, or 0 if freq changed
+	r      float64 // pole
+	x1, y1 []float64
+}
+
+// NewDCBlock creates a new DCBlock with cutoff frequency freq, a few Hz,
+// such as 5.
+func NewDCBlock(freq float64) *DCBlock {
+	d := &DCBlock{freq: freq}
+	d.Add(ParamInfo{Name: "freq", Unit: "Hz", Min: 0.1, Max: 100, Default: freq},
+		func() float64 { return d.freq }, func(v float64) { d.freq = v; d.rate = 0 })
+	return d
+}
+
+// Reset implements Resetter.
+func (d *DCBlock) Reset() {
+	for c := range d.x1 {
+		d.x1[c], d.y1[c] = 0, 0
+	}
+}
+
+// ChannelMode implements Processor.
+func (d *DCBlock) ChannelMode() ChannelMode {
+	return FullMode
+}
+
+// NextFrames implements Processor.
+func (d *DCBlock) NextFrames() (int, int) {
+	return DefaultInFrames, DefaultInFrames
+}
+
+// Process implements Processor.
+func (d *DCBlock) Process(dst, src *Block) error {
+	if rate := src.SampleRate.Float64(); rate != d.rate {
+		d.r = math.Exp(-2 * math.Pi * d.freq / rate)
+		d.rate = rate
+	}
+	for len(d.x1) < src.Channels {
+		d.x1 = append(d.x1, 0)
+		d.y1 = append(d.y1, 0)
+	}
+	dst.Frames = src.Frames
+	for c := 0; c < src.Channels; c++ {
+		x1, y1 := d.x1[c], d.y1[c]
+		out := dst.Channel(c)
+		for i, x := range src.Channel(c) {
+			y1 = x - x1 + d.r*y1
+			x1 = x
+			out[i] = y1
+		}
+		d.x1[c], d.y1[c] = x1, y1
+	}
+	return nil
+}
@@ -0,0 +1,41 @@
+// Copyright 2018 The ZikiChombo Authors. All rights reserved.  Use of this source
+// code is governed by a license that can be found in the License file.
+
+package plug
+
+import (
+	"math"
+	"testing"
+
+	"zikichombo.org/sound"
+)
+
+func TestDCBlock(t *testing.T) {
+	mono := sound.MonoCd()
+	d := NewDCBlock(5)
+	N := 44100
+	src := &Block{SampleRate: mono.SampleRate(), Channels: 1, Frames: N, Samples: make([]float64, N)}
+	dst := &Block{Channels: 1, Frames: N, Samples: make([]float64, N)}
+	for i := range src.Samples {
+		src.Samples[i] = 0.5 + 0.1*math.Sin(2*math.Pi*1000*float64(i)/44100)
+	}
+	if err := d.Process(dst, src); err != nil {
+		t.Fatal(err)
+	}
+	mean := 0.0
+	for _, v := range dst.Samples[N-4410:] {
+		mean += v / 4410
+	}
+	if math.Abs(mean) > 1e-3 {
+		t.Errorf("got offset %f", mean)
+	}
+	if g := response(NewDCBlock(5), 1000); math.Abs(g-1) > 1e-3 {
+		t.Errorf("got gain %f at 1kHz", g)
+	}
+	if g := response(Subsonic(20), 5); g > 0.1 {
+		t.Errorf("subsonic: got gain %f at 5Hz", g)
+	}
+	if g := response(Subsonic(20), 1000); math.Abs(g-1) > 1e-2 {
+		t.Errorf("subsonic: got gain %f at 1kHz", g)
+	}
+}
@@ -0,0 +1,172 @@
+// Copyright 2018 The ZikiChombo Authors. All rights reserved.  Use of this source
+// code is governed by a license that can be found in the License file.
+
+package plug
+
+import (
+	"fmt"
+	"math"
+)
+
+// MaxDelay is the maximum delay time of a Delay, in ms.
+const MaxDelay = 5000
+
+// Delay is a FullMode delay, or echo, processor.  Each channel is delayed
+// by a fractional delay time, with the delayed signal fed back into the
+// delay line, and additional taps may read the delay line at other times.
+// The output is the input mixed with the delayed signal and the taps.
+//
+// The delay line persists across blocks, and once the input has ended the
+// Delay outputs its echoes as its tail until they decay below -96dB.
+//
+// The delay time in ms, the feedback gain, and the mix of the delayed
+// signal from 0 (dry) to 1 (wet) are the parameters "time", "feedback" and
+// "mix".  Changes of the delay time are smoothed.
+type Delay struct {
+	ParamTable
+	time, feedback, mix float64
+	taps                []delayTap
+
+	rate  float64
+	cur   float64 // current delay, in frames
+	lines [][]float64
+	w     int // write position in lines
+	left  int // frames of tail left, or -1 before the input ends
+}
+
+type delayTap struct {
+	time, gain float64
+}
+
+// NewDelay creates a new Delay with delay time ms, feedback gain feedback
+// and mix.
+func NewDelay(ms, feedback, mix float64) *Delay {
+	d := &Delay{time: ms, feedback: feedback, mix: mix, left: -1}
+	d.Add(ParamInfo{Name: "time", Unit: "ms", Min: 0.1, Max: MaxDelay, Default: ms},
+		func() float64 { return d.time }, func(v float64) { d.time = v })
+	d.Add(ParamInfo{Name: "feedback", Min: -0.99, Max: 0.99, Default: feedback},
+		func() float64 { return d.feedback }, func(v float64) { d.feedback = v })
+	d.Add(ParamInfo{Name: "mix", Min: 0, Max: 1, Default: mix},
+		func() float64 { return d.mix }, func(v float64) { d.mix = v })
+	return d
+}
+
+// AddTap adds a tap reading the delay line ms ms back with gain gain to
+// the delayed signal.
+func (d *Delay) AddTap(ms, gain float64) error {
+	if ms < 0.1 || ms > MaxDelay {
+		return fmt.Errorf("delay: tap at %fms out of range [0.1, %d]", ms, MaxDelay)
+	}
+	d.taps = append(d.taps, delayTap{time: ms, gain: gain})
+	return nil
+}
+
+// TailLength returns the number of frames the Delay outputs after its
+// input has ended, or 0 if it has not yet processed any input.
+func (d *Delay) TailLength() int {
+	dMax := d.time
+	for _, tap := range d.taps {
+		dMax = math.Max(dMax, tap.time)
+	}
+	n := dMax
+	if fb := math.Abs(d.feedback); fb > 0 {
+		// -96dB
+		n += d.time * math.Log(1.6e-5) / math.Log(fb)
+	}
+	return int(math.Ceil(n * 1e-3 * d.rate))
+}
+
+// Tail implements Tailer.
+func (d *Delay) Tail() bool {
+	return d.left != 0
+}
+
+// Reset implements Resetter.
+func (d *Delay) Reset() {
+	for _, line := range d.lines {
+		zero(line)
+	}
+	d.cur = d.time * 1e-3 * d.rate
+	d.left = -1
+}
+
+// ChannelMode implements Processor.
+func (d *Delay) ChannelMode() ChannelMode {
+	return FullMode
+}
+
+// NextFrames implements Processor.
+func (d *Delay) NextFrames() (int, int) {
+	return DefaultInFrames, DefaultInFrames
+}
+
+// frames returns a delay of ms ms in frames, within the delay line.
+func (d *Delay) frames(ms float64) float64 {
+	f := ms * 1e-3 * d.rate
+	if f < 2 {
+		return 2
+	}
+	if max := float64(len(d.lines[0]) - 3); f > max {
+		return max
+	}
+	return f
+}
+
+// read reads line f frames back from the last written frame.
+func (d *Delay) read(line []float64, f float64) float64 {
+	M := len(line)
+	k := int(f)
+	at := func(back int) float64 {
+		return line[((d.w-back)%M+M)%M]
+	}
+	return cubic(at(k-1), at(k), at(k+1), at(k+2), f-float64(k))
+}
+
+// Process implements Processor.
+func (d *Delay) Process(dst, src *Block) error {
+	if src.Channels != dst.Channels {
+		return fmt.Errorf("delay: cannot map %d channels to %d", src.Channels, dst.Channels)
+	}
+	nC := src.Channels
+	if rate := src.SampleRate.Float64(); rate != d.rate || len(d.lines) != nC {
+		d.rate = rate
+		M := int(MaxDelay*1e-3*rate) + 4
+		d.lines = make([][]float64, nC)
+		for c := range d.lines {
+			d.lines[c] = make([]float64, M)
+		}
+		d.cur = d.frames(d.time)
+	}
+	N := src.Frames
+	in := src.Samples
+	if N == 0 {
+		if d.left < 0 {
+			d.left = d.TailLength()
+		}
+		N = dst.Frames
+		if N > d.left {
+			N = d.left
+		}
+		d.left -= N
+		in = dst.Samples[:nC*N]
+		zero(in)
+	}
+	target := d.frames(d.time)
+	M := len(d.lines[0])
+	for i := 0; i < N; i++ {
+		d.cur += (target - d.cur) * 1e-3
+		for c, line := range d.lines {
+			x := in[c*N+i]
+			y := d.read(line, d.cur-1)
+			wet := y
+			for _, tap := range d.taps {
+				wet += tap.gain * d.read(line, d.frames(tap.time)-1)
+			}
+			line[(d.w+1)%M] = x + d.feedback*y
+			dst.Samples[c*N+i] = (1-d.mix)*x + d.mix*wet
+		}
+		d.w = (d.w + 1) % M
+	}
+	dst.Frames = N
+	return nil
+}
@@ -0,0 +1,51 @@
+// Copyright 2018 The ZikiChombo Authors. All rights reserved.  Use of this source
+// code is governed by a license that can be found in the License file.
+
+package plug
+
+import (
+	"math"
+	"testing"
+
+	"zikichombo.org/sound"
+)
+
+func TestDelay(t *testing.T) {
+	mono := sound.MonoCd()
+	d := NewDelay(10, 0.5, 1)
+	if err := d.AddTap(5, 0.25); err != nil {
+		t.Fatal(err)
+	}
+	N := 1024
+	src := &Block{SampleRate: mono.SampleRate(), Channels: 1, Frames: N, Samples: make([]float64, N)}
+	src.Samples[0] = 1
+	dst := &Block{Channels: 1, Samples: make([]float64, N)}
+	if err := d.Process(dst, src); err != nil {
+		t.Fatal(err)
+	}
+	// the tap is 220.5 frames back, interpolated between frames.
+	for _, e := range []struct {
+		i int
+		v float64
+	}{{0, 0}, {220, 0.25 * 9 / 16}, {441, 1}, {661, 0.125 * 9 / 16}, {882, 0.5}, {1000, 0}} {
+		if math.Abs(dst.Samples[e.i]-e.v) > 1e-9 {
+			t.Errorf("frame %d: got %f not %f", e.i, dst.Samples[e.i], e.v)
+		}
+	}
+
+	var g Graph
+	d = NewDelay(10, 0.5, 0.5)
+	u := g.New(mono, mono, d)
+	u.SetInput(&ramp{Form: mono, n: 1500})
+	snk := &capture{Form: mono}
+	u.AddOutput(snk)
+	if err := u.Run(); err != nil {
+		t.Fatal(err)
+	}
+	if n := d.TailLength(); n < 7000 || snk.frames != int64(1500+n) {
+		t.Errorf("got %d frames, tail %d", snk.frames, n)
+	}
+	if v := snk.mix[1500+441]; math.Abs(v-0.25*(1059+0.5*(618+0.5*177))) > 1e-6 {
+		t.Errorf("got echo %f", v)
+	}
+}
@@ -0,0 +1,145 @@
+// Copyright 2018 The ZikiChombo Authors. All rights reserved.  Use of this source
+// code is governed by a license that can be found in the License file.
+
+package plug
+
+import (
+	"math"
+	"sync"
+)
+
+const (
+	denoiseSize = 1024
+	denoiseHop  = denoiseSize / 4
+)
+
+// Denoiser is a FullMode noise suppression processor using spectral
+// subtraction with a Wiener-like gain rule.
+//
+// The noise profile is learned from the input: while learning is on (see
+// Learn), the Denoiser passes its input through (delayed) and averages the
+// power spectrum of each channel.  When learning is turned off, the learned
+// profile is subtracted from the signal.  Until a profile has been learned,
+// the Denoiser passes its input through.
+//
+// The Denoiser introduces a latency of 1024 frames.
+type Denoiser struct {
+	mu        sync.Mutex
+	learning  bool
+	reduction float64
+	floor     float64
+	chans     []*denoiseChan
+}
+
+type denoiseChan struct {
+	stft  *stft
+	noise []float64
+	n     int
+}
+
+// NewDenoiser creates a new Denoiser.  The default over-subtraction
+// factor is 1 and the default gain floor is -20dB.
+func NewDenoiser() *Denoiser {
+	return &Denoiser{reduction: 1, floor: 0.1}
+}
+
+// Learn turns noise profile learning on or off.  Turning learning on
+// discards any previously learned profile.
+func (d *Denoiser) Learn(on bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if on && !d.learning {
+		for _, ch := range d.chans {
+			for i := range ch.noise {
+				ch.noise[i] = 0
+			}
+			ch.n = 0
+		}
+	}
+	d.learning = on
+}
+
+// Learning returns whether the noise profile is being learned.
+func (d *Denoiser) Learning() bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.learning
+}
+
+// SetReduction sets the over-subtraction factor, which scales the learned
+// noise profile before it is subtracted.  Values above 1 remove more noise
+// at the expense of more artifacts.
+func (d *Denoiser) SetReduction(r float64) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.reduction = r
+}
+
+// SetFloor sets the minimum gain, in dB, applied to any frequency bin.
+func (d *Denoiser) SetFloor(db float64) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.floor = math.Pow(10, db/20)
+}
+
+// Latency implements LatencyReporter.
+func (d *Denoiser) Latency() int {
+	return denoiseSize
+}
+
+// ChannelMode implements Processor.
+func (d *Denoiser) ChannelMode() ChannelMode {
+	return FullMode
+}
+
+// NextFrames implements Processor.
+func (d *Denoiser) NextFrames() (int, int) {
+	return DefaultInFrames, DefaultInFrames
+}
+
+// Process implements Processor.
+func (d *Denoiser) Process(dst, src *Block) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for len(d.chans) < src.Channels {
+		d.chans = append(d.chans, &denoiseChan{
+			stft:  newSTFT(denoiseSize, denoiseHop),
+			noise: make([]float64, denoiseSize/2+1)})
+	}
+	N := src.Frames
+	for c := 0; c < src.Channels; c++ {
+		ch := d.chans[c]
+		ch.stft.run(dst.Samples[c*N:(c+1)*N], src.Samples[c*N:(c+1)*N], func(bins []complex128) {
+			d.spectral(ch, bins)
+		})
+	}
+	dst.Frames = N
+	return nil
+}
+
+func (d *Denoiser) spectral(ch *denoiseChan, bins []complex128) {
+	half := len(ch.noise)
+	if d.learning {
+		ch.n++
+		w := 1 / float64(ch.n)
+		for i := 0; i < half; i++ {
+			p := real(bins[i])*real(bins[i]) + imag(bins[i])*imag(bins[i])
+			ch.noise[i] += (p - ch.noise[i]) * w
+		}
+		return
+	}
+	if ch.n == 0 {
+		return
+	}
+	for i := 0; i < half; i++ {
+		p := real(bins[i])*real(bins[i]) + imag(bins[i])*imag(bins[i])
+		g := d.floor
+		if p > 0 {
+			g = math.Max(d.floor, 1-d.reduction*ch.noise[i]/p)
+		}
+		bins[i] *= complex(g, 0)
+		if i != 0 && i != half-1 {
+			bins[len(bins)-i] *= complex(g, 0)
+		}
+	}
+}
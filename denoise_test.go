@@ -0,0 +1,77 @@
+// Copyright 2018 The ZikiChombo Authors. All rights reserved.  Use of this source
+// code is governed by a license that can be found in the License file.
+
+package plug
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+
+	"zikichombo.org/sound/freq"
+)
+
+// denoise processes src through d in blocks, returning the rms of the
+// output after its first second.
+func denoise(t *testing.T, d *Denoiser, src []float64) float64 {
+	sr := 48000 * freq.Hertz
+	N := 1024
+	var sum float64
+	for off := 0; off+N <= len(src); off += N {
+		s := &Block{SampleRate: sr, Channels: 1, Frames: N, Samples: src[off : off+N]}
+		o := &Block{SampleRate: sr, Channels: 1, Samples: make([]float64, N)}
+		if err := d.Process(o, s); err != nil {
+			t.Fatal(err)
+		}
+		if off < 48000 {
+			continue
+		}
+		for _, v := range o.Samples {
+			sum += v * v
+		}
+	}
+	return math.Sqrt(sum / float64(len(src)-48000))
+}
+
+func rms(d []float64) float64 {
+	var sum float64
+	for _, v := range d {
+		sum += v * v
+	}
+	return math.Sqrt(sum / float64(len(d)))
+}
+
+func TestDenoiser(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	noise := make([]float64, 48000*3)
+	for i := range noise {
+		noise[i] = 0.05 * rnd.NormFloat64()
+	}
+	tone := sineBlock(48000*freq.Hertz, 1000, 0.5, len(noise)).Samples
+
+	d := NewDenoiser()
+	// without a profile, the input passes through.
+	if got, exp := denoise(t, d, noise), rms(noise[48000:]); math.Abs(got-exp) > 0.05*exp {
+		t.Errorf("unlearned: got rms %f not %f", got, exp)
+	}
+	d.Learn(true)
+	if !d.Learning() {
+		t.Fatal("not learning")
+	}
+	denoise(t, d, noise)
+	d.Learn(false)
+
+	in := rms(noise[48000:])
+	got := denoise(t, d, noise)
+	if got > in/2 {
+		t.Errorf("noise: got rms %f from %f, reduced by only %.1fdB", got, in, 20*math.Log10(in/got))
+	}
+	// over-subtraction removes more, down to the floor.
+	d.SetReduction(4)
+	if more := denoise(t, d, noise); more >= got || more < in/10*0.99 {
+		t.Errorf("noise with reduction 4: got rms %f from %f", more, in)
+	}
+	if got, in := denoise(t, d, tone), rms(tone[48000:]); math.Abs(20*math.Log10(got/in)) > 0.5 {
+		t.Errorf("tone: got rms %f not %f", got, in)
+	}
+}
@@ -0,0 +1,69 @@
+// Copyright 2018 The ZikiChombo Authors. All rights reserved.  Use of this source
+// code is governed by a license that can be found in the License file.
+
+package plug
+
+import (
+	"fmt"
+
+	"zikichombo.org/sound"
+)
+
+// SetDetachOnError implements OutputController.
+func (n *node) SetDetachOnError(v bool) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.detach = v
+}
+
+// ReplaceOutput implements OutputController.
+func (n *node) ReplaceOutput(i int, d sound.Sink) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if i < 0 || i >= len(n.oPkts) {
+		return fmt.Errorf("no output %d", i)
+	}
+	pkt := &n.oPkts[i]
+	if pkt.src != nil {
+		return fmt.Errorf("output %d was not added by AddOutput", i)
+	}
+	if pkt.q != nil {
+		return fmt.Errorf("output %d is queued", i)
+	}
+	if pkt.removed {
+		return fmt.Errorf("output %d was removed", i)
+	}
+	if d.SampleRate() != pkt.snk.SampleRate() || d.Channels() != pkt.snk.Channels() {
+		return fmt.Errorf("form mismatch: got %d channels at %s, not %d channels at %s",
+			d.Channels(), d.SampleRate(), pkt.snk.Channels(), pkt.snk.SampleRate())
+	}
+	if !pkt.off {
+		pkt.snk.Close()
+	}
+	n.wmu.Lock()
+	pkt.snk = d
+	n.wmu.Unlock()
+	pkt.off = false
+	return nil
+}
+
+// outIndex gives the index of the output packet pkt.
+func (n *node) outIndex(pkt *packet) int {
+	for i := range n.oPkts {
+		if &n.oPkts[i] == pkt {
+			return i
+		}
+	}
+	return -1
+}
+
+// outQueueIndex gives the index of the output served by the queue q, or -1
+// if q serves a secondary output.
+func (n *node) outQueueIndex(q *outQueue) int {
+	for i := range n.oPkts {
+		if n.oPkts[i].q == q {
+			return i
+		}
+	}
+	return -1
+}
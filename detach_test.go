@@ -0,0 +1,89 @@
+// Copyright 2018 The ZikiChombo Authors. All rights reserved.  Use of this source
+// code is governed by a license that can be found in the License file.
+
+package plug
+
+import (
+	"testing"
+
+	"zikichombo.org/sound"
+)
+
+func TestIOReplaceOutput(t *testing.T) {
+	mono := sound.MonoCd()
+	u := New(mono, mono, PassThrough)
+	u.SetInput(&ramp{Form: mono, n: 10240})
+	primary := &countSink{Form: mono}
+	u.AddOutput(primary)
+	u.AddOutput(&failSink{countSink: countSink{Form: mono}})
+	u.SetDetachOnError(true)
+	rec := &record{Form: mono}
+	var evs []*OutputDetached
+	u.OnEvent(func(e Event) {
+		if od, ok := e.(*OutputDetached); ok {
+			evs = append(evs, od)
+			if err := u.ReplaceOutput(od.Output, rec); err != nil {
+				t.Error(err)
+			}
+		}
+	})
+	if err := u.Run(); err != nil {
+		t.Fatal(err)
+	}
+	if len(evs) != 1 || evs[0].Output != 1 || evs[0].Err != errFailSink {
+		t.Fatalf("got events %v", evs)
+	}
+	if primary.n != 10240 {
+		t.Errorf("primary got %d frames not 10240", primary.n)
+	}
+	// the replacement gets the blocks after the one which failed.
+	out := rec.chs[0]
+	if len(out) == 0 || out[0] == 0 || int(out[0])+len(out) != 10240 {
+		t.Fatalf("replacement got %d frames from %v", len(out), out[:1])
+	}
+	for i, v := range out {
+		if v != out[0]+float64(i) {
+			t.Fatalf("frame %d: got %g not %g", i, v, out[0]+float64(i))
+		}
+	}
+}
+
+func TestIOReplaceOutputErrors(t *testing.T) {
+	mono := sound.MonoCd()
+	u := New(mono, mono, PassThrough)
+	snk := &countSink{Form: mono}
+	u.AddOutput(snk)
+	u.Output()
+	removed := &countSink{Form: mono}
+	u.AddOutput(removed)
+	if err := u.RemoveOutput(removed); err != nil {
+		t.Fatal(err)
+	}
+	for _, tc := range []struct {
+		name string
+		i    int
+		d    sound.Sink
+	}{
+		{"out of range", 3, &countSink{Form: mono}},
+		{"form mismatch", 0, &countSink{Form: sound.StereoCd()}},
+		{"from Output", 1, &countSink{Form: mono}},
+		{"removed", 2, &countSink{Form: mono}},
+	} {
+		if err := u.ReplaceOutput(tc.i, tc.d); err == nil {
+			t.Errorf("%s: replaced output %d", tc.name, tc.i)
+		}
+	}
+	if err := u.ReplaceOutput(0, &countSink{Form: mono}); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestIOOutputFailsWithoutDetach(t *testing.T) {
+	mono := sound.MonoCd()
+	u := New(mono, mono, PassThrough)
+	u.SetInput(&ramp{Form: mono, n: 10240})
+	u.AddOutput(&failSink{countSink: countSink{Form: mono}})
+	if err := u.Run(); err != errFailSink {
+		t.Errorf("got %v not %v", err, errFailSink)
+	}
+}
@@ -51,4 +51,11 @@
 // more responsability on the processing function in terms of coordinating what
 // data is where.
 //
+// Once running, the IO tier does not allocate in the steady state: blocks
+// are buffered once per node and reused, temporary blocks used by processors
+// wrapping other processors are pooled, and outputs are piped to inputs
+// without intermediate copies.  Processors which do not allocate while
+// processing hence make graphs which do not allocate, as the benchmarks with
+// allocs/op verify.
+//
 package plug /* import "zikichombo.org/plug" */
@@ -0,0 +1,112 @@
+// Copyright 2018 The ZikiChombo Authors. All rights reserved.  Use of this source
+// code is governed by a license that can be found in the License file.
+
+package plug
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+
+	"zikichombo.org/sound"
+)
+
+// Dot writes a Graphviz DOT description of g to w, for visual debugging.
+// Nodes are labeled with their index, processor type and input and output
+// forms.  Edges between nodes are labeled with the channels they map, and
+// sources and sinks outside the graph are shown as endpoints: boxes for
+// sources and sinks, and a dashed edge for secondary and monitor outputs.
+// Outputs created with Output and not consumed by a node of the graph are
+// shown as "output" endpoints.
+func (g *Graph) Dot(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, "digraph plug {\n")
+	fmt.Fprintf(bw, "\trankdir=LR;\n")
+	ids := make(map[*node]int, len(g.nodes))
+	for i, n := range g.nodes {
+		if nd, ok := n.(*node); ok {
+			ids[nd] = i
+		}
+	}
+	consumed := make(map[sound.Source]bool)
+	for _, n := range g.nodes {
+		nd, ok := n.(*node)
+		if !ok {
+			continue
+		}
+		nd.mu.Lock()
+		for i := range nd.iPkts {
+			if ns, ok := nd.iPkts[i].src.(*nodeSource); ok {
+				consumed[ns.Source] = true
+			}
+		}
+		nd.mu.Unlock()
+	}
+	for i, n := range g.nodes {
+		nd, ok := n.(*node)
+		if !ok {
+			fmt.Fprintf(bw, "\tn%d [label=%q];\n", i, fmt.Sprintf("%d: %T", i, n))
+			continue
+		}
+		nd.dot(bw, i, ids, consumed)
+	}
+	fmt.Fprintf(bw, "}\n")
+	return bw.Flush()
+}
+
+// dot writes the DOT description of node n with index i and its edges to
+// w.
+func (n *node) dot(w io.Writer, i int, ids map[*node]int, consumed map[sound.Source]bool) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	fmt.Fprintf(w, "\tn%d [label=%q];\n", i,
+		fmt.Sprintf("%d: %T\n%s -> %s", i, n.proc, formLabel(n.iForm), formLabel(n.oForm)))
+	for k := range n.iPkts {
+		pkt := &n.iPkts[k]
+		to := chanLabel(pkt.cmap.i)
+		if ns, ok := pkt.src.(*nodeSource); ok {
+			if j, ok := ids[ns.n]; ok {
+				from := ns.cs
+				if len(from) == 0 {
+					from = chanRange(0, ns.n.oForm.Channels())
+				}
+				fmt.Fprintf(w, "\tn%d -> n%d [label=%q];\n", j, i, chanLabel(from)+" > "+to)
+				continue
+			}
+		}
+		fmt.Fprintf(w, "\tn%d_in%d [shape=box,label=%q];\n", i, k, fmt.Sprintf("%T\n%s", pkt.src, formLabel(pkt.src)))
+		fmt.Fprintf(w, "\tn%d_in%d -> n%d [label=%q];\n", i, k, i, "> "+to)
+	}
+	for k := range n.oPkts {
+		pkt := &n.oPkts[k]
+		if pkt.src != nil && consumed[pkt.src] {
+			continue
+		}
+		from := chanLabel(pkt.cmap.i)
+		label := "output"
+		if pkt.src == nil {
+			label = fmt.Sprintf("%T\n%s", pkt.snk, formLabel(pkt.snk))
+		}
+		fmt.Fprintf(w, "\tn%d_out%d [shape=box,label=%q];\n", i, k, label)
+		fmt.Fprintf(w, "\tn%d -> n%d_out%d [label=%q];\n", i, i, k, from+" >")
+	}
+	for k, q := range n.queues {
+		if n.outQueueIndex(q) != -1 {
+			continue
+		}
+		fmt.Fprintf(w, "\tn%d_sec%d [shape=box,label=%q];\n", i, k, fmt.Sprintf("%T\n%s", q.snk, formLabel(q.snk)))
+		fmt.Fprintf(w, "\tn%d -> n%d_sec%d [style=dashed,label=%q];\n", i, i, k, chanLabel(q.slots[0].cmap.i)+" >")
+	}
+	for k, q := range n.monitors {
+		fmt.Fprintf(w, "\tn%d_mon%d [shape=box,label=%q];\n", i, k, fmt.Sprintf("%T\n%s", q.snk, formLabel(q.snk)))
+		fmt.Fprintf(w, "\tn%d -> n%d_mon%d [style=dashed,label=%q];\n", i, i, k, "monitor "+chanLabel(q.slots[0].cmap.i)+" >")
+	}
+}
+
+func formLabel(f sound.Form) string {
+	return fmt.Sprintf("%d ch @ %s", f.Channels(), f.SampleRate())
+}
+
+func chanLabel(cs []int) string {
+	return fmt.Sprint(cs)
+}
@@ -0,0 +1,59 @@
+// Copyright 2018 The ZikiChombo Authors. All rights reserved.  Use of this source
+// code is governed by a license that can be found in the License file.
+
+package plug
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"zikichombo.org/sound"
+)
+
+// dotGolden is the output of Graph.Dot for the graph of TestGraphDot, with
+// $rate standing for the sample rate.
+const dotGolden = `digraph plug {
+	rankdir=LR;
+	n0 [label="0: *plug.proc\n1 ch @ $rate -> 1 ch @ $rate"];
+	n0_in0 [shape=box,label="*plug.ramp\n1 ch @ $rate"];
+	n0_in0 -> n0 [label="> [0]"];
+	n1 [label="1: *plug.proc\n2 ch @ $rate -> 2 ch @ $rate"];
+	n0 -> n1 [label="[0] > [0]"];
+	n0 -> n1 [label="[0] > [1]"];
+	n1_out0 [shape=box,label="*plug.countSink\n2 ch @ $rate"];
+	n1 -> n1_out0 [label="[0 1] >"];
+	n1_out1 [shape=box,label="output"];
+	n1 -> n1_out1 [label="[1] >"];
+	n1_sec0 [shape=box,label="*plug.countSink\n1 ch @ $rate"];
+	n1 -> n1_sec0 [style=dashed,label="[0] >"];
+	n1_mon0 [shape=box,label="*plug.countSink\n1 ch @ $rate"];
+	n1 -> n1_mon0 [style=dashed,label="monitor [1] >"];
+}
+`
+
+func TestGraphDot(t *testing.T) {
+	mono, stereo := sound.MonoCd(), sound.StereoCd()
+	var g Graph
+	u0 := g.New(mono, mono, PassThrough)
+	u1 := g.New(stereo, stereo, PassThrough)
+	g.Connect(u0, nil, u1, []int{0})
+	g.Connect(u0, nil, u1, []int{1})
+	u0.SetInput(&ramp{Form: mono, n: 10})
+	u1.AddOutput(&countSink{Form: stereo})
+	u1.Output(1)
+	if _, err := u1.AddSecondaryOutput(&countSink{Form: mono}, 2, 0); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := u1.AddMonitor(&countSink{Form: mono}, 1); err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if err := g.Dot(&buf); err != nil {
+		t.Fatal(err)
+	}
+	exp := strings.Replace(dotGolden, "$rate", mono.SampleRate().String(), -1)
+	if got := buf.String(); got != exp {
+		t.Errorf("got\n%s\nnot\n%s", got, exp)
+	}
+}
@@ -0,0 +1,188 @@
+// Copyright 2018 The ZikiChombo Authors. All rights reserved.  Use of this source
+// code is governed by a license that can be found in the License file.
+
+package plug
+
+import (
+	"fmt"
+	"math/cmplx"
+	"sync"
+)
+
+const (
+	driftWindow = 4096
+	driftHist   = 16
+)
+
+// DriftEstimator is a FullMode analyzer which estimates the clock drift
+// between two input channels, for example the same signal captured by two
+// devices with independent clocks.  Channel 0 is the reference and channel 1
+// is the drifting signal.  The DriftEstimator passes its input through
+// unchanged.
+//
+// At regular intervals the lag between the two channels is measured by cross
+// correlation, and the drift is estimated by a linear fit of the lag over
+// time.  The result is a correction ratio: the number of frames of channel 1
+// which correspond to one frame of channel 0.  Resampling channel 1 by the
+// inverse of the ratio aligns it with channel 0.
+//
+// The lags which may be measured are limited to half of 4096 frames, so the
+// signals should be roughly aligned to begin with.
+type DriftEstimator struct {
+	mu       sync.Mutex
+	interval int
+	ratio    float64
+	lag      float64
+	pos      int64
+	next     int64
+	bufs     [2][]float64
+	ts, lags []float64
+	xa, xb   []complex128
+	c        chan float64
+}
+
+// NewDriftEstimator creates a new DriftEstimator which measures the lag every
+// interval frames.
+func NewDriftEstimator(interval int) *DriftEstimator {
+	if interval < driftWindow {
+		interval = driftWindow
+	}
+	return &DriftEstimator{
+		interval: interval,
+		ratio:    1,
+		next:     driftWindow,
+		xa:       make([]complex128, 2*driftWindow),
+		xb:       make([]complex128, 2*driftWindow),
+		c:        make(chan float64, 1)}
+}
+
+// Ratio returns the current correction ratio estimate.
+func (d *DriftEstimator) Ratio() float64 {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.ratio
+}
+
+// Lag returns the last measured lag of channel 1 w.r.t. channel 0, in
+// frames.
+func (d *DriftEstimator) Lag() float64 {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.lag
+}
+
+// Ratios returns a channel on which every new correction ratio estimate is
+// made available.  Only the latest estimate is kept, so a slow reader does
+// not hold up processing.
+func (d *DriftEstimator) Ratios() <-chan float64 {
+	return d.c
+}
+
+// ChannelMode implements Processor.
+func (d *DriftEstimator) ChannelMode() ChannelMode {
+	return FullMode
+}
+
+// NextFrames implements Processor.
+func (d *DriftEstimator) NextFrames() (int, int) {
+	return DefaultInFrames, DefaultInFrames
+}
+
+// Process implements Processor.
+func (d *DriftEstimator) Process(dst, src *Block) error {
+	if src.Channels != 2 {
+		return fmt.Errorf("drift estimator: need 2 channels, got %d", src.Channels)
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	N := src.Frames
+	copy(dst.Samples[:2*N], src.Samples[:2*N])
+	dst.Frames = N
+	for f := 0; f < N; {
+		end := N
+		if rem := int(d.next - d.pos); f+rem < end {
+			end = f + rem
+		}
+		for c := range d.bufs {
+			b := append(d.bufs[c], src.Samples[c*N+f:c*N+end]...)
+			if len(b) > driftWindow {
+				b = b[:copy(b, b[len(b)-driftWindow:])]
+			}
+			d.bufs[c] = b
+		}
+		d.pos += int64(end - f)
+		f = end
+		if d.pos == d.next {
+			d.measure()
+			d.next += int64(d.interval)
+		}
+	}
+	return nil
+}
+
+func (d *DriftEstimator) measure() {
+	W := driftWindow
+	for i := range d.xa {
+		d.xa[i], d.xb[i] = 0, 0
+	}
+	for i := 0; i < W; i++ {
+		d.xa[i] = complex(d.bufs[0][i], 0)
+		d.xb[i] = complex(d.bufs[1][i], 0)
+	}
+	fft(d.xa, false)
+	fft(d.xb, false)
+	for i := range d.xa {
+		d.xa[i] = d.xb[i] * cmplx.Conj(d.xa[i])
+	}
+	fft(d.xa, true)
+	// lag l of channel 1 is at index l mod 2W.
+	best, bi := 0.0, 0
+	for l := -W / 2; l <= W/2; l++ {
+		if v := real(d.xa[(l+2*W)%(2*W)]); v > best {
+			best, bi = v, l
+		}
+	}
+	if best <= 0 {
+		return
+	}
+	// parabolic interpolation for sub-frame precision.
+	y0 := real(d.xa[(bi-1+2*W)%(2*W)])
+	y2 := real(d.xa[(bi+1+2*W)%(2*W)])
+	lag := float64(bi)
+	if den := y0 - 2*best + y2; den != 0 {
+		lag += 0.5 * (y0 - y2) / den
+	}
+	d.lag = lag
+	d.ts = append(d.ts, float64(d.pos))
+	d.lags = append(d.lags, lag)
+	if len(d.ts) > driftHist {
+		d.ts = d.ts[1:]
+		d.lags = d.lags[1:]
+	}
+	if len(d.ts) < 2 {
+		return
+	}
+	d.ratio = 1 + slope(d.ts, d.lags)
+	select {
+	case <-d.c:
+	default:
+	}
+	d.c <- d.ratio
+}
+
+// slope gives the least squares slope of ys w.r.t. xs.
+func slope(xs, ys []float64) float64 {
+	n := float64(len(xs))
+	var sx, sy, sxx, sxy float64
+	for i, x := range xs {
+		sx += x
+		sy += ys[i]
+		sxx += x * x
+		sxy += x * ys[i]
+	}
+	den := n*sxx - sx*sx
+	if den == 0 {
+		return 0
+	}
+	return (n*sxy - sx*sy) / den
+}
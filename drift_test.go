@@ -0,0 +1,74 @@
+// Copyright 2018 The ZikiChombo Authors. All rights reserved.  Use of this source
+// code is governed by a license that can be found in the License file.
+
+package plug
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+
+	"zikichombo.org/sound/freq"
+)
+
+func TestDriftEstimator(t *testing.T) {
+	const ppm = 100
+	sr := 48000 * freq.Hertz
+	rate := sr.Float64()
+	// a band limited signal, which may be evaluated between frames.
+	rnd := rand.New(rand.NewSource(1))
+	var hz, ph [32]float64
+	for i := range hz {
+		hz[i] = 100 + 4900*rnd.Float64()
+		ph[i] = 2 * math.Pi * rnd.Float64()
+	}
+	sig := func(t float64) float64 {
+		v := 0.0
+		for i := range hz {
+			v += math.Sin(2*math.Pi*hz[i]*t/rate + ph[i])
+		}
+		return v / float64(len(hz))
+	}
+	d := NewDriftEstimator(24000)
+	N := 1024
+	var pos int
+	for pos < 10*48000 {
+		src := &Block{SampleRate: sr, Channels: 2, Frames: N, Samples: make([]float64, 2*N)}
+		for f := 0; f < N; f++ {
+			n := float64(pos + f)
+			src.Samples[f] = sig(n)
+			// channel 1 runs slow by ppm.
+			src.Samples[N+f] = sig(n * (1 - ppm*1e-6))
+		}
+		dst := &Block{SampleRate: sr, Channels: 2, Samples: make([]float64, 2*N)}
+		if err := d.Process(dst, src); err != nil {
+			t.Fatal(err)
+		}
+		for i, v := range src.Samples {
+			if dst.Samples[i] != v {
+				t.Fatalf("sample %d: got %g not %g", i, dst.Samples[i], v)
+			}
+		}
+		pos += N
+	}
+	if got := (d.Ratio() - 1) * 1e6; math.Abs(got-ppm) > 5 {
+		t.Errorf("got drift of %.2fppm not %dppm", got, ppm)
+	}
+	// the last lag is measured over the window ending at the last interval.
+	last := driftWindow + (pos-driftWindow)/24000*24000
+	if exp := float64(last-driftWindow/2) * ppm * 1e-6; math.Abs(d.Lag()-exp) > 1 {
+		t.Errorf("got lag %.2f, expected about %.2f", d.Lag(), exp)
+	}
+	select {
+	case r := <-d.Ratios():
+		if r != d.Ratio() {
+			t.Errorf("got ratio %f from Ratios not %f", r, d.Ratio())
+		}
+	default:
+		t.Error("no ratio available from Ratios")
+	}
+	mono := &Block{SampleRate: sr, Channels: 1, Frames: N, Samples: make([]float64, N)}
+	if err := d.Process(mono, mono); err == nil {
+		t.Error("estimated the drift of a mono input")
+	}
+}
@@ -0,0 +1,277 @@
+// Copyright 2018 The ZikiChombo Authors. All rights reserved.  Use of this source
+// code is governed by a license that can be found in the License file.
+
+package plug
+
+import (
+	"fmt"
+	"math"
+
+	"zikichombo.org/sound"
+)
+
+// timeCoef returns the coefficient of a one pole smoother with time
+// constant ms milliseconds at sample rate rate.
+func timeCoef(ms, rate float64) float64 {
+	return math.Exp(-1 / (ms * 1e-3 * rate))
+}
+
+// ckDynamics checks that a dynamics processor may map in to out.
+func ckDynamics(what string, in, out sound.Form) error {
+	if in.Channels() != out.Channels() {
+		return fmt.Errorf("%s: cannot map %d channels to %d", what, in.Channels(), out.Channels())
+	}
+	return nil
+}
+
+// Compressor is a FullMode feed-forward compressor.  The level of the
+// input, the peak over all channels so that the stereo image is kept, is
+// reduced above the threshold by the ratio, with a gain reduction which
+// follows the level with the attack and release times.  The makeup gain is
+// then applied.
+//
+// The threshold and makeup in dB, the ratio, and the attack and release
+// times in ms are the parameters "threshold", "makeup", "ratio", "attack"
+// and "release".
+type Compressor struct {
+	ParamTable
+	threshold, ratio, attack, release, makeup float64
+
+	rate     float64 // of the coefficients, or 0 if they changed
+	att, rel float64 // coefficients
+	env      float64 // gain reduction in dB
+}
+
+// NewCompressor creates a new Compressor with threshold in dB, ratio, and
+// attack and release times in ms, without makeup gain.
+func NewCompressor(threshold, ratio, attack, release float64) *Compressor {
+	c := &Compressor{threshold: threshold, ratio: ratio, attack: attack, release: release}
+	dirty := func(p *float64) func(float64) {
+		return func(v float64) {
+			*p = v
+			c.rate = 0
+		}
+	}
+	get := func(p *float64) func() float64 {
+		return func() float64 { return *p }
+	}
+	c.Add(ParamInfo{Name: "threshold", Unit: "dB", Min: -96, Max: 0, Default: threshold}, get(&c.threshold), dirty(&c.threshold))
+	c.Add(ParamInfo{Name: "ratio", Min: 1, Max: 100, Default: ratio}, get(&c.ratio), dirty(&c.ratio))
+	c.Add(ParamInfo{Name: "attack", Unit: "ms", Min: 0.01, Max: 1000, Default: attack}, get(&c.attack), dirty(&c.attack))
+	c.Add(ParamInfo{Name: "release", Unit: "ms", Min: 1, Max: 10000, Default: release}, get(&c.release), dirty(&c.release))
+	c.Add(ParamInfo{Name: "makeup", Unit: "dB", Min: 0, Max: 48}, get(&c.makeup), dirty(&c.makeup))
+	return c
+}
+
+// SetMakeup sets the makeup gain in dB.
+func (c *Compressor) SetMakeup(db float64) {
+	c.makeup = db
+}
+
+// Start implements ProcessorStarter.
+func (c *Compressor) Start(in, out sound.Form) error {
+	if err := ckDynamics("compressor", in, out); err != nil {
+		return err
+	}
+	c.coefs(in.SampleRate().Float64())
+	return nil
+}
+
+func (c *Compressor) coefs(rate float64) {
+	c.att = timeCoef(c.attack, rate)
+	c.rel = timeCoef(c.release, rate)
+	c.rate = rate
+}
+
+// Reset implements Resetter.
+func (c *Compressor) Reset() {
+	c.env = 0
+}
+
+// ChannelMode implements Processor.
+func (c *Compressor) ChannelMode() ChannelMode {
+	return FullMode
+}
+
+// NextFrames implements Processor.
+func (c *Compressor) NextFrames() (int, int) {
+	return DefaultInFrames, DefaultInFrames
+}
+
+// Process implements Processor.
+func (c *Compressor) Process(dst, src *Block) error {
+	if rate := src.SampleRate.Float64(); rate != c.rate {
+		c.coefs(rate)
+	}
+	N, nC := src.Frames, src.Channels
+	slope := 1 - 1/c.ratio
+	for i := 0; i < N; i++ {
+		peak := 0.0
+		for ch := 0; ch < nC; ch++ {
+			peak = math.Max(peak, math.Abs(src.Samples[ch*N+i]))
+		}
+		gr := 0.0
+		if over := 20*math.Log10(peak+1e-30) - c.threshold; over > 0 {
+			gr = over * slope
+		}
+		k := c.rel
+		if gr > c.env {
+			k = c.att
+		}
+		c.env = k*c.env + (1-k)*gr
+		g := math.Pow(10, (c.makeup-c.env)/20)
+		for ch := 0; ch < nC; ch++ {
+			dst.Samples[ch*N+i] = g * src.Samples[ch*N+i]
+		}
+	}
+	dst.Frames = N
+	return nil
+}
+
+// Limiter is a FullMode brickwall limiter: no output sample exceeds the
+// ceiling.  The Limiter looks ahead at its input to reduce the gain
+// smoothly before peaks, and so delays its input by the lookahead, which
+// it reports as its latency.  Once the input ends, the lookahead is
+// flushed as the tail of the Limiter.
+//
+// The ceiling in dB and the release time in ms are the parameters
+// "ceiling" and "release".
+type Limiter struct {
+	ParamTable
+	ceiling, release float64
+	L                int // lookahead, in frames
+
+	rate   float64 // of rel, or 0 if release changed
+	rel    float64
+	gwin   []float64 // required gains of the last L+1 frames, circular
+	box    []float64 // released gains of the last L frames, circular
+	i      int       // position in gwin and box
+	sum    float64   // of box
+	r      float64   // released gain
+	lines  []*delayLine
+	gains  []float64
+	zeros  Block
+	primed bool // whether input was processed since the last flush
+}
+
+// NewLimiter creates a new Limiter with ceiling in dB, looking ahead
+// lookahead frames, at most DefaultInFrames, and with release time
+// release ms.
+func NewLimiter(ceiling float64, lookahead int, release float64) *Limiter {
+	if lookahead < 1 {
+		lookahead = 1
+	}
+	if lookahead > DefaultInFrames {
+		lookahead = DefaultInFrames
+	}
+	l := &Limiter{ceiling: ceiling, release: release, L: lookahead}
+	l.Add(ParamInfo{Name: "ceiling", Unit: "dB", Min: -48, Max: 0, Default: ceiling},
+		func() float64 { return l.ceiling }, func(v float64) { l.ceiling = v })
+	l.Add(ParamInfo{Name: "release", Unit: "ms", Min: 1, Max: 10000, Default: release},
+		func() float64 { return l.release }, func(v float64) { l.release = v; l.rate = 0 })
+	l.Reset()
+	return l
+}
+
+// Start implements ProcessorStarter.
+func (l *Limiter) Start(in, out sound.Form) error {
+	if err := ckDynamics("limiter", in, out); err != nil {
+		return err
+	}
+	l.rel = timeCoef(l.release, in.SampleRate().Float64())
+	l.rate = in.SampleRate().Float64()
+	return nil
+}
+
+// Latency implements LatencyReporter.
+func (l *Limiter) Latency() int {
+	return l.L
+}
+
+// Tail implements Tailer.
+func (l *Limiter) Tail() bool {
+	return l.primed
+}
+
+// Reset implements Resetter.
+func (l *Limiter) Reset() {
+	l.gwin = make([]float64, l.L+1)
+	l.box = make([]float64, l.L)
+	for i := range l.gwin {
+		l.gwin[i] = 1
+	}
+	for i := range l.box {
+		l.box[i] = 1
+	}
+	l.i = 0
+	l.sum = float64(l.L)
+	l.r = 1
+	l.lines = nil
+	l.primed = false
+}
+
+// ChannelMode implements Processor.
+func (l *Limiter) ChannelMode() ChannelMode {
+	return FullMode
+}
+
+// NextFrames implements Processor.
+func (l *Limiter) NextFrames() (int, int) {
+	return DefaultInFrames, DefaultInFrames
+}
+
+// Process implements Processor.
+func (l *Limiter) Process(dst, src *Block) error {
+	if rate := src.SampleRate.Float64(); rate != l.rate {
+		l.rel = timeCoef(l.release, rate)
+		l.rate = rate
+	}
+	if src.Frames == 0 {
+		// flush the lookahead with silence.
+		z := &l.zeros
+		z.SampleRate, z.Channels, z.Frames = src.SampleRate, src.Channels, l.L
+		z.Samples = buffer(z.Samples, src.Channels, l.L)
+		zero(z.Samples)
+		src = z
+		l.primed = false
+	} else {
+		l.primed = true
+	}
+	N, nC := src.Frames, src.Channels
+	for len(l.lines) < nC {
+		l.lines = append(l.lines, newDelayLine(l.L))
+	}
+	ceil := math.Pow(10, l.ceiling/20)
+	l.gains = buffer(l.gains, 1, N)
+	W := len(l.gwin)
+	for i := 0; i < N; i++ {
+		peak := 0.0
+		for ch := 0; ch < nC; ch++ {
+			peak = math.Max(peak, math.Abs(src.Samples[ch*N+i]))
+		}
+		req := 1.0
+		if peak > ceil {
+			req = ceil / peak
+		}
+		l.gwin[l.i%W] = req
+		h := 1.0
+		for _, g := range l.gwin {
+			h = math.Min(h, g)
+		}
+		l.r = math.Min(h, l.r+(1-l.r)*(1-l.rel))
+		b := l.i % l.L
+		l.sum += l.r - l.box[b]
+		l.box[b] = l.r
+		l.gains[i] = l.sum / float64(l.L)
+		l.i++
+	}
+	for ch := 0; ch < nC; ch++ {
+		d := dst.Samples[ch*N : (ch+1)*N]
+		l.lines[ch].run(d, src.Samples[ch*N:(ch+1)*N])
+		for i, g := range l.gains {
+			d[i] *= g
+		}
+	}
+	dst.Frames = N
+	return nil
+}
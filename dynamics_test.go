@@ -0,0 +1,70 @@
+// Copyright 2018 The ZikiChombo Authors. All rights reserved.  Use of this source
+// code is governed by a license that can be found in the License file.
+
+package plug
+
+import (
+	"math"
+	"testing"
+
+	"zikichombo.org/sound"
+)
+
+func TestCompressor(t *testing.T) {
+	c := NewCompressor(-20, 4, 1, 50)
+	N := 1024
+	src := &Block{SampleRate: sound.MonoCd().SampleRate(), Channels: 1, Frames: N, Samples: make([]float64, N)}
+	for i := range src.Samples {
+		src.Samples[i] = 1
+	}
+	dst := &Block{Channels: 1, Samples: make([]float64, N)}
+	for i := 0; i < 4; i++ {
+		if err := c.Process(dst, src); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// 20dB over the threshold at 4:1 is reduced by 15dB.
+	if exp := math.Pow(10, -15.0/20); math.Abs(dst.Samples[N-1]-exp) > 1e-6 {
+		t.Errorf("got %f not %f", dst.Samples[N-1], exp)
+	}
+	if err := c.Set("makeup", 15); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Process(dst, src); err != nil {
+		t.Fatal(err)
+	}
+	if math.Abs(dst.Samples[N-1]-1) > 1e-6 {
+		t.Errorf("got %f with makeup", dst.Samples[N-1])
+	}
+	if err := c.Start(sound.MonoCd(), sound.StereoCd()); err == nil {
+		t.Errorf("started mono to stereo")
+	}
+}
+
+func TestLimiter(t *testing.T) {
+	mono := sound.MonoCd()
+	var g Graph
+	l := NewLimiter(-6, 64, 20)
+	u := g.New(mono, mono, l)
+	u.SetInput(&ramp{Form: mono, n: 1500})
+	snk := &capture{Form: mono}
+	u.AddOutput(snk)
+	if err := u.Run(); err != nil {
+		t.Fatal(err)
+	}
+	if lat, err := g.Latency(u); err != nil || lat != 64 {
+		t.Errorf("got latency %d, %v", lat, err)
+	}
+	if snk.frames != 1500+64 {
+		t.Errorf("got %d frames", snk.frames)
+	}
+	ceil := math.Pow(10, -6.0/20)
+	for i, v := range snk.mix[:snk.frames] {
+		if math.Abs(v) > ceil+1e-9 {
+			t.Fatalf("frame %d: got %f over %f", i, v, ceil)
+		}
+	}
+	if v := snk.mix[snk.frames-1]; v < 0.9*ceil {
+		t.Errorf("last frame limited to %f", v)
+	}
+}
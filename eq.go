@@ -0,0 +1,120 @@
+// Copyright 2018 The ZikiChombo Authors. All rights reserved.  Use of this source
+// code is governed by a license that can be found in the License file.
+
+package plug
+
+import "fmt"
+
+// Band is a band of an EQ: a peaking filter boosting or cutting, by Gain
+// dB, the frequencies around Freq Hz in a bandwidth given by Q.
+type Band struct {
+	Freq, Gain, Q float64
+}
+
+// EQ is a MonoMode parametric equalizer made of bands applied in series.
+// The IO running an EQ processes every channel with its own filter state,
+// and the parameters are shared by all channels.
+//
+// The frequency, gain and Q of band i are the parameters "i.freq",
+// "i.gain" and "i.q", for example "0.gain" for the first band.
+type EQ struct {
+	ParamTable
+	bands []Band
+	bqs   []biquad
+	rate  float64 // of bqs, or 0 if bands changed
+	chans []*eqChannel
+}
+
+type eqChannel struct {
+	eq *EQ
+	st []bqState
+}
+
+// NewEQ creates a new EQ with the given bands.
+func NewEQ(bands ...Band) *EQ {
+	e := &EQ{bands: append([]Band(nil), bands...), bqs: make([]biquad, len(bands))}
+	for i := range e.bands {
+		b := &e.bands[i]
+		e.Add(ParamInfo{Name: fmt.Sprintf("%d.freq", i), Unit: "Hz", Min: 1, Max: 100000, Default: b.Freq},
+			func() float64 { return b.Freq }, func(v float64) { b.Freq = v; e.rate = 0 })
+		e.Add(ParamInfo{Name: fmt.Sprintf("%d.gain", i), Unit: "dB", Min: -48, Max: 48, Default: b.Gain},
+			func() float64 { return b.Gain }, func(v float64) { b.Gain = v; e.rate = 0 })
+		e.Add(ParamInfo{Name: fmt.Sprintf("%d.q", i), Min: 0.01, Max: 100, Default: b.Q},
+			func() float64 { return b.Q }, func(v float64) { b.Q = v; e.rate = 0 })
+	}
+	return e
+}
+
+// Bands returns the number of bands.
+func (e *EQ) Bands() int {
+	return len(e.bands)
+}
+
+// Band returns band i.
+func (e *EQ) Band(i int) Band {
+	return e.bands[i]
+}
+
+// SetBand sets band i to b.
+func (e *EQ) SetBand(i int, b Band) {
+	e.bands[i] = b
+	e.rate = 0
+}
+
+// Reset implements Resetter.
+func (e *EQ) Reset() {
+	for _, ch := range e.chans {
+		for i := range ch.st {
+			ch.st[i] = bqState{}
+		}
+	}
+}
+
+// ChannelMode implements Processor.
+func (e *EQ) ChannelMode() ChannelMode {
+	return MonoMode
+}
+
+// NextFrames implements Processor.
+func (e *EQ) NextFrames() (int, int) {
+	return DefaultInFrames, DefaultInFrames
+}
+
+// Process implements Processor, processing channel 0.
+func (e *EQ) Process(dst, src *Block) error {
+	return e.channel(0).Process(dst, src)
+}
+
+// channel returns the processor of channel c, with its own state.
+func (e *EQ) channel(c int) Processor {
+	for len(e.chans) <= c {
+		e.chans = append(e.chans, &eqChannel{eq: e, st: make([]bqState, len(e.bands))})
+	}
+	return e.chans[c]
+}
+
+func (ch *eqChannel) ChannelMode() ChannelMode {
+	return MonoMode
+}
+
+func (ch *eqChannel) NextFrames() (int, int) {
+	return ch.eq.NextFrames()
+}
+
+func (ch *eqChannel) Process(dst, src *Block) error {
+	e := ch.eq
+	if rate := src.SampleRate.Float64(); rate != e.rate {
+		for i, b := range e.bands {
+			e.bqs[i] = peakingBQ(rate, b.Freq, b.Q, b.Gain)
+		}
+		e.rate = rate
+	}
+	N := src.Frames
+	d := dst.Samples[:N]
+	copy(d, src.Samples[:N])
+	for i := range e.bqs {
+		e.bqs[i].runSlice(&ch.st[i], d, d)
+	}
+	dst.Frames = N
+	return nil
+}
@@ -0,0 +1,50 @@
+// Copyright 2018 The ZikiChombo Authors. All rights reserved.  Use of this source
+// code is governed by a license that can be found in the License file.
+
+package plug
+
+import (
+	"math"
+	"testing"
+
+	"zikichombo.org/sound"
+)
+
+func TestEQ(t *testing.T) {
+	bands := []Band{{Freq: 100, Gain: 6, Q: 1}, {Freq: 5000, Gain: -12, Q: 2}}
+	e := NewEQ(bands...)
+	if g := 20 * math.Log10(response(e, 100)); math.Abs(g-6) > 0.2 {
+		t.Errorf("got %.1fdB at 100Hz", g)
+	}
+	if g := 20 * math.Log10(response(NewEQ(bands...), 5000)); math.Abs(g+12) > 0.2 {
+		t.Errorf("got %.1fdB at 5kHz", g)
+	}
+	if err := e.Set("1.gain", 0); err != nil {
+		t.Fatal(err)
+	}
+	if e.Band(1).Gain != 0 {
+		t.Errorf("got band %+v", e.Band(1))
+	}
+
+	// channels with the same input have the same output.
+	mono := sound.MonoCd()
+	stereo := sound.StereoCd()
+	u := New(mono, mono, NewEQ(bands...))
+	u.SetInput(&tone{Form: mono, end: 5000})
+	want := &capture{Form: mono}
+	u.AddOutput(want)
+	u.Run()
+	u = New(stereo, stereo, NewEQ(bands...))
+	u.SetInput(&tone{Form: mono, end: 5000}, 0)
+	u.SetInput(&tone{Form: mono, end: 5000}, 1)
+	got := &capture{Form: stereo}
+	u.AddOutput(got)
+	if err := u.Run(); err != nil {
+		t.Fatal(err)
+	}
+	for i, v := range want.mix {
+		if math.Abs(got.mix[i]-v) > 1e-12 {
+			t.Fatalf("frame %d: got %f not %f", i, got.mix[i], v)
+		}
+	}
+}
@@ -0,0 +1,50 @@
+// Copyright 2018 The ZikiChombo Authors. All rights reserved.  Use of this source
+// code is governed by a license that can be found in the License file.
+
+package plug
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Event describes something noteworthy which happened in a running IO.
+// Events are delivered to the function registered with Notifier.OnEvent.
+type Event interface {
+	fmt.Stringer
+}
+
+// ErrChannelsChanged may be returned by the Receive method of an input
+// source whose number of channels has changed, for example an audio
+// interface which has been reconfigured.  The source should return
+// ErrChannelsChanged with no frames, and report the new number of channels
+// from Channels() thereafter.
+//
+// An IO receiving ErrChannelsChanged does not fail.  Instead, it
+// renegotiates the input: channels which are no longer provided by the
+// source are filled with silence and new channels of the source which are
+// not mapped to the IO are ignored.  A ChannelsChanged event is then
+// delivered and the input is received again.
+var ErrChannelsChanged = errors.New("source channels changed")
+
+// ChannelsChanged is the event delivered when an input source has changed
+// its number of channels.
+type ChannelsChanged struct {
+	Input    int // index of the input in the order of calls to SetInput.
+	Old, New int // the number of channels of the source before and after.
+}
+
+func (e *ChannelsChanged) String() string {
+	return fmt.Sprintf("input %d channels changed from %d to %d", e.Input, e.Old, e.New)
+}
+
+// OutputDetached is the event delivered when an output has failed and has
+// been detached.  See OutputController.SetDetachOnError.
+type OutputDetached struct {
+	Output int // index of the output in the order of calls to AddOutput and Output, or -1 for a secondary or monitor output.
+	Err    error
+}
+
+func (e *OutputDetached) String() string {
+	return fmt.Sprintf("output %d detached: %s", e.Output, e.Err)
+}
@@ -0,0 +1,79 @@
+// Copyright 2018 The ZikiChombo Authors. All rights reserved.  Use of this source
+// code is governed by a license that can be found in the License file.
+
+package plug
+
+import (
+	"io"
+	"testing"
+
+	"zikichombo.org/sound"
+)
+
+// rechan is a source of n frames of value 1 per channel, which then
+// changes to mono and gives n frames of value 2.
+type rechan struct {
+	sound.Form
+	n, pos  int
+	changed bool
+}
+
+func (s *rechan) Close() error { return nil }
+
+func (s *rechan) Receive(d []float64) (int, error) {
+	if s.pos == s.n {
+		if s.changed {
+			return 0, io.EOF
+		}
+		s.changed = true
+		s.pos = 0
+		s.Form = sound.MonoCd()
+		return 0, ErrChannelsChanged
+	}
+	v := 1.0
+	if s.changed {
+		v = 2
+	}
+	frms := len(d) / s.Channels()
+	if frms > s.n-s.pos {
+		frms = s.n - s.pos
+	}
+	for i := range d[:frms*s.Channels()] {
+		d[i] = v
+	}
+	s.pos += frms
+	return frms, nil
+}
+
+func TestIOChannelsChanged(t *testing.T) {
+	stereo := sound.StereoCd()
+	u := New(stereo, stereo, PassThrough)
+	u.SetInput(&rechan{Form: stereo, n: 1000})
+	var evs []*ChannelsChanged
+	u.OnEvent(func(e Event) {
+		if cc, ok := e.(*ChannelsChanged); ok {
+			evs = append(evs, cc)
+		}
+	})
+	rec := &record{Form: stereo}
+	u.AddOutput(rec)
+	if err := u.Run(); err != nil {
+		t.Fatal(err)
+	}
+	if len(evs) != 1 || *evs[0] != (ChannelsChanged{Input: 0, Old: 2, New: 1}) {
+		t.Fatalf("got events %v", evs)
+	}
+	if len(rec.chs[0]) != 2000 {
+		t.Fatalf("got %d frames not 2000", len(rec.chs[0]))
+	}
+	for i := range rec.chs[0] {
+		l, r := 1.0, 1.0
+		if i >= 1000 {
+			// the channel no longer provided is silent.
+			l, r = 2, 0
+		}
+		if rec.chs[0][i] != l || rec.chs[1][i] != r {
+			t.Fatalf("frame %d: got %g %g not %g %g", i, rec.chs[0][i], rec.chs[1][i], l, r)
+		}
+	}
+}
@@ -0,0 +1,55 @@
+// Copyright 2018 The ZikiChombo Authors. All rights reserved.  Use of this source
+// code is governed by a license that can be found in the License file.
+
+package plug
+
+import (
+	"math"
+	"math/cmplx"
+)
+
+// fft computes in place the discrete Fourier transform of x, or its
+// inverse (scaled by 1/len(x)) if inv is true.  len(x) must be a power
+// of 2.
+func fft(x []complex128, inv bool) {
+	N := len(x)
+	for i, j := 1, 0; i < N; i++ {
+		bit := N >> 1
+		for ; j&bit != 0; bit >>= 1 {
+			j ^= bit
+		}
+		j ^= bit
+		if i < j {
+			x[i], x[j] = x[j], x[i]
+		}
+	}
+	sign := -1.0
+	if inv {
+		sign = 1.0
+	}
+	for sz := 2; sz <= N; sz <<= 1 {
+		w := cmplx.Rect(1, sign*2*math.Pi/float64(sz))
+		half := sz / 2
+		for start := 0; start < N; start += sz {
+			wk := complex(1, 0)
+			for k := 0; k < half; k++ {
+				a := x[start+k]
+				b := x[start+k+half] * wk
+				x[start+k] = a + b
+				x[start+k+half] = a - b
+				wk *= w
+			}
+		}
+	}
+	if inv {
+		s := complex(1/float64(N), 0)
+		for i := range x {
+			x[i] *= s
+		}
+	}
+}
+
+// isPow2 returns whether n is a positive power of 2.
+func isPow2(n int) bool {
+	return n > 0 && n&(n-1) == 0
+}
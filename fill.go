@@ -0,0 +1,38 @@
+// Copyright 2018 The ZikiChombo Authors. All rights reserved.  Use of this source
+// code is governed by a license that can be found in the License file.
+
+package plug
+
+// SetSilenceFill implements InputController.
+func (n *node) SetSilenceFill(cs ...int) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if len(cs) == 0 {
+		cs = chanRange(0, len(n.icCounts))
+	}
+	if n.fill == nil {
+		n.fill = make([]bool, len(n.icCounts))
+	}
+	for _, c := range cs {
+		n.fill[c] = true
+	}
+}
+
+// filled returns whether input channel c is unconnected and filled with
+// silence.  n.mu must be held.
+func (n *node) filled(c int) bool {
+	return n.fill != nil && n.fill[c] && n.icCounts[c] == 0
+}
+
+// fillSilence zeroes the input channels of b filled with silence.  n.mu
+// must be held.
+func (n *node) fillSilence(b *Block, nFrms int) {
+	if n.fill == nil {
+		return
+	}
+	for c := range n.icCounts {
+		if n.filled(c) {
+			zero(b.Samples[c*nFrms : (c+1)*nFrms])
+		}
+	}
+}
@@ -0,0 +1,32 @@
+// Copyright 2018 The ZikiChombo Authors. All rights reserved.  Use of this source
+// code is governed by a license that can be found in the License file.
+
+package plug
+
+import (
+	"testing"
+
+	"zikichombo.org/sound"
+)
+
+func TestIOSilenceFill(t *testing.T) {
+	stereo := sound.StereoCd()
+	mono := sound.MonoCd()
+	u := New(stereo, stereo, PassThrough)
+	u.SetInput(&ramp{Form: mono, n: 4096}, 0)
+	u.AddOutput(&countSink{Form: stereo})
+	if err := u.Run(); err == nil {
+		t.Fatalf("ran with unconnected channel")
+	}
+	u = New(stereo, stereo, PassThrough)
+	u.SetInput(&ramp{Form: mono, n: 4096}, 0)
+	u.SetSilenceFill()
+	snk := &capture{Form: stereo}
+	u.AddOutput(snk)
+	if err := u.Run(); err != nil {
+		t.Fatal(err)
+	}
+	if snk.frames != 4096 || snk.mix[100] != 50 {
+		t.Errorf("got %d frames, %v", snk.frames, snk.mix[100])
+	}
+}
@@ -0,0 +1,135 @@
+// Copyright 2018 The ZikiChombo Authors. All rights reserved.  Use of this source
+// code is governed by a license that can be found in the License file.
+
+package plug
+
+import "math"
+
+// Filter is a FullMode second order (biquad) filter processor, designed
+// after the RBJ audio EQ cookbook, with a separate state for every
+// channel.
+//
+// The cutoff or center frequency in Hz, the Q and, for shelving filters,
+// the gain in dB are the parameters "freq", "q" and "gain", which may be
+// changed while running.
+type Filter struct {
+	ParamTable
+	design        func(rate, f, q, g float64) biquad
+	freq, q, gain float64
+	rate          float64 // of the coefficients
+	bq            biquad
+	st            []bqState
+}
+
+func newFilter(design func(rate, f, q, g float64) biquad, freq, q, gain float64, shelf bool) *Filter {
+	flt := &Filter{design: design, freq: freq, q: q, gain: gain}
+	flt.Add(ParamInfo{Name: "freq", Unit: "Hz", Min: 1, Max: 100000, Default: freq}, flt.Freq, flt.SetFreq)
+	flt.Add(ParamInfo{Name: "q", Min: 0.01, Max: 100, Default: q}, flt.Q, flt.SetQ)
+	if shelf {
+		flt.Add(ParamInfo{Name: "gain", Unit: "dB", Min: -48, Max: 48, Default: gain}, flt.Gain, flt.SetGain)
+	}
+	return flt
+}
+
+// LowPass creates a low pass Filter with cutoff frequency freq.
+func LowPass(freq, q float64) *Filter {
+	return newFilter(func(rate, f, q, _ float64) biquad { return lowpassBQ(rate, f, q) }, freq, q, 0, false)
+}
+
+// HighPass creates a high pass Filter with cutoff frequency freq.
+func HighPass(freq, q float64) *Filter {
+	return newFilter(func(rate, f, q, _ float64) biquad { return highpassBQ(rate, f, q) }, freq, q, 0, false)
+}
+
+// Subsonic creates a Butterworth high pass Filter removing the subsonic
+// frequencies below freq, typically 20Hz.
+func Subsonic(freq float64) *Filter {
+	return HighPass(freq, math.Sqrt2/2)
+}
+
+// BandPass creates a band pass Filter with center frequency freq and a
+// peak gain of 0dB.
+func BandPass(freq, q float64) *Filter {
+	return newFilter(func(rate, f, q, _ float64) biquad { return bandpassBQ(rate, f, q) }, freq, q, 0, false)
+}
+
+// Notch creates a notch Filter with center frequency freq.
+func Notch(freq, q float64) *Filter {
+	return newFilter(func(rate, f, q, _ float64) biquad { return notchBQ(rate, f, q) }, freq, q, 0, false)
+}
+
+// LowShelf creates a Filter applying gain dB below frequency freq.
+func LowShelf(freq, gain, q float64) *Filter {
+	return newFilter(lowShelfBQ, freq, q, gain, true)
+}
+
+// HighShelf creates a Filter applying gain dB above frequency freq.
+func HighShelf(freq, gain, q float64) *Filter {
+	return newFilter(highShelfBQ, freq, q, gain, true)
+}
+
+// SetFreq sets the cutoff or center frequency, in Hz.
+func (f *Filter) SetFreq(freq float64) {
+	f.freq = freq
+	f.rate = 0
+}
+
+// Freq returns the cutoff or center frequency, in Hz.
+func (f *Filter) Freq() float64 {
+	return f.freq
+}
+
+// SetQ sets the Q.
+func (f *Filter) SetQ(q float64) {
+	f.q = q
+	f.rate = 0
+}
+
+// Q returns the Q.
+func (f *Filter) Q() float64 {
+	return f.q
+}
+
+// SetGain sets the gain of a shelving filter, in dB.
+func (f *Filter) SetGain(gain float64) {
+	f.gain = gain
+	f.rate = 0
+}
+
+// Gain returns the gain of a shelving filter, in dB.
+func (f *Filter) Gain() float64 {
+	return f.gain
+}
+
+// Reset implements Resetter.
+func (f *Filter) Reset() {
+	for i := range f.st {
+		f.st[i] = bqState{}
+	}
+}
+
+// ChannelMode implements Processor.
+func (f *Filter) ChannelMode() ChannelMode {
+	return FullMode
+}
+
+// NextFrames implements Processor.
+func (f *Filter) NextFrames() (int, int) {
+	return DefaultInFrames, DefaultInFrames
+}
+
+// Process implements Processor.
+func (f *Filter) Process(dst, src *Block) error {
+	if rate := src.SampleRate.Float64(); rate != f.rate {
+		f.bq = f.design(rate, f.freq, f.q, f.gain)
+		f.rate = rate
+	}
+	for len(f.st) < src.Channels {
+		f.st = append(f.st, bqState{})
+	}
+	dst.Frames = src.Frames
+	src.EachChannel(func(c int, s []float64) {
+		f.bq.runSlice(&f.st[c], dst.Channel(c), s)
+	})
+	return nil
+}
@@ -0,0 +1,49 @@
+// Copyright 2018 The ZikiChombo Authors. All rights reserved.  Use of this source
+// code is governed by a license that can be found in the License file.
+
+package plug
+
+import (
+	"math"
+	"testing"
+)
+
+func TestFilters(t *testing.T) {
+	db := func(g float64) float64 { return 20 * math.Log10(g) }
+	for _, e := range []struct {
+		name string
+		p    Processor
+		f    float64
+		db   float64
+	}{
+		{"lowpass pass", LowPass(1000, math.Sqrt(0.5)), 50, 0},
+		{"lowpass cutoff", LowPass(1000, math.Sqrt(0.5)), 1000, -3},
+		{"lowpass stop", LowPass(1000, math.Sqrt(0.5)), 10000, -40},
+		{"highpass stop", HighPass(1000, math.Sqrt(0.5)), 50, -52},
+		{"highpass pass", HighPass(1000, math.Sqrt(0.5)), 10000, 0},
+		{"bandpass center", BandPass(1000, 2), 1000, 0},
+		{"notch center", Notch(1000, 2), 1000, -100},
+		{"notch pass", Notch(1000, 2), 10000, 0},
+		{"lowshelf low", LowShelf(1000, 6, math.Sqrt(0.5)), 30, 6},
+		{"lowshelf high", LowShelf(1000, 6, math.Sqrt(0.5)), 15000, 0},
+		{"highshelf high", HighShelf(1000, -6, math.Sqrt(0.5)), 15000, -6},
+	} {
+		got := db(response(e.p, e.f))
+		if e.db <= -40 {
+			if got > e.db {
+				t.Errorf("%s: got %.1fdB, expected below %.1fdB", e.name, got, e.db)
+			}
+			continue
+		}
+		if math.Abs(got-e.db) > 0.2 {
+			t.Errorf("%s: got %.1fdB not %.1fdB", e.name, got, e.db)
+		}
+	}
+	f := LowPass(1000, 1)
+	if err := f.Set("freq", 200); err != nil || f.Freq() != 200 {
+		t.Errorf("got freq %f, %v", f.Freq(), err)
+	}
+	if _, ok := f.Info("gain"); ok {
+		t.Errorf("low pass has a gain")
+	}
+}
@@ -0,0 +1,190 @@
+// Copyright 2018 The ZikiChombo Authors. All rights reserved.  Use of this source
+// code is governed by a license that can be found in the License file.
+
+package plug
+
+import (
+	"math"
+	"math/bits"
+	"sync"
+)
+
+const (
+	fpSize = 4096 // analysis frame size
+	fpHop  = 1024 // frames between sub-fingerprints
+	fpMinF = 28.0 // lowest frequency in the chroma, Hertz
+	fpMaxF = 3520.0
+)
+
+// Fingerprint is a sequence of 32 bit sub-fingerprints, one per 1024
+// input frames, starting at input frame Frame.
+type Fingerprint struct {
+	Frame  int64
+	Hashes []uint32
+}
+
+// Fingerprinter is a processor computing a chromaprint style acoustic
+// fingerprint of its input, for detecting known content passing through a
+// graph.  It passes its input through unchanged.
+//
+// The input is mixed down to mono and analysed in frames of 4096 frames
+// every 1024 frames.  Each analysis frame gives the energy of the 12 pitch
+// classes (the chroma), from which a 32 bit sub-fingerprint is derived by
+// comparing neighbouring pitch classes, and successive chroma.  Sub
+// fingerprints of the same content at the same sample rate and hop
+// alignment are similar even after noise, equalization or lossy coding,
+// and may be compared with FingerprintMatch.
+type Fingerprinter struct {
+	mu     sync.Mutex
+	span   int
+	pos    int64
+	buf    []float64
+	win    []float64
+	x      []complex128
+	chroma [12]float64
+	prev   [12]float64
+	cur    Fingerprint
+	c      chan Fingerprint
+}
+
+// NewFingerprinter creates a new Fingerprinter which emits a Fingerprint
+// of span sub-fingerprints at a time.
+func NewFingerprinter(span int) *Fingerprinter {
+	if span < 1 {
+		span = 1
+	}
+	res := &Fingerprinter{
+		span: span,
+		win:  make([]float64, fpSize),
+		x:    make([]complex128, fpSize),
+		c:    make(chan Fingerprint, 1)}
+	for i := range res.win {
+		res.win[i] = 0.5 - 0.5*math.Cos(2*math.Pi*float64(i)/fpSize)
+	}
+	return res
+}
+
+// Fingerprints returns a channel on which every Fingerprint of span
+// sub-fingerprints is made available.  Only the latest Fingerprint is
+// kept, so a slow reader does not hold up processing.
+func (p *Fingerprinter) Fingerprints() <-chan Fingerprint {
+	return p.c
+}
+
+// ChannelMode implements Processor.
+func (p *Fingerprinter) ChannelMode() ChannelMode {
+	return FullMode
+}
+
+// NextFrames implements Processor.
+func (p *Fingerprinter) NextFrames() (int, int) {
+	return DefaultInFrames, DefaultInFrames
+}
+
+// Process implements Processor.
+func (p *Fingerprinter) Process(dst, src *Block) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	N, C := src.Frames, src.Channels
+	copy(dst.Samples[:C*N], src.Samples[:C*N])
+	dst.Frames = N
+	for f := 0; f < N; f++ {
+		v := 0.0
+		for c := 0; c < C; c++ {
+			v += src.Samples[c*N+f]
+		}
+		p.buf = append(p.buf, v/float64(C))
+		p.pos++
+		if len(p.buf) == fpSize {
+			p.analyse(src.SampleRate.Float64())
+			p.buf = p.buf[:copy(p.buf, p.buf[fpHop:])]
+		}
+	}
+	return nil
+}
+
+// analyse computes the sub-fingerprint of the current analysis frame.
+func (p *Fingerprinter) analyse(rate float64) {
+	for i, v := range p.buf {
+		p.x[i] = complex(v*p.win[i], 0)
+	}
+	fft(p.x, false)
+	p.prev = p.chroma
+	p.chroma = [12]float64{}
+	for k := 1; k < fpSize/2; k++ {
+		f := float64(k) * rate / fpSize
+		if f < fpMinF || f > fpMaxF {
+			continue
+		}
+		pc := int(math.Floor(12*math.Log2(f/440)+0.5)) % 12
+		if pc < 0 {
+			pc += 12
+		}
+		re, im := real(p.x[k]), imag(p.x[k])
+		p.chroma[pc] += re*re + im*im
+	}
+	sum := 0.0
+	for _, v := range p.chroma {
+		sum += v
+	}
+	if sum > 0 {
+		for i := range p.chroma {
+			p.chroma[i] /= sum
+		}
+	}
+	if len(p.cur.Hashes) == 0 {
+		p.cur.Frame = p.pos - fpSize
+	}
+	p.cur.Hashes = append(p.cur.Hashes, chromaHash(&p.chroma, &p.prev))
+	if len(p.cur.Hashes) < p.span {
+		return
+	}
+	select {
+	case <-p.c:
+	default:
+	}
+	p.c <- p.cur
+	p.cur = Fingerprint{}
+}
+
+// chromaHash derives a 32 bit sub-fingerprint from the chroma c and the
+// previous chroma prev.
+func chromaHash(c, prev *[12]float64) uint32 {
+	var h uint32
+	for i := 0; i < 12; i++ {
+		if c[i] > c[(i+1)%12] {
+			h |= 1 << uint(i)
+		}
+		if c[i] > prev[i] {
+			h |= 1 << uint(12+i)
+		}
+	}
+	for i := 0; i < 8; i++ {
+		if c[i]+c[i+1] > c[(i+2)%12]+c[(i+3)%12] {
+			h |= 1 << uint(24+i)
+		}
+	}
+	return h
+}
+
+// FingerprintMatch finds where the reference sub-fingerprints ref best
+// match the sub-fingerprints fp, returning the offset in fp at which ref
+// starts and the similarity there: the proportion of equal bits, from
+// about 0.5 for unrelated content to 1 for identical content.  ref must
+// not be longer than fp.
+func FingerprintMatch(ref, fp []uint32) (offset int, similarity float64) {
+	if len(ref) == 0 || len(ref) > len(fp) {
+		return 0, 0
+	}
+	best := -1
+	for o := 0; o+len(ref) <= len(fp); o++ {
+		diff := 0
+		for i, h := range ref {
+			diff += bits.OnesCount32(h ^ fp[o+i])
+		}
+		if best == -1 || diff < best {
+			best, offset = diff, o
+		}
+	}
+	return offset, 1 - float64(best)/float64(32*len(ref))
+}
@@ -0,0 +1,82 @@
+// Copyright 2018 The ZikiChombo Authors. All rights reserved.  Use of this source
+// code is governed by a license that can be found in the License file.
+
+package plug
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+
+	"zikichombo.org/sound/freq"
+)
+
+// melody returns frames frames of a sequence of random notes.
+func melody(seed int64, frames int) []float64 {
+	rnd := rand.New(rand.NewSource(seed))
+	res := make([]float64, frames)
+	var hz float64
+	for i := range res {
+		if i%6000 == 0 {
+			hz = 110 * math.Pow(2, float64(rnd.Intn(36))/12)
+		}
+		w := 2 * math.Pi * hz * float64(i) / 44100
+		res[i] = 0.3*math.Sin(w) + 0.1*math.Sin(2*w) + 0.05*math.Sin(3*w)
+	}
+	return res
+}
+
+// fingerprint returns the sub-fingerprints of d.
+func fingerprint(t *testing.T, d []float64) []uint32 {
+	p := NewFingerprinter(1)
+	var res []uint32
+	N := 1024
+	for off := 0; off+N <= len(d); off += N {
+		src := &Block{SampleRate: 44100 * freq.Hertz, Channels: 1, Frames: N, Samples: d[off : off+N]}
+		dst := &Block{SampleRate: src.SampleRate, Channels: 1, Samples: make([]float64, N)}
+		if err := p.Process(dst, src); err != nil {
+			t.Fatal(err)
+		}
+		for i, v := range src.Samples {
+			if dst.Samples[i] != v {
+				t.Fatalf("frame %d: got %g, not passed through", off+i, dst.Samples[i])
+			}
+		}
+		select {
+		case fp := <-p.Fingerprints():
+			if len(fp.Hashes) != 1 || fp.Frame != int64(off+N-fpSize) {
+				t.Fatalf("got fingerprint of %d hashes at frame %d after frame %d", len(fp.Hashes), fp.Frame, off+N)
+			}
+			res = append(res, fp.Hashes[0])
+		default:
+		}
+	}
+	return res
+}
+
+func TestFingerprinter(t *testing.T) {
+	a := melody(1, 400*1024)
+	ref := fingerprint(t, a)[100:160]
+
+	// a noisy, quieter copy of a after 200 blocks of other content.
+	rnd := rand.New(rand.NewSource(2))
+	b := append(melody(3, 200*1024), a...)
+	for i := range b {
+		if i >= 200*1024 {
+			b[i] *= 0.5
+		}
+		b[i] += 0.01 * rnd.NormFloat64()
+	}
+	fp := fingerprint(t, b)
+	off, sim := FingerprintMatch(ref, fp)
+	if off != 300 || sim < 0.8 {
+		t.Errorf("got match at %d with similarity %.2f, expected 300", off, sim)
+	}
+	// unrelated content does not match.
+	if _, sim := FingerprintMatch(ref, fingerprint(t, melody(4, 200*1024))); sim > 0.75 {
+		t.Errorf("got similarity %.2f for unrelated content", sim)
+	}
+	if off, sim := FingerprintMatch(fp, ref); off != 0 || sim != 0 {
+		t.Errorf("matched a longer reference at %d with similarity %.2f", off, sim)
+	}
+}
@@ -0,0 +1,136 @@
+// Copyright 2018 The ZikiChombo Authors. All rights reserved.  Use of this source
+// code is governed by a license that can be found in the License file.
+
+package plug
+
+import (
+	"math"
+
+	"zikichombo.org/sound/freq"
+)
+
+// FixedBlock is like Block, but holds samples in signed fixed point with
+// Frac fractional bits: a sample s represents the value s / 2^Frac.  With
+// Frac = 31 (Q31), the full range of int32 represents [-1, 1); smaller
+// values of Frac give headroom above full scale.
+type FixedBlock struct {
+	Samples    []int32
+	Frames     int    // setable by processor
+	Channels   int    // read only, static w.r.t. IO lifecycle
+	SampleRate freq.T // read only, static w.r.t. IO lifecycle
+	Frac       uint   // read only, static w.r.t. IO lifecycle
+}
+
+// FixedProcessor is like Processor, but processes FixedBlocks.  Fixed point
+// processing is meant for targets where float64 throughput is poor, such
+// as microcontrollers and ARM cores without NEON.
+type FixedProcessor interface {
+	ChannelMode() ChannelMode
+	NextFrames() (int, int)
+
+	// ProcessFixed is as Processor.Process, with fixed point blocks.
+	ProcessFixed(dst, src *FixedBlock) error
+}
+
+// FixedProcFunc gives the type of a fixed point processing function.
+type FixedProcFunc func(dst, src *FixedBlock) error
+
+type fixedProc struct {
+	mode      ChannelMode
+	inFrames  int
+	outFrames int
+	fn        FixedProcFunc
+}
+
+// NewFixedProcessor creates a new fixed point processor with channel mode
+// mode and input and output frames ifrms and ofrms.
+func NewFixedProcessor(mode ChannelMode, fn FixedProcFunc, ifrms, ofrms int) FixedProcessor {
+	return &fixedProc{mode: mode, inFrames: ifrms, outFrames: ofrms, fn: fn}
+}
+
+func (p *fixedProc) ChannelMode() ChannelMode {
+	return p.mode
+}
+
+func (p *fixedProc) NextFrames() (int, int) {
+	return p.inFrames, p.outFrames
+}
+
+func (p *fixedProc) ProcessFixed(dst, src *FixedBlock) error {
+	return p.fn(dst, src)
+}
+
+type fixed struct {
+	p        FixedProcessor
+	src, dst FixedBlock
+}
+
+// Fixed creates a Processor running the fixed point processor p with frac
+// fractional bits.  Samples are converted to fixed point before, and back
+// from fixed point after, every call to p, saturating values out of range,
+// so that fixed point processors can be used in any IO.  A chain of fixed
+// point processing is best placed in a single FixedProcessor, so that
+// samples are converted only at its boundaries.
+func Fixed(p FixedProcessor, frac uint) Processor {
+	res := &fixed{p: p}
+	res.src.Frac = frac
+	res.dst.Frac = frac
+	return res
+}
+
+func (f *fixed) ChannelMode() ChannelMode {
+	return f.p.ChannelMode()
+}
+
+func (f *fixed) NextFrames() (int, int) {
+	return f.p.NextFrames()
+}
+
+func (f *fixed) Process(dst, src *Block) error {
+	fs, fd := &f.src, &f.dst
+	fs.Samples = ToFixed(fs.Samples, src.Samples, fs.Frac)
+	fs.Frames, fs.Channels, fs.SampleRate = src.Frames, src.Channels, src.SampleRate
+	if cap(fd.Samples) < len(dst.Samples) {
+		fd.Samples = make([]int32, len(dst.Samples))
+	}
+	fd.Samples = fd.Samples[:len(dst.Samples)]
+	fd.Frames, fd.Channels, fd.SampleRate = dst.Frames, dst.Channels, dst.SampleRate
+	if err := f.p.ProcessFixed(fd, fs); err != nil {
+		return err
+	}
+	dst.Frames = fd.Frames
+	FromFixed(dst.Samples, fd.Samples, fd.Frac)
+	return nil
+}
+
+// ToFixed converts src to fixed point with frac fractional bits, placing
+// the result in dst, which is reallocated if it is too small, and returning
+// it.  Values out of range saturate.
+func ToFixed(dst []int32, src []float64, frac uint) []int32 {
+	if cap(dst) < len(src) {
+		dst = make([]int32, len(src))
+	}
+	dst = dst[:len(src)]
+	scale := float64(uint64(1) << frac)
+	for i, v := range src {
+		v = math.Floor(v*scale + 0.5)
+		switch {
+		case v >= math.MaxInt32:
+			dst[i] = math.MaxInt32
+		case v <= math.MinInt32:
+			dst[i] = math.MinInt32
+		default:
+			dst[i] = int32(v)
+		}
+	}
+	return dst
+}
+
+// FromFixed converts the fixed point samples src with frac fractional bits
+// to floating point in dst.  dst must be at least as long as src.
+func FromFixed(dst []float64, src []int32, frac uint) {
+	scale := 1 / float64(uint64(1)<<frac)
+	for i, v := range src {
+		dst[i] = float64(v) * scale
+	}
+}
@@ -0,0 +1,79 @@
+// Copyright 2018 The ZikiChombo Authors. All rights reserved.  Use of this source
+// code is governed by a license that can be found in the License file.
+
+package plug
+
+import (
+	"math"
+	"testing"
+
+	"zikichombo.org/sound"
+)
+
+func TestFixedRoundTrip(t *testing.T) {
+	src := []float64{0, 0.5, -0.5, 0.123456789, -0.987654321, 1e-9, -1}
+	for _, frac := range []uint{15, 24, 31} {
+		q := ToFixed(nil, src, frac)
+		got := make([]float64, len(q))
+		FromFixed(got, q, frac)
+		tol := 0.5 / float64(uint64(1)<<frac)
+		for i, v := range src {
+			if math.Abs(got[i]-v) > tol {
+				t.Errorf("Q%d: %g came back as %g", frac, v, got[i])
+			}
+		}
+	}
+}
+
+func TestFixedClipping(t *testing.T) {
+	for _, e := range []struct {
+		v    float64
+		frac uint
+		want int32
+	}{
+		{1, 31, math.MaxInt32},
+		{-1, 31, math.MinInt32},
+		{1.5, 31, math.MaxInt32},
+		{-1.5, 31, math.MinInt32},
+		// 7 bits of headroom above full scale.
+		{2, 24, 2 << 24},
+		{-100, 24, -100 << 24},
+		{200, 24, math.MaxInt32},
+		{-200, 24, math.MinInt32},
+	} {
+		if got := ToFixed(nil, []float64{e.v}, e.frac)[0]; got != e.want {
+			t.Errorf("%g in Q%d: got %d not %d", e.v, e.frac, got, e.want)
+		}
+	}
+}
+
+func TestFixed(t *testing.T) {
+	mono := sound.MonoCd()
+	// halves its input, in Q24.
+	half := NewFixedProcessor(MonoMode, func(dst, src *FixedBlock) error {
+		if src.Frac != 24 {
+			t.Errorf("got %d fractional bits", src.Frac)
+		}
+		for i, v := range src.Samples[:src.Frames] {
+			dst.Samples[i] = v / 2
+		}
+		dst.Frames = src.Frames
+		return nil
+	}, 512, 512)
+	u := New(mono, mono, Fixed(half, 24))
+	u.SetInput(&tone{Form: mono, f: 440, end: 3000})
+	rec := &record{Form: mono}
+	u.AddOutput(rec)
+	if err := u.Run(); err != nil {
+		t.Fatal(err)
+	}
+	if len(rec.chs[0]) != 3000 {
+		t.Fatalf("got %d frames", len(rec.chs[0]))
+	}
+	for i, v := range rec.chs[0] {
+		exp := 0.5 * math.Sin(2*math.Pi*440*float64(i)/44100)
+		if math.Abs(v-exp) > 1.0/(1<<24) {
+			t.Fatalf("frame %d: got %g not %g", i, v, exp)
+		}
+	}
+}
@@ -0,0 +1,176 @@
+// Copyright 2018 The ZikiChombo Authors. All rights reserved.  Use of this source
+// code is governed by a license that can be found in the License file.
+
+package plug
+
+import (
+	"fmt"
+	"math"
+	"math/cmplx"
+
+	"zikichombo.org/sound"
+)
+
+// fixtureSpectrum is the maximum size of the spectrum used to find the
+// spectral peak of a fixture output.
+const fixtureSpectrum = 1 << 16
+
+// Expect gives the expected characteristics of an output of a Fixture.
+// Zero fields are not checked.
+type Expect struct {
+	MinFrames, MaxFrames int64   // bounds on the length of the output.
+	MinRMS, MaxRMS       float64 // bounds on the RMS of all the samples.
+
+	// Peak is the expected frequency in Hertz of the spectral peak of the
+	// output, averaged over its channels, within PeakTolerance Hertz.
+	Peak, PeakTolerance float64
+}
+
+// Fixture declares a regression test of a graph: the graph, sources for
+// its inputs and the expected characteristics of its outputs.  Fixtures
+// are typically listed in a table and checked by CheckFixtures.
+type Fixture struct {
+	Name string
+
+	// Graph is the graph, in the JSON encoding of Graph.MarshalJSON.
+	Graph string
+
+	// Inputs gives, by index of node in the graph, a function creating the
+	// source of all the input channels of the node.
+	Inputs map[int]func() sound.Source
+
+	// Outputs gives, by index of node in the graph, the expected
+	// characteristics of all the output channels of the node.
+	Outputs map[int]Expect
+}
+
+// Check builds and runs the graph of f, and checks its outputs.
+func (f *Fixture) Check() error {
+	var g Graph
+	if err := g.UnmarshalJSON([]byte(f.Graph)); err != nil {
+		return err
+	}
+	nodes := g.Nodes()
+	for i, src := range f.Inputs {
+		if i < 0 || i >= len(nodes) {
+			return fmt.Errorf("input node %d out of range", i)
+		}
+		if err := nodes[i].SetInput(src()); err != nil {
+			return fmt.Errorf("input node %d: %s", i, err)
+		}
+	}
+	caps := make(map[int]*capture, len(f.Outputs))
+	for i := range f.Outputs {
+		if i < 0 || i >= len(nodes) {
+			return fmt.Errorf("output node %d out of range", i)
+		}
+		c := &capture{Form: nodes[i].OutForm()}
+		if err := nodes[i].AddOutput(c); err != nil {
+			return fmt.Errorf("output node %d: %s", i, err)
+		}
+		caps[i] = c
+	}
+	var res error
+	for err := range g.Run() {
+		if res == nil {
+			res = err
+		}
+	}
+	if res != nil {
+		return res
+	}
+	for i, e := range f.Outputs {
+		if err := caps[i].check(e); err != nil {
+			return fmt.Errorf("output node %d: %s", i, err)
+		}
+	}
+	return nil
+}
+
+// CheckFixtures checks the fixtures fs, reporting failures to t, which is
+// typically a *testing.T.
+func CheckFixtures(t interface {
+	Errorf(format string, args ...interface{})
+}, fs []Fixture) {
+	for i := range fs {
+		if err := fs[i].Check(); err != nil {
+			t.Errorf("fixture %s: %s", fs[i].Name, err)
+		}
+	}
+}
+
+// capture is a sink keeping the statistics of its audio, and the first
+// fixtureSpectrum frames of its channel average.
+type capture struct {
+	sound.Form
+	frames int64
+	sumSq  float64
+	mix    []float64
+}
+
+func (c *capture) Close() error { return nil }
+
+func (c *capture) Send(d []float64) error {
+	nC := c.Channels()
+	if len(d)%nC != 0 {
+		return sound.ErrChannelAlignment
+	}
+	n := len(d) / nC
+	for _, v := range d {
+		c.sumSq += v * v
+	}
+	for i := 0; i < n && len(c.mix) < fixtureSpectrum; i++ {
+		s := 0.0
+		for ch := 0; ch < nC; ch++ {
+			s += d[ch*n+i]
+		}
+		c.mix = append(c.mix, s/float64(nC))
+	}
+	c.frames += int64(n)
+	return nil
+}
+
+func (c *capture) check(e Expect) error {
+	if c.frames < e.MinFrames || (e.MaxFrames > 0 && c.frames > e.MaxFrames) {
+		return fmt.Errorf("got %d frames, expected [%d, %d]", c.frames, e.MinFrames, e.MaxFrames)
+	}
+	rms := 0.0
+	if c.frames > 0 {
+		rms = math.Sqrt(c.sumSq / float64(c.frames*int64(c.Channels())))
+	}
+	if rms < e.MinRMS || (e.MaxRMS > 0 && rms > e.MaxRMS) {
+		return fmt.Errorf("got RMS %g, expected [%g, %g]", rms, e.MinRMS, e.MaxRMS)
+	}
+	if e.Peak > 0 {
+		p := c.peak()
+		if math.Abs(p-e.Peak) > e.PeakTolerance {
+			return fmt.Errorf("got spectral peak %gHz, expected %g±%gHz", p, e.Peak, e.PeakTolerance)
+		}
+	}
+	return nil
+}
+
+// peak returns the frequency of the largest bin, excluding DC, of the
+// Hann windowed spectrum of the largest power of 2 prefix of c.mix.
+func (c *capture) peak() float64 {
+	N := 1
+	for N*2 <= len(c.mix) {
+		N *= 2
+	}
+	if N < 2 {
+		return 0
+	}
+	x := make([]complex128, N)
+	for i := range x {
+		w := 0.5 - 0.5*math.Cos(2*math.Pi*float64(i)/float64(N))
+		x[i] = complex(w*c.mix[i], 0)
+	}
+	fft(x, false)
+	best, bin := 0.0, 0
+	for k := 1; k <= N/2; k++ {
+		if a := cmplx.Abs(x[k]); a > best {
+			best, bin = a, k
+		}
+	}
+	return float64(bin) * c.SampleRate().Float64() / float64(N)
+}
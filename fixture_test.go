@@ -0,0 +1,34 @@
+// Copyright 2018 The ZikiChombo Authors. All rights reserved.  Use of this source
+// code is governed by a license that can be found in the License file.
+
+package plug
+
+import (
+	"testing"
+
+	"zikichombo.org/sound"
+)
+
+func init() {
+	Register("test.pass", func(params map[string]interface{}) (Processor, error) {
+		return PassThrough, nil
+	})
+}
+
+func TestFixtures(t *testing.T) {
+	CheckFixtures(t, []Fixture{{
+		Name:  "tone",
+		Graph: `{"nodes":[{"processor":"test.pass","in":{"rate":44100,"channels":1},"out":{"rate":44100,"channels":1}}],"edges":[]}`,
+		Inputs: map[int]func() sound.Source{
+			0: func() sound.Source { return &tone{Form: sound.MonoCd(), f: 1000, end: 44100} }},
+		Outputs: map[int]Expect{
+			0: {MinFrames: 44100, MaxFrames: 44100, MinRMS: 0.7, MaxRMS: 0.72, Peak: 1000, PeakTolerance: 2}}}})
+	f := Fixture{
+		Graph: `{"nodes":[{"processor":"test.pass","in":{"rate":44100,"channels":1},"out":{"rate":44100,"channels":1}}],"edges":[]}`,
+		Inputs: map[int]func() sound.Source{
+			0: func() sound.Source { return &tone{Form: sound.MonoCd(), f: 1000, end: 100} }},
+		Outputs: map[int]Expect{0: {MinFrames: 1000}}}
+	if err := f.Check(); err == nil {
+		t.Errorf("short output passed")
+	}
+}
@@ -0,0 +1,107 @@
+// Copyright 2018 The ZikiChombo Authors. All rights reserved.  Use of this source
+// code is governed by a license that can be found in the License file.
+
+package plug
+
+import (
+	"time"
+
+	"zikichombo.org/sound/freq"
+)
+
+// Block32 is a Block with float32 samples, for processors wrapping float32
+// DSP libraries and hardware APIs.  Its fields are those of a Block.
+type Block32 struct {
+	Samples     []float32
+	Frames      int    // setable by processor
+	Channels    int    // read only, static w.r.t. IO lifecycle
+	SampleRate  freq.T // read only, static w.r.t. IO lifecycle
+	Interleaved bool   // read only, static w.r.t. IO lifecycle
+	StartFrame  int64
+	Time        time.Time
+	Annotations []Annotation
+}
+
+// Processor32 is a Processor processing Block32s.  Process32 is called
+// like Processor.Process.
+//
+// A Processor32 may implement the optional interfaces of a Processor,
+// such as LatencyReporter, Resetter, Tailer and Interleaver.
+type Processor32 interface {
+	ChannelMode() ChannelMode
+	NextFrames() (int, int)
+	Process32(dst, src *Block32) error
+}
+
+// Float32 returns a Processor running p.  The blocks of the IO are
+// converted to float32 for p and its output back, once per block.
+func Float32(p Processor32) Processor {
+	return &proc32{p: p}
+}
+
+type proc32 struct {
+	p        Processor32
+	src, dst Block32
+}
+
+func (p *proc32) ChannelMode() ChannelMode {
+	return p.p.ChannelMode()
+}
+
+func (p *proc32) NextFrames() (int, int) {
+	return p.p.NextFrames()
+}
+
+func (p *proc32) Process(dst, src *Block) error {
+	sb, db := &p.src, &p.dst
+	n := src.Channels * src.Frames
+	*sb = Block32{SampleRate: src.SampleRate, Channels: src.Channels, Frames: src.Frames,
+		Interleaved: src.Interleaved, StartFrame: src.StartFrame, Time: src.Time, Annotations: src.Annotations,
+		Samples: buffer32(sb.Samples, n)}
+	for i, v := range src.Samples[:n] {
+		sb.Samples[i] = float32(v)
+	}
+	*db = Block32{SampleRate: dst.SampleRate, Channels: dst.Channels, Frames: dst.Frames,
+		Interleaved: dst.Interleaved, StartFrame: dst.StartFrame, Time: dst.Time, Annotations: dst.Annotations,
+		Samples: buffer32(db.Samples, dst.Channels*dst.Frames)}
+	if err := p.p.Process32(db, sb); err != nil {
+		return err
+	}
+	for i, v := range db.Samples[:dst.Channels*db.Frames] {
+		dst.Samples[i] = float64(v)
+	}
+	dst.Frames = db.Frames
+	dst.Annotations = db.Annotations
+	return nil
+}
+
+func (p *proc32) Latency() int {
+	if l, ok := p.p.(LatencyReporter); ok {
+		return l.Latency()
+	}
+	return 0
+}
+
+func (p *proc32) Reset() {
+	if r, ok := p.p.(Resetter); ok {
+		r.Reset()
+	}
+}
+
+func (p *proc32) Tail() bool {
+	t, ok := p.p.(Tailer)
+	return ok && t.Tail()
+}
+
+func (p *proc32) Interleaved() bool {
+	il, ok := p.p.(Interleaver)
+	return ok && il.Interleaved()
+}
+
+// buffer32 is like buffer for float32 samples.
+func buffer32(d []float32, n int) []float32 {
+	if cap(d) < n {
+		d = make([]float32, n)
+	}
+	return d[:n]
+}
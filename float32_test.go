@@ -0,0 +1,24 @@
+// Copyright 2018 The ZikiChombo Authors. All rights reserved.  Use of this source
+// code is governed by a license that can be found in the License file.
+
+package plug
+
+import (
+	"testing"
+
+	"zikichombo.org/sound"
+)
+
+func TestFloat32(t *testing.T) {
+	mono := sound.MonoCd()
+	u := New(mono, mono, Float32(half{}))
+	u.SetInput(&ramp{Form: mono, n: 3000})
+	snk := &capture{Form: mono}
+	u.AddOutput(snk)
+	if err := u.Run(); err != nil {
+		t.Fatal(err)
+	}
+	if snk.frames != 3000 || snk.mix[2999] != 1499.5 {
+		t.Errorf("got %d frames, %f", snk.frames, snk.mix[2999])
+	}
+}
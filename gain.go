@@ -0,0 +1,56 @@
+// Copyright 2018 The ZikiChombo Authors. All rights reserved.  Use of this source
+// code is governed by a license that can be found in the License file.
+
+package plug
+
+import (
+	"fmt"
+
+	"zikichombo.org/sound"
+)
+
+// OutputOptions gives options for an output added by OutputController.AddOutputWith.
+type OutputOptions struct {
+	// Channels lists the channels of the IO mapped to the destination, as
+	// the cs argument of AddOutput.
+	Channels []int
+
+	// Gains, if not empty, gives a linear gain per destination channel,
+	// applied when copying the output of the IO to the destination.
+	Gains []float64
+}
+
+// AddOutputWith implements OutputController.
+func (n *node) AddOutputWith(d sound.Sink, opts OutputOptions) error {
+	nC := len(opts.Channels)
+	if nC == 0 {
+		nC = n.oForm.Channels()
+	}
+	if len(opts.Gains) != 0 && len(opts.Gains) != nC {
+		return fmt.Errorf("got %d gains for %d channels", len(opts.Gains), nC)
+	}
+	var gains []float64
+	if len(opts.Gains) != 0 {
+		gains = append(gains, opts.Gains...)
+	}
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return n.addOutput(d, gains, opts.Channels...)
+}
+
+// gain applies the gains of p, if any, to its samples.
+func (p *packet) gain() {
+	if p.gains == nil {
+		return
+	}
+	m := p.n
+	for c, g := range p.gains {
+		if g == 1 {
+			continue
+		}
+		d := p.samples[c*m : (c+1)*m]
+		for i := range d {
+			d[i] *= g
+		}
+	}
+}
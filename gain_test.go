@@ -0,0 +1,30 @@
+// Copyright 2018 The ZikiChombo Authors. All rights reserved.  Use of this source
+// code is governed by a license that can be found in the License file.
+
+package plug
+
+import (
+	"testing"
+
+	"zikichombo.org/sound"
+)
+
+func TestIOAddOutputWith(t *testing.T) {
+	stereo := sound.StereoCd()
+	mono := sound.MonoCd()
+	u := New(mono, mono, PassThrough)
+	u.SetInput(&ramp{Form: mono, n: 4096})
+	if err := u.AddOutputWith(&countSink{Form: stereo}, OutputOptions{Gains: []float64{1, 2}}); err == nil {
+		t.Errorf("added output with too many gains")
+	}
+	snk := &capture{Form: mono}
+	if err := u.AddOutputWith(snk, OutputOptions{Gains: []float64{0.5}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := u.Run(); err != nil {
+		t.Fatal(err)
+	}
+	if snk.mix[100] != 50 {
+		t.Errorf("got %v not 50", snk.mix[100])
+	}
+}
@@ -4,6 +4,7 @@
 package plug
 
 import (
+	"fmt"
 	"sync"
 
 	"zikichombo.org/sound"
@@ -19,6 +20,17 @@ import (
 // some operations when there are many I/O plugs.
 type Graph struct {
 	nodes []IO
+	edges []Edge
+	specs map[IO]*nodeSpec
+	clock *Clock
+}
+
+// Edge describes a connection made by Graph.Connect.
+type Edge struct {
+	From      IO
+	FromChans []int // output channels of From, or nil for all.
+	To        IO
+	ToChans   []int // input channels of To, or nil for all.
 }
 
 // Run runs the graph and returns an error channel
@@ -36,12 +48,13 @@ func (g *Graph) Run() <-chan error {
 
 	for _, n := range g.nodes {
 		wg.Add(1)
-		go func() {
+		go func(n IO) {
+			defer wg.Done()
 			err := n.Run()
 			if err != nil {
 				c <- err
 			}
-		}()
+		}(n)
 	}
 	go func() {
 		wg.Wait()
@@ -51,18 +64,123 @@ func (g *Graph) Run() <-chan error {
 }
 
 // New creates a new I/O plug.
-func (g *Graph) New(iForm, oForm sound.Form, proc Processor) IO {
+func (g *Graph) New(iForm, oForm sound.Form, proc Processor) Plug {
 	n := New(iForm, oForm, proc)
 	g.nodes = append(g.nodes, n)
 	return n
 }
 
+// Nodes returns the nodes of the graph, in the order in which they were
+// created.
+func (g *Graph) Nodes() []IO {
+	return g.nodes
+}
+
+// Edges returns the connections made with Connect, in the order in which
+// they were made.
+func (g *Graph) Edges() []Edge {
+	return g.edges
+}
+
+// Connect connects the output channels fromChans of from to the input
+// channels toChans of to: fromChans[i] is connected to toChans[i].  If
+// fromChans is empty, all output channels of from are used, and if toChans
+// is empty, all input channels of to are used.
+//
+// Connect is equivalent to
+//
+//  to.SetInput(from.Output(fromChans...), toChans...)
+//
+// except that it checks the connection before making it, returning an
+// error rather than panicking or leaving an unconnected output behind, and
+// records it as an Edge of the graph.  Both from and to must belong to g.
+func (g *Graph) Connect(from IO, fromChans []int, to IO, toChans []int) error {
+	if !g.has(from) || !g.has(to) {
+		return fmt.Errorf("connect: node not in graph")
+	}
+	if from.OutForm().SampleRate() != to.InForm().SampleRate() {
+		return fmt.Errorf("connect: frequency mismatch: %s to %s", from.OutForm().SampleRate(), to.InForm().SampleRate())
+	}
+	nFrom := len(fromChans)
+	if nFrom == 0 {
+		nFrom = from.OutForm().Channels()
+	}
+	nTo := len(toChans)
+	if nTo == 0 {
+		nTo = to.InForm().Channels()
+	}
+	if nFrom != nTo {
+		return fmt.Errorf("connect: channel mismatch: %d to %d", nFrom, nTo)
+	}
+	if err := ckChans(fromChans, from.OutForm().Channels()); err != nil {
+		return fmt.Errorf("connect: output %s", err)
+	}
+	if err := ckChans(toChans, to.InForm().Channels()); err != nil {
+		return fmt.Errorf("connect: input %s", err)
+	}
+	if n, ok := to.(*node); ok {
+		if err := n.ckInputsFree(toChans...); err != nil {
+			return fmt.Errorf("connect: %s", err)
+		}
+	}
+	if err := to.SetInput(from.Output(fromChans...), toChans...); err != nil {
+		return err
+	}
+	g.edges = append(g.edges, Edge{
+		From:      from,
+		FromChans: append([]int(nil), fromChans...),
+		To:        to,
+		ToChans:   append([]int(nil), toChans...)})
+	return nil
+}
+
+func (g *Graph) has(n IO) bool {
+	for _, m := range g.nodes {
+		if m == n {
+			return true
+		}
+	}
+	return false
+}
+
+// ckChans checks that the channels cs are distinct and less than nC.
+func ckChans(cs []int, nC int) error {
+	seen := make(map[int]bool, len(cs))
+	for _, c := range cs {
+		if c < 0 || c >= nC {
+			return fmt.Errorf("channel %d out of range [0, %d)", c, nC)
+		}
+		if seen[c] {
+			return fmt.Errorf("channel %d used twice", c)
+		}
+		seen[c] = true
+	}
+	return nil
+}
+
+// SetDepth sets the depth of every node in the graph implementing
+// OutputController, as per OutputController.SetDepth.
+func (g *Graph) SetDepth(d int) {
+	for _, n := range g.nodes {
+		if oc, ok := n.(OutputController); ok {
+			oc.SetDepth(d)
+		}
+	}
+}
+
 // CheckConnectivity checks whether the graph is fully connected and
-// acyclic.
+// acyclic.  The nodes of composites are checked as well, while other
+// implementations of IO are not checked.
 func (g *Graph) CheckConnectivity() error {
 	for _, n := range g.nodes {
-		// will need to deal with this check with other implementations eventually...
-		if err := n.(*node).checkConns(); err != nil {
+		var err error
+		switch n := n.(type) {
+		case *node:
+			err = n.checkConns()
+		case *composite:
+			err = n.g.CheckConnectivity()
+		}
+		if err != nil {
 			return err
 		}
 	}
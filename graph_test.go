@@ -0,0 +1,37 @@
+// Copyright 2018 The ZikiChombo Authors. All rights reserved.  Use of this source
+// code is governed by a license that can be found in the License file.
+
+package plug
+
+import (
+	"testing"
+
+	"zikichombo.org/sound"
+	"zikichombo.org/sound/gen"
+	"zikichombo.org/sound/ops"
+)
+
+func TestGraphConnect(t *testing.T) {
+	stereo := sound.StereoCd()
+	mono := sound.MonoCd()
+	var g Graph
+	u0 := g.New(stereo, stereo, PassThrough)
+	u1 := g.New(mono, mono, PassThrough)
+	u0.SetInput(ops.Limit(gen.Noise(), 1000), 0)
+	u0.SetInput(ops.Limit(gen.Noise(), 1000), 1)
+	if err := g.Connect(u0, nil, u1, nil); err == nil {
+		t.Errorf("connected 2 channels to 1")
+	}
+	if err := g.Connect(u0, []int{2}, u1, nil); err == nil {
+		t.Errorf("connected out of range channel")
+	}
+	if err := g.Connect(u0, []int{1}, u1, nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := g.Connect(u0, []int{0}, u1, nil); err == nil {
+		t.Errorf("connected input twice")
+	}
+	if len(g.Edges()) != 1 {
+		t.Errorf("got %d edges not 1", len(g.Edges()))
+	}
+}
@@ -0,0 +1,79 @@
+// Copyright 2018 The ZikiChombo Authors. All rights reserved.  Use of this source
+// code is governed by a license that can be found in the License file.
+
+package plug
+
+import "math"
+
+// Headroom is a processor which attenuates the input of a wrapped
+// processor and compensates its output by the inverse gain, so that a
+// fixed-point or saturating processor has room to process peaks without
+// clipping.  Headroom has the channel mode, shape and latency of the
+// processor it wraps, and its attenuation is the parameter "headroom".
+type Headroom struct {
+	ParamTable
+	p    Processor
+	db   float64
+	gain float64
+	buf  []float64
+}
+
+// WithHeadroom wraps p in a Headroom processor attenuating its input by db
+// decibels.
+func WithHeadroom(p Processor, db float64) *Headroom {
+	h := &Headroom{p: p}
+	h.SetHeadroom(db)
+	h.Add(ParamInfo{Name: "headroom", Unit: "dB", Max: 48, Default: db},
+		func() float64 { return h.db }, h.SetHeadroom)
+	return h
+}
+
+// SetHeadroom sets the attenuation, in dB, of the input of the wrapped
+// processor.  It is meant to be called through Parameterized.Apply, for example
+//
+//	io.Apply("headroom", 12, h.SetHeadroom)
+func (h *Headroom) SetHeadroom(db float64) {
+	h.db = db
+	h.gain = math.Pow(10, -db/20)
+}
+
+// ChannelMode implements Processor.
+func (h *Headroom) ChannelMode() ChannelMode {
+	return h.p.ChannelMode()
+}
+
+// NextFrames implements Processor.
+func (h *Headroom) NextFrames() (int, int) {
+	return h.p.NextFrames()
+}
+
+// Latency implements LatencyReporter.
+func (h *Headroom) Latency() int {
+	return latency(h.p)
+}
+
+// Reset implements Resetter, resetting the wrapped processor if it
+// implements Resetter.
+func (h *Headroom) Reset() {
+	if r, ok := h.p.(Resetter); ok {
+		r.Reset()
+	}
+}
+
+// Process implements Processor.
+func (h *Headroom) Process(dst, src *Block) error {
+	h.buf = buffer(h.buf, 1, len(src.Samples))
+	for i, v := range src.Samples {
+		h.buf[i] = v * h.gain
+	}
+	in := *src
+	in.Samples = h.buf
+	if err := h.p.Process(dst, &in); err != nil {
+		return err
+	}
+	d := dst.Samples[:dst.Channels*dst.Frames]
+	for i := range d {
+		d[i] /= h.gain
+	}
+	return nil
+}
@@ -0,0 +1,39 @@
+// Copyright 2018 The ZikiChombo Authors. All rights reserved.  Use of this source
+// code is governed by a license that can be found in the License file.
+
+package plug
+
+import (
+	"math"
+	"testing"
+
+	"zikichombo.org/sound"
+)
+
+func TestWithHeadroom(t *testing.T) {
+	mono := sound.MonoCd()
+	var peak float64
+	clip := NewProcessor(MonoMode, func(dst, src *Block) error {
+		for i, v := range src.Samples[:src.Frames] {
+			peak = math.Max(peak, math.Abs(v))
+			dst.Samples[i] = math.Max(-1, math.Min(1, v))
+		}
+		dst.Frames = src.Frames
+		return nil
+	})
+	h := WithHeadroom(clip, 0)
+	h.SetHeadroom(6.0206)
+	src := &Block{SampleRate: mono.SampleRate(), Channels: 1, Frames: 4, Samples: []float64{0.5, -1.5, 1.8, 0}}
+	dst := &Block{SampleRate: mono.SampleRate(), Channels: 1, Frames: 4, Samples: make([]float64, 4)}
+	if err := h.Process(dst, src); err != nil {
+		t.Fatal(err)
+	}
+	for i, v := range src.Samples {
+		if math.Abs(dst.Samples[i]-v) > 1e-3 {
+			t.Errorf("frame %d: got %f not %f", i, dst.Samples[i], v)
+		}
+	}
+	if peak > 1 || src.Samples[1] != -1.5 {
+		t.Errorf("got peak %f into the clipping processor", peak)
+	}
+}
@@ -0,0 +1,214 @@
+// Copyright 2018 The ZikiChombo Authors. All rights reserved.  Use of this source
+// code is governed by a license that can be found in the License file.
+
+package plug
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Quota limits the resources used by a graph run by a Host.  Zero fields
+// impose no limit.
+type Quota struct {
+	// Workers bounds the number of nodes of the graph processing at once.
+	Workers int
+	// CPU bounds the processing time of the graph, as a proportion of the
+	// wall time: 0.5 allows half of one core.
+	CPU float64
+	// Memory bounds the memory of the graph in bytes, as per Budget.
+	Memory int64
+}
+
+// GraphMetrics gives the metrics of a graph run by a Host, or their sum.
+type GraphMetrics struct {
+	Blocks     int64         // blocks processed
+	Frames     int64         // input frames processed
+	Processing time.Duration // time spent processing
+	Throttled  time.Duration // time spent waiting for a worker or the CPU quota
+	Running    int           // number of running graphs
+	Err        error         // first error, for a single graph
+}
+
+// Host runs many independent graphs, for example one per connected user of
+// a server, sharing a pool of workers: at most a fixed number of nodes
+// across all graphs process a block at once.  Each graph may be given a
+// Quota, and the Host keeps metrics per graph and in total.
+type Host struct {
+	pool   chan struct{}
+	mu     sync.Mutex
+	graphs map[string]*hosted
+}
+
+// hosted is a graph run by a host.  It is the gate through which its
+// nodes process blocks.
+type hosted struct {
+	h       *Host
+	g       *Graph
+	quota   Quota
+	workers chan struct{}
+	done    chan struct{}
+
+	mu      sync.Mutex
+	m       GraphMetrics
+	tokens  time.Duration // CPU quota bucket
+	refill  time.Time
+	running bool
+}
+
+// NewHost creates a new host with a pool of workers workers.
+func NewHost(workers int) *Host {
+	if workers < 1 {
+		workers = 1
+	}
+	return &Host{
+		pool:   make(chan struct{}, workers),
+		graphs: make(map[string]*hosted)}
+}
+
+// Start starts running g under name with quota q.  g must not be running
+// and its nodes must not be shared with other graphs.
+func (h *Host) Start(name string, g *Graph, q Quota) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if _, ok := h.graphs[name]; ok {
+		return fmt.Errorf("host: graph %q already started", name)
+	}
+	hg := &hosted{h: h, g: g, quota: q, done: make(chan struct{}), running: true, refill: time.Now()}
+	if q.Workers > 0 {
+		hg.workers = make(chan struct{}, q.Workers)
+	}
+	if q.Memory > 0 {
+		g.SetMemoryBudget(q.Memory)
+	}
+	for _, n := range g.nodes {
+		if nd, ok := n.(*node); ok {
+			nd.gate = hg
+		}
+	}
+	h.graphs[name] = hg
+	ec := g.Run()
+	go func() {
+		for err := range ec {
+			hg.mu.Lock()
+			if hg.m.Err == nil {
+				hg.m.Err = err
+			}
+			hg.mu.Unlock()
+		}
+		hg.mu.Lock()
+		hg.running = false
+		hg.mu.Unlock()
+		close(hg.done)
+	}()
+	return nil
+}
+
+// Stop stops the graph name as per Graph.Stop.
+func (h *Host) Stop(name string) {
+	h.mu.Lock()
+	hg := h.graphs[name]
+	h.mu.Unlock()
+	if hg != nil {
+		hg.g.Stop()
+	}
+}
+
+// Wait waits for the graph name to end, removes it from the host, and
+// returns its first error.
+func (h *Host) Wait(name string) error {
+	h.mu.Lock()
+	hg := h.graphs[name]
+	h.mu.Unlock()
+	if hg == nil {
+		return fmt.Errorf("host: no graph %q", name)
+	}
+	<-hg.done
+	h.mu.Lock()
+	delete(h.graphs, name)
+	h.mu.Unlock()
+	return hg.m.Err
+}
+
+// Metrics returns the metrics of every graph of the host, by name, and
+// their sum.
+func (h *Host) Metrics() (map[string]GraphMetrics, GraphMetrics) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	res := make(map[string]GraphMetrics, len(h.graphs))
+	var ttl GraphMetrics
+	for name, hg := range h.graphs {
+		hg.mu.Lock()
+		m := hg.m
+		if hg.running {
+			m.Running = 1
+		}
+		hg.mu.Unlock()
+		res[name] = m
+		ttl.Blocks += m.Blocks
+		ttl.Frames += m.Frames
+		ttl.Processing += m.Processing
+		ttl.Throttled += m.Throttled
+		ttl.Running += m.Running
+	}
+	return res, ttl
+}
+
+// throttle waits for the CPU quota of the graph before a node processes a
+// block.  It is called without holding the lock of the node, so that the
+// node may be configured and inspected while it waits.
+func (hg *hosted) throttle() {
+	if hg.quota.CPU <= 0 {
+		return
+	}
+	hg.mu.Lock()
+	now := time.Now()
+	hg.tokens += time.Duration(float64(now.Sub(hg.refill)) * hg.quota.CPU)
+	if max := time.Second; hg.tokens > max {
+		hg.tokens = max
+	}
+	hg.refill = now
+	wait := time.Duration(float64(-hg.tokens) / hg.quota.CPU)
+	hg.mu.Unlock()
+	if wait <= 0 {
+		return
+	}
+	time.Sleep(wait)
+	hg.mu.Lock()
+	hg.m.Throttled += time.Since(now)
+	hg.mu.Unlock()
+}
+
+// acquire waits for a worker of the graph and of the host before a node
+// processes a block.
+func (hg *hosted) acquire() time.Time {
+	start := time.Now()
+	if hg.workers != nil {
+		hg.workers <- struct{}{}
+	}
+	hg.h.pool <- struct{}{}
+	now := time.Now()
+	hg.mu.Lock()
+	hg.m.Throttled += now.Sub(start)
+	hg.mu.Unlock()
+	return now
+}
+
+// release releases the workers acquired at start, accounting for a block
+// of frms frames.
+func (hg *hosted) release(start time.Time, frms int) {
+	<-hg.h.pool
+	if hg.workers != nil {
+		<-hg.workers
+	}
+	d := time.Since(start)
+	hg.mu.Lock()
+	defer hg.mu.Unlock()
+	hg.m.Blocks++
+	hg.m.Frames += int64(frms)
+	hg.m.Processing += d
+	if hg.quota.CPU > 0 {
+		hg.tokens -= d
+	}
+}
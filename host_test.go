@@ -0,0 +1,106 @@
+// Copyright 2018 The ZikiChombo Authors. All rights reserved.  Use of this source
+// code is governed by a license that can be found in the License file.
+
+package plug
+
+import (
+	"testing"
+	"time"
+
+	"zikichombo.org/sound"
+	"zikichombo.org/sound/gen"
+)
+
+func TestHost(t *testing.T) {
+	mono := sound.MonoCd()
+	h := NewHost(1)
+	for _, name := range []string{"a", "b"} {
+		g := &Graph{}
+		u := g.New(mono, mono, PassThrough)
+		u.SetInput(&ramp{Form: mono, n: 10000})
+		u.AddOutput(&countSink{Form: mono})
+		if err := h.Start(name, g, Quota{Workers: 1}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := h.Start("a", &Graph{}, Quota{}); err == nil {
+		t.Error("started a graph twice")
+	}
+	_, ttl := h.Metrics()
+	if ttl.Running > 2 {
+		t.Errorf("got %d running graphs", ttl.Running)
+	}
+	for _, name := range []string{"a", "b"} {
+		if err := h.Wait(name); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := h.Wait("a"); err == nil {
+		t.Error("waited for a removed graph")
+	}
+}
+
+func TestHostMetrics(t *testing.T) {
+	mono := sound.MonoCd()
+	h := NewHost(2)
+	g := &Graph{}
+	u := g.New(mono, mono, PassThrough)
+	u.SetInput(&ramp{Form: mono, n: 10000})
+	u.AddOutput(&countSink{Form: mono})
+	if err := h.Start("a", g, Quota{}); err != nil {
+		t.Fatal(err)
+	}
+	for {
+		ms, _ := h.Metrics()
+		if ms["a"].Running == 0 {
+			if ms["a"].Frames != 10000 || ms["a"].Blocks != 10 {
+				t.Errorf("got %d frames in %d blocks", ms["a"].Frames, ms["a"].Blocks)
+			}
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if err := h.Wait("a"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestHostCPUQuota(t *testing.T) {
+	mono := sound.MonoCd()
+	h := NewHost(1)
+	g := &Graph{}
+	u := g.New(mono, mono, NewProcessor(MonoMode, func(dst, src *Block) error {
+		time.Sleep(5 * time.Millisecond)
+		return PassThrough.Process(dst, src)
+	}))
+	u.SetInput(gen.Noise())
+	rec := &record{Form: mono}
+	u.AddOutput(rec)
+	// every block of 5ms is followed by a wait of about 500ms.
+	if err := h.Start("a", g, Quota{CPU: 0.01}); err != nil {
+		t.Fatal(err)
+	}
+	for rec.frames() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	// the node is not locked while it waits for the quota.
+	start := time.Now()
+	u.Latency()
+	if d := time.Since(start); d > 250*time.Millisecond {
+		t.Errorf("node locked for %s while throttled", d)
+	}
+	for deadline := time.Now().Add(5 * time.Second); ; {
+		ms, _ := h.Metrics()
+		if ms["a"].Throttled >= 400*time.Millisecond {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("throttled %s", ms["a"].Throttled)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	h.Stop("a")
+	if err := h.Wait("a"); err != nil {
+		t.Fatal(err)
+	}
+}
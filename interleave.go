@@ -0,0 +1,70 @@
+// Copyright 2018 The ZikiChombo Authors. All rights reserved.  Use of this source
+// code is governed by a license that can be found in the License file.
+
+package plug
+
+// Interleaver is implemented by FullMode processors which prefer their
+// blocks in channel interleaved format, such as those wrapping C
+// libraries.
+//
+// If Interleaved returns true, the src and dst blocks given to Process
+// are interleaved, with Block.Interleaved set, and the IO converts them
+// from and to the deinterleaved format of the rest of the graph, once per
+// block.  Interleaved should not change while the processor runs.
+type Interleaver interface {
+	Interleaved() bool
+}
+
+// Interleave puts the deinterleaved samples src of nC channels into dst
+// in interleaved format.  dst and src have the same length and do not
+// overlap.
+func Interleave(dst, src []float64, nC int) {
+	N := len(src) / nC
+	for c := 0; c < nC; c++ {
+		for f, v := range src[c*N : (c+1)*N] {
+			dst[f*nC+c] = v
+		}
+	}
+}
+
+// Deinterleave puts the interleaved samples src of nC channels into dst
+// in deinterleaved format.  dst and src have the same length and do not
+// overlap.
+func Deinterleave(dst, src []float64, nC int) {
+	N := len(src) / nC
+	for c := 0; c < nC; c++ {
+		d := dst[c*N : (c+1)*N]
+		for f := range d {
+			d[f] = src[f*nC+c]
+		}
+	}
+}
+
+// runLayout runs the FullMode processor p from src to dst, in the layout
+// p prefers.  If p is an Interleaver, tmp holds the interleaved blocks,
+// and is taken from the pool if nil.
+func runLayout(p Processor, dst, src *Block, tmp *[2]Block) error {
+	if il, ok := p.(Interleaver); !ok || !il.Interleaved() || src.Interleaved {
+		return p.Process(dst, src)
+	}
+	if tmp == nil {
+		tmp = getBlocks()
+		defer func(bs *[2]Block) { putBlocks(bs, [2][]float64{bs[0].Samples, bs[1].Samples}) }(tmp)
+	}
+	ib, ob := &tmp[0], &tmp[1]
+	*ib = Block{SampleRate: src.SampleRate, Channels: src.Channels, Frames: src.Frames, Interleaved: true,
+		StartFrame: src.StartFrame, Time: src.Time, Annotations: src.Annotations,
+		Samples: buffer(ib.Samples, src.Channels, src.Frames)}
+	Interleave(ib.Samples, src.Samples[:src.Channels*src.Frames], src.Channels)
+	*ob = Block{SampleRate: dst.SampleRate, Channels: dst.Channels, Frames: dst.Frames, Interleaved: true,
+		StartFrame: dst.StartFrame, Time: dst.Time, Annotations: dst.Annotations,
+		Samples: buffer(ob.Samples, dst.Channels, dst.Frames)}
+	if err := p.Process(ob, ib); err != nil {
+		return err
+	}
+	M := ob.Frames
+	Deinterleave(dst.Samples[:dst.Channels*M], ob.Samples[:dst.Channels*M], dst.Channels)
+	dst.Frames = M
+	dst.Annotations = ob.Annotations
+	return nil
+}
@@ -0,0 +1,48 @@
+// Copyright 2018 The ZikiChombo Authors. All rights reserved.  Use of this source
+// code is governed by a license that can be found in the License file.
+
+package plug
+
+import (
+	"fmt"
+	"testing"
+
+	"zikichombo.org/sound"
+)
+
+func TestInterleaved(t *testing.T) {
+	d := []float64{1, 2, 3, 4, 5, 6}
+	il := make([]float64, 6)
+	Interleave(il, d, 2)
+	if fmt.Sprint(il) != "[1 4 2 5 3 6]" {
+		t.Errorf("got %v", il)
+	}
+	Deinterleave(d, il, 2)
+	if fmt.Sprint(d) != "[1 2 3 4 5 6]" {
+		t.Errorf("got %v", d)
+	}
+
+	src := &Block{Channels: 2, Frames: 3, Samples: d}
+	dst := &Block{Channels: 2, Frames: 3, Samples: make([]float64, 6)}
+	if err := runFull(swapper{}, dst, src); err != nil {
+		t.Fatal(err)
+	}
+	if fmt.Sprint(dst.Samples) != "[4 5 6 1 2 3]" {
+		t.Errorf("got %v", dst.Samples)
+	}
+
+	mono := sound.MonoCd()
+	stereo := sound.StereoCd()
+	u := New(stereo, stereo, swapper{})
+	u.SetInput(&ramp{Form: mono, n: 3000}, 0)
+	u.SetInput(&ramp{Form: mono, pos: 1000, n: 4000}, 1)
+	snk0, snk1 := &capture{Form: mono}, &capture{Form: mono}
+	u.AddOutput(snk0, 0)
+	u.AddOutput(snk1, 1)
+	if err := u.Run(); err != nil {
+		t.Fatal(err)
+	}
+	if snk0.frames != 3000 || snk0.mix[2999] != 3999 || snk1.mix[2999] != 2999 {
+		t.Errorf("got %d frames, %f, %f", snk0.frames, snk0.mix[2999], snk1.mix[2999])
+	}
+}
@@ -4,9 +4,11 @@
 package plug
 
 import (
+	"context"
 	"fmt"
 	"io"
 	"sync"
+	"time"
 
 	"zikichombo.org/sound"
 )
@@ -14,6 +16,11 @@ import (
 // IO provides a generic minimal interface for an audio/sound processor.
 // Implementations must be safe for use in multiple goroutines, but
 // may assume that the Run() method is called at most once.
+//
+// IOs created by New and Graph.New implement Plug, which adds the
+// optional interfaces below.  Other implementations, such as those
+// returned by Compose, implement the optional interfaces which make sense
+// for them, and functions such as those of Graph check for them.
 type IO interface {
 
 	// InForm returns the sample rate and number of channels of the
@@ -36,6 +43,10 @@ type IO interface {
 	// which would map to more than one sound.Source as a result of the call to
 	// SetInput.
 	//
+	// SetInput may be called while the IO runs, in which case it waits for
+	// the block being processed and s is received from starting with the
+	// next block.  It returns an error once the IO
+	// has ended.
 	SetInput(s sound.Source, cs ...int) error
 
 	// AddOutput, if successful, causes the object implementing IO to direct a copy of
@@ -51,6 +62,10 @@ type IO interface {
 	// AddOutput returns a non-nil error if the channel and sample rates of
 	// IO.OutForm() and d are not compatible.
 	//
+	// AddOutput may be called while the IO runs, in which case it waits for
+	// the block being processed and d is sent the output starting with the
+	// next block.  It returns an error once the IO
+	// has ended.
 	AddOutput(d sound.Sink, cs ...int) error
 
 	// Output returns the output of the node as a sound.Source.
@@ -63,6 +78,9 @@ type IO interface {
 	//
 	// Every non-panicking call to Output generates a distinct new sound.Source which
 	// can be used independently in different goroutines.
+	//
+	// Like AddOutput, Output may be called while the IO runs.  Once the IO
+	// has ended, the result is at end of stream.
 	Output(cs ...int) sound.Source
 
 	// Run runs the IO plug.  Run blocks until it returns.  It will return a non-nil
@@ -71,13 +89,359 @@ type IO interface {
 	Run() error
 }
 
+// Stopper is implemented by IOs which may be stopped before their inputs
+// end.
+type Stopper interface {
+	// Stop stops the IO gracefully: the IO stops receiving from its inputs
+	// as if they had ended, processes its run-out if any (see SetRunOut),
+	// sends the last blocks to its outputs and closes them, so that IOs
+	// downstream end in turn.  Stop does not wait for Run to return.
+	Stop()
+
+	// RunContext is like Run, but if ctx is done before the IO ends, the IO
+	// is stopped and all Sources going into it and Sinks going out are
+	// Close()d, unblocking any pending Receive or Send.  RunContext then
+	// returns ctx.Err() once Run would have returned.
+	RunContext(ctx context.Context) error
+}
+
+// Notifier is implemented by IOs reporting events and errors while they
+// run.
+type Notifier interface {
+	// OnEvent registers fn to be called with events occuring while the IO
+	// runs, replacing any previously registered function.  fn is called from
+	// the goroutine which called Run, between processing blocks.
+	OnEvent(fn func(Event))
+
+	// OnError registers fn to be called with every error the IO
+	// encounters receiving from its inputs, processing or sending to its
+	// outputs, replacing any previously registered function.  Unlike the
+	// error returned by Run, errors of outputs which are then detached are
+	// reported too.  fn is called like the function registered with
+	// OnEvent, so errors ending Run are reported before Run returns.
+	OnError(fn func(error))
+}
+
+// Pauser is implemented by IOs which may be suspended between processing
+// blocks.
+type Pauser interface {
+	// Quiesce stops the IO at the next processing block boundary, and returns
+	// once the processor is not running.  While quiesced, the IO holds at
+	// most one block of received input, and its processor is not called.
+	Quiesce()
+
+	// Pause stops the IO from receiving from its inputs at the next
+	// processing block boundary, without closing anything, so that a live
+	// capture may be suspended and resumed.  Unlike Quiesce, Pause does not
+	// wait and the IO holds no received input while paused.  Stop ends a
+	// paused IO.
+	Pause()
+
+	// Resume resumes processing after Quiesce or Pause.
+	Resume()
+}
+
+// Checkpointer is implemented by IOs whose state may be captured while
+// quiesced and restored.
+type Checkpointer interface {
+	// Checkpoint captures the state of a quiesced IO: its input position,
+	// any input frames received but not yet processed, including those
+	// received from other IOs for an incomplete block, and the state of
+	// its processor, if the processor implements StateSaver.  Frames
+	// received from other sources are not captured, so a graph restored
+	// from a checkpoint should have its sources positioned at the
+	// checkpointed input positions of the nodes reading them.
+	Checkpoint() (*NodeState, error)
+
+	// Restore restores the state captured by Checkpoint, and should be called
+	// before Run.
+	Restore(st *NodeState) error
+}
+
+// OutputController is implemented by IOs offering further kinds of
+// outputs and control over them.
+type OutputController interface {
+	// AddOutputWith is like AddOutput, with the channels and further
+	// options given by opts, such as per channel gains to attenuate a
+	// monitor send.
+	AddOutputWith(d sound.Sink, opts OutputOptions) error
+
+	// AddSecondaryOutput is like AddOutput, except that the output is
+	// secondary: sending to it never delays processing or the other outputs.
+	// Instead, up to queue blocks are buffered for d, and further blocks are
+	// dropped while the buffer is full.  Outputs added with AddOutput or
+	// Output are primary.
+	//
+	// Secondary outputs are meant for recorders, meters and the like which
+	// should not disturb a primary output such as a playback device.  If d
+	// fails, it is detached: it is sent no further blocks, an
+	// OutputDetached event is delivered, and the IO goes on.  Like
+	// AddOutput, AddSecondaryOutput may be called while the IO runs.
+	AddSecondaryOutput(d sound.Sink, queue int, cs ...int) (*SecondaryOutput, error)
+
+	// AddMonitor adds a monitor output which receives the input of the IO,
+	// or the input channels cs if cs is not empty, as soon as it is
+	// received, while the processor runs in parallel.  A monitor lets a
+	// performer hear their input without the latency of processing.
+	// Like secondary outputs, monitors never delay processing: blocks are
+	// dropped when a monitor falls more than a couple of blocks behind, and
+	// a failing monitor is detached.
+	// AddMonitor may be called while the IO runs.
+	AddMonitor(d sound.Sink, cs ...int) (*SecondaryOutput, error)
+
+	// ReplaceOutput replaces the sink of output i, which is the i'th output
+	// added by AddOutput or Output, with d, reattaching it if it was
+	// detached.  d must have the same form as the sink it replaces.  Output
+	// i must have been added by AddOutput.
+	ReplaceOutput(i int, d sound.Sink) error
+
+	// RemoveOutput removes the output d added by AddOutput: the IO closes d
+	// and no longer sends to it, without disturbing its other outputs.
+	// RemoveOutput may be called while the IO runs.  Removed outputs keep
+	// their index, as given to ReplaceOutput, but cannot be replaced.
+	RemoveOutput(d sound.Sink) error
+
+	// RemoveOutputSource is like RemoveOutput for an output s created by
+	// Output.  The reader of s then receives io.EOF.
+	RemoveOutputSource(s sound.Source) error
+
+	// SetDetachOnError sets whether an output which fails while running is
+	// detached rather than ending the IO.  When an output is detached, its
+	// sink is closed, an OutputDetached event is delivered and the IO
+	// continues serving its other outputs.
+	SetDetachOnError(v bool)
+
+	// SetBackpressure sets the policy of output i, in the order of calls to
+	// AddOutput and Output, for when its sink does not keep up.  Output i
+	// is then queued, overriding SetDepth.  SetBackpressure must be called
+	// before Run.  Discarded blocks are reported by OutputDropped events.
+	SetBackpressure(i int, p Backpressure) error
+
+	// SetDepth sets the number of blocks which may be in flight on each
+	// output of the IO.  With a depth of 0, the default, the IO waits for
+	// every output to accept a block before processing the next one, so a
+	// chain of IOs processes blocks in lock step.  With a depth of n > 0, up
+	// to n blocks are buffered per output, allowing the IO to process ahead
+	// of its consumers: this trades latency for throughput.  Errors sending
+	// to outputs are then reported one block late, and failed outputs are
+	// not detached.  SetDepth must be called before Run.
+	SetDepth(n int)
+}
+
+// InputController is implemented by IOs offering control over how their
+// inputs are received.
+type InputController interface {
+	// SetSilenceFill makes the IO fill the input channels cs, or all input
+	// channels if cs is empty, with silence while they have no input, so
+	// that a partially connected IO may run.  An IO with no input at all
+	// then runs until stopped.
+	SetSilenceFill(cs ...int)
+
+	// SetMixPolicy sets how the IO mixes several sources set as input to
+	// the same channel by SetInput.  With the default policy, MixNone,
+	// SetInput returns an error for a channel which already has an input.
+	// SetMixPolicy should be called before SetInput.
+	SetMixPolicy(p MixPolicy)
+
+	// SetRunOut sets the number of frames of silence the IO processes after
+	// its inputs end, before ending its outputs.  A run-out lets reverbs,
+	// delays and limiters settle and gives downstream devices a clean end
+	// instead of an abrupt one.  SetRunOut should be called before Run.
+	SetRunOut(frames int)
+
+	// SetRetryPolicy sets the policy for handling errors receiving from the
+	// inputs and sending to the outputs of the IO.  SetRetryPolicy should
+	// be called before Run.
+	SetRetryPolicy(p RetryPolicy)
+}
+
+// BusConnector is implemented by IOs whose channels may be grouped in
+// named buses.
+type BusConnector interface {
+	// AddInputBus names the group of input channels b.Channels as the bus
+	// b.Name, so that it may be connected by Graph.ConnectBus.  It returns
+	// an error if the name is taken or the channels do not fit the layout
+	// or the input form.
+	AddInputBus(b Bus) error
+
+	// AddOutputBus is like AddInputBus for output channels.
+	AddOutputBus(b Bus) error
+
+	// InputBus returns the input bus named name, if any.
+	InputBus(name string) (Bus, bool)
+
+	// OutputBus returns the output bus named name, if any.
+	OutputBus(name string) (Bus, bool)
+}
+
+// ProcessorController is implemented by IOs giving access to their
+// processor.
+type ProcessorController interface {
+	// SwapProcessor replaces the processor of the IO with p between two
+	// processing blocks, so that a live graph may change effects without
+	// being rebuilt.  If fade > 0, the outputs of the old and new
+	// processors are crossfaded over fade frames, during which both run on
+	// the same input; they should then map frames one to one and have the
+	// same latency.
+	//
+	// If the IO is running, p is started before the swap, and SwapProcessor
+	// returns the error of its Start method if any, leaving the processor
+	// unchanged.  The old processor is closed once it no longer runs, when
+	// the swap or fade completes, and errors closing it are reported as
+	// per Notifier.OnError.
+	SwapProcessor(p Processor, fade int) error
+
+	// SetBypass sets whether the IO bypasses its processor, routing its
+	// input directly to its output instead, so that an effect may be
+	// compared with its input while running.  The processor is kept and
+	// not called while bypassed.  The output has as many frames as the
+	// processor would output.  A single input channel is copied to every
+	// output channel, all input channels are averaged into a single output
+	// channel, and otherwise channels are copied in order, any further
+	// output channels being silent.  Like Apply, SetBypass takes effect at
+	// the next block boundary and does not wait for the block being
+	// processed.
+	SetBypass(v bool)
+
+	// Reset resets the processor of the IO if it implements Resetter.
+	// Reset may be called while the IO runs, in which case it waits for
+	// the block being processed and the next block is processed from a
+	// clear state.
+	Reset()
+
+	// Latency returns the latency of the processor of the IO, in input
+	// frames, as reported by its LatencyReporter if any.  Graph.Latency
+	// aggregates the latencies along the paths of a graph.
+	Latency() int
+
+	// Validate performs the checks which Run would perform, without moving
+	// any audio: it checks that all the input and output channels are
+	// connected and that the shape of the processor is compatible with the
+	// input and output forms.  If the processor implements Resetter, it is
+	// also called once on a block of silence to check that it does not
+	// fail, and then reset.  Other processors are not called, so that
+	// their state is unchanged.
+	Validate() error
+
+	// SetAdaptation makes the IO adapt the block size of its processor,
+	// which must implement BlockSizer, to the measured headroom as per a.
+	// If a is nil, the block size is not adapted.
+	SetAdaptation(a *Adaptation)
+
+	// ReadAt renders dst.Frames frames of output starting at output frame
+	// position frame into dst, pulling the input from upstream on demand
+	// rather than streaming it.  ReadAt requires every source upstream of
+	// the IO to be either a Seeker or the Output of another IO, and lets
+	// editors render arbitrary regions without running the graph from the
+	// start.  If the inputs end, the remainder of dst is silent and ReadAt
+	// returns io.EOF.
+	//
+	// The processor is run from whatever state it is in, so stateful
+	// processors should be given some pre-roll by reading from a slightly
+	// earlier position.  ReadAt must not be called while the IO runs.
+	ReadAt(frame int64, dst *Block) error
+}
+
+// Parameterized is implemented by IOs whose processor parameters may be
+// changed while running.
+type Parameterized interface {
+	// Params returns the parameters of the processor of the IO, if it
+	// implements Params, and nil otherwise.  Unlike those of the processor,
+	// the methods of the result are safe to call while the IO runs: Set
+	// applies changes between processing blocks like Apply, and Get
+	// reflects changes not yet applied.  Neither waits for the block being
+	// processed.
+	Params() Params
+
+	// Apply applies a parameter change between processing blocks: set(v) is
+	// called before the next block is processed.  name identifies the
+	// parameter in the Journal, if any.  Apply does not wait for the block
+	// being processed, nor for the inputs and outputs of the IO.
+	//
+	// Apply is the means to change processor parameters at a well defined
+	// frame position.
+	Apply(name string, v float64, set func(float64))
+
+	// ApplyAt is like Apply, but schedules the change to take effect
+	// exactly at input frame frame, counted like the frames of a Journal,
+	// for automation and tempo synced effects.  The IO splits the block in
+	// which the frame falls, processing the frames before it with the old
+	// value.  Changes scheduled at a frame already processed take effect
+	// at the next block.
+	ApplyAt(frame int64, name string, v float64, set func(float64))
+
+	// SetJournal sets the journal in which parameter changes applied by the
+	// IO are recorded.  If j is nil, changes are not recorded.
+	SetJournal(j *Journal)
+}
+
+// Instrumented is implemented by IOs which may be measured, traced and
+// limited.
+type Instrumented interface {
+	// Stats returns the state of the IO and its progress so far.  Unlike
+	// most methods, Stats does not wait for the block being processed, so
+	// that supervisors and user interfaces may poll it while the IO runs.
+	Stats() Stats
+
+	// TrackProvenance makes the IO record, under name, a step in the
+	// provenance of the blocks it processes.  Provenance is passed from IO
+	// to IO through outputs created by Output, and to sinks implementing
+	// ProvenanceSink added by AddOutput, but not through queued or
+	// secondary outputs.  IOs which do not track provenance pass on that of
+	// their inputs.
+	TrackProvenance(name string)
+
+	// SetTrace sets the trace in which the IO records its processing
+	// blocks.  If t is nil, no trace is recorded.
+	SetTrace(t *Trace)
+
+	// SetClock makes the IO the master of c: c is advanced by the number of
+	// input frames of every block the IO processes.  If c is nil, the IO
+	// advances no clock.
+	SetClock(c *Clock)
+
+	// SetBudget sets the memory budget from which the IO reserves its
+	// sample buffers.  If b is nil, the IO's memory is not limited.
+	// SetBudget must be called before Run.
+	SetBudget(b *Budget)
+}
+
+// Plug is the interface of the IOs created by New and Graph.New, which
+// implement all the optional interfaces of IO.
+type Plug interface {
+	IO
+	Stopper
+	Notifier
+	Pauser
+	Checkpointer
+	OutputController
+	InputController
+	BusConnector
+	ProcessorController
+	Parameterized
+	Instrumented
+}
+
 type node struct {
 	mu             sync.Mutex
 	iForm, oForm   sound.Form
 	iBlock, oBlock *Block
 	icCounts       []int
 	ocCounts       []int
+	iBuses, oBuses []Bus
+	mix            MixPolicy
+	mixed          []bool // per input channel, whether a source was put
+	fill           []bool // per input channel, whether to fill with silence
+	adapt          *adapter
+	bypass         bool // as of the block being processed
+	stats          ioStats
+	ilv            [2]Block // interleaved blocks for an Interleaver
 
+	// wmu guards the iPkts and oPkts slices and the sources and sinks of
+	// their packets, which change while n runs, for abort and Graph.fed,
+	// which cannot take mu, as well as queues, for Quiesce.  Changes hold
+	// both mu and wmu.
+	wmu   sync.Mutex
 	ins   []*conn
 	outs  []*conn
 	iPkts []packet
@@ -88,11 +452,65 @@ type node struct {
 	odC   chan *packet
 	doneC chan struct{}
 	proc  Processor
+
+	running  bool // whether Run is serving the conns
+	started  bool // whether Run has started the processor
+	finished bool // whether Run has ended
+
+	onEvent  func(Event)
+	events   []Event
+	onError  func(error)
+	errs     []error
+	retry    RetryPolicy
+	pos      int64
+	version  int64  // number of changes applied
+	next     [2]int // frames requested for the next block, if hasNext
+	hasNext  bool
+	provName string
+	prov     Provenance // of the block being processed
+	anns     []Annotation
+	trace    *Trace
+	queues   []*outQueue
+	monitors []*outQueue
+	depth    int
+	clock    *Clock
+	detach   bool
+	runOut   int
+	ended    bool
+	budget   *Budget
+	rBlocks  int64 // bytes reserved from budget for blocks and packets
+	rQueues  int64 // bytes reserved from budget for output queues
+	stopC    chan struct{}
+	seq      *seqNode // non-nil when run by Graph.RunSequential
+	gate     *hosted  // non-nil when run by a Host
+	stopOnce sync.Once
+
+	// pmu guards the changes queued by Apply, ApplyAt, Params and
+	// SetBypass and the journal, so that control code need not wait for
+	// mu, which is held while n processes, including while it waits for its
+	// inputs and outputs.  process applies the changes at block boundaries,
+	// holding both.
+	pmu        sync.Mutex
+	pending    []pendingChange
+	sched      []pendingChange // by frame, queued by ApplyAt
+	bypassNext bool
+	journal    *Journal
+
+	// quiesce and checkpoint state
+	qmu         sync.Mutex
+	qc          *sync.Cond
+	quiesced    bool
+	paused      bool
+	wake        chan struct{} // signalled by Resume and Stop in a sequential run
+	inProc      bool
+	held        bool
+	carry       []float64
+	carryFrames int
 }
 
 // New creates a new plug mapping input of channels and sampling frequency
 // iForm to output oForm, using the Processor proc
-func New(iForm, oForm sound.Form, proc Processor) IO {
+func New(iForm, oForm sound.Form, proc Processor) Plug {
 	res := &node{
 		icCounts: make([]int, iForm.Channels()),
 		ocCounts: make([]int, oForm.Channels()),
@@ -105,11 +523,13 @@ func New(iForm, oForm sound.Form, proc Processor) IO {
 		inC:      make(chan *packet),
 		prC:      make(chan *packet),
 		doneC:    make(chan struct{}),
+		stopC:    make(chan struct{}),
 		iForm:    iForm,
 		oForm:    oForm,
 		iBlock:   &Block{SampleRate: iForm.SampleRate(), Channels: iForm.Channels()},
 		oBlock:   &Block{SampleRate: oForm.SampleRate(), Channels: oForm.Channels()},
 		proc:     proc}
+	res.qc = sync.NewCond(&res.qmu)
 	return res
 }
 
@@ -140,18 +560,61 @@ func (n *node) Output(cs ...int) sound.Source {
 	}
 	conn := newConn(n.oC, n.odC, n.doneC)
 	m := len(n.outs)
+	n.wmu.Lock()
 	n.outs = append(n.outs, conn)
 	n.oPkts = append(n.oPkts, packet{})
 	pkt := &n.oPkts[m]
 	pkt.init(n.oForm, cs...)
-	pkt.src, pkt.snk = sound.Pipe(ov)
-	return pkt.src
+	pkt.src, pkt.snk = newPipe(ov)
+	n.wmu.Unlock()
+	pkt.prov = &provSlot{}
+	pkt.ann = &annSlot{}
+	res := &nodeSource{Source: pkt.src, n: n, cs: append([]int(nil), cs...), prov: pkt.prov, ann: pkt.ann}
+	if n.ckAttach() != nil {
+		// the output will never be sent to.
+		pkt.off = true
+		pkt.snk.Close()
+		return res
+	}
+	if n.startOut(m) != nil {
+		pkt.snk.Close()
+	}
+	return res
 }
 
 // AddOutput implements IO.
 func (n *node) AddOutput(d sound.Sink, cs ...int) error {
 	n.mu.Lock()
 	defer n.mu.Unlock()
+	return n.addOutput(d, nil, cs...)
+}
+
+// addOutput adds the output d with per channel gains, if not nil.  n.mu
+// must be held.
+func (n *node) addOutput(d sound.Sink, gains []float64, cs ...int) error {
+	if err := n.ckAttach(); err != nil {
+		return err
+	}
+	if err := n.ckOutput(d, cs...); err != nil {
+		return err
+	}
+	conn := newConn(n.oC, n.odC, n.doneC)
+	m := len(n.outs)
+	n.wmu.Lock()
+	n.outs = append(n.outs, conn)
+	n.oPkts = append(n.oPkts, packet{})
+	pkt := &n.oPkts[m]
+	pkt.init(n.oForm, cs...)
+	pkt.snk = d
+	pkt.src = nil
+	pkt.gains = gains
+	n.wmu.Unlock()
+	return n.startOut(m)
+}
+
+// ckOutput checks that d may be used as an output for channels cs, and
+// if so counts the channels as connected.
+func (n *node) ckOutput(d sound.Sink, cs ...int) error {
 	if d.SampleRate() != n.oForm.SampleRate() {
 		return fmt.Errorf("frequency mismatch: got %s not %s\n", d.SampleRate(), n.iForm.SampleRate())
 	}
@@ -170,14 +633,6 @@ func (n *node) AddOutput(d sound.Sink, cs ...int) error {
 			n.ocCounts[c]++
 		}
 	}
-	conn := newConn(n.oC, n.odC, n.doneC)
-	m := len(n.outs)
-	n.outs = append(n.outs, conn)
-	n.oPkts = append(n.oPkts, packet{})
-	pkt := &n.oPkts[m]
-	pkt.init(n.oForm, cs...)
-	pkt.snk = d
-	pkt.src = nil
 	return nil
 }
 
@@ -188,37 +643,110 @@ func (n *node) SetInput(src sound.Source, cs ...int) error {
 	if src.SampleRate() != n.iForm.SampleRate() {
 		return fmt.Errorf("frequency mismatch: got %s not %s\n", src.SampleRate(), n.iForm.SampleRate())
 	}
+	if err := n.ckAttach(); err != nil {
+		return err
+	}
 	if err := n.ckInputsUnique(cs...); err != nil {
 		return err
 	}
 	conn := newConn(n.inC, n.prC, n.doneC)
 	m := len(n.ins)
+	n.wmu.Lock()
 	n.ins = append(n.ins, conn)
 	n.iPkts = append(n.iPkts, packet{})
 	pkt := &n.iPkts[m]
 	pkt.init(n.iForm, cs...)
 	pkt.src = src
+	n.wmu.Unlock()
+	n.startIn(m)
 	return nil
 }
 
+// SetDepth implements OutputController.
+func (n *node) SetDepth(d int) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.depth = d
+}
+
+// SetRetryPolicy implements InputController.
+func (n *node) SetRetryPolicy(p RetryPolicy) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.retry = p
+}
+
+// OnEvent implements Notifier.
+func (n *node) OnEvent(fn func(Event)) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.onEvent = fn
+}
+
+// emit queues an event for delivery after the current block.  n.mu
+// must be held.
+func (n *node) emit(e Event) {
+	n.events = append(n.events, e)
+}
+
+func (n *node) deliverEvents() {
+	n.deliverErrors()
+	n.mu.Lock()
+	evs := n.events
+	n.events = nil
+	fn := n.onEvent
+	n.mu.Unlock()
+	if fn == nil {
+		return
+	}
+	for _, e := range evs {
+		fn(e)
+	}
+}
+
 // Run implements T running the plug.
-func (n *node) Run() error {
+func (n *node) Run() (res error) {
 	defer func() {
+		n.mu.Lock()
+		defer n.mu.Unlock()
+		if err := n.closeProc(); err != nil && res == nil {
+			res = err
+		}
+		n.running = false
+		n.finished = true
+		n.setState(Finished)
 		close(n.doneC)
 		for i := range n.oPkts {
-			n.oPkts[i].snk.Close()
+			if !n.oPkts[i].off && n.oPkts[i].q == nil {
+				n.oPkts[i].snk.Close()
+			}
+		}
+		for _, q := range n.queues {
+			q.close()
+		}
+		for _, q := range n.monitors {
+			q.close()
 		}
 		for i := range n.iPkts {
 			n.iPkts[i].src.Close()
 		}
+		if n.budget != nil {
+			n.budget.release(n.rBlocks)
+			n.budget.release(n.rQueues)
+			n.rBlocks, n.rQueues = 0, 0
+		}
 	}()
-	if err := n.checkConns(); err != nil {
+	if err := n.serve(); err != nil {
 		return err
 	}
-	n.serve()
 	var err error
 	for {
+		n.waitPaused()
+		if n.gate != nil {
+			n.gate.throttle()
+		}
 		err = n.process()
+		n.deliverEvents()
 		if err == io.EOF {
 			return nil
 		}
@@ -228,14 +756,47 @@ func (n *node) Run() error {
 	}
 }
 
-func (n *node) process() error {
+// nextFrames returns the input and output frames requested by the
+// processor for the next block.  The processor's NextFrames is called once
+// per block however often nextFrames is, so that processors may advance
+// a schedule in NextFrames.  n.mu must be held.
+func (n *node) nextFrames() (int, int) {
+	if !n.hasNext {
+		n.next[0], n.next[1] = n.proc.NextFrames()
+		n.hasNext = true
+	}
+	return n.next[0], n.next[1]
+}
+
+// takeFrames is like nextFrames, for the block about to be processed.
+func (n *node) takeFrames() (int, int) {
+	iFrms, oFrms := n.nextFrames()
+	n.hasNext = false
+	return iFrms, oFrms
+}
+
+func (n *node) process() (res error) {
 	n.mu.Lock()
 	defer n.mu.Unlock()
+	defer func() {
+		if res != nil && res != io.EOF {
+			n.failed(res)
+		}
+	}()
+	v := n.version
+	n.applyPending()
+	if n.version != v {
+		// the changes may change the frames requested.
+		n.hasNext = false
+	}
 	proc := n.proc
 	iC := n.iForm.Channels()
 	oC := n.oForm.Channels()
-	iFrms, oFrms := proc.NextFrames()
+	iFrms, oFrms := n.takeFrames()
 	iBlock, oBlock := n.iBlock, n.oBlock
+	if err := n.reserveBlocks(iFrms, oFrms); err != nil {
+		return err
+	}
 
 	// ensure buffers are allocated as per request from proc.
 	iBlock.Samples = buffer(n.iBlock.Samples, iC, iFrms)
@@ -243,34 +804,135 @@ func (n *node) process() error {
 	oBlock.Samples = buffer(n.oBlock.Samples, oC, oFrms)
 	oBlock.Frames = oFrms
 
-	// trigger receives on all inputs
-	for i := range n.ins {
-		pkt := &n.iPkts[i]
-		pkt.err = nil
-		pkt.n = iFrms
-		pkt.samples = buffer(pkt.samples, pkt.nC, pkt.n)
-		n.inC <- pkt
+	// read all input into iBlock, or take it from a restored checkpoint.
+	var nFrms int
+	var err error
+	add := 0
+	switch {
+	case n.carry != nil:
+		nFrms = n.takeCarry(iBlock)
+	case n.ended:
+		nFrms, err = n.runOutBlock(iBlock, n.until(iFrms))
+		if err == io.EOF {
+			nFrms, err = n.tailBlock(proc, iBlock)
+		}
+		if err != nil {
+			return err
+		}
+		iBlock.Frames = nFrms
+	default:
+		nFrms, err = n.receive(iBlock, n.until(iFrms), oFrms)
+		if err == io.EOF && n.runOut > 0 {
+			n.ended = true
+			nFrms, err = n.runOutBlock(iBlock, n.until(iFrms))
+		}
+		if err == io.EOF {
+			nFrms, err = n.tailBlock(proc, iBlock)
+		}
+		if err != nil {
+			return err
+		}
+		iBlock.Frames = nFrms
+		add = nFrms
+		if err := n.monitor(iBlock); err != nil {
+			return err
+		}
 	}
-
-	// read all input into iBlock
-	nFrms := -1
-	for i := range n.ins {
-		_ = i
-		pkt := <-n.prC
-		if pkt.err != nil {
-			return pkt.err
+	frame := n.pos
+	n.stamp(iBlock, oBlock, frame)
+	n.enter(add)
+	defer n.leave()
+	n.prov = n.provenance(frame, add)
+	n.annotate(iBlock, oBlock, add)
+	began := time.Now()
+	switch {
+	case n.bypass:
+		bypassBlock(iBlock, oBlock, nFrms, iFrms, oFrms)
+	case nFrms > 0 && skipSilence(proc, iBlock, oBlock, nFrms, iFrms, oFrms):
+	case n.gate != nil:
+		start := n.gate.acquire()
+		err = n.runProc(proc, iBlock, oBlock, nFrms)
+		n.gate.release(start, nFrms)
+	default:
+		err = n.runProc(proc, iBlock, oBlock, nFrms)
+	}
+	n.adaptBlocks(proc, time.Since(began), iFrms, nFrms)
+	if err != nil {
+		return err
+	}
+	if sf, ok := proc.(*swapFade); ok && sf.done() && n.proc == proc {
+		n.setProc(sf.b)
+		n.retire(sf.a)
+	}
+	n.count(iFrms, oFrms, nFrms, oBlock.Frames)
+	n.carryAnnotations(oBlock)
+	if n.clock != nil {
+		n.clock.advance(nFrms)
+	}
+	if n.trace != nil {
+		n.trace.record(TraceBlock{In: iFrms, Out: oFrms, Frames: nFrms, Produced: oBlock.Frames})
+	}
+	// queue the queued outputs, then send out the others
+	for _, q := range n.queues {
+		if err := q.put(oBlock); err != nil {
+			return err
 		}
-		m := pkt.put(iBlock)
-		if nFrms == -1 {
-			nFrms = m
+		n.reportDrops(q, n.outQueueIndex(q))
+		n.reportDetached(q)
+	}
+	if n.seq != nil {
+		return n.seq.send(oBlock)
+	}
+	nSent := 0
+	for i := range n.oPkts {
+		pkt := &n.oPkts[i]
+		if pkt.off || pkt.q != nil {
+			continue
 		}
-		if m != nFrms {
-			panic("wilma!")
+		pkt.get(oBlock)
+		n.sendProvenance(pkt, pkt.n)
+		n.sendAnnotations(pkt, oBlock)
+		n.oC <- pkt
+		nSent++
+	}
+	// and make sure they are done, reporting any errors.
+	var oErr error
+	for i := 0; i < nSent; i++ {
+		pkt := <-n.odC
+		if pkt.err == nil {
+			continue
 		}
+		if !n.detach || pkt.src != nil {
+			oErr = pkt.err
+			continue
+		}
+		pkt.off = true
+		pkt.snk.Close()
+		n.failed(pkt.err)
+		n.emit(&OutputDetached{Output: n.outIndex(pkt), Err: pkt.err})
 	}
-	iBlock.Frames = nFrms
+	return oErr
+}
 
-	// actually finally process
+// renegotiate adapts the input packet pkt to the new number of channels
+// of its source and prepares it to receive frms frames again.
+func (n *node) renegotiate(pkt *packet, frms int) {
+	old := pkt.nC
+	pkt.nC = pkt.src.Channels()
+	pkt.err = nil
+	pkt.n = frms
+	pkt.samples = buffer(pkt.samples, pkt.nC, pkt.n)
+	for i := range n.iPkts {
+		if &n.iPkts[i] == pkt {
+			n.emit(&ChannelsChanged{Input: i, Old: old, New: pkt.nC})
+			break
+		}
+	}
+}
+
+// runProc runs the processor on the nFrms input frames in iBlock.
+func (n *node) runProc(proc Processor, iBlock, oBlock *Block, nFrms int) error {
+	iC := n.iForm.Channels()
 	switch proc.ChannelMode() {
 	case MonoMode:
 		// save channels and samples members and restore them later
@@ -280,6 +942,8 @@ func (n *node) process() error {
 		isl := iBlock.Samples
 		oc := oBlock.Channels
 		osl := oBlock.Samples
+		pick := monoPick(proc)
+		iBlock.Channels, oBlock.Channels = 1, 1
 		for i := 0; i < iC; i++ {
 			iStart := i * nFrms
 			iEnd := iStart + nFrms
@@ -287,7 +951,9 @@ func (n *node) process() error {
 			oStart := i * nFrms
 			oEnd := oStart + nFrms
 			oBlock.Samples = osl[oStart:oEnd]
-			if err := proc.Process(oBlock, iBlock); err != nil {
+			if err := pick(i).Process(oBlock, iBlock); err != nil {
+				iBlock.Channels, iBlock.Samples = ic, isl
+				oBlock.Channels, oBlock.Samples = oc, osl
 				return err
 			}
 		}
@@ -297,59 +963,181 @@ func (n *node) process() error {
 		oBlock.Samples = osl
 
 	case FullMode:
-		err := proc.Process(oBlock, iBlock)
-		if err != nil {
-			return err
-		}
+		return runLayout(proc, oBlock, iBlock, &n.ilv)
 	default:
 		panic("wilma!")
 	}
-	// send out the outputs
-	for i := range n.oPkts {
-		pkt := &n.oPkts[i]
-		pkt.get(oBlock)
-		n.oC <- pkt
+	return nil
+}
+
+// receive receives iFrms frames from all the inputs into iBlock,
+// returning the number of frames received.
+func (n *node) receive(iBlock *Block, iFrms, oFrms int) (int, error) {
+	if n.stopped() {
+		return 0, io.EOF
 	}
-	// and make sure they are done, reporting any errors.
-	for i := range n.oPkts {
-		_ = i
-		pkt := <-n.odC
+	if n.seq != nil {
+		return n.seq.receive(iBlock, iFrms)
+	}
+	// trigger receives on all inputs
+	for i := range n.ins {
+		pkt := &n.iPkts[i]
+		pkt.err = nil
+		pkt.n = iFrms
+		pkt.samples = buffer(pkt.samples, pkt.nC, pkt.n)
+		n.inC <- pkt
+	}
+
+	// read all input into iBlock
+	nFrms := -1
+	n.startPut()
+	for i := 0; i < len(n.ins); i++ {
+		var pkt *packet
+		select {
+		case pkt = <-n.prC:
+		case <-n.stopC:
+			return 0, io.EOF
+		}
+		if pkt.err == ErrChannelsChanged {
+			n.renegotiate(pkt, iFrms)
+			n.inC <- pkt
+			i--
+			continue
+		}
 		if pkt.err != nil {
-			return pkt.err
+			if pkt.err == io.EOF && n.trace != nil {
+				n.trace.record(TraceBlock{In: iFrms, Out: oFrms, EOF: true})
+			}
+			return 0, pkt.err
+		}
+		pkt.delay()
+		m := n.put(pkt, iBlock)
+		if nFrms == -1 {
+			nFrms = m
+		}
+		if m != nFrms {
+			panic("wilma!")
 		}
 	}
-	return nil
+	if nFrms == -1 {
+		// all input channels are filled with silence.
+		nFrms = iFrms
+	}
+	n.endPut(iBlock, nFrms)
+	return nFrms, nil
 }
 
-func (n *node) serve() {
+func (n *node) serve() error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if err := n.checkConns(); err != nil {
+		return err
+	}
+	if err := n.startProc(); err != nil {
+		return err
+	}
+	if err := n.serveQueues(); err != nil {
+		return err
+	}
+	n.running = true
+	n.setState(Running)
 	for _, iConn := range n.ins {
+		iConn.retry = n.retry
 		go iConn.serve()
 	}
-	for _, oConn := range n.outs {
+	for i, oConn := range n.outs {
+		if n.oPkts[i].q != nil {
+			continue
+		}
+		oConn.retry = n.retry
 		go oConn.serve()
 	}
+	for _, q := range n.queues {
+		q.run = true
+		go q.serve()
+	}
+	for _, q := range n.monitors {
+		q.run = true
+		go q.serve()
+	}
+	return nil
 }
 
-func (n *node) ckInputsUnique(cs ...int) error {
-	// check all input channels have at most one source
-	if len(cs) == 0 {
-		for c, ct := range n.icCounts {
-			if ct > 0 {
-				return fmt.Errorf("channel %d already has an input", c)
-			}
+// serveQueues creates the queues of the outputs as per SetDepth and
+// SetBackpressure, and fits all queues to the budget, if any, once the
+// blocks have been reserved.
+func (n *node) serveQueues() error {
+	var qs []*outQueue
+	for i := range n.oPkts {
+		pkt := &n.oPkts[i]
+		switch {
+		case pkt.removed:
+			continue
+		case pkt.bp != nil:
+			pkt.q = newPolicyQueue(pkt, n.doneC)
+		case n.depth > 0:
+			pkt.q = newOutQueue(pkt, n.depth, true, n.doneC)
+		default:
+			continue
 		}
-		for c := range n.icCounts {
-			n.icCounts[c] = 1
+		qs = append(qs, pkt.q)
+	}
+	n.wmu.Lock()
+	n.queues = append(qs, n.queues...)
+	n.wmu.Unlock()
+	if n.budget == nil {
+		return nil
+	}
+	iFrms, oFrms := n.nextFrames()
+	if err := n.reserveBlocks(iFrms, oFrms); err != nil {
+		return err
+	}
+	for _, q := range n.queues {
+		if err := n.reserveQueue(q, oFrms, n.outQueueIndex(q)); err != nil {
+			return err
 		}
-	} else {
+	}
+	for _, q := range n.monitors {
+		if err := n.reserveQueue(q, iFrms, -1); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ckInputsFree checks that the channels cs, or all channels if cs is
+// empty, have no input, unless n mixes its inputs.
+func (n *node) ckInputsFree(cs ...int) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if n.mix != MixNone {
+		return nil
+	}
+	if len(cs) == 0 {
+		cs = chanRange(0, len(n.icCounts))
+	}
+	for _, c := range cs {
+		if n.icCounts[c] > 0 {
+			return fmt.Errorf("channel %d already has an input", c)
+		}
+	}
+	return nil
+}
+
+func (n *node) ckInputsUnique(cs ...int) error {
+	// check all input channels have at most one source, unless mixing
+	if len(cs) == 0 {
+		cs = chanRange(0, len(n.icCounts))
+	}
+	if n.mix == MixNone {
 		for _, c := range cs {
 			if n.icCounts[c] > 0 {
 				return fmt.Errorf("channel %d already has an input", c)
 			}
 		}
-		for _, c := range cs {
-			n.icCounts[c] = 1
-		}
+	}
+	for _, c := range cs {
+		n.icCounts[c]++
 	}
 	return nil
 }
@@ -381,7 +1169,7 @@ func (n *node) ckOutputsUnique(cs ...int) error {
 func (n *node) checkConns() error {
 	// check local connectivity
 	for i, ct := range n.icCounts {
-		if ct == 0 {
+		if ct == 0 && !n.filled(i) {
 			return dce(true, i)
 		}
 	}
@@ -4,7 +4,9 @@
 package plug
 
 import (
+	"fmt"
 	"io"
+	"math"
 	"testing"
 
 	"zikichombo.org/sound"
@@ -179,3 +181,190 @@ func TestIODiamond(t *testing.T) {
 		t.Errorf("got %d not 44100", ttl)
 	}
 }
+
+func TestIODepth(t *testing.T) {
+	valve := sound.NewForm(44100*freq.Hertz, 2)
+	g := &Graph{}
+	u0 := g.New(valve, valve, PassThrough)
+	u1 := g.New(valve, valve, PassThrough)
+	u0.SetInput(ops.Limit(gen.Noise(), 44100), 0)
+	u0.SetInput(ops.Limit(gen.Noise(), 44100), 1)
+	u1.SetInput(u0.Output())
+	out := u1.Output()
+	g.SetDepth(4)
+	errs := g.Run()
+	buf := make([]float64, 1000)
+	ttl := 0
+	for {
+		n, err := out.Receive(buf)
+		ttl += n
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	for err := range errs {
+		t.Error(err)
+	}
+	if ttl != 44100 {
+		t.Errorf("got %d not 44100", ttl)
+	}
+}
+
+// ramp is a seekable mono source whose frame i has value i.
+type ramp struct {
+	sound.Form
+	pos, n int64
+}
+
+func (r *ramp) Close() error { return nil }
+
+func (r *ramp) SeekFrame(f int64) error {
+	r.pos = f
+	return nil
+}
+
+func (r *ramp) Receive(d []float64) (int, error) {
+	i := 0
+	for ; i < len(d) && r.pos < r.n; i++ {
+		d[i] = float64(r.pos)
+		r.pos++
+	}
+	if i == 0 {
+		return 0, io.EOF
+	}
+	return i, nil
+}
+
+// countSink counts the frames sent to it.
+type countSink struct {
+	sound.Form
+	n int
+}
+
+func (s *countSink) Close() error { return nil }
+
+func (s *countSink) Send(d []float64) error {
+	s.n += len(d) / s.Channels()
+	return nil
+}
+
+// lag delays a mono signal, reporting its latency.
+type lag struct {
+	*delayLine
+	d int
+}
+
+func (l *lag) ChannelMode() ChannelMode { return FullMode }
+func (l *lag) NextFrames() (int, int)   { return 1024, 1024 }
+func (l *lag) Latency() int             { return l.d }
+
+func (l *lag) Process(dst, src *Block) error {
+	l.run(dst.Samples[:src.Frames], src.Samples[:src.Frames])
+	dst.Frames = src.Frames
+	return nil
+}
+
+// tone is a sine source of a given length.
+type tone struct {
+	sound.Form
+	f   float64
+	i   int
+	end int
+}
+
+func (s *tone) Close() error { return nil }
+
+func (s *tone) Receive(d []float64) (int, error) {
+	if s.i == s.end {
+		return 0, io.EOF
+	}
+	n := len(d)
+	if n > s.end-s.i {
+		n = s.end - s.i
+	}
+	for j := 0; j < n; j++ {
+		d[j] = math.Sin(2 * math.Pi * s.f * float64(s.i+j) / s.SampleRate().Float64())
+	}
+	s.i += n
+	return n, nil
+}
+
+// echo passes its input through, then outputs blocks of ones as its
+// tail.
+type echo struct {
+	tail int
+}
+
+func (e *echo) ChannelMode() ChannelMode { return FullMode }
+func (e *echo) NextFrames() (int, int)   { return 1024, 1024 }
+func (e *echo) Tail() bool               { return e.tail > 0 }
+
+func (e *echo) Process(dst, src *Block) error {
+	if src.Frames > 0 {
+		copy(dst.Samples, src.Samples[:src.Channels*src.Frames])
+		dst.Frames = src.Frames
+		return nil
+	}
+	for i := range dst.Samples[:dst.Channels*dst.Frames] {
+		dst.Samples[i] = 1
+	}
+	e.tail--
+	return nil
+}
+
+// response returns the gain of p for a sine of frequency f, once settled.
+func response(p Processor, f float64) float64 {
+	mono := sound.MonoCd()
+	N := 4096
+	src := &Block{SampleRate: mono.SampleRate(), Channels: 1, Frames: N, Samples: make([]float64, N)}
+	dst := &Block{SampleRate: mono.SampleRate(), Channels: 1, Frames: N, Samples: make([]float64, N)}
+	var in, out float64
+	for b := 0; b < 8; b++ {
+		for i := range src.Samples {
+			src.Samples[i] = math.Sin(2 * math.Pi * f * float64(b*N+i) / 44100)
+		}
+		dst.Frames = N
+		p.Process(dst, src)
+		in, out = 0, 0
+		for i := range src.Samples {
+			in += src.Samples[i] * src.Samples[i]
+			out += dst.Samples[i] * dst.Samples[i]
+		}
+	}
+	return math.Sqrt(out / in)
+}
+
+// swapper swaps the channels of stereo interleaved blocks.
+type swapper struct{}
+
+func (s swapper) ChannelMode() ChannelMode { return FullMode }
+func (s swapper) NextFrames() (int, int)   { return 1024, 1024 }
+func (s swapper) Interleaved() bool        { return true }
+
+func (s swapper) Process(dst, src *Block) error {
+	if !src.Interleaved || !dst.Interleaved {
+		return fmt.Errorf("not interleaved")
+	}
+	for f := 0; f < src.Frames; f++ {
+		dst.Samples[2*f], dst.Samples[2*f+1] = src.Samples[2*f+1], src.Samples[2*f]
+	}
+	dst.Frames = src.Frames
+	return nil
+}
+
+// half halves float32 samples.
+type half struct{}
+
+func (h half) ChannelMode() ChannelMode { return MonoMode }
+func (h half) NextFrames() (int, int)   { return 1024, 1024 }
+
+func (h half) Process32(dst, src *Block32) error {
+	for i, v := range src.Samples[:src.Frames] {
+		dst.Samples[i] = v / 2
+	}
+	dst.Frames = src.Frames
+	return nil
+}
@@ -0,0 +1,85 @@
+// Copyright 2018 The ZikiChombo Authors. All rights reserved.  Use of this source
+// code is governed by a license that can be found in the License file.
+
+package plug
+
+import "sync"
+
+// ParamChange records a parameter change applied by an IO.
+type ParamChange struct {
+	// Frame is the position, in input frames since the IO started running,
+	// of the first frame processed with the new value.
+	Frame int64
+	Name  string
+	Value float64
+}
+
+// Journal records parameter changes with the frame position at which they
+// took effect, so that what happened live may be reproduced exactly or
+// automation debugged.
+//
+// A Journal may be shared by several IOs.
+type Journal struct {
+	mu      sync.Mutex
+	changes []ParamChange
+}
+
+// NewJournal creates a new empty Journal.
+func NewJournal() *Journal {
+	return &Journal{}
+}
+
+// Record adds c to the journal.
+func (j *Journal) Record(c ParamChange) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.changes = append(j.changes, c)
+}
+
+// Changes returns a copy of the changes recorded so far, in the order in
+// which they were recorded.
+func (j *Journal) Changes() []ParamChange {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return append([]ParamChange(nil), j.changes...)
+}
+
+type pendingChange struct {
+	name   string
+	value  float64
+	set    func(float64)
+	params bool  // whether set by the Params of the IO
+	frame  int64 // when scheduled by ApplyAt
+}
+
+// Apply implements Parameterized.
+func (n *node) Apply(name string, v float64, set func(float64)) {
+	n.pmu.Lock()
+	defer n.pmu.Unlock()
+	n.pending = append(n.pending, pendingChange{name: name, value: v, set: set})
+}
+
+// SetJournal implements Parameterized.
+func (n *node) SetJournal(j *Journal) {
+	n.pmu.Lock()
+	defer n.pmu.Unlock()
+	n.journal = j
+}
+
+// applyPending applies and journals pending changes, and the bypass set by
+// SetBypass.  n.mu must be held, and n.pmu is taken only while the changes
+// are applied.
+func (n *node) applyPending() {
+	n.pmu.Lock()
+	defer n.pmu.Unlock()
+	for _, c := range n.pending {
+		c.set(c.value)
+		if n.journal != nil {
+			n.journal.Record(ParamChange{Frame: n.pos, Name: c.name, Value: c.value})
+		}
+	}
+	n.version += int64(len(n.pending))
+	n.pending = n.pending[:0]
+	n.bypass = n.bypassNext
+	n.applyScheduled()
+}
@@ -0,0 +1,73 @@
+// Copyright 2018 The ZikiChombo Authors. All rights reserved.  Use of this source
+// code is governed by a license that can be found in the License file.
+
+package plug
+
+import (
+	"testing"
+
+	"zikichombo.org/sound"
+)
+
+// applying captures its input, calling fn once it has received more than
+// at frames.
+type applying struct {
+	capture
+	at int64
+	fn func()
+}
+
+func (a *applying) Send(d []float64) error {
+	if err := a.capture.Send(d); err != nil {
+		return err
+	}
+	if a.fn != nil && a.frames > a.at {
+		a.fn()
+		a.fn = nil
+	}
+	return nil
+}
+
+func TestIOJournal(t *testing.T) {
+	mono := sound.MonoCd()
+	g := 1.0
+	p := NewProcessorFrames(FullMode, func(dst, src *Block) error {
+		for i, v := range src.Samples[:src.Frames] {
+			dst.Samples[i] = g * v
+		}
+		dst.Frames = src.Frames
+		return nil
+	}, 500, 500)
+	u := New(mono, mono, p)
+	j := NewJournal()
+	u.SetJournal(j)
+	set := func(v float64) { g = v }
+	u.Apply("gain", 2, set)
+	u.SetInput(&ramp{Form: mono, n: 3000})
+	// applied while the block of frames 1500 to 2000 is sent, so taking
+	// effect at the boundary of the next block.
+	snk := &applying{capture: capture{Form: mono}, at: 1500}
+	snk.fn = func() { u.Apply("gain", 3, set) }
+	u.AddOutput(snk)
+	if err := u.Run(); err != nil {
+		t.Fatal(err)
+	}
+	if snk.frames != 3000 {
+		t.Fatalf("got %d frames", snk.frames)
+	}
+	cs := j.Changes()
+	want := []ParamChange{{Frame: 0, Name: "gain", Value: 2}, {Frame: 2000, Name: "gain", Value: 3}}
+	if len(cs) != len(want) {
+		t.Fatalf("got changes %v", cs)
+	}
+	for i := range want {
+		if cs[i] != want[i] {
+			t.Errorf("change %d: got %v not %v", i, cs[i], want[i])
+		}
+	}
+	for _, e := range [][2]float64{{1, 2}, {1999, 2 * 1999}, {2000, 3 * 2000}} {
+		if v := snk.mix[int(e[0])]; v != e[1] {
+			t.Errorf("frame %d: got %f not %f", int(e[0]), v, e[1])
+		}
+	}
+}
@@ -0,0 +1,85 @@
+// Copyright 2018 The ZikiChombo Authors. All rights reserved.  Use of this source
+// code is governed by a license that can be found in the License file.
+
+package plug
+
+import "zikichombo.org/sound"
+
+// ProcessorStarter is implemented by processors which need to be set up,
+// for example to allocate FFT plans or to open external resources, before
+// they process their first block.
+type ProcessorStarter interface {
+	// Start is called by the IO running the processor when it starts
+	// running, with the forms of its input and output.  If Start fails,
+	// the IO does not run and returns the error.
+	Start(in, out sound.Form) error
+}
+
+// ProcessorCloser is implemented by processors which need to be torn down
+// once they have processed their last block.
+type ProcessorCloser interface {
+	// Close is called by the IO running the processor when it stops
+	// running, if it started the processor.  An error of Close is
+	// returned by Run unless Run fails otherwise.
+	Close() error
+}
+
+// startProc starts the processor of n.  n.mu must be held.
+func (n *node) startProc() error {
+	if err := n.start(n.proc); err != nil {
+		return err
+	}
+	n.started = true
+	return nil
+}
+
+// start starts p, or both processors of a fade, with the forms of n.
+func (n *node) start(p Processor) error {
+	if sf, ok := p.(*swapFade); ok {
+		if err := n.start(sf.a); err != nil {
+			return err
+		}
+		p = sf.b
+	}
+	if s, ok := p.(ProcessorStarter); ok {
+		return s.Start(n.iForm, n.oForm)
+	}
+	return nil
+}
+
+// closeProc closes the processor of n if it was started.  n.mu must be
+// held.
+func (n *node) closeProc() error {
+	if !n.started {
+		return nil
+	}
+	proc := n.proc
+	if sf, ok := proc.(*swapFade); ok {
+		// both processors of an unfinished fade were started.
+		n.retire(sf.a)
+		proc = sf.b
+	}
+	n.started = false
+	if c, ok := proc.(ProcessorCloser); ok {
+		return c.Close()
+	}
+	return nil
+}
+
+// retire closes p, a processor of n replaced by SwapProcessor, if n
+// started it.  Errors closing p are reported as per Notifier.OnError.
+// n.mu must be held.
+func (n *node) retire(p Processor) {
+	if !n.started {
+		return
+	}
+	if sf, ok := p.(*swapFade); ok {
+		n.retire(sf.a)
+		p = sf.b
+	}
+	if c, ok := p.(ProcessorCloser); ok {
+		if err := c.Close(); err != nil {
+			n.failed(err)
+		}
+	}
+}
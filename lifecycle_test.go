@@ -0,0 +1,112 @@
+// Copyright 2018 The ZikiChombo Authors. All rights reserved.  Use of this source
+// code is governed by a license that can be found in the License file.
+
+package plug
+
+import (
+	"testing"
+	"time"
+
+	"zikichombo.org/sound"
+	"zikichombo.org/sound/gen"
+)
+
+type lifecycle struct {
+	Processor
+	in, out sound.Form
+	started bool
+	closed  int
+}
+
+func (p *lifecycle) Start(in, out sound.Form) error {
+	p.in, p.out = in, out
+	p.started = true
+	return nil
+}
+
+func (p *lifecycle) Close() error {
+	p.closed++
+	return ErrInjected
+}
+
+func TestIOProcessorLifecycle(t *testing.T) {
+	mono := sound.MonoCd()
+	p := &lifecycle{Processor: PassThrough}
+	u := New(mono, mono, p)
+	u.SetInput(&ramp{Form: mono, n: 3000})
+	u.AddOutput(&capture{Form: mono})
+	if err := u.Run(); err != ErrInjected {
+		t.Errorf("got %v not the error of Close", err)
+	}
+	if !p.started || p.in != mono || p.closed != 1 {
+		t.Errorf("got started %t with %v, closed %d times", p.started, p.in, p.closed)
+	}
+	g := &Graph{}
+	p = &lifecycle{Processor: PassThrough}
+	u = g.New(mono, mono, p)
+	u.SetInput(&ramp{Form: mono, n: 3000})
+	u.AddOutput(&capture{Form: mono})
+	if err := g.RunSequential(); err != ErrInjected {
+		t.Errorf("got %v not the error of Close", err)
+	}
+	if !p.started || p.closed != 1 {
+		t.Errorf("got started %t, closed %d times", p.started, p.closed)
+	}
+}
+
+// failStart fails to start.
+type failStart struct {
+	Processor
+}
+
+func (p *failStart) Start(in, out sound.Form) error {
+	return ErrInjected
+}
+
+func TestIOSwapProcessorLifecycle(t *testing.T) {
+	mono := sound.MonoCd()
+	p1 := &lifecycle{Processor: PassThrough}
+	u := New(mono, mono, p1)
+	u.SetInput(gen.Noise())
+	rec := &record{Form: mono}
+	u.AddOutput(rec)
+	var errs []error
+	u.OnError(func(err error) {
+		errs = append(errs, err)
+	})
+	errC := make(chan error)
+	go func() {
+		errC <- u.Run()
+	}()
+	for rec.frames() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	if err := u.SwapProcessor(&failStart{Processor: PassThrough}, 0); err != ErrInjected {
+		t.Errorf("got %v not the error of Start", err)
+	}
+	p2 := &lifecycle{Processor: PassThrough}
+	if err := u.SwapProcessor(p2, 0); err != nil {
+		t.Fatal(err)
+	}
+	if !p2.started || p1.closed != 1 {
+		t.Errorf("got started %t, old processor closed %d times", p2.started, p1.closed)
+	}
+	// p2 is closed once faded out.
+	p3 := &lifecycle{Processor: PassThrough}
+	if err := u.SwapProcessor(p3, 2048); err != nil {
+		t.Fatal(err)
+	}
+	if !p3.started || p2.closed != 0 {
+		t.Errorf("got started %t, faded out processor closed %d times", p3.started, p2.closed)
+	}
+	for n := rec.frames(); rec.frames() < n+8192; {
+		time.Sleep(time.Millisecond)
+	}
+	u.Stop()
+	if err := <-errC; err != ErrInjected {
+		t.Errorf("got %v not the error of Close", err)
+	}
+	if p2.closed != 1 || p3.closed != 1 || len(errs) != 2 {
+		t.Errorf("closed %d and %d times, got errors %v", p2.closed, p3.closed, errs)
+	}
+}
@@ -0,0 +1,159 @@
+// Copyright 2018 The ZikiChombo Authors. All rights reserved.  Use of this source
+// code is governed by a license that can be found in the License file.
+
+package plug
+
+import (
+	"fmt"
+	"math"
+	"sync"
+
+	"zikichombo.org/sound"
+)
+
+const (
+	// maximum gain applied by a LoudnessNormalizer, in dB.
+	loudnormMaxGain = 20.0
+	// time constant of the gain ramp of a LoudnessNormalizer, in seconds.
+	loudnormRamp = 1.0
+	// ceiling of the limiter of a LoudnessNormalizer, in dBFS.
+	loudnormCeiling = -1.0
+	// lookahead of the limiter, in frames.
+	loudnormLookahead = 64
+	// release time of the limiter, in ms.
+	loudnormRelease = 50.0
+)
+
+func init() {
+	Register("loudnorm", func(params map[string]interface{}) (Processor, error) {
+		target, ok := params["target"].(float64)
+		if !ok {
+			return nil, fmt.Errorf("loudnorm: target must be a number")
+		}
+		return NewLoudnessNormalizer(target), nil
+	})
+}
+
+// LoudnessNormalizer is a FullMode processor for playback at a target
+// loudness.  It composes a Meter, a gain ramp and a Limiter in one
+// processing step.  The gain is driven by the short-term (3s) loudness
+// measured by the Meter and moves smoothly towards the gain which gives
+// the target, and the Limiter keeps the output under -1dBFS.  The latency
+// of the Limiter is reported as that of the LoudnessNormalizer.
+//
+// While the input is below the absolute gate (SilenceLUFS), the gain is
+// held so that silence is not amplified.
+//
+// LoudnessNormalizer is registered as "loudnorm", with the target loudness
+// as the parameter "target".
+type LoudnessNormalizer struct {
+	mu     sync.Mutex
+	target float64
+	gain   float64
+	alpha  float64
+	rate   float64 // of alpha
+	m      *Meter
+	lim    *Limiter
+	c      Processor
+}
+
+// NewLoudnessNormalizer creates a new LoudnessNormalizer with target
+// loudness target, in LUFS.
+func NewLoudnessNormalizer(target float64) *LoudnessNormalizer {
+	n := &LoudnessNormalizer{target: target, gain: 1, m: NewMeter()}
+	n.lim = NewLimiter(loudnormCeiling, loudnormLookahead, loudnormRelease)
+	n.c = Compose(n.m, loudnormGain{n}, n.lim)
+	return n
+}
+
+// SetTarget sets the target loudness in LUFS.
+func (n *LoudnessNormalizer) SetTarget(target float64) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.target = target
+}
+
+// Target returns the target loudness in LUFS.
+func (n *LoudnessNormalizer) Target() float64 {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return n.target
+}
+
+// Gain returns the gain currently applied, in dB, not counting the limiter.
+func (n *LoudnessNormalizer) Gain() float64 {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return 20 * math.Log10(n.gain)
+}
+
+// Start implements ProcessorStarter.
+func (n *LoudnessNormalizer) Start(in, out sound.Form) error {
+	return n.lim.Start(in, out)
+}
+
+// Latency implements LatencyReporter.
+func (n *LoudnessNormalizer) Latency() int {
+	return latency(n.c)
+}
+
+// Tail implements Tailer, flushing the lookahead of the limiter.
+func (n *LoudnessNormalizer) Tail() bool {
+	return n.c.(Tailer).Tail()
+}
+
+// ChannelMode implements Processor.
+func (n *LoudnessNormalizer) ChannelMode() ChannelMode {
+	return FullMode
+}
+
+// NextFrames implements Processor.
+func (n *LoudnessNormalizer) NextFrames() (int, int) {
+	return n.c.NextFrames()
+}
+
+// Process implements Processor.
+func (n *LoudnessNormalizer) Process(dst, src *Block) error {
+	return n.c.Process(dst, src)
+}
+
+// loudnormGain is the gain ramp stage of a LoudnessNormalizer, following
+// the loudness measured by its Meter.
+type loudnormGain struct {
+	n *LoudnessNormalizer
+}
+
+func (g loudnormGain) ChannelMode() ChannelMode {
+	return FullMode
+}
+
+func (g loudnormGain) NextFrames() (int, int) {
+	return DefaultInFrames, DefaultInFrames
+}
+
+func (g loudnormGain) Process(dst, src *Block) error {
+	n := g.n
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if rate := src.SampleRate.Float64(); rate != n.rate {
+		n.alpha = 1 - math.Exp(-1/(loudnormRamp*rate))
+		n.rate = rate
+	}
+	goal := n.gain
+	if st := n.m.ShortTerm(); st > SilenceLUFS {
+		db := n.target - st
+		if db > loudnormMaxGain {
+			db = loudnormMaxGain
+		}
+		goal = math.Pow(10, db/20)
+	}
+	N := src.Frames
+	for f := 0; f < N; f++ {
+		n.gain += (goal - n.gain) * n.alpha
+		for c := 0; c < src.Channels; c++ {
+			dst.Samples[c*N+f] = src.Samples[c*N+f] * n.gain
+		}
+	}
+	dst.Frames = N
+	return nil
+}
@@ -0,0 +1,90 @@
+// Copyright 2018 The ZikiChombo Authors. All rights reserved.  Use of this source
+// code is governed by a license that can be found in the License file.
+
+package plug
+
+import (
+	"math"
+	"sync"
+)
+
+// LoudnessMatch is a FullMode processor which wraps another processor and
+// applies gain to its output so that the loudness of the output matches
+// that of the input.
+//
+// A LoudnessMatch makes level jumps disappear when a processor is swapped
+// for another one or bypassed, so that A/B listening compares the sound of
+// the processors rather than their level.  The gain follows the momentary
+// (400ms) loudness and moves smoothly; it is held while either input or
+// output is below SilenceLUFS.
+//
+// The wrapped processor should not change the number of channels nor the
+// number of frames.
+type LoudnessMatch struct {
+	mu      sync.Mutex
+	p       Processor
+	on      bool
+	in, out *loudness
+	gain    float64
+	alpha   float64
+}
+
+// NewLoudnessMatch creates a new LoudnessMatch wrapping p, with matching
+// enabled.
+func NewLoudnessMatch(p Processor) *LoudnessMatch {
+	return &LoudnessMatch{p: p, on: true, gain: 1}
+}
+
+// SetEnabled sets whether the gain compensation is applied.
+func (m *LoudnessMatch) SetEnabled(v bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.on = v
+}
+
+// Gain returns the compensation gain currently applied, in dB.
+func (m *LoudnessMatch) Gain() float64 {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return 20 * math.Log10(m.gain)
+}
+
+// ChannelMode implements Processor.
+func (m *LoudnessMatch) ChannelMode() ChannelMode {
+	return FullMode
+}
+
+// NextFrames implements Processor.
+func (m *LoudnessMatch) NextFrames() (int, int) {
+	return m.p.NextFrames()
+}
+
+// Process implements Processor.
+func (m *LoudnessMatch) Process(dst, src *Block) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if err := runFull(m.p, dst, src); err != nil {
+		return err
+	}
+	if m.in == nil {
+		m.in = newLoudness(src.SampleRate, src.Channels)
+		m.out = newLoudness(dst.SampleRate, dst.Channels)
+		m.alpha = 1 - math.Exp(-1/(0.4*dst.SampleRate.Float64()))
+	}
+	m.in.measure(src)
+	m.out.measure(dst)
+	goal := m.gain
+	if !m.on {
+		goal = 1
+	} else if li, lo := m.in.momentary(), m.out.momentary(); li > SilenceLUFS && lo > SilenceLUFS {
+		goal = math.Pow(10, (li-lo)/20)
+	}
+	N := dst.Frames
+	for f := 0; f < N; f++ {
+		m.gain += (goal - m.gain) * m.alpha
+		for c := 0; c < dst.Channels; c++ {
+			dst.Samples[c*N+f] *= m.gain
+		}
+	}
+	return nil
+}
@@ -0,0 +1,63 @@
+// Copyright 2018 The ZikiChombo Authors. All rights reserved.  Use of this source
+// code is governed by a license that can be found in the License file.
+
+package plug
+
+import (
+	"math"
+	"testing"
+
+	"zikichombo.org/sound/freq"
+)
+
+func TestLoudnessMatch(t *testing.T) {
+	sr := 48000 * freq.Hertz
+	double := NewProcessor(MonoMode, func(dst, src *Block) error {
+		for i, v := range src.Samples[:src.Frames] {
+			dst.Samples[i] = 2 * v
+		}
+		dst.Frames = src.Frames
+		return nil
+	})
+	m := NewLoudnessMatch(double)
+	sine := sineBlock(sr, 997, 0.2, 48000*3)
+	// run processes the sine, or silence, returning the peak of the output
+	// in the last 1024 frames.
+	run := func(silent bool) float64 {
+		N := 1024
+		peak := 0.0
+		for off := 0; off+N <= sine.Frames; off += N {
+			src := &Block{SampleRate: sr, Channels: 1, Frames: N, Samples: make([]float64, N)}
+			if !silent {
+				copy(src.Samples, sine.Samples[off:])
+			}
+			dst := &Block{SampleRate: sr, Channels: 1, Frames: N, Samples: make([]float64, N)}
+			if err := m.Process(dst, src); err != nil {
+				t.Fatal(err)
+			}
+			peak = 0
+			for _, v := range dst.Samples[:dst.Frames] {
+				peak = math.Max(peak, math.Abs(v))
+			}
+		}
+		return peak
+	}
+	if p := run(false); math.Abs(p-0.2) > 0.005 {
+		t.Errorf("matched: got peak %f not 0.2", p)
+	}
+	if g := m.Gain(); math.Abs(g+6.02) > 0.05 {
+		t.Errorf("got gain %fdB not -6.02dB", g)
+	}
+	// the gain is held over silence.
+	run(true)
+	if g := m.Gain(); math.Abs(g+6.02) > 0.05 {
+		t.Errorf("after silence: got gain %fdB not -6.02dB", g)
+	}
+	m.SetEnabled(false)
+	if p := run(false); math.Abs(p-0.4) > 0.01 {
+		t.Errorf("disabled: got peak %f not 0.4", p)
+	}
+	if g := m.Gain(); math.Abs(g) > 0.05 {
+		t.Errorf("disabled: got gain %fdB not 0dB", g)
+	}
+}
@@ -0,0 +1,354 @@
+// Copyright 2018 The ZikiChombo Authors. All rights reserved.  Use of this source
+// code is governed by a license that can be found in the License file.
+
+package plug
+
+import (
+	"math"
+	"sync"
+
+	"zikichombo.org/sound/freq"
+)
+
+// SilenceLUFS is the loudness reported when there is no measurable signal.
+// It is the absolute gate of ITU-R BS.1770.
+const SilenceLUFS = -70.0
+
+const (
+	gateRes  = 0.1 // width of the bins of a gateHist, in LU
+	gateBins = 800 // bins of a gateHist, up to +10 LUFS
+)
+
+// loudness measures loudness according to ITU-R BS.1770, treating all
+// channels with unit weight.
+type loudness struct {
+	sr     freq.T
+	pre    biquad
+	rlb    biquad
+	preSt  []bqState
+	rlbSt  []bqState
+	filt   []float64
+	subLen int       // frames in a 100ms sub-block
+	subN   int       // frames in the current sub-block
+	subAcc float64   // sum of squares in the current sub-block
+	subs   []float64 // ring of the last 30 sub-block mean powers
+	subI   int
+	subCt  int
+	gates  gateHist // of all 400ms gating blocks, for integrated loudness
+	peak   float64
+	tp     *truePeak
+}
+
+func newLoudness(sr freq.T, nC int) *loudness {
+	res := &loudness{
+		sr:    sr,
+		preSt: make([]bqState, nC),
+		rlbSt: make([]bqState, nC),
+		subs:  make([]float64, 30),
+		tp:    newTruePeak(nC)}
+	rate := sr.Float64()
+	res.subLen = int(rate/10 + 0.5)
+
+	// K-weighting, computed for arbitrary sample rates.
+	f0 := 1681.974450955533
+	G := 3.999843853973347
+	Q := 0.7071752369554196
+	K := math.Tan(math.Pi * f0 / rate)
+	Vh := math.Pow(10, G/20)
+	Vb := math.Pow(Vh, 0.4996667741545416)
+	a0 := 1 + K/Q + K*K
+	res.pre = biquad{
+		b0: (Vh + Vb*K/Q + K*K) / a0,
+		b1: 2 * (K*K - Vh) / a0,
+		b2: (Vh - Vb*K/Q + K*K) / a0,
+		a1: 2 * (K*K - 1) / a0,
+		a2: (1 - K/Q + K*K) / a0}
+	f0 = 38.13547087602444
+	Q = 0.5003270373238773
+	K = math.Tan(math.Pi * f0 / rate)
+	a0 = 1 + K/Q + K*K
+	res.rlb = biquad{
+		b0: 1,
+		b1: -2,
+		b2: 1,
+		a1: 2 * (K*K - 1) / a0,
+		a2: (1 - K/Q + K*K) / a0}
+	return res
+}
+
+// measure accumulates the samples in b, which must be in FullMode layout.
+func (l *loudness) measure(b *Block) {
+	N := b.Frames
+	l.filt = buffer(l.filt, 1, N)
+	for f := 0; f < N; {
+		end := f + l.subLen - l.subN
+		if end > N {
+			end = N
+		}
+		for c := 0; c < b.Channels; c++ {
+			s := b.Samples[c*N+f : c*N+end]
+			l.tp.measure(c, s)
+			z := l.filt[:len(s)]
+			l.pre.runSlice(&l.preSt[c], z, s)
+			l.rlb.runSlice(&l.rlbSt[c], z, z)
+			for i, v := range z {
+				l.subAcc += v * v
+				if a := math.Abs(s[i]); a > l.peak {
+					l.peak = a
+				}
+			}
+		}
+		l.subN += end - f
+		f = end
+		if l.subN == l.subLen {
+			l.endSub()
+		}
+	}
+}
+
+func (l *loudness) endSub() {
+	l.subs[l.subI] = l.subAcc / float64(l.subLen)
+	l.subI = (l.subI + 1) % len(l.subs)
+	l.subCt++
+	l.subN = 0
+	l.subAcc = 0
+	if l.subCt >= 4 {
+		l.gates.add(l.power(4))
+	}
+}
+
+// power gives the mean power over the last n sub-blocks.
+func (l *loudness) power(n int) float64 {
+	if l.subCt < n {
+		n = l.subCt
+	}
+	if n == 0 {
+		return 0
+	}
+	acc := 0.0
+	for i := 1; i <= n; i++ {
+		acc += l.subs[(l.subI-i+len(l.subs))%len(l.subs)]
+	}
+	return acc / float64(n)
+}
+
+func (l *loudness) momentary() float64 {
+	return lufs(l.power(4))
+}
+
+func (l *loudness) shortTerm() float64 {
+	return lufs(l.power(30))
+}
+
+func (l *loudness) integrated() float64 {
+	p := l.gates.mean(SilenceLUFS)
+	if p == 0 {
+		return SilenceLUFS
+	}
+	return lufs(l.gates.mean(lufs(p) - 10))
+}
+
+// gateHist is a histogram of the powers of gating blocks by loudness, in
+// bins of gateRes LU above SilenceLUFS, so that integrated loudness is
+// measured in constant memory however long the input.  Blocks louder
+// than the last bin are counted in it.
+type gateHist struct {
+	n [gateBins]int
+	p [gateBins]float64 // sum of the powers of the blocks in each bin
+}
+
+// add adds a gating block of power p, unless it is below the absolute gate.
+func (h *gateHist) add(p float64) {
+	v := lufs(p)
+	if v <= SilenceLUFS {
+		return
+	}
+	i := int((v - SilenceLUFS) / gateRes)
+	if i >= gateBins {
+		i = gateBins - 1
+	}
+	h.n[i]++
+	h.p[i] += p
+}
+
+// mean returns the mean power of the blocks louder than thresh, to within
+// a bin, or 0 if there are none.
+func (h *gateHist) mean(thresh float64) float64 {
+	i := int((thresh - SilenceLUFS) / gateRes)
+	if i < 0 {
+		i = 0
+	}
+	acc, n := 0.0, 0
+	for ; i < gateBins; i++ {
+		acc += h.p[i]
+		n += h.n[i]
+	}
+	if n == 0 {
+		return 0
+	}
+	return acc / float64(n)
+}
+
+func lufs(p float64) float64 {
+	if p <= 0 {
+		return SilenceLUFS
+	}
+	v := -0.691 + 10*math.Log10(p)
+	if v < SilenceLUFS {
+		return SilenceLUFS
+	}
+	return v
+}
+
+// Meter is a FullMode pass-through processor which measures loudness
+// according to ITU-R BS.1770.  All channels are weighted equally.
+//
+// The measurements may be read from any goroutine while the processor is
+// in use.
+type Meter struct {
+	mu sync.Mutex
+	l  *loudness
+}
+
+// NewMeter creates a new loudness meter.
+func NewMeter() *Meter {
+	return &Meter{}
+}
+
+// ChannelMode implements Processor.
+func (m *Meter) ChannelMode() ChannelMode {
+	return FullMode
+}
+
+// NextFrames implements Processor.
+func (m *Meter) NextFrames() (int, int) {
+	return DefaultInFrames, DefaultInFrames
+}
+
+// Process implements Processor.
+func (m *Meter) Process(dst, src *Block) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.l == nil {
+		m.l = newLoudness(src.SampleRate, src.Channels)
+	}
+	m.l.measure(src)
+	n := src.Channels * src.Frames
+	copy(dst.Samples[:n], src.Samples[:n])
+	dst.Frames = src.Frames
+	return nil
+}
+
+// Momentary returns the loudness over the last 400ms in LUFS.
+func (m *Meter) Momentary() float64 {
+	return m.read((*loudness).momentary, SilenceLUFS)
+}
+
+// ShortTerm returns the loudness over the last 3s in LUFS.
+func (m *Meter) ShortTerm() float64 {
+	return m.read((*loudness).shortTerm, SilenceLUFS)
+}
+
+// Integrated returns the gated loudness of everything measured so far,
+// in LUFS.
+func (m *Meter) Integrated() float64 {
+	return m.read((*loudness).integrated, SilenceLUFS)
+}
+
+// Peak returns the maximum absolute sample value measured so far.
+func (m *Meter) Peak() float64 {
+	return m.read(func(l *loudness) float64 { return l.peak }, 0)
+}
+
+// TruePeak returns the maximum absolute value of the signal measured so far,
+// including intersample peaks, as estimated by 4x oversampling according to
+// ITU-R BS.1770.  Like Peak, the value is linear; 20*log10(m.TruePeak())
+// gives the true peak level in dBTP.
+func (m *Meter) TruePeak() float64 {
+	return m.read(func(l *loudness) float64 { return l.tp.peak }, 0)
+}
+
+func (m *Meter) read(fn func(*loudness) float64, none float64) float64 {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.l == nil {
+		return none
+	}
+	return fn(m.l)
+}
+
+const (
+	tpOver = 4  // oversampling factor
+	tpTaps = 12 // taps per phase
+)
+
+// tpCoefs holds the polyphase interpolation filter of a truePeak, phase
+// major.
+var tpCoefs = func() [tpOver][tpTaps]float64 {
+	var res [tpOver][tpTaps]float64
+	L := tpOver * tpTaps
+	mid := float64(L-1) / 2
+	for n := 0; n < L; n++ {
+		x := (float64(n) - mid) / tpOver
+		h := 1.0
+		if x != 0 {
+			h = math.Sin(math.Pi*x) / (math.Pi * x)
+		}
+		// Blackman window
+		w := 0.42 - 0.5*math.Cos(2*math.Pi*float64(n)/float64(L-1)) +
+			0.08*math.Cos(4*math.Pi*float64(n)/float64(L-1))
+		res[n%tpOver][n/tpOver] = h * w
+	}
+	for p := range res {
+		acc := 0.0
+		for _, h := range res[p] {
+			acc += h
+		}
+		for i := range res[p] {
+			res[p][i] /= acc
+		}
+	}
+	return res
+}()
+
+// truePeak measures true peak values by oversampling.
+type truePeak struct {
+	hist [][]float64 // per channel, last tpTaps samples twice for linear access
+	pos  []int
+	peak float64
+}
+
+func newTruePeak(nC int) *truePeak {
+	res := &truePeak{hist: make([][]float64, nC), pos: make([]int, nC)}
+	for c := range res.hist {
+		res.hist[c] = make([]float64, 2*tpTaps)
+	}
+	return res
+}
+
+func (t *truePeak) measure(c int, s []float64) {
+	h := t.hist[c]
+	pos := t.pos[c]
+	for _, v := range s {
+		h[pos] = v
+		h[pos+tpTaps] = v
+		pos++
+		if pos == tpTaps {
+			pos = 0
+		}
+		x := h[pos : pos+tpTaps]
+		for p := range tpCoefs {
+			acc := 0.0
+			for i, k := range tpCoefs[p] {
+				acc += k * x[tpTaps-1-i]
+			}
+			if acc < 0 {
+				acc = -acc
+			}
+			if acc > t.peak {
+				t.peak = acc
+			}
+		}
+	}
+	t.pos[c] = pos
+}
@@ -0,0 +1,136 @@
+// Copyright 2018 The ZikiChombo Authors. All rights reserved.  Use of this source
+// code is governed by a license that can be found in the License file.
+
+package plug
+
+import (
+	"io"
+	"math"
+	"testing"
+
+	"zikichombo.org/sound"
+	"zikichombo.org/sound/freq"
+)
+
+func sineBlock(sr freq.T, hz, amp float64, frames int) *Block {
+	b := &Block{SampleRate: sr, Channels: 1, Frames: frames}
+	b.Samples = make([]float64, frames)
+	rate := sr.Float64()
+	for i := range b.Samples {
+		b.Samples[i] = amp * math.Sin(2*math.Pi*hz*float64(i)/rate)
+	}
+	return b
+}
+
+func TestMeterSine(t *testing.T) {
+	sr := 48000 * freq.Hertz
+	src := sineBlock(sr, 997, 1, 48000*4)
+	dst := &Block{SampleRate: sr, Channels: 1, Samples: make([]float64, len(src.Samples))}
+	m := NewMeter()
+	if err := m.Process(dst, src); err != nil {
+		t.Fatal(err)
+	}
+	for _, v := range []float64{m.Momentary(), m.ShortTerm(), m.Integrated()} {
+		if math.Abs(v+3.01) > 0.1 {
+			t.Errorf("got %f LUFS not -3.01", v)
+		}
+	}
+}
+
+// blockSource is a mono source of the samples of a Block.
+type blockSource struct {
+	sound.Form
+	b   *Block
+	pos int
+}
+
+func (s *blockSource) Close() error { return nil }
+
+func (s *blockSource) Receive(d []float64) (int, error) {
+	n := copy(d, s.b.Samples[s.pos:s.b.Frames])
+	s.pos += n
+	if n == 0 {
+		return 0, io.EOF
+	}
+	return n, nil
+}
+
+func TestLoudnessNormalizer(t *testing.T) {
+	sr := 48000 * freq.Hertz
+	mono := sound.NewForm(sr, 1)
+	src := sineBlock(sr, 997, 0.1, 48000*10)
+	n := NewLoudnessNormalizer(-16)
+	u := New(mono, mono, n)
+	u.SetInput(&blockSource{Form: mono, b: src})
+	rec := &record{Form: mono}
+	u.AddOutput(rec)
+	if err := u.Run(); err != nil {
+		t.Fatal(err)
+	}
+	out := rec.chs[0]
+	if len(out) != src.Frames+n.Latency() {
+		t.Fatalf("got %d frames not %d", len(out), src.Frames+n.Latency())
+	}
+	m := NewMeter()
+	tail := sineBlock(sr, 997, 0, 48000)
+	copy(tail.Samples, out[len(out)-48000:])
+	if err := m.Process(&Block{Samples: make([]float64, 48000)}, tail); err != nil {
+		t.Fatal(err)
+	}
+	if v := m.Momentary(); math.Abs(v+16) > 0.5 {
+		t.Errorf("got %f LUFS not -16", v)
+	}
+	ceil := math.Pow(10, loudnormCeiling/20)
+	for i, v := range out {
+		if math.Abs(v) > ceil+1e-9 {
+			t.Fatalf("frame %d: %g over the ceiling", i, v)
+		}
+	}
+}
+
+func TestLoudnessNormalizerRegistered(t *testing.T) {
+	mono := sound.MonoCd()
+	var g Graph
+	if _, err := g.NewRegistered("loudnorm", map[string]interface{}{"target": -23.0}, mono, mono); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := g.NewRegistered("loudnorm", nil, mono, mono); err == nil {
+		t.Error("created without a target")
+	}
+}
+
+func TestMeterIntegratedGating(t *testing.T) {
+	sr := 48000 * freq.Hertz
+	// 10s of a full scale sine, at -3.01 LUFS, then 10s at -43.01 LUFS,
+	// which the relative gate excludes.
+	src := sineBlock(sr, 997, 1, 48000*20)
+	for i := 48000 * 10; i < src.Frames; i++ {
+		src.Samples[i] *= 0.01
+	}
+	m := NewMeter()
+	if err := m.Process(&Block{Samples: make([]float64, src.Frames)}, src); err != nil {
+		t.Fatal(err)
+	}
+	if v := m.Integrated(); math.Abs(v+3.01) > 0.1 {
+		t.Errorf("got %f LUFS not -3.01", v)
+	}
+}
+
+func TestMeterTruePeak(t *testing.T) {
+	sr := 48000 * freq.Hertz
+	// a sine at a quarter of the sample rate with all samples at +/-0.707
+	src := &Block{SampleRate: sr, Channels: 1, Frames: 4800, Samples: make([]float64, 4800)}
+	for i := range src.Samples {
+		src.Samples[i] = math.Sin(math.Pi/2*float64(i) + math.Pi/4)
+	}
+	m := NewMeter()
+	if err := m.Process(&Block{Samples: make([]float64, 4800)}, src); err != nil {
+		t.Fatal(err)
+	}
+	if p := m.Peak(); math.Abs(p-math.Sqrt2/2) > 1e-6 {
+		t.Errorf("got peak %f not %f", p, math.Sqrt2/2)
+	}
+	if p := m.TruePeak(); math.Abs(p-1) > 0.05 {
+		t.Errorf("got true peak %f not 1", p)
+	}
+}
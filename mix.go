@@ -0,0 +1,116 @@
+// Copyright 2018 The ZikiChombo Authors. All rights reserved.  Use of this source
+// code is governed by a license that can be found in the License file.
+
+package plug
+
+import "math"
+
+// MixPolicy gives how an IO mixes several sources set as input to the same
+// channel.
+type MixPolicy int
+
+const (
+	// MixNone, the default, allows at most one source per input channel.
+	MixNone MixPolicy = iota
+	// MixSum sums the sources.
+	MixSum
+	// MixAverage averages the sources.
+	MixAverage
+	// MixMax takes, sample by sample, the source of greatest magnitude.
+	MixMax
+)
+
+// SetMixPolicy implements InputController.
+func (n *node) SetMixPolicy(p MixPolicy) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.mix = p
+}
+
+// mixing returns whether some input channel of n has several sources.
+// n.mu must be held.
+func (n *node) mixing() bool {
+	for _, ct := range n.icCounts {
+		if ct > 1 {
+			return true
+		}
+	}
+	return false
+}
+
+// startPut prepares to put the input packets into a block.  n.mu must be
+// held.
+func (n *node) startPut() {
+	if !n.mixing() {
+		return
+	}
+	if len(n.mixed) != len(n.icCounts) {
+		n.mixed = make([]bool, len(n.icCounts))
+	}
+	for c := range n.mixed {
+		n.mixed[c] = false
+	}
+}
+
+// put puts the samples of the input packet pkt into b, mixing them with
+// those of other packets as per the mix policy, and returns the number of
+// frames.  n.mu must be held.
+func (n *node) put(pkt *packet, b *Block) int {
+	if !n.mixing() {
+		return pkt.put(b)
+	}
+	frms := pkt.n
+	for c := 0; c < b.Channels; c++ {
+		cc := pkt.cmap.mapC(c)
+		if cc == -1 {
+			continue
+		}
+		d := b.Samples[c*frms : (c+1)*frms]
+		var s []float64
+		if cc < pkt.nC {
+			s = pkt.samples[cc*frms : (cc+1)*frms]
+		}
+		if !n.mixed[c] {
+			n.mixed[c] = true
+			if s == nil {
+				zero(d)
+			} else {
+				copy(d, s)
+			}
+			continue
+		}
+		if s == nil {
+			continue
+		}
+		for i, v := range s {
+			if n.mix == MixMax {
+				if math.Abs(v) > math.Abs(d[i]) {
+					d[i] = v
+				}
+				continue
+			}
+			d[i] += v
+		}
+	}
+	return frms
+}
+
+// endPut finishes putting nFrms frames of the input packets into b,
+// filling unconnected channels with silence as per SetSilenceFill.  n.mu
+// must be held.
+func (n *node) endPut(b *Block, nFrms int) {
+	n.fillSilence(b, nFrms)
+	if n.mix != MixAverage || !n.mixing() {
+		return
+	}
+	for c, ct := range n.icCounts {
+		if ct < 2 {
+			continue
+		}
+		g := 1 / float64(ct)
+		d := b.Samples[c*nFrms : (c+1)*nFrms]
+		for i := range d {
+			d[i] *= g
+		}
+	}
+}
@@ -0,0 +1,39 @@
+// Copyright 2018 The ZikiChombo Authors. All rights reserved.  Use of this source
+// code is governed by a license that can be found in the License file.
+
+package plug
+
+import (
+	"testing"
+
+	"zikichombo.org/sound"
+	"zikichombo.org/sound/gen"
+)
+
+func TestIOMixPolicy(t *testing.T) {
+	mono := sound.MonoCd()
+	for _, tc := range []struct {
+		p   MixPolicy
+		exp float64
+	}{{MixSum, 1200}, {MixAverage, 600}, {MixMax, 1100}} {
+		u := New(mono, mono, PassThrough)
+		u.SetMixPolicy(tc.p)
+		u.SetInput(&ramp{Form: mono, n: 1024})
+		if err := u.SetInput(&ramp{Form: mono, pos: 1000, n: 2024}); err != nil {
+			t.Fatal(err)
+		}
+		snk := &capture{Form: mono}
+		u.AddOutput(snk)
+		if err := u.Run(); err != nil {
+			t.Fatal(err)
+		}
+		if snk.frames != 1024 || snk.mix[100] != tc.exp {
+			t.Errorf("policy %d: got %d frames, %v", tc.p, snk.frames, snk.mix[100])
+		}
+	}
+	u := New(mono, mono, PassThrough)
+	u.SetInput(gen.Noise())
+	if err := u.SetInput(gen.Noise()); err == nil {
+		t.Errorf("set two inputs without mixing")
+	}
+}
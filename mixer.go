@@ -0,0 +1,107 @@
+// Copyright 2018 The ZikiChombo Authors. All rights reserved.  Use of this source
+// code is governed by a license that can be found in the License file.
+
+package plug
+
+import (
+	"fmt"
+
+	"zikichombo.org/sound"
+)
+
+// Mixer is an IO which sums a number of inputs of the same form, each
+// with its own gain.
+//
+// The input form of a Mixer has the channels of every input in turn, and
+// its output form is that of the inputs.  Inputs which are not set are
+// silent.  The gain of input i is the parameter "i.gain", also available
+// through SetGain and Gain.  Gain changes are ramped over a block.
+type Mixer struct {
+	Plug
+	nC, n int
+}
+
+// NewMixer creates a new Mixer of nInputs inputs of form form, each with
+// gain 1.
+func NewMixer(form sound.Form, nInputs int) *Mixer {
+	nC := form.Channels()
+	p := &mixer{nC: nC, gains: make([]float64, nInputs), last: make([]float64, nInputs)}
+	for i := range p.gains {
+		g := &p.gains[i]
+		*g = 1
+		p.Add(ParamInfo{Name: fmt.Sprintf("%d.gain", i), Min: 0, Max: 16, Default: 1},
+			func() float64 { return *g }, func(v float64) { *g = v })
+	}
+	copy(p.last, p.gains)
+	u := New(sound.NewForm(form.SampleRate(), nInputs*nC), form, p)
+	u.SetSilenceFill()
+	return &Mixer{Plug: u, nC: nC, n: nInputs}
+}
+
+// Inputs returns the number of inputs.
+func (m *Mixer) Inputs() int {
+	return m.n
+}
+
+// SetInputAt sets input i to s, which must have the form of the Mixer.
+func (m *Mixer) SetInputAt(i int, s sound.Source) error {
+	if i < 0 || i >= m.n {
+		return fmt.Errorf("mixer: no input %d of %d", i, m.n)
+	}
+	if s.Channels() != m.nC {
+		return fmt.Errorf("mixer: cannot mix %d channels into %d", s.Channels(), m.nC)
+	}
+	return m.SetInput(s, chanRange(i*m.nC, (i+1)*m.nC)...)
+}
+
+// SetGain sets the gain of input i, as of the next block.
+func (m *Mixer) SetGain(i int, g float64) error {
+	return m.Params().Set(fmt.Sprintf("%d.gain", i), g)
+}
+
+// Gain returns the gain of input i.
+func (m *Mixer) Gain(i int) (float64, error) {
+	return m.Params().Get(fmt.Sprintf("%d.gain", i))
+}
+
+// mixer is the processor of a Mixer.
+type mixer struct {
+	ParamTable
+	nC    int
+	gains []float64
+	last  []float64 // gains of the last block
+}
+
+func (x *mixer) ChannelMode() ChannelMode {
+	return FullMode
+}
+
+func (x *mixer) NextFrames() (int, int) {
+	return DefaultInFrames, DefaultInFrames
+}
+
+func (x *mixer) Process(dst, src *Block) error {
+	nC := dst.Channels
+	if src.Channels != len(x.gains)*nC {
+		return fmt.Errorf("mixer: cannot mix %d channels into %d", src.Channels, nC)
+	}
+	N := src.Frames
+	out := dst.Samples[:nC*N]
+	zero(out)
+	for i, g := range x.gains {
+		g0 := x.last[i]
+		if g == 0 && g0 == 0 {
+			continue
+		}
+		in := src.Samples[i*nC*N : (i+1)*nC*N]
+		for c := 0; c < nC; c++ {
+			d, s := out[c*N:(c+1)*N], in[c*N:(c+1)*N]
+			for f, v := range s {
+				d[f] += v * (g0 + float64(f+1)/float64(N)*(g-g0))
+			}
+		}
+		x.last[i] = g
+	}
+	dst.Frames = N
+	return nil
+}
@@ -0,0 +1,48 @@
+// Copyright 2018 The ZikiChombo Authors. All rights reserved.  Use of this source
+// code is governed by a license that can be found in the License file.
+
+package plug
+
+import (
+	"testing"
+
+	"zikichombo.org/sound"
+)
+
+func TestMixer(t *testing.T) {
+	mono := sound.MonoCd()
+	m := NewMixer(mono, 3)
+	if err := m.SetInputAt(0, &ramp{Form: mono, n: 2048}); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.SetInputAt(1, &ramp{Form: mono, n: 2048}); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.SetInputAt(3, &ramp{Form: mono}); err == nil {
+		t.Errorf("set input 3 of 3")
+	}
+	if err := m.SetInputAt(2, &ramp{Form: sound.StereoCd()}); err == nil {
+		t.Errorf("set stereo input")
+	}
+	if err := m.SetGain(1, 0.5); err != nil {
+		t.Fatal(err)
+	}
+	if g, err := m.Gain(1); err != nil || g != 0.5 {
+		t.Errorf("got gain %f, %v", g, err)
+	}
+	snk := &capture{Form: mono}
+	m.AddOutput(snk)
+	if err := m.Run(); err != nil {
+		t.Fatal(err)
+	}
+	if snk.frames != 2048 {
+		t.Fatalf("got %d frames", snk.frames)
+	}
+	// the gain of input 1 ramps from 1 to 0.5 over the first block.
+	if v := snk.mix[1023]; v != 1023*1.5 {
+		t.Errorf("got %f at the end of the first block", v)
+	}
+	if v := snk.mix[2000]; v != 2000*1.5 {
+		t.Errorf("got %f in the second block", v)
+	}
+}
@@ -0,0 +1,218 @@
+// Copyright 2018 The ZikiChombo Authors. All rights reserved.  Use of this source
+// code is governed by a license that can be found in the License file.
+
+package plug
+
+import (
+	"fmt"
+	"math"
+)
+
+// Model is an inference model run on windows of audio, for example an
+// ONNX model for denoising, source separation or classification.  This
+// package depends on no inference runtime: a Model is an adapter over a
+// runtime binding, such as onnxruntime, supplied by the caller.
+type Model interface {
+	// Infer runs the model on a window of frames frames of channels
+	// channels, in channel deinterleaved format, and returns its output.
+	// For audio models, the output is a window of the same number of
+	// frames, in channel deinterleaved format.  For feature models, the
+	// output is arbitrary.
+	Infer(in []float32, channels, frames int) ([]float32, error)
+}
+
+// ModelConfig gives the windowing of a ModelProcessor.
+type ModelConfig struct {
+	Window int // frames per inference
+	Hop    int // frames between inferences, which must divide Window
+
+	// Normalize scales each window to a peak of 1 before inference, and
+	// scales audio output back, for models trained on normalized audio.
+	Normalize bool
+
+	// Features indicates the model outputs features rather than audio.
+	// Features are delivered by ModelProcessor.Features and the audio is
+	// passed through unchanged.
+	Features bool
+}
+
+// Feature is the output of a feature model for the window ending at
+// input frame Frame.
+type Feature struct {
+	Frame  int64
+	Values []float32
+}
+
+// ModelProcessor is a FullMode processor running a Model over overlapping
+// windows of its input.  With audio models, output windows are overlap
+// added with a Hann window, or concatenated if Hop == Window, and the
+// output lags the input by Window frames.
+type ModelProcessor struct {
+	m     Model
+	cfg   ModelConfig
+	win   []float64
+	in    [][]float64
+	acc   [][]float64
+	out   [][]float64
+	k     int
+	pos   int64
+	buf   []float32
+	feats chan Feature
+}
+
+// NewModelProcessor creates a new ModelProcessor running m with the
+// windowing of cfg.
+func NewModelProcessor(m Model, cfg ModelConfig) (*ModelProcessor, error) {
+	if cfg.Window <= 0 || cfg.Hop <= 0 || cfg.Window%cfg.Hop != 0 {
+		return nil, fmt.Errorf("hop %d does not divide window %d", cfg.Hop, cfg.Window)
+	}
+	res := &ModelProcessor{
+		m:     m,
+		cfg:   cfg,
+		win:   make([]float64, cfg.Window),
+		feats: make(chan Feature, 1)}
+	for i := range res.win {
+		res.win[i] = 1
+	}
+	if cfg.Hop < cfg.Window {
+		// periodic Hann, normalized so that overlapping windows sum to 1.
+		sum := 0.0
+		for i := range res.win {
+			res.win[i] = 0.5 - 0.5*math.Cos(2*math.Pi*float64(i)/float64(cfg.Window))
+			if i%cfg.Hop == 0 {
+				sum += res.win[i]
+			}
+		}
+		for i := range res.win {
+			res.win[i] /= sum
+		}
+	}
+	return res, nil
+}
+
+// Features returns a channel on which the output of a feature model is
+// made available.  Only the latest output is kept, so a slow reader does
+// not hold up processing.
+func (p *ModelProcessor) Features() <-chan Feature {
+	return p.feats
+}
+
+// Latency returns the latency of the processor in frames.
+func (p *ModelProcessor) Latency() int {
+	if p.cfg.Features {
+		return 0
+	}
+	return p.cfg.Window
+}
+
+// ChannelMode implements Processor.
+func (p *ModelProcessor) ChannelMode() ChannelMode {
+	return FullMode
+}
+
+// NextFrames implements Processor.
+func (p *ModelProcessor) NextFrames() (int, int) {
+	return DefaultInFrames, DefaultOutFrames
+}
+
+// Process implements Processor.
+func (p *ModelProcessor) Process(dst, src *Block) error {
+	W, H := p.cfg.Window, p.cfg.Hop
+	if p.in == nil {
+		p.in = chans(src.Channels, W)
+		p.acc = chans(dst.Channels, W)
+		p.out = chans(dst.Channels, H)
+	}
+	if p.cfg.Features && dst.Channels != src.Channels {
+		return fmt.Errorf("feature model: %d input channels to %d output channels", src.Channels, dst.Channels)
+	}
+	N := src.Frames
+	off := W - H
+	for f := 0; f < N; f++ {
+		for c, ch := range p.in {
+			ch[off+p.k] = src.Samples[c*N+f]
+		}
+		for c, ch := range p.out {
+			v := ch[p.k]
+			if p.cfg.Features {
+				v = src.Samples[c*N+f]
+			}
+			dst.Samples[c*N+f] = v
+		}
+		p.k++
+		p.pos++
+		if p.k < H {
+			continue
+		}
+		p.k = 0
+		if err := p.infer(); err != nil {
+			return err
+		}
+		for _, ch := range p.in {
+			copy(ch, ch[H:])
+		}
+	}
+	dst.Frames = N
+	return nil
+}
+
+// infer runs the model on the current window.
+func (p *ModelProcessor) infer() error {
+	W, H := p.cfg.Window, p.cfg.Hop
+	iC := len(p.in)
+	p.buf = p.buf[:0]
+	peak := 0.0
+	for _, ch := range p.in {
+		for _, v := range ch {
+			if a := math.Abs(v); a > peak {
+				peak = a
+			}
+		}
+	}
+	scale := 1.0
+	if p.cfg.Normalize && peak > 0 {
+		scale = 1 / peak
+	}
+	for _, ch := range p.in {
+		for _, v := range ch {
+			p.buf = append(p.buf, float32(v*scale))
+		}
+	}
+	res, err := p.m.Infer(p.buf, iC, W)
+	if err != nil {
+		return err
+	}
+	if p.cfg.Features {
+		select {
+		case <-p.feats:
+		default:
+		}
+		p.feats <- Feature{Frame: p.pos, Values: res}
+		return nil
+	}
+	oC := len(p.acc)
+	if len(res) != oC*W {
+		return fmt.Errorf("model output %d samples, expected %d", len(res), oC*W)
+	}
+	for c, acc := range p.acc {
+		r := res[c*W : (c+1)*W]
+		for i := range acc {
+			acc[i] += float64(r[i]) / scale * p.win[i]
+		}
+		copy(p.out[c], acc[:H])
+		copy(acc, acc[H:])
+		for i := W - H; i < W; i++ {
+			acc[i] = 0
+		}
+	}
+	return nil
+}
+
+// chans allocates nC channels of n samples.
+func chans(nC, n int) [][]float64 {
+	res := make([][]float64, nC)
+	for i := range res {
+		res[i] = make([]float64, n)
+	}
+	return res
+}
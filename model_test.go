@@ -0,0 +1,112 @@
+// Copyright 2018 The ZikiChombo Authors. All rights reserved.  Use of this source
+// code is governed by a license that can be found in the License file.
+
+package plug
+
+import (
+	"math"
+	"testing"
+
+	"zikichombo.org/sound"
+)
+
+// modelFunc adapts a function to Model.
+type modelFunc func(in []float32, channels, frames int) ([]float32, error)
+
+func (f modelFunc) Infer(in []float32, channels, frames int) ([]float32, error) {
+	return f(in, channels, frames)
+}
+
+// identity is a Model giving back its input.
+var identity = modelFunc(func(in []float32, channels, frames int) ([]float32, error) {
+	return append([]float32(nil), in...), nil
+})
+
+func TestModelProcessor(t *testing.T) {
+	if _, err := NewModelProcessor(identity, ModelConfig{Window: 512, Hop: 100}); err == nil {
+		t.Error("hop of 100 accepted for a window of 512")
+	}
+	mono := sound.MonoCd()
+	for _, cfg := range []ModelConfig{
+		{Window: 512, Hop: 512},
+		{Window: 512, Hop: 128},
+		{Window: 512, Hop: 128, Normalize: true},
+	} {
+		p, err := NewModelProcessor(identity, cfg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if p.Latency() != 512 {
+			t.Errorf("%+v: got latency %d", cfg, p.Latency())
+		}
+		u := New(mono, mono, p)
+		u.SetInput(&ramp{Form: mono, n: 5000})
+		rec := &record{Form: mono}
+		u.AddOutput(rec)
+		if err := u.Run(); err != nil {
+			t.Fatal(err)
+		}
+		for i, v := range rec.chs[0] {
+			exp := 0.0
+			if i >= 512 {
+				exp = float64(i - 512)
+			}
+			if math.Abs(v-exp) > 1e-6*math.Max(1, exp) {
+				t.Fatalf("%+v: frame %d: got %g not %g", cfg, i, v, exp)
+			}
+		}
+	}
+}
+
+func TestModelProcessorFeatures(t *testing.T) {
+	mono := sound.MonoCd()
+	// the model outputs the last sample of the window and the number of
+	// frames.
+	last := modelFunc(func(in []float32, channels, frames int) ([]float32, error) {
+		return []float32{in[len(in)-1], float32(frames)}, nil
+	})
+	p, err := NewModelProcessor(last, ModelConfig{Window: 256, Hop: 128, Features: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Latency() != 0 {
+		t.Errorf("got latency %d", p.Latency())
+	}
+	u := New(mono, mono, p)
+	u.SetInput(&ramp{Form: mono, n: 4096})
+	rec := &record{Form: mono}
+	u.AddOutput(rec)
+	if err := u.Run(); err != nil {
+		t.Fatal(err)
+	}
+	for i, v := range rec.chs[0] {
+		if v != float64(i) {
+			t.Fatalf("frame %d: got %g, not passed through", i, v)
+		}
+	}
+	select {
+	case f := <-p.Features():
+		if f.Frame != 4096 || len(f.Values) != 2 || f.Values[0] != 4095 || f.Values[1] != 256 {
+			t.Errorf("got feature %+v", f)
+		}
+	default:
+		t.Error("no feature")
+	}
+}
+
+func TestModelProcessorBadOutput(t *testing.T) {
+	mono := sound.MonoCd()
+	short := modelFunc(func(in []float32, channels, frames int) ([]float32, error) {
+		return in[:frames/2], nil
+	})
+	p, err := NewModelProcessor(short, ModelConfig{Window: 256, Hop: 256})
+	if err != nil {
+		t.Fatal(err)
+	}
+	u := New(mono, mono, p)
+	u.SetInput(&ramp{Form: mono, n: 4096})
+	u.AddOutput(&countSink{Form: mono})
+	if err := u.Run(); err == nil {
+		t.Error("accepted a short model output")
+	}
+}
@@ -0,0 +1,54 @@
+// Copyright 2018 The ZikiChombo Authors. All rights reserved.  Use of this source
+// code is governed by a license that can be found in the License file.
+
+package plug
+
+import (
+	"fmt"
+
+	"zikichombo.org/sound"
+)
+
+// monitorQueue is the number of blocks buffered for a monitor output.  It
+// is kept small, as a monitor which falls behind is better off dropping
+// blocks than lagging.
+const monitorQueue = 2
+
+// AddMonitor implements OutputController.
+func (n *node) AddMonitor(d sound.Sink, cs ...int) (*SecondaryOutput, error) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if err := n.ckAttach(); err != nil {
+		return nil, err
+	}
+	if d.SampleRate() != n.iForm.SampleRate() {
+		return nil, fmt.Errorf("frequency mismatch: got %s not %s\n", d.SampleRate(), n.iForm.SampleRate())
+	}
+	if len(cs) == 0 && d.Channels() != n.iForm.Channels() {
+		return nil, fmt.Errorf("channel mismatch: got %d not %d\n", d.Channels(), n.iForm.Channels())
+	}
+	if len(cs) != 0 && d.Channels() != len(cs) {
+		return nil, fmt.Errorf("channel mismatch: got %d not %d\n", d.Channels(), len(cs))
+	}
+	pkt := &packet{}
+	pkt.init(n.iForm, cs...)
+	pkt.snk = d
+	q := newOutQueue(pkt, monitorQueue, false, n.doneC)
+	q.detach = true
+	if err := n.startQueue(q, true); err != nil {
+		return nil, err
+	}
+	n.monitors = append(n.monitors, q)
+	return &SecondaryOutput{q: q}, nil
+}
+
+// monitor passes the input in b to the monitor outputs.
+func (n *node) monitor(b *Block) error {
+	for _, q := range n.monitors {
+		if err := q.put(b); err != nil {
+			return err
+		}
+		n.reportDetached(q)
+	}
+	return nil
+}
@@ -0,0 +1,80 @@
+// Copyright 2018 The ZikiChombo Authors. All rights reserved.  Use of this source
+// code is governed by a license that can be found in the License file.
+
+package plug
+
+type monoProcs struct {
+	factory func() Processor
+	procs   []Processor
+}
+
+// NewMonoProcessor creates a MonoMode processor for which the IO running
+// it instantiates one processor per input channel with factory, so that
+// stateful mono processors such as filters do not share state across
+// channels.  The instances should all have the same shape.
+//
+// Unlike PerChannel, the result is a MonoMode processor, and so may be
+// used wherever the instances may.
+func NewMonoProcessor(factory func() Processor) Processor {
+	return &monoProcs{
+		factory: factory,
+		procs:   []Processor{factory()}}
+}
+
+func (m *monoProcs) ChannelMode() ChannelMode {
+	return MonoMode
+}
+
+func (m *monoProcs) NextFrames() (int, int) {
+	for _, p := range m.procs[1:] {
+		p.NextFrames()
+	}
+	return m.procs[0].NextFrames()
+}
+
+func (m *monoProcs) Latency() int {
+	return latency(m.procs[0])
+}
+
+// Process processes channel 0, for callers which do not distinguish
+// channels.
+func (m *monoProcs) Process(dst, src *Block) error {
+	return m.procs[0].Process(dst, src)
+}
+
+// Reset implements Resetter, resetting every instance implementing
+// Resetter.
+func (m *monoProcs) Reset() {
+	for _, p := range m.procs {
+		if r, ok := p.(Resetter); ok {
+			r.Reset()
+		}
+	}
+}
+
+// channel returns the instance processing channel c, creating it as
+// needed.
+func (m *monoProcs) channel(c int) Processor {
+	for len(m.procs) <= c {
+		p := m.factory()
+		// keep the new instance in step with the others.
+		p.NextFrames()
+		m.procs = append(m.procs, p)
+	}
+	return m.procs[c]
+}
+
+// channeler is implemented by MonoMode processors which process every
+// channel with a processor of its own.
+type channeler interface {
+	channel(c int) Processor
+}
+
+// monoPick returns the function giving the processor of every channel
+// when p is run in MonoMode.
+func monoPick(p Processor) func(c int) Processor {
+	if ch, ok := p.(channeler); ok {
+		return ch.channel
+	}
+	return func(int) Processor { return p }
+}
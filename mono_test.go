@@ -0,0 +1,48 @@
+// Copyright 2018 The ZikiChombo Authors. All rights reserved.  Use of this source
+// code is governed by a license that can be found in the License file.
+
+package plug
+
+import (
+	"fmt"
+	"testing"
+
+	"zikichombo.org/sound"
+)
+
+func TestNewMonoProcessor(t *testing.T) {
+	mono := sound.MonoCd()
+	stereo := sound.StereoCd()
+	instances := 0
+	p := NewMonoProcessor(func() Processor {
+		instances++
+		sum := 0.0
+		return NewProcessor(MonoMode, func(dst, src *Block) error {
+			if src.Channels != 1 || dst.Channels != 1 {
+				return fmt.Errorf("got %d, %d channels", src.Channels, dst.Channels)
+			}
+			for i, v := range src.Samples[:src.Frames] {
+				sum += v
+				dst.Samples[i] = sum
+			}
+			dst.Frames = src.Frames
+			return nil
+		})
+	})
+	u := New(stereo, stereo, p)
+	u.SetInput(&ramp{Form: mono, n: 3000}, 0)
+	u.SetInput(&ramp{Form: mono, n: 3000}, 1)
+	snk := &capture{Form: stereo}
+	u.AddOutput(snk)
+	if err := u.Run(); err != nil {
+		t.Fatal(err)
+	}
+	if instances != 2 {
+		t.Errorf("got %d instances", instances)
+	}
+	for _, k := range []int{0, 1, 2999} {
+		if v, want := snk.mix[k], float64(k*(k+1)/2); v != want {
+			t.Errorf("frame %d: got %f not %f", k, v, want)
+		}
+	}
+}
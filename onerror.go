@@ -0,0 +1,33 @@
+// Copyright 2018 The ZikiChombo Authors. All rights reserved.  Use of this source
+// code is governed by a license that can be found in the License file.
+
+package plug
+
+// OnError implements Notifier.
+func (n *node) OnError(fn func(error)) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.onError = fn
+}
+
+// failed queues err for delivery to the function registered with OnError
+// after the current block.  n.mu must be held.
+func (n *node) failed(err error) {
+	n.errs = append(n.errs, err)
+}
+
+// deliverErrors calls the function registered with OnError with the
+// errors which occured in the last block.
+func (n *node) deliverErrors() {
+	n.mu.Lock()
+	errs := n.errs
+	n.errs = nil
+	fn := n.onError
+	n.mu.Unlock()
+	if fn == nil {
+		return
+	}
+	for _, err := range errs {
+		fn(err)
+	}
+}
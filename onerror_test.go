@@ -0,0 +1,45 @@
+// Copyright 2018 The ZikiChombo Authors. All rights reserved.  Use of this source
+// code is governed by a license that can be found in the License file.
+
+package plug
+
+import (
+	"testing"
+
+	"zikichombo.org/sound"
+)
+
+func TestIOOnError(t *testing.T) {
+	mono := sound.MonoCd()
+	u := New(mono, mono, PassThrough)
+	u.SetDetachOnError(true)
+	u.SetInput(&ramp{Form: mono, n: 3000})
+	u.AddOutput(&capture{Form: mono})
+	u.AddOutput(NewChaos(ChaosConfig{Error: 1}).Sink(&capture{Form: mono}))
+	var errs []error
+	u.OnError(func(err error) {
+		errs = append(errs, err)
+	})
+	if err := u.Run(); err != nil {
+		t.Fatal(err)
+	}
+	if len(errs) != 1 || errs[0] != ErrInjected {
+		t.Errorf("got errors %v", errs)
+	}
+	errs = nil
+	fail := NewProcessor(MonoMode, func(dst, src *Block) error {
+		return ErrInjected
+	})
+	u = New(mono, mono, fail)
+	u.SetInput(&ramp{Form: mono, n: 3000})
+	u.AddOutput(&capture{Form: mono})
+	u.OnError(func(err error) {
+		errs = append(errs, err)
+	})
+	if err := u.Run(); err != ErrInjected {
+		t.Errorf("got %v", err)
+	}
+	if len(errs) != 1 || errs[0] != ErrInjected {
+		t.Errorf("got errors %v", errs)
+	}
+}
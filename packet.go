@@ -15,6 +15,14 @@ type packet struct {
 	nC      int
 	src     sound.Source
 	snk     sound.Sink
+	off     bool         // detached output
+	removed bool         // output removed by RemoveOutput
+	q       *outQueue    // non-nil for outputs queued as per OutputController.SetDepth
+	lines   []*delayLine // per channel latency compensation of inputs
+	prov    *provSlot    // provenance of outputs created by Output
+	ann     *annSlot     // annotations of outputs created by Output
+	bp      *Backpressure
+	gains   []float64 // per channel gain of outputs
 }
 
 func (p *packet) init(v sound.Form, cs ...int) {
@@ -29,6 +37,18 @@ func (p *packet) init(v sound.Form, cs ...int) {
 	}
 }
 
+// delay runs the samples of p through its delay lines, if any.
+func (p *packet) delay() {
+	if p.lines == nil {
+		return
+	}
+	m := p.n
+	for c := 0; c < p.nC && c < len(p.lines); c++ {
+		d := p.samples[c*m : (c+1)*m]
+		p.lines[c].run(d, d)
+	}
+}
+
 func (p *packet) put(dst *Block) int {
 	sl := p.samples
 	nC := dst.Channels
@@ -39,10 +59,15 @@ func (p *packet) put(dst *Block) int {
 		if cc == -1 {
 			continue
 		}
-		sStart := cc * frms
-		sEnd := sStart + frms
 		dStart := c * frms
 		dEnd := dStart + frms
+		if cc >= p.nC {
+			// the source no longer provides this channel.
+			zero(dst.Samples[dStart:dEnd])
+			continue
+		}
+		sStart := cc * frms
+		sEnd := sStart + frms
 		copy(dst.Samples[dStart:dEnd], sl[sStart:sEnd])
 	}
 	return frms
@@ -64,6 +89,7 @@ func (p *packet) get(src *Block) {
 	}
 	p.samples = sl
 	p.n = frms
+	p.gain()
 }
 
 func buffer(d []float64, c, f int) []float64 {
@@ -75,3 +101,9 @@ func buffer(d []float64, c, f int) []float64 {
 	}
 	return d[:N]
 }
+
+func zero(d []float64) {
+	for i := range d {
+		d[i] = 0
+	}
+}
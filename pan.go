@@ -0,0 +1,91 @@
+// Copyright 2018 The ZikiChombo Authors. All rights reserved.  Use of this source
+// code is governed by a license that can be found in the License file.
+
+package plug
+
+import (
+	"fmt"
+	"math"
+)
+
+// Pan is a FullMode processor positioning its input in a stereo output.
+// A mono input is panned with constant power, so that its loudness does
+// not depend on its position.  A stereo input is balanced: the channel
+// away from which the input is panned is attenuated, with constant power
+// at the center and no change on the side panned to.
+//
+// The position is the parameter "pan", from -1 (left) to 1 (right).  Its
+// changes are ramped over a block to avoid clicks.
+type Pan struct {
+	ParamTable
+	pan    float64
+	gl, gr float64 // gains of the last frame processed
+	ramp   bool
+}
+
+// NewPan creates a new Pan with position pan.
+func NewPan(pan float64) *Pan {
+	p := &Pan{}
+	p.SetPan(pan)
+	p.Add(ParamInfo{Name: "pan", Min: -1, Max: 1, Default: p.pan}, p.Pan, p.SetPan)
+	return p
+}
+
+// SetPan sets the position, which is clamped to [-1..1].  It is meant to
+// be called through Parameterized.Apply or Parameterized.Params.
+func (p *Pan) SetPan(pan float64) {
+	p.pan = math.Max(-1, math.Min(1, pan))
+}
+
+// Pan returns the position.
+func (p *Pan) Pan() float64 {
+	return p.pan
+}
+
+// gains returns the gains of the left and right channels for a mono
+// input, or for a stereo input if balance.
+func (p *Pan) gains(balance bool) (float64, float64) {
+	th := (p.pan + 1) * math.Pi / 4
+	l, r := math.Cos(th), math.Sin(th)
+	if balance {
+		l = math.Min(1, math.Sqrt2*l)
+		r = math.Min(1, math.Sqrt2*r)
+	}
+	return l, r
+}
+
+// ChannelMode implements Processor.
+func (p *Pan) ChannelMode() ChannelMode {
+	return FullMode
+}
+
+// NextFrames implements Processor.
+func (p *Pan) NextFrames() (int, int) {
+	return DefaultInFrames, DefaultInFrames
+}
+
+// Process implements Processor.
+func (p *Pan) Process(dst, src *Block) error {
+	if (src.Channels != 1 && src.Channels != 2) || dst.Channels != 2 {
+		return fmt.Errorf("pan: cannot map %d channels to %d", src.Channels, dst.Channels)
+	}
+	N := src.Frames
+	gl, gr := p.gains(src.Channels == 2)
+	if !p.ramp {
+		p.gl, p.gr, p.ramp = gl, gr, true
+	}
+	in := src.Samples[:N]
+	inR := in
+	if src.Channels == 2 {
+		inR = src.Samples[N : 2*N]
+	}
+	l, r := dst.Samples[:N], dst.Samples[N:2*N]
+	for i := 0; i < N; i++ {
+		t := float64(i+1) / float64(N)
+		l[i] = in[i] * (p.gl + t*(gl-p.gl))
+		r[i] = inR[i] * (p.gr + t*(gr-p.gr))
+	}
+	p.gl, p.gr = gl, gr
+	dst.Frames = N
+	return nil
+}
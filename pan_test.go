@@ -0,0 +1,45 @@
+// Copyright 2018 The ZikiChombo Authors. All rights reserved.  Use of this source
+// code is governed by a license that can be found in the License file.
+
+package plug
+
+import (
+	"math"
+	"testing"
+
+	"zikichombo.org/sound"
+)
+
+func TestPan(t *testing.T) {
+	mono := sound.MonoCd()
+	stereo := sound.StereoCd()
+	for _, e := range []struct {
+		in     sound.Form
+		pan    float64
+		gl, gr float64
+	}{
+		{mono, 0, math.Sqrt(0.5), math.Sqrt(0.5)},
+		{mono, -1, 1, 0},
+		{mono, 1, 0, 1},
+		{stereo, 0, 1, 1},
+		{stereo, 0.5, math.Sqrt2 * math.Cos(0.375*math.Pi), 1},
+	} {
+		p := NewPan(e.pan)
+		N := 4
+		src := &Block{Channels: e.in.Channels(), Frames: N, Samples: make([]float64, e.in.Channels()*N)}
+		for i := range src.Samples {
+			src.Samples[i] = 1
+		}
+		dst := &Block{Channels: 2, Frames: N, Samples: make([]float64, 2*N)}
+		if err := p.Process(dst, src); err != nil {
+			t.Fatal(err)
+		}
+		if math.Abs(dst.Samples[0]-e.gl) > 1e-9 || math.Abs(dst.Samples[N]-e.gr) > 1e-9 {
+			t.Errorf("%d channels at %f: got %f, %f not %f, %f", e.in.Channels(), e.pan, dst.Samples[0], dst.Samples[N], e.gl, e.gr)
+		}
+	}
+	p := NewPan(0)
+	if err := p.Set("pan", 1); err != nil || p.Pan() != 1 {
+		t.Errorf("got pan %f, %v", p.Pan(), err)
+	}
+}
@@ -0,0 +1,176 @@
+// Copyright 2018 The ZikiChombo Authors. All rights reserved.  Use of this source
+// code is governed by a license that can be found in the License file.
+
+package plug
+
+import "fmt"
+
+// ParamInfo describes a parameter of a processor.
+type ParamInfo struct {
+	Name     string
+	Unit     string // for display, such as "dB", "Hz" or "ms", or empty
+	Min, Max float64
+	Default  float64
+}
+
+// Params is implemented by processors whose parameters may be discovered
+// and adjusted by name, for example by user interfaces or by automation,
+// OSC or MIDI control.
+//
+// The methods of a processor's Params need not be safe to call while the
+// processor runs: use those of Parameterized.Params instead.
+type Params interface {
+	// Names returns the names of the parameters.
+	Names() []string
+
+	// Info returns the description of the parameter name, if any.
+	Info(name string) (ParamInfo, bool)
+
+	// Get returns the value of the parameter name.
+	Get(name string) (float64, error)
+
+	// Set sets the parameter name to v, which must be in the range of the
+	// parameter.
+	Set(name string, v float64) error
+}
+
+// ParamTable implements Params by accessor functions, for processors to
+// embed.
+type ParamTable struct {
+	params []tableParam
+}
+
+type tableParam struct {
+	info ParamInfo
+	get  func() float64
+	set  func(float64)
+}
+
+// Add adds the parameter described by info, which is read by get and
+// written by set.
+func (t *ParamTable) Add(info ParamInfo, get func() float64, set func(float64)) {
+	t.params = append(t.params, tableParam{info: info, get: get, set: set})
+}
+
+// Names implements Params.
+func (t *ParamTable) Names() []string {
+	res := make([]string, len(t.params))
+	for i := range t.params {
+		res[i] = t.params[i].info.Name
+	}
+	return res
+}
+
+// Info implements Params.
+func (t *ParamTable) Info(name string) (ParamInfo, bool) {
+	p := t.find(name)
+	if p == nil {
+		return ParamInfo{}, false
+	}
+	return p.info, true
+}
+
+// Get implements Params.
+func (t *ParamTable) Get(name string) (float64, error) {
+	p := t.find(name)
+	if p == nil {
+		return 0, fmt.Errorf("unknown parameter %q", name)
+	}
+	return p.get(), nil
+}
+
+// Set implements Params.
+func (t *ParamTable) Set(name string, v float64) error {
+	p := t.find(name)
+	if p == nil {
+		return fmt.Errorf("unknown parameter %q", name)
+	}
+	if err := ckParam(p.info, v); err != nil {
+		return err
+	}
+	p.set(v)
+	return nil
+}
+
+func (t *ParamTable) find(name string) *tableParam {
+	for i := range t.params {
+		if t.params[i].info.Name == name {
+			return &t.params[i]
+		}
+	}
+	return nil
+}
+
+// ckParam checks that v is in the range of the parameter described by
+// info.
+func ckParam(info ParamInfo, v float64) error {
+	if v < info.Min || v > info.Max {
+		return fmt.Errorf("parameter %q: %g out of range [%g..%g]", info.Name, v, info.Min, info.Max)
+	}
+	return nil
+}
+
+// nodeParams gives safe access to the Params of the processor of a node.
+type nodeParams struct {
+	n *node
+	p Params
+}
+
+// Params implements Parameterized.
+func (n *node) Params() Params {
+	// n.proc is set under n.qmu as well as n.mu.
+	n.qmu.Lock()
+	proc := n.proc
+	n.qmu.Unlock()
+	if sf, ok := proc.(*swapFade); ok {
+		proc = sf.b
+	}
+	p, ok := proc.(Params)
+	if !ok {
+		return nil
+	}
+	return &nodeParams{n: n, p: p}
+}
+
+func (np *nodeParams) Names() []string {
+	return np.p.Names()
+}
+
+func (np *nodeParams) Info(name string) (ParamInfo, bool) {
+	return np.p.Info(name)
+}
+
+// Get returns the value of the parameter name, including any change not
+// yet applied.  Changes are applied under n.pmu, so holding it keeps the
+// processor's parameters from changing without waiting for the block being
+// processed.
+func (np *nodeParams) Get(name string) (float64, error) {
+	n := np.n
+	n.pmu.Lock()
+	defer n.pmu.Unlock()
+	for i := len(n.pending) - 1; i >= 0; i-- {
+		if c := &n.pending[i]; c.params && c.name == name {
+			return c.value, nil
+		}
+	}
+	return np.p.Get(name)
+}
+
+// Set checks the parameter change and applies it as Parameterized.Apply does.
+func (np *nodeParams) Set(name string, v float64) error {
+	info, ok := np.p.Info(name)
+	if !ok {
+		return fmt.Errorf("unknown parameter %q", name)
+	}
+	if err := ckParam(info, v); err != nil {
+		return err
+	}
+	n := np.n
+	n.pmu.Lock()
+	defer n.pmu.Unlock()
+	p := np.p
+	n.pending = append(n.pending, pendingChange{name: name, value: v, params: true, set: func(v float64) {
+		p.Set(name, v)
+	}})
+	return nil
+}
@@ -0,0 +1,46 @@
+// Copyright 2018 The ZikiChombo Authors. All rights reserved.  Use of this source
+// code is governed by a license that can be found in the License file.
+
+package plug
+
+import (
+	"testing"
+
+	"zikichombo.org/sound"
+)
+
+func TestIOParams(t *testing.T) {
+	mono := sound.MonoCd()
+	if New(mono, mono, PassThrough).Params() != nil {
+		t.Errorf("got params of PassThrough")
+	}
+	m := WithMix(PassThrough, 0.5)
+	u := New(mono, mono, m)
+	ps := u.Params()
+	if names := ps.Names(); len(names) != 1 || names[0] != "wet" {
+		t.Fatalf("got names %v", names)
+	}
+	if info, _ := ps.Info("wet"); info.Min != 0 || info.Max != 1 || info.Default != 0.5 {
+		t.Errorf("got info %+v", info)
+	}
+	if err := ps.Set("wet", 2); err == nil {
+		t.Errorf("set out of range")
+	}
+	if err := ps.Set("dry", 0); err == nil {
+		t.Errorf("set unknown parameter")
+	}
+	if err := ps.Set("wet", 0.25); err != nil {
+		t.Fatal(err)
+	}
+	if v, _ := ps.Get("wet"); v != 0.25 || m.Wet() != 0.5 {
+		t.Errorf("got pending %f, applied %f", v, m.Wet())
+	}
+	u.SetInput(&ramp{Form: mono, n: 3000})
+	u.AddOutput(&capture{Form: mono})
+	if err := u.Run(); err != nil {
+		t.Fatal(err)
+	}
+	if v, _ := ps.Get("wet"); v != 0.25 || m.Wet() != 0.25 {
+		t.Errorf("got %f, applied %f", v, m.Wet())
+	}
+}
@@ -0,0 +1,50 @@
+// Copyright 2018 The ZikiChombo Authors. All rights reserved.  Use of this source
+// code is governed by a license that can be found in the License file.
+
+package plug
+
+// Pause implements Pauser.
+func (n *node) Pause() {
+	n.qmu.Lock()
+	defer n.qmu.Unlock()
+	n.paused = true
+}
+
+// isPaused returns whether n is paused and not stopped.
+func (n *node) isPaused() bool {
+	n.qmu.Lock()
+	defer n.qmu.Unlock()
+	return n.paused && !n.stopped()
+}
+
+// wakeSeq wakes the sequential run of n, if any, waiting for paused nodes.
+// n.qmu must be held.
+func (n *node) wakeSeq() {
+	if n.wake == nil {
+		return
+	}
+	select {
+	case n.wake <- struct{}{}:
+	default:
+	}
+}
+
+// waitPaused waits while n is paused and not stopped, before it processes
+// a block.  n.mu must not be held, so that n may be configured while
+// paused.
+func (n *node) waitPaused() {
+	n.qmu.Lock()
+	defer n.qmu.Unlock()
+	for n.paused && !n.stopped() {
+		n.qc.Wait()
+	}
+}
+
+// Pause pauses all the nodes of the graph implementing Pauser.
+func (g *Graph) Pause() {
+	for _, n := range g.nodes {
+		if p, ok := n.(Pauser); ok {
+			p.Pause()
+		}
+	}
+}
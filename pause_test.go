@@ -0,0 +1,114 @@
+// Copyright 2018 The ZikiChombo Authors. All rights reserved.  Use of this source
+// code is governed by a license that can be found in the License file.
+
+package plug
+
+import (
+	"testing"
+	"time"
+
+	"zikichombo.org/sound"
+	"zikichombo.org/sound/gen"
+	"zikichombo.org/sound/ops"
+)
+
+func TestIOPause(t *testing.T) {
+	u := New(sound.MonoCd(), sound.MonoCd(), PassThrough)
+	u.SetInput(ops.Limit(gen.Noise(), 44100))
+	snk := &countSink{Form: sound.MonoCd()}
+	u.AddOutput(snk)
+	u.Pause()
+	errC := make(chan error)
+	go func() {
+		errC <- u.Run()
+	}()
+	select {
+	case err := <-errC:
+		t.Fatalf("paused IO ended: %v", err)
+	case <-time.After(20 * time.Millisecond):
+	}
+	u.Resume()
+	if err := <-errC; err != nil {
+		t.Fatal(err)
+	}
+	if snk.n != 44100 {
+		t.Errorf("got %d frames not 44100", snk.n)
+	}
+}
+
+func TestIOPauseConfigure(t *testing.T) {
+	u := New(sound.MonoCd(), sound.MonoCd(), NewDCBlock(10))
+	u.SetInput(gen.Noise())
+	u.AddOutput(&countSink{Form: sound.MonoCd()})
+	errC := make(chan error)
+	go func() {
+		errC <- u.Run()
+	}()
+	u.Pause()
+	// let the IO reach the pause.
+	time.Sleep(20 * time.Millisecond)
+	done := make(chan struct{})
+	go func() {
+		u.SetBypass(true)
+		u.Params().Set("freq", 20)
+		u.Latency()
+		u.AddOutput(&countSink{Form: sound.MonoCd()})
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("configuring a paused IO blocked")
+	}
+	u.Resume()
+	u.Stop()
+	if err := <-errC; err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestIOConfigureDownstreamOfPause(t *testing.T) {
+	mono := sound.MonoCd()
+	var g Graph
+	a := g.New(mono, mono, PassThrough)
+	b := g.New(mono, mono, NewDCBlock(10))
+	a.SetInput(gen.Noise())
+	if err := g.Connect(a, nil, b, nil); err != nil {
+		t.Fatal(err)
+	}
+	b.AddOutput(&countSink{Form: mono})
+	ec := g.Run()
+	a.Pause()
+	// let b wait for a.
+	time.Sleep(20 * time.Millisecond)
+	applied := make(chan float64, 1)
+	done := make(chan struct{})
+	go func() {
+		b.Apply("x", 1, func(v float64) { applied <- v })
+		b.ApplyAt(0, "y", 1, func(float64) {})
+		b.SetBypass(true)
+		b.SetBypass(false)
+		if err := b.Params().Set("freq", 20); err != nil {
+			t.Error(err)
+		}
+		if v, err := b.Params().Get("freq"); err != nil || v != 20 {
+			t.Errorf("got %g, %v", v, err)
+		}
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("configuring an IO downstream of a paused IO blocked")
+	}
+	a.Resume()
+	select {
+	case <-applied:
+	case <-time.After(time.Second):
+		t.Error("change not applied after resuming")
+	}
+	g.Stop()
+	for err := range ec {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,120 @@
+// Copyright 2018 The ZikiChombo Authors. All rights reserved.  Use of this source
+// code is governed by a license that can be found in the License file.
+
+package plug
+
+import (
+	"fmt"
+	"math"
+	"sync"
+)
+
+// PhaseReading is a measurement of a PhaseMeter.
+type PhaseReading struct {
+	// Correlation is the normalized correlation of the two channels, in
+	// [-1..1].  1 means the channels are identical up to gain, 0 that they are
+	// unrelated and -1 that one is the inverse of the other, which cancels
+	// when summed to mono.
+	Correlation float64
+	// Phase is the phase difference corresponding to Correlation, in
+	// radians in [0..Pi].
+	Phase float64
+}
+
+// PhaseMeter is a FullMode pass-through analyzer for stereo signals which
+// measures the correlation of its 2 channels and keeps goniometer (mid/side
+// vector scope) data.  It is useful for diagnosing mono compatibility.
+type PhaseMeter struct {
+	mu       sync.Mutex
+	tc       float64
+	lr       float64
+	ll, rr   float64
+	last     PhaseReading
+	gonio    [][2]float64
+	gi       int
+	readings chan PhaseReading
+}
+
+// NewPhaseMeter creates a new PhaseMeter which integrates its measurements
+// with time constant tc, in seconds, and keeps the last nPoints goniometer
+// points.
+func NewPhaseMeter(tc float64, nPoints int) *PhaseMeter {
+	return &PhaseMeter{
+		tc:       tc,
+		gonio:    make([][2]float64, nPoints),
+		readings: make(chan PhaseReading, 1)}
+}
+
+// Reading returns the latest reading.
+func (m *PhaseMeter) Reading() PhaseReading {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.last
+}
+
+// Readings returns a channel on which a new reading is made available after
+// every processed block.  Only the latest reading is kept.
+func (m *PhaseMeter) Readings() <-chan PhaseReading {
+	return m.readings
+}
+
+// Goniometer copies the most recent goniometer points into dst, oldest first,
+// and returns the number of points copied.  Each point is a (side, mid) pair,
+// so that a mono signal lies on the vertical axis.
+func (m *PhaseMeter) Goniometer(dst [][2]float64) int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	n := 0
+	L := len(m.gonio)
+	for i := 0; i < L && n < len(dst); i++ {
+		dst[n] = m.gonio[(m.gi+i)%L]
+		n++
+	}
+	return n
+}
+
+// ChannelMode implements Processor.
+func (m *PhaseMeter) ChannelMode() ChannelMode {
+	return FullMode
+}
+
+// NextFrames implements Processor.
+func (m *PhaseMeter) NextFrames() (int, int) {
+	return DefaultInFrames, DefaultInFrames
+}
+
+// Process implements Processor.
+func (m *PhaseMeter) Process(dst, src *Block) error {
+	if src.Channels != 2 {
+		return fmt.Errorf("phase meter: need 2 channels, got %d", src.Channels)
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	N := src.Frames
+	copy(dst.Samples[:2*N], src.Samples[:2*N])
+	dst.Frames = N
+	a := math.Exp(-1 / (m.tc * src.SampleRate.Float64()))
+	L := src.Samples[:N]
+	R := src.Samples[N : 2*N]
+	for i, l := range L {
+		r := R[i]
+		m.lr = a*m.lr + (1-a)*l*r
+		m.ll = a*m.ll + (1-a)*l*l
+		m.rr = a*m.rr + (1-a)*r*r
+		if len(m.gonio) > 0 {
+			m.gonio[m.gi] = [2]float64{(l - r) / math.Sqrt2, (l + r) / math.Sqrt2}
+			m.gi = (m.gi + 1) % len(m.gonio)
+		}
+	}
+	corr := 0.0
+	if den := math.Sqrt(m.ll * m.rr); den > 0 {
+		corr = math.Max(-1, math.Min(1, m.lr/den))
+	}
+	m.last = PhaseReading{Correlation: corr, Phase: math.Acos(corr)}
+	select {
+	case <-m.readings:
+	default:
+	}
+	m.readings <- m.last
+	return nil
+}
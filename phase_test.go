@@ -0,0 +1,63 @@
+// Copyright 2018 The ZikiChombo Authors. All rights reserved.  Use of this source
+// code is governed by a license that can be found in the License file.
+
+package plug
+
+import (
+	"math"
+	"testing"
+
+	"zikichombo.org/sound/freq"
+)
+
+func TestPhaseMeter(t *testing.T) {
+	sr := 48000 * freq.Hertz
+	N := 1024
+	for _, tc := range []struct {
+		name  string
+		r     func(l, q float64) float64 // of the left sample and its quadrature
+		corr  float64
+		phase float64
+	}{
+		{"in phase", func(l, q float64) float64 { return 0.5 * l }, 1, 0},
+		{"inverted", func(l, q float64) float64 { return -l }, -1, math.Pi},
+		{"quadrature", func(l, q float64) float64 { return q }, 0, math.Pi / 2},
+	} {
+		m := NewPhaseMeter(0.05, 16)
+		for off := 0; off < 48000; off += N {
+			src := &Block{SampleRate: sr, Channels: 2, Frames: N, Samples: make([]float64, 2*N)}
+			for f := 0; f < N; f++ {
+				w := 2 * math.Pi * 375 * float64(off+f) / sr.Float64()
+				src.Samples[f] = math.Sin(w)
+				src.Samples[N+f] = tc.r(math.Sin(w), math.Cos(w))
+			}
+			dst := &Block{SampleRate: sr, Channels: 2, Samples: make([]float64, 2*N)}
+			if err := m.Process(dst, src); err != nil {
+				t.Fatal(err)
+			}
+		}
+		rd := m.Reading()
+		if math.Abs(rd.Correlation-tc.corr) > 0.01 || math.Abs(rd.Phase-tc.phase) > 0.05 {
+			t.Errorf("%s: got %+v not correlation %g and phase %g", tc.name, rd, tc.corr, tc.phase)
+		}
+		if got := <-m.Readings(); got != rd {
+			t.Errorf("%s: got %+v from Readings not %+v", tc.name, got, rd)
+		}
+		pts := make([][2]float64, 32)
+		if n := m.Goniometer(pts); n != 16 {
+			t.Fatalf("%s: got %d goniometer points", tc.name, n)
+		}
+		for _, p := range pts[:16] {
+			switch tc.name {
+			case "inverted":
+				if math.Abs(p[1]) > 1e-9 {
+					t.Errorf("%s: got mid %g", tc.name, p[1])
+				}
+			case "in phase":
+				if math.Abs(p[0]-p[1]/3) > 1e-9 {
+					t.Errorf("%s: got point %v off the line", tc.name, p)
+				}
+			}
+		}
+	}
+}
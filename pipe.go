@@ -0,0 +1,187 @@
+// Copyright 2018 The ZikiChombo Authors. All rights reserved.  Use of this source
+// code is governed by a license that can be found in the License file.
+
+package plug
+
+import (
+	"io"
+	"sync"
+
+	"zikichombo.org/sound"
+)
+
+// pipe is a synchronous in memory pipe, like sound.Pipe, which hands the
+// samples sent over to the receiver without copying them to an
+// intermediate buffer, so that piping blocks does not allocate.  As Send
+// blocks until every frame sent is received, the sender's slice may be
+// read by the receiver in place.
+//
+// For checkpoints, a pipe keeps track of the frames received by the
+// Receive in progress, and may be given frames to receive before those
+// sent.
+type pipe struct {
+	sound.Form
+	c    chan *pipeChunk
+	ack  chan int
+	done chan struct{}
+	once sync.Once
+
+	mu     sync.Mutex
+	dst    []float64 // of the Receive in progress, or the last one
+	m, got int       // frames of dst and frames received into it
+	pre    []float64 // frames to receive first
+}
+
+// pipeChunk gives the frames of a Send not yet received.
+type pipeChunk struct {
+	d      []float64
+	F, off int
+}
+
+type pipeSrc struct {
+	*pipe
+}
+
+type pipeSnk struct {
+	*pipe
+	chunk pipeChunk
+}
+
+// newPipe creates a new pipe of form f, returning its source and sink.
+func newPipe(f sound.Form) (sound.Source, sound.Sink) {
+	p := &pipe{Form: f, c: make(chan *pipeChunk), ack: make(chan int), done: make(chan struct{})}
+	return pipeSrc{p}, &pipeSnk{pipe: p}
+}
+
+// Close implements sound.Source and sound.Sink, ending the pipe.
+func (p *pipe) Close() error {
+	p.once.Do(func() { close(p.done) })
+	return nil
+}
+
+// Receive implements sound.Source.
+func (s pipeSrc) Receive(dst []float64) (int, error) {
+	nC := s.Channels()
+	m := len(dst) / nC
+	s.mu.Lock()
+	s.dst, s.m, s.got = dst, m, 0
+	if len(s.pre) > 0 {
+		s.got = s.takePre(dst, m)
+	}
+	got := s.got
+	s.mu.Unlock()
+	for got < m {
+		select {
+		case ch := <-s.c:
+			n := ch.F - ch.off
+			if n > m-got {
+				n = m - got
+			}
+			s.mu.Lock()
+			for c := 0; c < nC; c++ {
+				src := ch.d[c*ch.F+ch.off:]
+				copy(dst[c*m+got:c*m+got+n], src[:n])
+			}
+			got += n
+			s.got = got
+			s.mu.Unlock()
+			s.ack <- n
+		case <-s.done:
+			if got == 0 {
+				return 0, io.EOF
+			}
+			// pack the partial result.
+			s.mu.Lock()
+			for c := 1; c < nC; c++ {
+				copy(dst[c*got:(c+1)*got], dst[c*m:c*m+got])
+			}
+			s.m = got
+			s.mu.Unlock()
+			return got, nil
+		}
+	}
+	return got, nil
+}
+
+// takePre moves up to m of the frames to receive first into dst, of m
+// frames, returning the number of frames moved.  p.mu must be held.
+func (p *pipe) takePre(dst []float64, m int) int {
+	nC := p.Channels()
+	F := len(p.pre) / nC
+	n := F
+	if n > m {
+		n = m
+	}
+	rest := make([]float64, nC*(F-n))
+	for c := 0; c < nC; c++ {
+		copy(dst[c*m:c*m+n], p.pre[c*F:c*F+n])
+		copy(rest[c*(F-n):(c+1)*(F-n)], p.pre[c*F+n:(c+1)*F])
+	}
+	p.pre = rest
+	return n
+}
+
+// partial returns the frames received since the last call to reset,
+// followed by any frames still to receive first, in channel
+// deinterleaved format.
+func (p *pipe) partial() []float64 {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	nC := p.Channels()
+	F := len(p.pre) / nC
+	N := p.got + F
+	if N == 0 {
+		return nil
+	}
+	res := make([]float64, nC*N)
+	for c := 0; c < nC; c++ {
+		copy(res[c*N:c*N+p.got], p.dst[c*p.m:c*p.m+p.got])
+		copy(res[c*N+p.got:(c+1)*N], p.pre[c*F:(c+1)*F])
+	}
+	return res
+}
+
+// reset forgets the frames received so far, once the receiver has taken
+// them in.
+func (p *pipe) reset() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.dst, p.m, p.got = nil, 0, 0
+}
+
+// restore sets the frames to receive before those sent, as returned by
+// partial.
+func (p *pipe) restore(pre []float64) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.pre = pre
+}
+
+// inPipe returns the pipe of the source s, if it is the Output of an IO.
+func inPipe(s sound.Source) *pipe {
+	ns, ok := s.(*nodeSource)
+	if !ok {
+		return nil
+	}
+	ps, ok := ns.Source.(pipeSrc)
+	if !ok {
+		return nil
+	}
+	return ps.pipe
+}
+
+// Send implements sound.Sink.
+func (s *pipeSnk) Send(src []float64) error {
+	ch := &s.chunk
+	ch.d, ch.F, ch.off = src, len(src)/s.Channels(), 0
+	defer func() { ch.d = nil }()
+	for ch.off < ch.F {
+		select {
+		case s.c <- ch:
+			ch.off += <-s.ack
+		case <-s.done:
+			return io.EOF
+		}
+	}
+	return nil
+}
@@ -0,0 +1,26 @@
+// Copyright 2018 The ZikiChombo Authors. All rights reserved.  Use of this source
+// code is governed by a license that can be found in the License file.
+
+package plug
+
+import "sync"
+
+// blockPool pools the pairs of temporary blocks used to run processors
+// wrapped by other processors, so that this does not allocate in the
+// steady state.  The samples of pooled blocks belong to the pool and are
+// reused with buffer.
+var blockPool = sync.Pool{New: func() interface{} { return new([2]Block) }}
+
+// getBlocks gets a pair of temporary blocks from the pool.
+func getBlocks() *[2]Block {
+	return blockPool.Get().(*[2]Block)
+}
+
+// putBlocks returns a pair of temporary blocks to the pool, with samples
+// samples, which are those of the pair as got from the pool.
+func putBlocks(bs *[2]Block, samples [2][]float64) {
+	for i := range bs {
+		bs[i] = Block{Samples: samples[i]}
+	}
+	blockPool.Put(bs)
+}
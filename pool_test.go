@@ -0,0 +1,103 @@
+// Copyright 2018 The ZikiChombo Authors. All rights reserved.  Use of this source
+// code is governed by a license that can be found in the License file.
+
+package plug
+
+import (
+	"io"
+	"math"
+	"testing"
+
+	"zikichombo.org/sound"
+)
+
+// raceEnabled is set when the race detector is on.
+var raceEnabled bool
+
+type nullSink struct{ sound.Form }
+
+func (s nullSink) Close() error           { return nil }
+func (s nullSink) Send(d []float64) error { return nil }
+
+// silence is a source of n frames which leaves dst untouched.
+type silence struct {
+	sound.Form
+	n int
+}
+
+func (s *silence) Close() error { return nil }
+
+func (s *silence) Receive(dst []float64) (int, error) {
+	if s.n <= 0 {
+		return 0, io.EOF
+	}
+	m := len(dst) / s.Channels()
+	if m > s.n {
+		m = s.n
+	}
+	s.n -= m
+	return m, nil
+}
+
+func benchChain(b *testing.B) {
+	v := sound.MonoCd()
+	b.ReportAllocs()
+	g := &Graph{}
+	u := g.New(v, v, PassThrough)
+	w := g.New(v, v, LowPass(1000, math.Sqrt2/2))
+	u.SetInput(&silence{Form: v, n: DefaultInFrames * b.N})
+	if err := g.Connect(u, nil, w, nil); err != nil {
+		b.Fatal(err)
+	}
+	w.AddOutput(nullSink{v})
+	b.ResetTimer()
+	for err := range g.Run() {
+		b.Fatal(err)
+	}
+}
+
+func benchIO(b *testing.B, p Processor) {
+	v := sound.StereoCd()
+	b.ReportAllocs()
+	u := New(v, v, p)
+	u.SetInput(&silence{Form: v, n: DefaultInFrames * b.N})
+	u.AddOutput(nullSink{v})
+	b.ResetTimer()
+	if err := u.Run(); err != nil {
+		b.Fatal(err)
+	}
+}
+
+func benchWrapped(b *testing.B) {
+	benchIO(b, WithMix(NewEQ(Band{Freq: 1000, Gain: 3, Q: 1}), 0.5))
+}
+
+func benchCompose(b *testing.B) {
+	benchIO(b, Compose(PassThrough, NewEQ(Band{Freq: 1000, Gain: 3, Q: 1}), swapper{}))
+}
+
+func BenchmarkChain(b *testing.B)   { benchChain(b) }
+func BenchmarkWrapped(b *testing.B) { benchWrapped(b) }
+func BenchmarkCompose(b *testing.B) { benchCompose(b) }
+
+func TestZeroAlloc(t *testing.T) {
+	if raceEnabled {
+		t.Skip("sync.Pool drops items under the race detector")
+	}
+	if testing.Short() {
+		t.Skip("benchmarks in short mode")
+	}
+	for _, c := range []struct {
+		name  string
+		bench func(*testing.B)
+	}{
+		{"chain", benchChain},
+		{"wrapped", benchWrapped},
+		{"compose", benchCompose},
+	} {
+		r := testing.Benchmark(c.bench)
+		if a := r.AllocsPerOp(); a != 0 {
+			t.Errorf("%s: %d allocs/op, want 0", c.name, a)
+		}
+	}
+}
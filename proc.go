@@ -41,18 +41,20 @@ type Processor interface {
 	// Assuming the last call to next frames returned N, M, Process may assume
 	// that
 	//
-	//  1. 1 <= src.Frames <= N
+	//  1. 1 <= src.Frames <= N, or src.Frames == 0 for the tail of a Tailer
 	//  2. dst.Frames == M
 	//  3. len(src.Samples) = N * src.Channels
 	//  4. len(dst.Samples) = M * dst.Channels
-	//  5. src.Samples and dst.Samples are in channel deinterleaved format.
+	//  5. src.Samples and dst.Samples are in channel deinterleaved format,
+	//     or interleaved for an Interleaver.
 	//
 	// In turn, let us denote the value of dst.Frames before the call as M, and after,  M';
 	// then process should guarantee that
 	//
 	// 1. M' contains the real number of outputs written
 	// 2. 0 <= M' <= M
-	// 3. dst.Samples[:d.Channels*M'] is in channel de-interleaved format.
+	// 3. dst.Samples[:d.Channels*M'] is in channel de-interleaved format,
+	//    or interleaved for an Interleaver.
 	Process(dst, src *Block) error
 }
 
@@ -0,0 +1,133 @@
+// Copyright 2018 The ZikiChombo Authors. All rights reserved.  Use of this source
+// code is governed by a license that can be found in the License file.
+
+package plug
+
+import (
+	"fmt"
+	"sync"
+)
+
+// provenanceBacklog is the maximum number of records kept for an output
+// created by Output which is not read.
+const provenanceBacklog = 64
+
+// ProvenanceStep records that a node processed a block.
+type ProvenanceStep struct {
+	Node      string // the name given to Instrumented.TrackProvenance.
+	Processor string // the Go type of the processor.
+	Version   int64  // number of parameter changes applied by the node.
+	Frame     int64  // input frame position of the block at the node.
+}
+
+// Provenance records the nodes which processed a block, upstream nodes
+// first.  When a node reads blocks of a different size than its inputs
+// produce, a block may record several steps of the same upstream node.
+type Provenance []ProvenanceStep
+
+// ProvenanceSink is implemented by sinks which audit the provenance of the
+// audio sent to them.  SetProvenance is called before each Send with the
+// provenance of the block sent, if any.
+type ProvenanceSink interface {
+	SetProvenance(p Provenance)
+}
+
+// TrackProvenance implements Instrumented.
+func (n *node) TrackProvenance(name string) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.provName = name
+}
+
+// provRec is the provenance of frames frames sent to an output.
+type provRec struct {
+	frames int
+	p      Provenance
+}
+
+// provSlot carries the provenance of the blocks sent to an output created
+// by Output to the node reading it.
+type provSlot struct {
+	mu   sync.Mutex
+	recs []provRec
+	off  int // frames of recs[0] already read
+}
+
+func (s *provSlot) push(frames int, p Provenance) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.recs) == provenanceBacklog {
+		s.recs = s.recs[:copy(s.recs, s.recs[1:])]
+		s.off = 0
+	}
+	s.recs = append(s.recs, provRec{frames: frames, p: p})
+}
+
+// pop returns the provenance of the next frames frames read.
+func (s *provSlot) pop(frames int) Provenance {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var res Provenance
+	for frames > 0 && len(s.recs) > 0 {
+		r := &s.recs[0]
+		res = mergeProvenance(res, r.p)
+		m := r.frames - s.off
+		if m > frames {
+			s.off += frames
+			break
+		}
+		frames -= m
+		s.off = 0
+		s.recs = s.recs[:copy(s.recs, s.recs[1:])]
+	}
+	return res
+}
+
+// mergeProvenance appends to dst the steps of src not in dst.
+func mergeProvenance(dst, src Provenance) Provenance {
+outer:
+	for _, st := range src {
+		for _, d := range dst {
+			if d == st {
+				continue outer
+			}
+		}
+		dst = append(dst, st)
+	}
+	return dst
+}
+
+// provenance computes the provenance of the block being processed, made of
+// nFrms frames from the inputs starting at frame, if any.  n.mu must be
+// held.
+func (n *node) provenance(frame int64, nFrms int) Provenance {
+	var res Provenance
+	for i := range n.iPkts {
+		if ns, ok := n.iPkts[i].src.(*nodeSource); ok && ns.prov != nil {
+			res = mergeProvenance(res, ns.prov.pop(nFrms))
+		}
+	}
+	if n.provName == "" {
+		return res
+	}
+	return append(res, ProvenanceStep{
+		Node:      n.provName,
+		Processor: fmt.Sprintf("%T", n.proc),
+		Version:   n.version,
+		Frame:     frame})
+}
+
+// sendProvenance passes the provenance of the output block to output pkt.
+// n.mu must be held.
+func (n *node) sendProvenance(pkt *packet, frames int) {
+	if n.prov == nil {
+		return
+	}
+	if pkt.prov != nil {
+		pkt.prov.push(frames, n.prov)
+		return
+	}
+	if ps, ok := pkt.snk.(ProvenanceSink); ok {
+		ps.SetProvenance(n.prov)
+	}
+}
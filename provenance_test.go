@@ -0,0 +1,48 @@
+// Copyright 2018 The ZikiChombo Authors. All rights reserved.  Use of this source
+// code is governed by a license that can be found in the License file.
+
+package plug
+
+import (
+	"testing"
+
+	"zikichombo.org/sound"
+	"zikichombo.org/sound/gen"
+	"zikichombo.org/sound/ops"
+)
+
+// provSink keeps the last provenance set.
+type provSink struct {
+	countSink
+	p Provenance
+}
+
+func (s *provSink) SetProvenance(p Provenance) {
+	s.p = p
+}
+
+func TestIOProvenance(t *testing.T) {
+	mono := sound.MonoCd()
+	var g Graph
+	u0 := g.New(mono, mono, PassThrough)
+	u1 := g.New(mono, mono, NewProcessorFrames(MonoMode, PassThrough.Process, 300, 300))
+	u0.TrackProvenance("a")
+	u1.TrackProvenance("b")
+	u0.SetInput(ops.Limit(gen.Noise(), 4096))
+	if err := g.Connect(u0, nil, u1, nil); err != nil {
+		t.Fatal(err)
+	}
+	snk := &provSink{countSink: countSink{Form: mono}}
+	u1.AddOutput(snk)
+	u0.Apply("x", 1, func(float64) {})
+	if err := g.RunSequential(); err != nil {
+		t.Fatal(err)
+	}
+	p := snk.p
+	if len(p) != 2 || p[0].Node != "a" || p[1].Node != "b" {
+		t.Fatalf("got provenance %v", p)
+	}
+	if p[0].Version != 1 || p[0].Frame != 3072 || p[1].Frame != 3900 {
+		t.Errorf("got provenance %v", p)
+	}
+}
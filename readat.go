@@ -0,0 +1,163 @@
+// Copyright 2018 The ZikiChombo Authors. All rights reserved.  Use of this source
+// code is governed by a license that can be found in the License file.
+
+package plug
+
+import (
+	"fmt"
+	"io"
+
+	"zikichombo.org/sound"
+)
+
+// Seeker is implemented by sources which can be positioned at an arbitrary
+// frame, as required by ProcessorController.ReadAt.
+type Seeker interface {
+	// SeekFrame positions the source so that the next frame received is
+	// frame.
+	SeekFrame(frame int64) error
+}
+
+// nodeSource is the source returned by IO.Output.  Besides streaming the
+// output of its node, it lets ProcessorController.ReadAt downstream pull from the node.
+type nodeSource struct {
+	sound.Source
+	n    *node
+	cs   []int
+	prov *provSlot
+	ann  *annSlot
+}
+
+// ReadAt implements ProcessorController.
+func (n *node) ReadAt(frame int64, dst *Block) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	_, err := n.readAt(frame, dst)
+	return err
+}
+
+// readAt renders dst.Frames output frames starting at frame into dst,
+// returning the number of frames rendered before the inputs ended.
+// n.mu is held.
+func (n *node) readAt(frame int64, dst *Block) (int, error) {
+	oC := n.oForm.Channels()
+	if dst.Channels != oC {
+		return 0, fmt.Errorf("channel mismatch: got %d not %d", dst.Channels, oC)
+	}
+	dst.Samples = buffer(dst.Samples, oC, dst.Frames)
+	zero(dst.Samples)
+	iC := n.iForm.Channels()
+	iBlock := &Block{SampleRate: n.iForm.SampleRate(), Channels: iC}
+	oBlock := &Block{SampleRate: n.oForm.SampleRate(), Channels: oC}
+	pos := int64(float64(frame) * n.iForm.SampleRate().Float64() / n.oForm.SampleRate().Float64())
+	done := 0
+	for done < dst.Frames {
+		iFrms, oFrms := n.takeFrames()
+		if iFrms <= 0 || oFrms <= 0 {
+			return done, fmt.Errorf("processor requested %d/%d frames", iFrms, oFrms)
+		}
+		iBlock.Samples = buffer(iBlock.Samples, iC, iFrms)
+		iBlock.Frames = iFrms
+		oBlock.Samples = buffer(oBlock.Samples, oC, oFrms)
+		oBlock.Frames = oFrms
+		m, err := n.readInputsAt(pos, iBlock)
+		if err != nil && err != io.EOF {
+			return done, err
+		}
+		eof := err == io.EOF
+		iBlock.StartFrame, oBlock.StartFrame = pos, frame+int64(done)
+		if err := n.runProc(n.proc, iBlock, oBlock, iFrms); err != nil {
+			return done, err
+		}
+		k := oBlock.Frames
+		if eof {
+			k = k * m / iFrms
+		}
+		if k > dst.Frames-done {
+			k = dst.Frames - done
+		}
+		for c := 0; c < oC; c++ {
+			d := dst.Samples[c*dst.Frames+done:]
+			copy(d[:k], oBlock.Samples[c*oFrms:])
+		}
+		done += k
+		pos += int64(iFrms)
+		if eof {
+			return done, io.EOF
+		}
+	}
+	return done, nil
+}
+
+// readInputsAt reads iBlock.Frames frames starting at pos from every input
+// into iBlock, returning the least number of frames read.  Inputs which end
+// are padded with silence, and io.EOF is returned.
+func (n *node) readInputsAt(pos int64, iBlock *Block) (int, error) {
+	zero(iBlock.Samples)
+	frms := iBlock.Frames
+	nFrms := frms
+	var eof error
+	n.startPut()
+	for i := range n.iPkts {
+		pkt := &n.iPkts[i]
+		pkt.samples = buffer(pkt.samples, pkt.nC, frms)
+		m, err := readSourceAt(pkt.src, pos, pkt.samples, pkt.nC, frms)
+		if err == io.EOF {
+			eof = err
+		} else if err != nil {
+			return 0, fmt.Errorf("input %d: %v", i, err)
+		}
+		if m < nFrms {
+			nFrms = m
+		}
+		pkt.n = frms
+		n.put(pkt, iBlock)
+	}
+	n.endPut(iBlock, frms)
+	return nFrms, eof
+}
+
+// readSourceAt reads frms frames of nC channels starting at pos from src
+// into d, with channel stride frms.
+func readSourceAt(src sound.Source, pos int64, d []float64, nC, frms int) (int, error) {
+	switch s := src.(type) {
+	case *nodeSource:
+		b := &Block{SampleRate: s.n.oForm.SampleRate(), Channels: s.n.oForm.Channels(), Frames: frms}
+		s.n.mu.Lock()
+		m, err := s.n.readAt(pos, b)
+		s.n.mu.Unlock()
+		for i := 0; i < nC; i++ {
+			c := i
+			if len(s.cs) != 0 {
+				c = s.cs[i]
+			}
+			copy(d[i*frms:(i+1)*frms], b.Samples[c*frms:(c+1)*frms])
+		}
+		return m, err
+	case Seeker:
+		if err := s.SeekFrame(pos); err != nil {
+			return 0, err
+		}
+		return readFull(src, d, nC, frms)
+	}
+	return 0, fmt.Errorf("source is not seekable")
+}
+
+// readFull receives frms frames of nC channels from src into d, with
+// channel stride frms, padding with silence if src ends.
+func readFull(src sound.Source, d []float64, nC, frms int) (int, error) {
+	zero(d)
+	tmp := make([]float64, nC*frms)
+	got := 0
+	for got < frms {
+		m, err := src.Receive(tmp[:nC*(frms-got)])
+		for c := 0; c < nC; c++ {
+			copy(d[c*frms+got:c*frms+got+m], tmp[c*m:(c+1)*m])
+		}
+		got += m
+		if err != nil {
+			return got, err
+		}
+	}
+	return got, nil
+}
@@ -0,0 +1,34 @@
+// Copyright 2018 The ZikiChombo Authors. All rights reserved.  Use of this source
+// code is governed by a license that can be found in the License file.
+
+package plug
+
+import (
+	"io"
+	"testing"
+
+	"zikichombo.org/sound"
+)
+
+func TestIOReadAt(t *testing.T) {
+	valve := sound.MonoCd()
+	u0 := New(valve, valve, PassThrough)
+	u1 := New(valve, valve, PassThrough)
+	u0.SetInput(&ramp{Form: valve, n: 10000})
+	u1.SetInput(u0.Output())
+	b := &Block{Channels: 1, Frames: 3000}
+	if err := u1.ReadAt(5000, b); err != nil {
+		t.Fatal(err)
+	}
+	for i, v := range b.Samples {
+		if v != float64(5000+i) {
+			t.Fatalf("frame %d: got %f", i, v)
+		}
+	}
+	if err := u1.ReadAt(9000, b); err != io.EOF {
+		t.Errorf("got %v not EOF", err)
+	}
+	if b.Samples[999] != 9999 || b.Samples[1000] != 0 {
+		t.Errorf("bad end %f %f", b.Samples[999], b.Samples[1000])
+	}
+}
@@ -0,0 +1,217 @@
+// Copyright 2018 The ZikiChombo Authors. All rights reserved.  Use of this source
+// code is governed by a license that can be found in the License file.
+
+package plug
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"sync"
+
+	"zikichombo.org/sound"
+	"zikichombo.org/sound/freq"
+)
+
+// Factory creates a processor from parameters, as decoded from JSON.
+type Factory func(params map[string]interface{}) (Processor, error)
+
+var registry = struct {
+	sync.Mutex
+	m map[string]Factory
+}{m: make(map[string]Factory)}
+
+// Register registers the processor factory f under name, so that graphs
+// with nodes created by Graph.NewRegistered may be serialized to and from
+// JSON.  Register panics if name is already registered.
+func Register(name string, f Factory) {
+	registry.Lock()
+	defer registry.Unlock()
+	if _, ok := registry.m[name]; ok {
+		panic(fmt.Sprintf("plug: processor %q registered twice", name))
+	}
+	registry.m[name] = f
+}
+
+// Registered returns the sorted names of the registered processors.
+func Registered() []string {
+	registry.Lock()
+	defer registry.Unlock()
+	res := make([]string, 0, len(registry.m))
+	for name := range registry.m {
+		res = append(res, name)
+	}
+	sort.Strings(res)
+	return res
+}
+
+func factory(name string) (Factory, error) {
+	registry.Lock()
+	defer registry.Unlock()
+	f, ok := registry.m[name]
+	if !ok {
+		return nil, fmt.Errorf("processor %q not registered", name)
+	}
+	return f, nil
+}
+
+// nodeSpec records how a node was created by NewRegistered.
+type nodeSpec struct {
+	Processor string                 `json:"processor"`
+	Params    map[string]interface{} `json:"params,omitempty"`
+	In        formSpec               `json:"in"`
+	Out       formSpec               `json:"out"`
+}
+
+type formSpec struct {
+	Rate     float64 `json:"rate"` // Hertz
+	Channels int     `json:"channels"`
+}
+
+func newFormSpec(f sound.Form) formSpec {
+	return formSpec{Rate: f.SampleRate().Float64(), Channels: f.Channels()}
+}
+
+// form returns the form of f, which comes from untrusted JSON and so is
+// checked first.
+func (f formSpec) form() (sound.Form, error) {
+	if !(f.Rate > 0) {
+		return nil, fmt.Errorf("invalid sample rate %g", f.Rate)
+	}
+	if f.Channels <= 0 {
+		return nil, fmt.Errorf("invalid number of channels %d", f.Channels)
+	}
+	return sound.NewForm(freq.T(f.Rate*float64(freq.Hertz)), f.Channels), nil
+}
+
+type edgeSpec struct {
+	From      int   `json:"from"`
+	FromChans []int `json:"fromChans,omitempty"`
+	To        int   `json:"to"`
+	ToChans   []int `json:"toChans,omitempty"`
+}
+
+type graphSpec struct {
+	Nodes []*nodeSpec `json:"nodes"`
+	Edges []edgeSpec  `json:"edges"`
+}
+
+// NewRegistered is like New, but creates the processor with the factory
+// registered under name, with parameters params.  Graphs whose nodes are
+// all created by NewRegistered and connected by Connect can be serialized
+// to JSON.
+func (g *Graph) NewRegistered(name string, params map[string]interface{}, iForm, oForm sound.Form) (Plug, error) {
+	f, err := factory(name)
+	if err != nil {
+		return nil, err
+	}
+	p, err := f(params)
+	if err != nil {
+		return nil, fmt.Errorf("processor %q: %s", name, err)
+	}
+	n := g.New(iForm, oForm, p)
+	if g.specs == nil {
+		g.specs = make(map[IO]*nodeSpec)
+	}
+	g.specs[n] = &nodeSpec{
+		Processor: name,
+		Params:    params,
+		In:        newFormSpec(iForm),
+		Out:       newFormSpec(oForm)}
+	return n, nil
+}
+
+// MarshalJSON encodes the nodes and edges of g as JSON.  Every node must
+// have been created by NewRegistered, and every input a node reads from
+// another node of g must have been set by Connect.  Sources and sinks
+// outside the graph are not encoded; after decoding, they should be
+// connected to the nodes returned by Nodes, which are in the same order.
+func (g *Graph) MarshalJSON() ([]byte, error) {
+	spec := &graphSpec{Nodes: make([]*nodeSpec, len(g.nodes)), Edges: []edgeSpec{}}
+	ids := make(map[IO]int, len(g.nodes))
+	for i, n := range g.nodes {
+		s, ok := g.specs[n]
+		if !ok {
+			return nil, fmt.Errorf("node %d: processor not registered", i)
+		}
+		spec.Nodes[i] = s
+		ids[n] = i
+	}
+	edges := make(map[[2]IO]int, len(g.edges))
+	for _, e := range g.edges {
+		edges[[2]IO{e.From, e.To}]++
+	}
+	for i, n := range g.nodes {
+		for _, m := range g.feeders(n) {
+			k := [2]IO{m, n}
+			if edges[k] == 0 {
+				return nil, fmt.Errorf("node %d: input from node %d not set by Connect", i, ids[m])
+			}
+			edges[k]--
+		}
+	}
+	for _, e := range g.edges {
+		spec.Edges = append(spec.Edges, edgeSpec{
+			From:      ids[e.From],
+			FromChans: e.FromChans,
+			To:        ids[e.To],
+			ToChans:   e.ToChans})
+	}
+	return json.Marshal(spec)
+}
+
+// UnmarshalJSON decodes a graph encoded by MarshalJSON into g, which must
+// be empty, creating its nodes with the registered factories and
+// connecting them.
+func (g *Graph) UnmarshalJSON(d []byte) error {
+	if len(g.nodes) != 0 {
+		return fmt.Errorf("unmarshal into non-empty graph")
+	}
+	spec := &graphSpec{}
+	if err := json.Unmarshal(d, spec); err != nil {
+		return err
+	}
+	for i, s := range spec.Nodes {
+		if s == nil {
+			return fmt.Errorf("node %d: missing", i)
+		}
+		iForm, err := s.In.form()
+		if err != nil {
+			return fmt.Errorf("node %d: input: %s", i, err)
+		}
+		oForm, err := s.Out.form()
+		if err != nil {
+			return fmt.Errorf("node %d: output: %s", i, err)
+		}
+		if _, err := g.NewRegistered(s.Processor, s.Params, iForm, oForm); err != nil {
+			return fmt.Errorf("node %d: %s", i, err)
+		}
+	}
+	for i, e := range spec.Edges {
+		if e.From < 0 || e.From >= len(g.nodes) || e.To < 0 || e.To >= len(g.nodes) {
+			return fmt.Errorf("edge %d: node out of range", i)
+		}
+		if err := g.Connect(g.nodes[e.From], e.FromChans, g.nodes[e.To], e.ToChans); err != nil {
+			return fmt.Errorf("edge %d: %s", i, err)
+		}
+	}
+	return nil
+}
+
+// feeders returns the nodes of g which n reads from, once per input.  Like
+// fed, it only takes n.wmu.
+func (g *Graph) feeders(n IO) []IO {
+	nd, ok := n.(*node)
+	if !ok {
+		return nil
+	}
+	nd.wmu.Lock()
+	defer nd.wmu.Unlock()
+	var res []IO
+	for i := range nd.iPkts {
+		if ns, ok := nd.iPkts[i].src.(*nodeSource); ok && g.has(ns.n) {
+			res = append(res, ns.n)
+		}
+	}
+	return res
+}
@@ -0,0 +1,80 @@
+// Copyright 2018 The ZikiChombo Authors. All rights reserved.  Use of this source
+// code is governed by a license that can be found in the License file.
+
+package plug
+
+import (
+	"testing"
+
+	"zikichombo.org/sound"
+)
+
+func init() {
+	Register("test.mono", func(params map[string]interface{}) (Processor, error) {
+		return ToMono, nil
+	})
+}
+
+func TestGraphJSON(t *testing.T) {
+	stereo := sound.StereoCd()
+	mono := sound.MonoCd()
+	var g Graph
+	u0, err := g.NewRegistered("test.mono", nil, stereo, mono)
+	if err != nil {
+		t.Fatal(err)
+	}
+	u1, err := g.NewRegistered("test.mono", map[string]interface{}{"x": 1.0}, stereo, mono)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := g.Connect(u0, nil, u1, []int{1}); err != nil {
+		t.Fatal(err)
+	}
+	d, err := g.MarshalJSON()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var h Graph
+	if err := h.UnmarshalJSON(d); err != nil {
+		t.Fatal(err)
+	}
+	e, err := h.MarshalJSON()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(d) != string(e) {
+		t.Errorf("got %s not %s", e, d)
+	}
+	if _, err := g.NewRegistered("test.none", nil, stereo, mono); err == nil {
+		t.Errorf("created unregistered processor")
+	}
+}
+
+func TestGraphJSONErrors(t *testing.T) {
+	for _, d := range []string{
+		`{"nodes":[{"processor":"test.mono","in":{"rate":44100,"channels":-1},"out":{"rate":44100,"channels":1}}],"edges":[]}`,
+		`{"nodes":[{"processor":"test.mono","in":{"rate":44100,"channels":2},"out":{"rate":0,"channels":1}}],"edges":[]}`,
+		`{"nodes":[{"processor":"test.mono","in":{"channels":2},"out":{"rate":44100,"channels":1}}],"edges":[]}`} {
+		var g Graph
+		if err := g.UnmarshalJSON([]byte(d)); err == nil {
+			t.Errorf("decoded %s", d)
+		}
+	}
+	stereo := sound.StereoCd()
+	mono := sound.MonoCd()
+	var g Graph
+	u0, err := g.NewRegistered("test.mono", nil, stereo, mono)
+	if err != nil {
+		t.Fatal(err)
+	}
+	u1, err := g.NewRegistered("test.mono", nil, stereo, mono)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := u1.SetInput(u0.Output(), 0); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := g.MarshalJSON(); err == nil {
+		t.Errorf("encoded an input set by SetInput")
+	}
+}
@@ -0,0 +1,70 @@
+// Copyright 2018 The ZikiChombo Authors. All rights reserved.  Use of this source
+// code is governed by a license that can be found in the License file.
+
+package plug
+
+import (
+	"fmt"
+
+	"zikichombo.org/sound"
+)
+
+// RemoveOutput implements OutputController.
+func (n *node) RemoveOutput(d sound.Sink) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	for i := range n.oPkts {
+		pkt := &n.oPkts[i]
+		if pkt.src == nil && !pkt.removed && pkt.snk == d {
+			n.removeOut(i)
+			return nil
+		}
+	}
+	return fmt.Errorf("sink is not an output")
+}
+
+// RemoveOutputSource implements OutputController.
+func (n *node) RemoveOutputSource(s sound.Source) error {
+	if ns, ok := s.(*nodeSource); ok {
+		s = ns.Source
+	}
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	for i := range n.oPkts {
+		pkt := &n.oPkts[i]
+		if pkt.src != nil && !pkt.removed && pkt.src == s {
+			n.removeOut(i)
+			return nil
+		}
+	}
+	return fmt.Errorf("source is not an output")
+}
+
+// removeOut removes output i, closing its sink or queue and ending the
+// goroutine of its conn.  The packet stays in place, turned off, so that
+// the indices of the other outputs do not change.  n.mu must be held.
+func (n *node) removeOut(i int) {
+	pkt := &n.oPkts[i]
+	switch {
+	case pkt.q != nil:
+		for j, q := range n.queues {
+			if q == pkt.q {
+				n.wmu.Lock()
+				n.queues = append(n.queues[:j], n.queues[j+1:]...)
+				n.wmu.Unlock()
+				break
+			}
+		}
+		pkt.q.close()
+	case !pkt.off:
+		pkt.snk.Close()
+	}
+	pkt.off = true
+	pkt.removed = true
+	close(n.outs[i].quit)
+	for c := 0; c < pkt.nC; c++ {
+		if oc := pkt.cmap.imapC(c); oc >= 0 && n.ocCounts[oc] > 0 {
+			n.ocCounts[oc]--
+		}
+	}
+}
@@ -0,0 +1,37 @@
+// Copyright 2018 The ZikiChombo Authors. All rights reserved.  Use of this source
+// code is governed by a license that can be found in the License file.
+
+package plug
+
+import (
+	"testing"
+
+	"zikichombo.org/sound"
+	"zikichombo.org/sound/gen"
+	"zikichombo.org/sound/ops"
+)
+
+func TestIORemoveOutput(t *testing.T) {
+	mono := sound.MonoCd()
+	u := New(mono, mono, PassThrough)
+	u.SetInput(ops.Limit(gen.Noise(), 44100))
+	a, b := &countSink{Form: mono}, &countSink{Form: mono}
+	u.AddOutput(a)
+	u.AddOutput(b)
+	src := u.Output()
+	if err := u.RemoveOutput(a); err != nil {
+		t.Fatal(err)
+	}
+	if err := u.RemoveOutput(a); err == nil {
+		t.Errorf("removed output twice")
+	}
+	if err := u.RemoveOutputSource(src); err != nil {
+		t.Fatal(err)
+	}
+	if err := u.Run(); err != nil {
+		t.Fatal(err)
+	}
+	if a.n != 0 || b.n != 44100 {
+		t.Errorf("got %d and %d frames", a.n, b.n)
+	}
+}
@@ -0,0 +1,199 @@
+// Copyright 2018 The ZikiChombo Authors. All rights reserved.  Use of this source
+// code is governed by a license that can be found in the License file.
+
+package plug
+
+import (
+	"math"
+
+	"zikichombo.org/sound/freq"
+)
+
+// SRCQuality gives the quality of sample rate conversion by an SRC.
+type SRCQuality int
+
+const (
+	// SRCFast is the fastest and least accurate conversion.
+	SRCFast SRCQuality = iota
+	// SRCMedium balances speed and accuracy.
+	SRCMedium
+	// SRCBest is the slowest and most accurate conversion.
+	SRCBest
+)
+
+// zero crossings on each side of the sinc kernel by quality.
+var srcZeros = [...]int{SRCFast: 8, SRCMedium: 16, SRCBest: 32}
+
+// kernel table entries per zero crossing.
+const srcRes = 512
+
+// SRC is a FullMode sample rate conversion processor, converting its input
+// at one sample rate to output at another by band limited interpolation
+// with a windowed sinc kernel.  When converting down, the kernel also
+// filters out the frequencies above the output Nyquist frequency.
+//
+// The number of input frames an SRC asks for in NextFrames is whatever
+// gives a block of output, so that an IO running an SRC may have input and
+// output forms with different sample rates, for example to feed a 44.1kHz
+// encoder from a 48kHz capture.  Output frame m corresponds to input at
+// time m/out, and once the input has ended the SRC outputs the frames up
+// to the end of the input as its tail.
+//
+// The input and output forms of a node running an SRC should have the
+// same number of channels.
+type SRC struct {
+	ratio  float64 // input frames per output frame
+	fc     float64 // cutoff, relative to the input Nyquist frequency
+	Z, W   int     // kernel zero crossings and half width in input frames
+	kernel []float64
+	w      []float64
+	in     [][]float64
+	pa     float64 // position of the next output frame in in
+	nIn    int64   // input frames
+	nOut   int64   // output frames
+	ended  bool
+}
+
+// NewSRC creates a new SRC converting from sample rate in to sample rate
+// out with quality q.
+func NewSRC(in, out freq.T, q SRCQuality) *SRC {
+	if q < SRCFast || q > SRCBest {
+		q = SRCMedium
+	}
+	s := &SRC{ratio: in.Float64() / out.Float64(), Z: srcZeros[q]}
+	s.fc = math.Min(1, 1/s.ratio)
+	s.W = int(math.Ceil(float64(s.Z) / s.fc))
+	s.kernel = make([]float64, s.Z*srcRes+2)
+	for i := range s.kernel {
+		u := float64(i) / srcRes
+		if u >= float64(s.Z) {
+			break
+		}
+		// Blackman window
+		x := math.Pi * (1 + u/float64(s.Z))
+		w := 0.42 - 0.5*math.Cos(x) + 0.08*math.Cos(2*x)
+		s.kernel[i] = s.fc * sinc(u) * w
+	}
+	s.Reset()
+	return s
+}
+
+func sinc(x float64) float64 {
+	if x == 0 {
+		return 1
+	}
+	return math.Sin(math.Pi*x) / (math.Pi * x)
+}
+
+// Reset implements Resetter.
+func (s *SRC) Reset() {
+	s.in = nil
+	s.pa = float64(s.W)
+	s.nIn, s.nOut = 0, 0
+	s.ended = false
+}
+
+// Tail implements Tailer.
+func (s *SRC) Tail() bool {
+	return !s.ended || s.nOut < s.want()
+}
+
+// want returns the number of output frames for the input so far.
+func (s *SRC) want() int64 {
+	return int64(math.Ceil(float64(s.nIn) / s.ratio))
+}
+
+// ChannelMode implements Processor.
+func (s *SRC) ChannelMode() ChannelMode {
+	return FullMode
+}
+
+// NextFrames implements Processor.
+func (s *SRC) NextFrames() (int, int) {
+	have := 0
+	if len(s.in) > 0 {
+		have = len(s.in[0])
+	}
+	n := int(math.Ceil(s.pa+DefaultOutFrames*s.ratio)) + s.W + 1 - have
+	if n < 1 {
+		n = 1
+	}
+	return n, DefaultOutFrames
+}
+
+// Process implements Processor.
+func (s *SRC) Process(dst, src *Block) error {
+	nC := src.Channels
+	for len(s.in) < nC {
+		// silence precedes the input for the kernel.
+		s.in = append(s.in, make([]float64, s.W))
+	}
+	N := src.Frames
+	if N == 0 && !s.ended {
+		s.ended = true
+		for c := range s.in {
+			s.in[c] = append(s.in[c], make([]float64, s.W+1)...)
+		}
+	}
+	for c := 0; c < nC; c++ {
+		s.in[c] = append(s.in[c], src.Samples[c*N:(c+1)*N]...)
+	}
+	s.nIn += int64(N)
+	M := dst.Frames
+	if s.ended && int64(M) > s.want()-s.nOut {
+		M = int(s.want() - s.nOut)
+	}
+	avail := len(s.in[0])
+	if len(s.w) < 2*s.W {
+		s.w = make([]float64, 2*s.W)
+	}
+	m := 0
+	for ; m < M; m++ {
+		i := int(s.pa)
+		if i+s.W >= avail {
+			break
+		}
+		s.weights(s.pa - float64(i))
+		for c := 0; c < nC; c++ {
+			x := s.in[c][i-s.W+1 : i+s.W+1]
+			y := 0.0
+			for k, w := range s.w {
+				y += w * x[k]
+			}
+			dst.Samples[c*M+m] = y
+		}
+		s.pa += s.ratio
+	}
+	if m < M {
+		for c := 1; c < nC; c++ {
+			copy(dst.Samples[c*m:(c+1)*m], dst.Samples[c*M:c*M+m])
+		}
+	}
+	dst.Frames = m
+	s.nOut += int64(m)
+
+	// discard input which is no longer needed.
+	if cut := int(s.pa) - s.W; cut > 0 {
+		for c, x := range s.in {
+			s.in[c] = x[:copy(x, x[cut:])]
+		}
+		s.pa -= float64(cut)
+	}
+	return nil
+}
+
+// weights sets s.w to the kernel weights of the input frames around an
+// output frame at fraction f past an input frame.
+func (s *SRC) weights(f float64) {
+	Z := float64(s.Z)
+	for k := range s.w {
+		u := math.Abs(f+float64(s.W-1-k)) * s.fc
+		if u >= Z {
+			s.w[k] = 0
+			continue
+		}
+		p := u * srcRes
+		j := int(p)
+		s.w[k] = s.kernel[j] + (p-float64(j))*(s.kernel[j+1]-s.kernel[j])
+	}
+}
@@ -0,0 +1,44 @@
+// Copyright 2018 The ZikiChombo Authors. All rights reserved.  Use of this source
+// code is governed by a license that can be found in the License file.
+
+package plug
+
+import (
+	"math"
+	"testing"
+
+	"zikichombo.org/sound"
+	"zikichombo.org/sound/freq"
+)
+
+func TestSRC(t *testing.T) {
+	for _, e := range []struct {
+		in, out freq.T
+		q       SRCQuality
+		tol     float64
+	}{
+		{48000 * freq.Hertz, 44100 * freq.Hertz, SRCBest, 1e-4},
+		{48000 * freq.Hertz, 44100 * freq.Hertz, SRCFast, 1e-2},
+		{22050 * freq.Hertz, 48000 * freq.Hertz, SRCMedium, 1e-3},
+	} {
+		iForm, oForm := sound.NewForm(e.in, 1), sound.NewForm(e.out, 1)
+		var g Graph
+		u := g.New(iForm, oForm, NewSRC(e.in, e.out, e.q))
+		u.SetInput(&tone{Form: iForm, f: 1000, end: 4800})
+		snk := &capture{Form: oForm}
+		u.AddOutput(snk)
+		if err := u.Run(); err != nil {
+			t.Fatal(err)
+		}
+		n := int64(math.Ceil(4800 * e.out.Float64() / e.in.Float64()))
+		if snk.frames != n {
+			t.Errorf("%s to %s: got %d frames not %d", e.in, e.out, snk.frames, n)
+		}
+		for m := 200; m < int(n)-200; m++ {
+			exp := math.Sin(2 * math.Pi * 1000 * float64(m) / e.out.Float64())
+			if d := math.Abs(snk.mix[m] - exp); d > e.tol {
+				t.Fatalf("%s to %s: frame %d: got %f not %f", e.in, e.out, m, snk.mix[m], exp)
+			}
+		}
+	}
+}
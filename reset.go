@@ -0,0 +1,40 @@
+// Copyright 2018 The ZikiChombo Authors. All rights reserved.  Use of this source
+// code is governed by a license that can be found in the License file.
+
+package plug
+
+// Resetter is implemented by stateful processors, such as filters and
+// delays, which can clear their state to be reused, for example across
+// Run sessions or after a seek, without being reallocated.
+type Resetter interface {
+	// Reset clears the state of the processor, as if it had not processed
+	// any block.
+	Reset()
+}
+
+// Reset implements ProcessorController.
+func (n *node) Reset() {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	proc := n.proc
+	if sf, ok := proc.(*swapFade); ok {
+		// reset the processor being faded in, ending the fade.
+		proc = sf.b
+		n.setProc(proc)
+		n.retire(sf.a)
+	}
+	if r, ok := proc.(Resetter); ok {
+		r.Reset()
+	}
+	n.anns = nil
+}
+
+// Reset resets the processors of all the nodes of the graph, for example
+// from a function registered with Clock.OnSeek.
+func (g *Graph) Reset() {
+	for _, n := range g.nodes {
+		if pc, ok := n.(ProcessorController); ok {
+			pc.Reset()
+		}
+	}
+}
@@ -0,0 +1,48 @@
+// Copyright 2018 The ZikiChombo Authors. All rights reserved.  Use of this source
+// code is governed by a license that can be found in the License file.
+
+package plug
+
+import (
+	"math"
+	"testing"
+
+	"zikichombo.org/sound"
+)
+
+type resetCount struct {
+	Processor
+	resets int
+}
+
+func (p *resetCount) Reset() { p.resets++ }
+
+func TestIOReset(t *testing.T) {
+	mono := sound.MonoCd()
+	p := &resetCount{Processor: PassThrough}
+	g := &Graph{}
+	u := g.New(mono, mono, WithHeadroom(p, 6))
+	u.Reset()
+	g.Reset()
+	if p.resets != 2 {
+		t.Errorf("got %d resets", p.resets)
+	}
+	d := NewDriftCorrector(1.002)
+	u = New(mono, mono, d)
+	u.SetInput(&ramp{Form: mono, n: 3000})
+	u.AddOutput(&capture{Form: mono})
+	if err := u.Run(); err != nil {
+		t.Fatal(err)
+	}
+	u.Reset()
+	u = New(mono, mono, d)
+	u.SetInput(&ramp{Form: mono, n: 3000})
+	snk := &capture{Form: mono}
+	u.AddOutput(snk)
+	if err := u.Run(); err != nil {
+		t.Fatal(err)
+	}
+	if snk.mix[0] != 0 || math.Abs(snk.mix[1000]-1002) > 1e-6 {
+		t.Errorf("got %f, %f after reset", snk.mix[0], snk.mix[1000])
+	}
+}
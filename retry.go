@@ -0,0 +1,53 @@
+// Copyright 2018 The ZikiChombo Authors. All rights reserved.  Use of this source
+// code is governed by a license that can be found in the License file.
+
+package plug
+
+import (
+	"io"
+	"time"
+)
+
+// RetryPolicy describes how an IO treats errors receiving from its inputs
+// and sending to its outputs.  io.EOF and ErrChannelsChanged are never
+// treated as errors to retry.
+//
+// The zero value of RetryPolicy gives the default behavior: the first error
+// ends the IO.
+type RetryPolicy struct {
+	// Retries gives the number of times a failed Receive or Send is retried.
+	Retries int
+	// Backoff gives the delay before the first retry.  The delay doubles
+	// with every subsequent retry.
+	Backoff time.Duration
+	// Silence, if true, means that when all retries fail the error is
+	// dropped: a failed input provides silence for the block and a failed
+	// output misses the block.  Otherwise, the error ends the IO.
+	Silence bool
+}
+
+func (p *RetryPolicy) retryable(err error) bool {
+	return err != nil && err != io.EOF && err != ErrChannelsChanged
+}
+
+// do calls fn until it succeeds or the policy is exhausted, returning the
+// last error.  do returns early with the last error if doneC is closed
+// while waiting to retry.
+func (p *RetryPolicy) do(doneC chan struct{}, fn func() error) error {
+	err := fn()
+	d := p.Backoff
+	for i := 0; i < p.Retries && p.retryable(err); i++ {
+		if d > 0 {
+			t := time.NewTimer(d)
+			select {
+			case <-doneC:
+				t.Stop()
+				return err
+			case <-t.C:
+			}
+			d *= 2
+		}
+		err = fn()
+	}
+	return err
+}
@@ -0,0 +1,84 @@
+// Copyright 2018 The ZikiChombo Authors. All rights reserved.  Use of this source
+// code is governed by a license that can be found in the License file.
+
+package plug
+
+import (
+	"testing"
+	"time"
+
+	"zikichombo.org/sound"
+)
+
+// flaky is a ramp whose every 3rd Receive fails fails times before
+// succeeding.
+type flaky struct {
+	ramp
+	fails      int
+	calls, cur int
+}
+
+func (f *flaky) Receive(d []float64) (int, error) {
+	f.calls++
+	if f.calls%3 == 0 && f.cur < f.fails {
+		f.cur++
+		f.calls--
+		return 0, ErrInjected
+	}
+	f.cur = 0
+	return f.ramp.Receive(d)
+}
+
+func TestIORetryPolicy(t *testing.T) {
+	mono := sound.MonoCd()
+	run := func(p RetryPolicy) (*record, error) {
+		u := New(mono, mono, PassThrough)
+		u.SetInput(&flaky{ramp: ramp{Form: mono, n: 10000}, fails: 2})
+		u.SetRetryPolicy(p)
+		rec := &record{Form: mono}
+		u.AddOutput(rec)
+		return rec, u.Run()
+	}
+
+	// the retries outlast the failures.
+	rec, err := run(RetryPolicy{Retries: 2, Backoff: time.Millisecond})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rec.chs[0]) != 10000 {
+		t.Fatalf("got %d frames not 10000", len(rec.chs[0]))
+	}
+	for i, v := range rec.chs[0] {
+		if v != float64(i) {
+			t.Fatalf("frame %d: got %g", i, v)
+		}
+	}
+
+	// the retries are exhausted.
+	if _, err := run(RetryPolicy{Retries: 1}); err != ErrInjected {
+		t.Errorf("exhausted retries: got %v not %v", err, ErrInjected)
+	}
+	if _, err := run(RetryPolicy{}); err != ErrInjected {
+		t.Errorf("no retries: got %v not %v", err, ErrInjected)
+	}
+
+	// the exhausted retries are replaced by silence.
+	rec, err = run(RetryPolicy{Retries: 1, Silence: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	silent, next := 0, 0.0
+	for _, v := range rec.chs[0] {
+		switch v {
+		case next:
+			next++
+		case 0:
+			silent++
+		default:
+			t.Fatalf("got %g not %g", v, next)
+		}
+	}
+	if next != 10000 || silent == 0 {
+		t.Errorf("got %d ramp frames and %d silent frames", int(next), silent)
+	}
+}
@@ -0,0 +1,234 @@
+// Copyright 2018 The ZikiChombo Authors. All rights reserved.  Use of this source
+// code is governed by a license that can be found in the License file.
+
+package plug
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"zikichombo.org/sound"
+)
+
+// Route describes how the channels of a source are routed to the channels
+// of a destination, as a gain matrix.
+type Route struct {
+	// Matrix[o][i] gives the gain of source channel i in destination
+	// channel o.
+	Matrix [][]float64
+}
+
+// ParseRoute parses a route expression for a source with nIn channels and
+// a destination with nOut channels.
+//
+// A route expression is a comma separated list of rules.  Each rule has
+// the form
+//
+//  sources>destination
+//
+// where sources is a comma separated list of source channels, each
+// optionally followed by a gain, as in "1*0.5".  The destination is either
+// a destination channel, in which case the sources are summed into it, or
+// "mix", in which case the sources are averaged into every destination
+// channel.  For example
+//
+//  "0>1,1>0"     swaps 2 channels
+//  "0,1>mix"     mixes 2 channels down to mono
+//  "0>0,0*0.5>1" sends channel 0 to channel 0 and at half gain to channel 1
+//
+// Destination channels with no rule are left unrouted.
+func ParseRoute(expr string, nIn, nOut int) (*Route, error) {
+	res := &Route{Matrix: make([][]float64, nOut)}
+	for o := range res.Matrix {
+		res.Matrix[o] = make([]float64, nIn)
+	}
+	var srcs []string
+	for _, tok := range strings.Split(expr, ",") {
+		tok = strings.TrimSpace(tok)
+		gt := strings.Index(tok, ">")
+		if gt == -1 {
+			srcs = append(srcs, tok)
+			continue
+		}
+		srcs = append(srcs, strings.TrimSpace(tok[:gt]))
+		if err := res.rule(srcs, strings.TrimSpace(tok[gt+1:]), nIn, nOut); err != nil {
+			return nil, fmt.Errorf("route %q: %s", expr, err)
+		}
+		srcs = nil
+	}
+	if len(srcs) != 0 {
+		return nil, fmt.Errorf("route %q: sources %v have no destination", expr, srcs)
+	}
+	return res, nil
+}
+
+func (r *Route) rule(srcs []string, dst string, nIn, nOut int) error {
+	gains := make([]float64, nIn)
+	for _, s := range srcs {
+		g := 1.0
+		if star := strings.Index(s, "*"); star != -1 {
+			var err error
+			g, err = strconv.ParseFloat(strings.TrimSpace(s[star+1:]), 64)
+			if err != nil {
+				return err
+			}
+			s = strings.TrimSpace(s[:star])
+		}
+		c, err := strconv.Atoi(s)
+		if err != nil {
+			return err
+		}
+		if c < 0 || c >= nIn {
+			return fmt.Errorf("source channel %d out of range", c)
+		}
+		gains[c] += g
+	}
+	if dst == "mix" {
+		for o := range r.Matrix {
+			for i, g := range gains {
+				r.Matrix[o][i] += g / float64(len(srcs))
+			}
+		}
+		return nil
+	}
+	o, err := strconv.Atoi(dst)
+	if err != nil {
+		return err
+	}
+	if o < 0 || o >= nOut {
+		return fmt.Errorf("destination channel %d out of range", o)
+	}
+	for i, g := range gains {
+		r.Matrix[o][i] += g
+	}
+	return nil
+}
+
+// ChannelMap returns, if the route is a one-to-one mapping of every source
+// channel to a distinct destination channel with unit gain, the channel
+// list to pass to IO.SetInput, and true.  Otherwise, it returns nil and
+// false.
+func (r *Route) ChannelMap() ([]int, bool) {
+	if len(r.Matrix) == 0 {
+		return nil, false
+	}
+	cs := make([]int, len(r.Matrix[0]))
+	for i := range cs {
+		cs[i] = -1
+	}
+	for o, row := range r.Matrix {
+		for i, g := range row {
+			switch g {
+			case 0:
+			case 1:
+				if cs[i] != -1 {
+					return nil, false
+				}
+				cs[i] = o
+			default:
+				return nil, false
+			}
+		}
+	}
+	for _, c := range cs {
+		if c == -1 {
+			return nil, false
+		}
+	}
+	return cs, true
+}
+
+// targets returns the destination channels which receive signal.
+func (r *Route) targets() []int {
+	var res []int
+	for o, row := range r.Matrix {
+		for _, g := range row {
+			if g != 0 {
+				res = append(res, o)
+				break
+			}
+		}
+	}
+	return res
+}
+
+// Matrix returns a FullMode processor which maps its input channels to
+// its output channels by the gain matrix m: m[o][i] gives the gain of input
+// channel i in output channel o.  The processor thus expects len(m[o])
+// input channels and len(m) output channels.  A single matrix covers
+// downmixing, upmixing, swapping channels and per channel gains, for
+// example
+//
+//  Matrix([][]float64{{0.5, 0.5}})     mixes stereo down to mono
+//  Matrix([][]float64{{0, 1}, {1, 0}}) swaps stereo channels
+//
+// m is copied.
+func Matrix(m [][]float64) Processor {
+	res := &matrix{m: make([][]float64, len(m))}
+	for o, row := range m {
+		res.m[o] = append([]float64(nil), row...)
+	}
+	return res
+}
+
+type matrix struct {
+	m [][]float64
+}
+
+func (x *matrix) ChannelMode() ChannelMode {
+	return FullMode
+}
+
+func (x *matrix) NextFrames() (int, int) {
+	return DefaultInFrames, DefaultInFrames
+}
+
+func (x *matrix) Process(dst, src *Block) error {
+	if len(x.m) != dst.Channels {
+		return fmt.Errorf("matrix with %d rows cannot produce %d channels", len(x.m), dst.Channels)
+	}
+	N := src.Frames
+	for o, row := range x.m {
+		if len(row) != src.Channels {
+			return fmt.Errorf("matrix with %d columns cannot take %d channels", len(row), src.Channels)
+		}
+		d := dst.Samples[o*N : (o+1)*N]
+		zero(d)
+		for i, g := range row {
+			if g == 0 {
+				continue
+			}
+			s := src.Samples[i*N : (i+1)*N]
+			for f, v := range s {
+				d[f] += g * v
+			}
+		}
+	}
+	dst.Frames = N
+	return nil
+}
+
+// Route routes src to the input of dst as described by the route
+// expression expr (see ParseRoute).  If the route is a plain channel
+// mapping, it is done with dst.SetInput.  Otherwise, a mixing node is added
+// to the graph.
+func (g *Graph) Route(src sound.Source, dst IO, expr string) error {
+	r, err := ParseRoute(expr, src.Channels(), dst.InForm().Channels())
+	if err != nil {
+		return err
+	}
+	if cs, ok := r.ChannelMap(); ok {
+		return dst.SetInput(src, cs...)
+	}
+	ts := r.targets()
+	m := &matrix{m: make([][]float64, len(ts))}
+	for i, o := range ts {
+		m.m[i] = r.Matrix[o]
+	}
+	mix := g.New(src, sound.NewForm(src.SampleRate(), len(ts)), m)
+	if err := mix.SetInput(src); err != nil {
+		return err
+	}
+	return dst.SetInput(mix.Output(), ts...)
+}
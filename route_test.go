@@ -0,0 +1,71 @@
+// Copyright 2018 The ZikiChombo Authors. All rights reserved.  Use of this source
+// code is governed by a license that can be found in the License file.
+
+package plug
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseRoute(t *testing.T) {
+	for _, tc := range []struct {
+		expr      string
+		nIn, nOut int
+		m         [][]float64
+		cs        []int
+	}{
+		{"0>1,1>0", 2, 2, [][]float64{{0, 1}, {1, 0}}, []int{1, 0}},
+		{"0,1>mix", 2, 1, [][]float64{{0.5, 0.5}}, nil},
+		{"0>0, 0*0.5>1", 1, 2, [][]float64{{1}, {0.5}}, nil},
+		{"1>0", 2, 1, [][]float64{{0, 1}}, nil},
+	} {
+		r, err := ParseRoute(tc.expr, tc.nIn, tc.nOut)
+		if err != nil {
+			t.Errorf("%q: %s", tc.expr, err)
+			continue
+		}
+		if !reflect.DeepEqual(r.Matrix, tc.m) {
+			t.Errorf("%q: got %v not %v", tc.expr, r.Matrix, tc.m)
+		}
+		cs, _ := r.ChannelMap()
+		if !reflect.DeepEqual(cs, tc.cs) {
+			t.Errorf("%q: got map %v not %v", tc.expr, cs, tc.cs)
+		}
+	}
+	for _, expr := range []string{"0", "2>0", "0>2", "x>0", "0*y>0"} {
+		if _, err := ParseRoute(expr, 2, 2); err == nil {
+			t.Errorf("%q: no error", expr)
+		}
+	}
+}
+
+func TestMatrix(t *testing.T) {
+	for _, tc := range []struct {
+		m        [][]float64
+		src, exp []float64
+	}{
+		{[][]float64{{0.5, 0.5}}, []float64{1, 2, 3, 5}, []float64{2, 3.5}},
+		{[][]float64{{0, 1}, {1, 0}}, []float64{1, 2, 3, 5}, []float64{3, 5, 1, 2}},
+		{[][]float64{{1}, {0.5}}, []float64{2, 4}, []float64{2, 4, 1, 2}},
+		{[][]float64{{2, 0}, {0, 0}}, []float64{1, 2, 3, 5}, []float64{2, 4, 0, 0}},
+	} {
+		p := Matrix(tc.m)
+		nIn, nOut := len(tc.m[0]), len(tc.m)
+		N := len(tc.src) / nIn
+		src := &Block{Channels: nIn, Frames: N, Samples: tc.src}
+		dst := &Block{Channels: nOut, Frames: N, Samples: make([]float64, nOut*N)}
+		if err := p.Process(dst, src); err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(dst.Samples, tc.exp) {
+			t.Errorf("%v: got %v not %v", tc.m, dst.Samples, tc.exp)
+		}
+	}
+	p := Matrix([][]float64{{1, 1}})
+	src := &Block{Channels: 3, Frames: 1, Samples: make([]float64, 3)}
+	dst := &Block{Channels: 1, Frames: 1, Samples: make([]float64, 1)}
+	if err := p.Process(dst, src); err == nil {
+		t.Errorf("2 column matrix took 3 channels")
+	}
+}
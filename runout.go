@@ -0,0 +1,30 @@
+// Copyright 2018 The ZikiChombo Authors. All rights reserved.  Use of this source
+// code is governed by a license that can be found in the License file.
+
+package plug
+
+import "io"
+
+// SetRunOut implements InputController.
+func (n *node) SetRunOut(frames int) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.runOut = frames
+}
+
+// runOutBlock fills iBlock with up to iFrms frames of silence from the
+// remaining run-out, returning the number of frames, or io.EOF once the
+// run-out is exhausted.
+func (n *node) runOutBlock(iBlock *Block, iFrms int) (int, error) {
+	if n.runOut <= 0 {
+		return 0, io.EOF
+	}
+	nFrms := iFrms
+	if nFrms > n.runOut {
+		nFrms = n.runOut
+	}
+	n.runOut -= nFrms
+	iBlock.Samples = iBlock.Samples[:iBlock.Channels*nFrms]
+	zero(iBlock.Samples)
+	return nFrms, nil
+}
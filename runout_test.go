@@ -0,0 +1,45 @@
+// Copyright 2018 The ZikiChombo Authors. All rights reserved.  Use of this source
+// code is governed by a license that can be found in the License file.
+
+package plug
+
+import (
+	"io"
+	"testing"
+
+	"zikichombo.org/sound"
+	"zikichombo.org/sound/gen"
+	"zikichombo.org/sound/ops"
+)
+
+func TestIORunOut(t *testing.T) {
+	valve := sound.MonoCd()
+	u := New(valve, valve, PassThrough)
+	u.SetInput(ops.Limit(gen.Noise(), 1000))
+	u.SetRunOut(2500)
+	out := u.Output()
+	go u.Run()
+	buf := make([]float64, 512)
+	ttl, tail := 0, 0.0
+	for {
+		n, err := out.Receive(buf)
+		for i := 0; i < n; i++ {
+			if ttl+i >= 1000 && buf[i] != 0 {
+				tail = buf[i]
+			}
+		}
+		ttl += n
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if ttl != 3500 {
+		t.Errorf("got %d frames not 3500", ttl)
+	}
+	if tail != 0 {
+		t.Errorf("run-out not silent: %f", tail)
+	}
+}
@@ -0,0 +1,237 @@
+// Copyright 2018 The ZikiChombo Authors. All rights reserved.  Use of this source
+// code is governed by a license that can be found in the License file.
+
+package plug
+
+import (
+	"sync"
+	"time"
+
+	"zikichombo.org/sound"
+)
+
+// SecondaryOutput is an output of an IO which never delays processing or
+// the primary outputs of the IO.  SecondaryOutputs are created by
+// OutputController.AddSecondaryOutput.
+type SecondaryOutput struct {
+	q *outQueue
+}
+
+// AddSecondaryOutput implements OutputController.
+func (n *node) AddSecondaryOutput(d sound.Sink, queue int, cs ...int) (*SecondaryOutput, error) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if err := n.ckAttach(); err != nil {
+		return nil, err
+	}
+	if err := n.ckOutput(d, cs...); err != nil {
+		return nil, err
+	}
+	pkt := &packet{}
+	pkt.init(n.oForm, cs...)
+	pkt.snk = d
+	q := newOutQueue(pkt, queue, false, n.doneC)
+	q.detach = true
+	if err := n.startQueue(q, false); err != nil {
+		return nil, err
+	}
+	n.wmu.Lock()
+	n.queues = append(n.queues, q)
+	n.wmu.Unlock()
+	return &SecondaryOutput{q: q}, nil
+}
+
+// Dropped returns the number of blocks dropped because the output was not
+// keeping up.
+func (s *SecondaryOutput) Dropped() int64 {
+	s.q.mu.Lock()
+	defer s.q.mu.Unlock()
+	return s.q.dropped
+}
+
+// Err returns the error which detached the output, if its sink failed.
+func (s *SecondaryOutput) Err() error {
+	s.q.mu.Lock()
+	defer s.q.mu.Unlock()
+	return s.q.err
+}
+
+// outQueue sends blocks to a sink asynchronously, buffering up to a fixed
+// number of blocks.  When the buffer is full, the sender waits if block is
+// set, and otherwise a block is dropped: the oldest queued one if oldest is
+// set, or the new one, after waiting up to timeout if timed is set.  If
+// detach is set, a failing sink is sent no further blocks and its error
+// is not returned by put.
+type outQueue struct {
+	snk    sound.Sink
+	slots  []packet
+	free   chan *packet
+	q      chan *packet
+	block  bool
+	oldest bool // when full, drop the oldest block rather than the new one
+	timed  bool // when full, wait up to timeout before dropping
+	detach bool // the queue serves a secondary or monitor output
+	doneC  chan struct{}
+	run    bool
+	budget *Budget // non-nil if the queue was shortened by a budget
+
+	timeout  time.Duration
+	reported int64 // dropped blocks reported as events
+	detached bool  // whether the error of a detached sink was reported
+
+	mu      sync.Mutex
+	dropped int64
+	err     error
+
+	busy sync.WaitGroup // blocks queued and not yet sent, for Quiesce
+}
+
+// newOutQueue creates a new outQueue for the output described by the
+// initialized packet pkt, buffering size blocks.
+func newOutQueue(pkt *packet, size int, block bool, doneC chan struct{}) *outQueue {
+	if size < 1 {
+		size = 1
+	}
+	res := &outQueue{
+		snk:   pkt.snk,
+		slots: make([]packet, size),
+		free:  make(chan *packet, size),
+		q:     make(chan *packet, size),
+		block: block,
+		doneC: doneC}
+	for i := range res.slots {
+		slot := &res.slots[i]
+		slot.cmap = pkt.cmap
+		slot.nC = pkt.nC
+		slot.snk = pkt.snk
+		slot.gains = pkt.gains
+		res.free <- slot
+	}
+	return res
+}
+
+// put queues the data in b for sending.  put returns any error
+// encountered while sending previous blocks, unless q detaches its sink.
+func (q *outQueue) put(b *Block) error {
+	q.mu.Lock()
+	err := q.err
+	q.mu.Unlock()
+	if err != nil {
+		if q.detach {
+			return nil
+		}
+		return err
+	}
+	var pkt *packet
+	select {
+	case pkt = <-q.free:
+	default:
+	}
+	switch {
+	case pkt != nil:
+	case q.block:
+		if q.budget != nil {
+			q.budget.count(1, 0)
+		}
+		select {
+		case pkt = <-q.free:
+		case <-q.doneC:
+			return nil
+		}
+	case q.oldest:
+		select {
+		case pkt = <-q.free:
+		case pkt = <-q.q:
+			q.busy.Done()
+			q.drop()
+		default:
+			// the sink is sending the only queued block.
+			q.drop()
+			return nil
+		}
+	case q.timed:
+		t := time.NewTimer(q.timeout)
+		select {
+		case pkt = <-q.free:
+			t.Stop()
+		case <-t.C:
+			q.drop()
+			return nil
+		case <-q.doneC:
+			t.Stop()
+			return nil
+		}
+	default:
+		q.drop()
+		return nil
+	}
+	pkt.get(b)
+	q.busy.Add(1)
+	q.q <- pkt
+	return nil
+}
+
+// drop counts a dropped block.
+func (q *outQueue) drop() {
+	q.mu.Lock()
+	q.dropped++
+	q.mu.Unlock()
+	if q.budget != nil {
+		q.budget.count(0, 1)
+	}
+}
+
+// limit shortens the queue to size blocks, counting blocking and dropping
+// thereafter in b.  limit must be called before the queue is served.
+func (q *outQueue) limit(size int, b *Budget) {
+	for i := size; i < len(q.slots); i++ {
+		<-q.free
+	}
+	q.budget = b
+}
+
+func (q *outQueue) serve() {
+	for pkt := range q.q {
+		q.mu.Lock()
+		off := q.detach && q.err != nil
+		q.mu.Unlock()
+		if off {
+			q.busy.Done()
+			q.free <- pkt
+			continue
+		}
+		if err := q.snk.Send(pkt.samples); err != nil {
+			q.mu.Lock()
+			q.err = err
+			q.mu.Unlock()
+		}
+		q.busy.Done()
+		q.free <- pkt
+	}
+	q.snk.Close()
+}
+
+// reportDetached emits an OutputDetached event the first time the sink of
+// q is found to have failed, if q detaches it.  n.mu must be held.
+func (n *node) reportDetached(q *outQueue) {
+	if !q.detach || q.detached {
+		return
+	}
+	q.mu.Lock()
+	err := q.err
+	q.mu.Unlock()
+	if err == nil {
+		return
+	}
+	q.detached = true
+	n.emit(&OutputDetached{Output: -1, Err: err})
+}
+
+// close sends any queued blocks and then closes the sink.
+func (q *outQueue) close() {
+	if !q.run {
+		q.snk.Close()
+		return
+	}
+	close(q.q)
+}
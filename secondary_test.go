@@ -0,0 +1,92 @@
+// Copyright 2018 The ZikiChombo Authors. All rights reserved.  Use of this source
+// code is governed by a license that can be found in the License file.
+
+package plug
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"zikichombo.org/sound"
+)
+
+// failSink fails from its second Send.
+type failSink struct {
+	countSink
+}
+
+var errFailSink = errors.New("sink failed")
+
+func (s *failSink) Send(d []float64) error {
+	if s.n > 0 {
+		return errFailSink
+	}
+	return s.countSink.Send(d)
+}
+
+func TestIOSecondaryFails(t *testing.T) {
+	mono := sound.MonoCd()
+	u := New(mono, mono, PassThrough)
+	u.SetInput(&ramp{Form: mono, n: 10240})
+	primary := &countSink{Form: mono}
+	u.AddOutput(primary)
+	rec := &failSink{countSink: countSink{Form: mono}}
+	so, err := u.AddSecondaryOutput(rec, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mon := &failSink{countSink: countSink{Form: mono}}
+	if _, err := u.AddMonitor(mon); err != nil {
+		t.Fatal(err)
+	}
+	detached := 0
+	u.OnEvent(func(e Event) {
+		if d, ok := e.(*OutputDetached); ok && d.Output == -1 && d.Err == errFailSink {
+			detached++
+		}
+	})
+	if err := u.Run(); err != nil {
+		t.Fatal(err)
+	}
+	if primary.n != 10240 {
+		t.Errorf("primary output got %d frames", primary.n)
+	}
+	if so.Err() != errFailSink {
+		t.Errorf("got error %v", so.Err())
+	}
+	if detached > 2 {
+		t.Errorf("got %d detached events", detached)
+	}
+}
+
+func TestIODropOldestBusy(t *testing.T) {
+	mono := sound.MonoCd()
+	slow := &slowSink{countSink: countSink{Form: mono}, d: 5 * time.Millisecond, closed: make(chan struct{})}
+	pkt := &packet{}
+	pkt.init(mono)
+	pkt.snk = slow
+	pkt.bp = &Backpressure{Mode: DropOldest, Queue: 2}
+	q := newPolicyQueue(pkt, make(chan struct{}))
+	q.run = true
+	go q.serve()
+	b := &Block{Channels: 1, Frames: 64, Samples: make([]float64, 64)}
+	for i := 0; i < 16; i++ {
+		if err := q.put(b); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// every queued block, stolen or sent, is done.
+	waited := make(chan struct{})
+	go func() {
+		q.busy.Wait()
+		close(waited)
+	}()
+	select {
+	case <-waited:
+	case <-time.After(time.Second):
+		t.Fatal("queued blocks never done")
+	}
+	q.close()
+	<-slow.closed
+}
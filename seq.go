@@ -0,0 +1,449 @@
+// Copyright 2018 The ZikiChombo Authors. All rights reserved.  Use of this source
+// code is governed by a license that can be found in the License file.
+
+package plug
+
+import (
+	"fmt"
+	"io"
+	"math/rand"
+
+	"zikichombo.org/sound"
+)
+
+// fifo buffers the output of a node read by another node when a graph is
+// run sequentially.
+type fifo struct {
+	nC    int
+	data  [][]float64
+	ended bool
+}
+
+func newFifo(nC int) *fifo {
+	return &fifo{nC: nC, data: make([][]float64, nC)}
+}
+
+func (f *fifo) frames() int {
+	return len(f.data[0])
+}
+
+// push appends frms frames, with channel stride frms, from d.
+func (f *fifo) push(d []float64, frms int) {
+	for c := range f.data {
+		f.data[c] = append(f.data[c], d[c*frms:(c+1)*frms]...)
+	}
+}
+
+// pop removes frms frames into d, with channel stride frms.
+func (f *fifo) pop(d []float64, frms int) {
+	for c, ch := range f.data {
+		copy(d[c*frms:(c+1)*frms], ch[:frms])
+		f.data[c] = ch[:copy(ch, ch[frms:])]
+	}
+}
+
+// seqInput is the sequential state of an input of a node.
+type seqInput struct {
+	f   *fifo // non-nil for inputs from other nodes of the graph
+	eof bool  // whether the source, outside the graph, has ended
+	got int
+	buf []float64
+}
+
+// seqNode is the sequential state of a node.
+type seqNode struct {
+	n    *node
+	ins  []seqInput
+	outs []*fifo // non-nil for outputs read by other nodes of the graph
+	done bool
+	wake chan struct{} // shared by the nodes of the run, see waitResumed
+}
+
+// RunSequential runs the graph in the calling goroutine, processing the
+// blocks of the nodes in topological order until all nodes have ended.
+// Unlike Run, the order of processing depends only on the graph, which
+// makes offline rendering deterministic and easy to debug, and avoids
+// goroutine overhead for batch jobs.
+//
+// The nodes of the graph must be connected with Output or Connect, and
+// must not be running.  Sources outside the graph are read, and sinks
+// added with AddOutput are written, synchronously.  Outputs created with
+// Output and not read by a node of the graph must be read by another
+// goroutine.  Secondary and monitor outputs are still served
+// asynchronously, and depths set by SetDepth are ignored.  Paused nodes
+// are skipped while the other nodes run on, and RunSequential waits for
+// them to be resumed once no other node can process.  The nodes of
+// composites added to the graph are run like the other nodes.
+func (g *Graph) RunSequential() (res error) {
+	seqs, err := g.startSeq()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := finishSeq(seqs); err != nil && res == nil {
+			res = err
+		}
+	}()
+	for {
+		live, progress := 0, false
+		for _, s := range seqs {
+			if s.done {
+				continue
+			}
+			live++
+			if !s.ready() {
+				continue
+			}
+			progress = true
+			if err := s.step(); err != nil {
+				return err
+			}
+		}
+		if live == 0 {
+			return nil
+		}
+		if !progress && !waitResumed(seqs) {
+			return fmt.Errorf("sequential run stalled with %d nodes remaining", live)
+		}
+	}
+}
+
+// RunDeterministic is like RunSequential, but at every step processes one
+// block of a node chosen among the nodes ready to process by a random
+// generator seeded with seed.  The interleaving of the nodes thus varies
+// with seed, as it varies from run to run with Run, but a given seed always
+// gives the same interleaving, so that bugs depending on the order of
+// processing, for example in processors sharing state, can be reproduced
+// exactly by running a range of seeds and rerunning a failing one.
+func (g *Graph) RunDeterministic(seed int64) (res error) {
+	seqs, err := g.startSeq()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := finishSeq(seqs); err != nil && res == nil {
+			res = err
+		}
+	}()
+	rnd := rand.New(rand.NewSource(seed))
+	var ready []*seqNode
+	for {
+		ready = ready[:0]
+		live := 0
+		for _, s := range seqs {
+			if s.done {
+				continue
+			}
+			live++
+			if s.ready() {
+				ready = append(ready, s)
+			}
+		}
+		if live == 0 {
+			return nil
+		}
+		if len(ready) == 0 {
+			if waitResumed(seqs) {
+				continue
+			}
+			return fmt.Errorf("deterministic run stalled with %d nodes remaining", live)
+		}
+		if err := ready[rnd.Intn(len(ready))].step(); err != nil {
+			return err
+		}
+	}
+}
+
+// startSeq prepares the nodes of g to be run sequentially, returning them
+// in topological order.
+func (g *Graph) startSeq() ([]*seqNode, error) {
+	order, err := g.sorted()
+	if err != nil {
+		return nil, err
+	}
+	in := make(map[*node]bool, len(order))
+	for _, n := range order {
+		in[n] = true
+	}
+	consumed := make(map[sound.Source]*seqInput)
+	seqs := make([]*seqNode, len(order))
+	wake := make(chan struct{}, 1)
+	for i, n := range order {
+		if err := n.checkConns(); err != nil {
+			return nil, err
+		}
+		s := &seqNode{n: n, ins: make([]seqInput, len(n.iPkts)), outs: make([]*fifo, len(n.oPkts)), wake: wake}
+		for k := range n.iPkts {
+			if ns, ok := n.iPkts[k].src.(*nodeSource); ok && in[ns.n] {
+				consumed[ns.Source] = &s.ins[k]
+			}
+		}
+		seqs[i] = s
+	}
+	for i, n := range order {
+		if err := n.startProc(); err != nil {
+			for _, m := range order[:i] {
+				m.closeProc()
+			}
+			return nil, err
+		}
+	}
+	for _, s := range seqs {
+		n := s.n
+		n.seq = s
+		n.qmu.Lock()
+		n.wake = wake
+		n.qmu.Unlock()
+		n.setState(Running)
+		for k := range n.oPkts {
+			pkt := &n.oPkts[k]
+			in, ok := consumed[pkt.src]
+			if !ok {
+				continue
+			}
+			s.outs[k] = newFifo(pkt.nC)
+			in.f = s.outs[k]
+		}
+		for _, q := range n.queues {
+			q.run = true
+			go q.serve()
+		}
+		for _, q := range n.monitors {
+			q.run = true
+			go q.serve()
+		}
+	}
+	return seqs, nil
+}
+
+// finishSeq ends the nodes run sequentially, returning the first error
+// closing their processors.
+func finishSeq(seqs []*seqNode) error {
+	var res error
+	for _, s := range seqs {
+		if err := s.finish(); err != nil && res == nil {
+			res = err
+		}
+		s.n.seq = nil
+		s.n.qmu.Lock()
+		s.n.wake = nil
+		s.n.qmu.Unlock()
+	}
+	return res
+}
+
+// waitResumed waits for a paused node of seqs to be resumed or stopped,
+// returning false without waiting if no node is paused.
+func waitResumed(seqs []*seqNode) bool {
+	for _, s := range seqs {
+		if !s.done && s.n.isPaused() {
+			<-s.wake
+			return true
+		}
+	}
+	return false
+}
+
+// step processes one block of the node.
+func (s *seqNode) step() error {
+	err := s.n.process()
+	s.n.deliverEvents()
+	if err == io.EOF {
+		return s.finish()
+	}
+	return err
+}
+
+// flat returns the nodes of g, with the composites of g replaced by the
+// nodes of their graphs.
+func (g *Graph) flat() ([]*node, error) {
+	var res []*node
+	for _, n := range g.nodes {
+		switch n := n.(type) {
+		case *node:
+			res = append(res, n)
+		case *composite:
+			ns, err := n.g.flat()
+			if err != nil {
+				return nil, err
+			}
+			res = append(res, ns...)
+		default:
+			return nil, fmt.Errorf("cannot run %T sequentially", n)
+		}
+	}
+	return res, nil
+}
+
+// sorted returns the nodes of g, including those of its composites, in
+// topological order.
+func (g *Graph) sorted() ([]*node, error) {
+	nodes, err := g.flat()
+	if err != nil {
+		return nil, err
+	}
+	deps := make(map[*node]int, len(nodes))
+	for _, nd := range nodes {
+		deps[nd] = 0
+	}
+	down := make(map[*node][]*node, len(nodes))
+	for _, nd := range nodes {
+		nd.wmu.Lock()
+		for i := range nd.iPkts {
+			if ns, ok := nd.iPkts[i].src.(*nodeSource); ok {
+				if _, in := deps[ns.n]; in {
+					deps[nd]++
+					down[ns.n] = append(down[ns.n], nd)
+				}
+			}
+		}
+		nd.wmu.Unlock()
+	}
+	var res []*node
+	for _, nd := range nodes {
+		if deps[nd] == 0 {
+			res = append(res, nd)
+		}
+	}
+	for i := 0; i < len(res); i++ {
+		for _, m := range down[res[i]] {
+			deps[m]--
+			if deps[m] == 0 {
+				res = append(res, m)
+			}
+		}
+	}
+	if len(res) != len(nodes) {
+		return nil, fmt.Errorf("graph has a cycle")
+	}
+	return res, nil
+}
+
+// ready returns whether the node can process a block without waiting for
+// other nodes.  Paused nodes are not ready, so that the other nodes run on.
+func (s *seqNode) ready() bool {
+	n := s.n
+	if n.isPaused() {
+		return false
+	}
+	if n.ended || n.carry != nil {
+		return true
+	}
+	n.mu.Lock()
+	iFrms, _ := n.nextFrames()
+	n.mu.Unlock()
+	for i := range s.ins {
+		f := s.ins[i].f
+		if f != nil && !f.ended && f.frames() < iFrms {
+			return false
+		}
+	}
+	return true
+}
+
+// receive is node.receive for a node run sequentially.
+func (s *seqNode) receive(iBlock *Block, iFrms int) (int, error) {
+	n := s.n
+	nFrms := iFrms
+	for i := range s.ins {
+		in := &s.ins[i]
+		if in.f != nil {
+			if m := in.f.frames(); m < nFrms {
+				nFrms = m
+			}
+			continue
+		}
+		if in.eof {
+			return 0, io.EOF
+		}
+		pkt := &n.iPkts[i]
+		in.buf = buffer(in.buf, pkt.nC, iFrms)
+		got, err := readFull(pkt.src, in.buf, pkt.nC, iFrms)
+		if err == io.EOF {
+			in.eof = true
+		} else if err != nil {
+			return 0, err
+		}
+		in.got = got
+		if got < nFrms {
+			nFrms = got
+		}
+	}
+	if nFrms == 0 {
+		return 0, io.EOF
+	}
+	n.startPut()
+	for i := range s.ins {
+		in := &s.ins[i]
+		pkt := &n.iPkts[i]
+		pkt.samples = buffer(pkt.samples, pkt.nC, nFrms)
+		if in.f != nil {
+			in.f.pop(pkt.samples, nFrms)
+		} else {
+			for c := 0; c < pkt.nC; c++ {
+				copy(pkt.samples[c*nFrms:(c+1)*nFrms], in.buf[c*iFrms:])
+			}
+		}
+		pkt.n = nFrms
+		pkt.delay()
+		n.put(pkt, iBlock)
+	}
+	n.endPut(iBlock, nFrms)
+	return nFrms, nil
+}
+
+// send sends oBlock to the outputs of a node run sequentially.
+func (s *seqNode) send(oBlock *Block) error {
+	n := s.n
+	for k := range n.oPkts {
+		pkt := &n.oPkts[k]
+		if pkt.off {
+			continue
+		}
+		pkt.get(oBlock)
+		n.sendProvenance(pkt, pkt.n)
+		n.sendAnnotations(pkt, oBlock)
+		if f := s.outs[k]; f != nil {
+			f.push(pkt.samples, pkt.n)
+			continue
+		}
+		if err := pkt.snk.Send(pkt.samples); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// finish ends the node, as Run does upon returning.
+func (s *seqNode) finish() error {
+	if s.done {
+		return nil
+	}
+	s.done = true
+	n := s.n
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	err := n.closeProc()
+	n.finished = true
+	n.setState(Finished)
+	close(n.doneC)
+	for k := range n.oPkts {
+		if f := s.outs[k]; f != nil {
+			f.ended = true
+			continue
+		}
+		if !n.oPkts[k].off {
+			n.oPkts[k].snk.Close()
+		}
+	}
+	for _, q := range n.queues {
+		q.close()
+	}
+	for _, q := range n.monitors {
+		q.close()
+	}
+	for i := range n.iPkts {
+		n.iPkts[i].src.Close()
+	}
+	return err
+}
@@ -0,0 +1,142 @@
+// Copyright 2018 The ZikiChombo Authors. All rights reserved.  Use of this source
+// code is governed by a license that can be found in the License file.
+
+package plug
+
+import (
+	"testing"
+	"time"
+
+	"zikichombo.org/sound"
+	"zikichombo.org/sound/gen"
+	"zikichombo.org/sound/ops"
+)
+
+func TestGraphRunSequential(t *testing.T) {
+	stereo := sound.StereoCd()
+	mono := sound.MonoCd()
+	var g Graph
+	u0 := g.New(stereo, stereo, PassThrough)
+	u1 := g.New(stereo, mono, ToMono)
+	u2 := g.New(mono, mono, NewProcessorFrames(MonoMode, PassThrough.Process, 300, 300))
+	u0.SetInput(ops.Limit(gen.Noise(), 44100), 0)
+	u0.SetInput(ops.Limit(gen.Noise(), 44100), 1)
+	if err := g.Connect(u0, nil, u1, nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := g.Connect(u1, nil, u2, nil); err != nil {
+		t.Fatal(err)
+	}
+	snk := &countSink{Form: mono}
+	if err := u2.AddOutput(snk); err != nil {
+		t.Fatal(err)
+	}
+	if err := g.RunSequential(); err != nil {
+		t.Fatal(err)
+	}
+	if snk.n != 44100 {
+		t.Errorf("got %d not 44100", snk.n)
+	}
+}
+
+func TestGraphRunDeterministic(t *testing.T) {
+	mono := sound.MonoCd()
+	run := func(seed int64) []int {
+		var order []int
+		rec := func(id int) Processor {
+			return NewProcessor(MonoMode, func(dst, src *Block) error {
+				order = append(order, id)
+				return PassThrough.Process(dst, src)
+			})
+		}
+		var g Graph
+		u0 := g.New(mono, mono, rec(0))
+		u1 := g.New(mono, mono, rec(1))
+		u2 := g.New(mono, mono, rec(2))
+		u0.SetInput(ops.Limit(gen.Noise(), 44100))
+		u1.SetInput(u0.Output())
+		u2.SetInput(ops.Limit(gen.Noise(), 44100))
+		u1.AddOutput(&countSink{Form: mono})
+		u2.AddOutput(&countSink{Form: mono})
+		if err := g.RunDeterministic(seed); err != nil {
+			t.Fatal(err)
+		}
+		return order
+	}
+	a, b := run(1), run(1)
+	if len(a) != len(b) {
+		t.Fatalf("got %d then %d blocks", len(a), len(b))
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			t.Fatalf("block %d: got node %d then %d", i, a[i], b[i])
+		}
+	}
+}
+
+func TestGraphRunSequentialPaused(t *testing.T) {
+	mono := sound.MonoCd()
+	var g Graph
+	u0 := g.New(mono, mono, PassThrough)
+	u1 := g.New(mono, mono, PassThrough)
+	u0.SetInput(&ramp{Form: mono, n: 10000})
+	u1.SetInput(&ramp{Form: mono, n: 10000})
+	rec0 := &record{Form: mono}
+	rec1 := &record{Form: mono}
+	u0.AddOutput(rec0)
+	u1.AddOutput(rec1)
+	u0.Pause()
+	errC := make(chan error)
+	go func() {
+		errC <- g.RunSequential()
+	}()
+	// u1 runs to the end while u0 is paused.
+	for rec1.frames() < 10000 {
+		time.Sleep(time.Millisecond)
+	}
+	if n := rec0.frames(); n != 0 {
+		t.Errorf("paused node sent %d frames", n)
+	}
+	u0.Resume()
+	if err := <-errC; err != nil {
+		t.Fatal(err)
+	}
+	if n := rec0.frames(); n != 10000 {
+		t.Errorf("got %d frames not 10000", n)
+	}
+}
+
+func TestGraphRunSequentialComposite(t *testing.T) {
+	stereo := sound.StereoCd()
+	mono := sound.MonoCd()
+	var sub Graph
+	s0 := sub.New(stereo, stereo, PassThrough)
+	s1 := sub.New(stereo, mono, ToMono)
+	if err := sub.Connect(s0, nil, s1, nil); err != nil {
+		t.Fatal(err)
+	}
+	chain, err := sub.Compose([]IO{s0}, []IO{s1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var g Graph
+	u0 := g.New(stereo, stereo, PassThrough)
+	g.Add(chain)
+	u1 := g.New(mono, mono, NewProcessorFrames(MonoMode, PassThrough.Process, 300, 300))
+	u0.SetInput(ops.Limit(gen.Noise(), 44100), 0)
+	u0.SetInput(ops.Limit(gen.Noise(), 44100), 1)
+	if err := g.Connect(u0, nil, chain, nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := g.Connect(chain, nil, u1, nil); err != nil {
+		t.Fatal(err)
+	}
+	snk := &countSink{Form: mono}
+	u1.AddOutput(snk)
+	if err := g.RunSequential(); err != nil {
+		t.Fatal(err)
+	}
+	if snk.n != 44100 {
+		t.Errorf("got %d not 44100", snk.n)
+	}
+}
@@ -0,0 +1,252 @@
+// Copyright 2018 The ZikiChombo Authors. All rights reserved.  Use of this source
+// code is governed by a license that can be found in the License file.
+
+package plug
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"zikichombo.org/sound"
+)
+
+// SessionConfig configures a Session.
+type SessionConfig struct {
+	// Build builds a graph ready to run, with its sources and sinks.  When
+	// restarting after a failure, cp is the last checkpoint, which the
+	// session restores after Build returns; Build should position the
+	// sources accordingly.  cp is nil for the first run, or if no
+	// checkpoint has been taken.
+	Build func(cp *Checkpoint) (*Graph, error)
+
+	// CheckpointEvery gives the interval between checkpoints.  If it is
+	// 0, no checkpoints are taken and graphs restart from scratch.
+	//
+	// A checkpoint quiesces the graph, as per Graph.Quiesce, so that its
+	// audio stalls for the time taken to drain it and save its state.
+	// Graphs driving a device in real time should use an interval long
+	// enough for the occasional glitch to be acceptable, or none.
+	CheckpointEvery time.Duration
+
+	// MaxFailures bounds the number of failures within FailureWindow:
+	// the session gives up on the failure exceeding it.  If MaxFailures
+	// is 0, the session gives up on the first failure.  If FailureWindow
+	// is 0, all the failures of the session count.
+	MaxFailures   int
+	FailureWindow time.Duration
+
+	// Backoff gives the delay before restarting after a failure.
+	Backoff time.Duration
+}
+
+// Session runs a graph for a long time, for example a 24/7 stream, by
+// rebuilding and restarting it whenever it fails, restoring the state of
+// the failed graph from its last checkpoint, within a bounded budget of
+// failures.  Outputs which should be split into files of bounded length
+// may be given a RotatingSink.
+type Session struct {
+	cfg SessionConfig
+
+	mu       sync.Mutex
+	g        *Graph
+	cp       *Checkpoint
+	failures []time.Time
+	restarts int
+	stopped  bool
+}
+
+// NewSession creates a new session with configuration cfg.
+func NewSession(cfg SessionConfig) *Session {
+	return &Session{cfg: cfg}
+}
+
+// Restarts returns the number of times the graph has been restarted.
+func (s *Session) Restarts() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.restarts
+}
+
+// Stop stops the session, stopping the running graph as per Graph.Stop.
+func (s *Session) Stop() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.stopped = true
+	if s.g != nil {
+		s.g.Stop()
+	}
+}
+
+// Run runs the session until a graph ends without error, the session is
+// stopped, or the failure budget is exhausted, in which case Run returns
+// the last error.
+func (s *Session) Run() error {
+	for {
+		err := s.runOnce()
+		if err == nil {
+			return nil
+		}
+		s.mu.Lock()
+		if s.stopped {
+			s.mu.Unlock()
+			return nil
+		}
+		now := time.Now()
+		s.failures = append(s.failures, now)
+		for s.cfg.FailureWindow > 0 && now.Sub(s.failures[0]) > s.cfg.FailureWindow {
+			s.failures = s.failures[1:]
+		}
+		if len(s.failures) > s.cfg.MaxFailures {
+			s.mu.Unlock()
+			if s.cfg.FailureWindow == 0 {
+				return fmt.Errorf("session: %d failures, last: %s", len(s.failures), err)
+			}
+			return fmt.Errorf("session: %d failures within %s, last: %s", len(s.failures), s.cfg.FailureWindow, err)
+		}
+		s.restarts++
+		s.mu.Unlock()
+		time.Sleep(s.cfg.Backoff)
+	}
+}
+
+// runOnce builds, restores and runs a graph, checkpointing it regularly.
+// Each checkpoint stalls the graph, see SessionConfig.CheckpointEvery.
+func (s *Session) runOnce() error {
+	s.mu.Lock()
+	cp := s.cp
+	s.mu.Unlock()
+	g, err := s.cfg.Build(cp)
+	if err != nil {
+		return err
+	}
+	if cp != nil {
+		if err := g.Restore(cp); err != nil {
+			return err
+		}
+	}
+	s.mu.Lock()
+	if s.stopped {
+		s.mu.Unlock()
+		return nil
+	}
+	s.g = g
+	s.mu.Unlock()
+	defer func() {
+		s.mu.Lock()
+		s.g = nil
+		s.mu.Unlock()
+	}()
+
+	var tick <-chan time.Time
+	if s.cfg.CheckpointEvery > 0 {
+		t := time.NewTicker(s.cfg.CheckpointEvery)
+		defer t.Stop()
+		tick = t.C
+	}
+	ec := g.Run()
+	var res error
+	for {
+		select {
+		case err, ok := <-ec:
+			if !ok {
+				return res
+			}
+			if res == nil {
+				res = err
+				// end the other nodes, so that the graph may be rebuilt.
+				g.Stop()
+			}
+		case <-tick:
+			if res != nil {
+				continue
+			}
+			g.Quiesce()
+			cp, err := g.Checkpoint()
+			g.Resume()
+			if err == nil {
+				s.mu.Lock()
+				s.cp = cp
+				s.mu.Unlock()
+			}
+		}
+	}
+}
+
+// RotatingSink is a sound.Sink which splits its input into a sequence of
+// sinks of a bounded number of frames, for example files rotated every
+// hour.  A sink is closed as soon as it has received its frames, and the
+// next one is opened by the next frame sent.
+type RotatingSink struct {
+	sound.Form
+	frames int
+	open   func(i int) (sound.Sink, error)
+	cur    sound.Sink
+	i, n   int
+	buf    []float64
+}
+
+// NewRotatingSink creates a new RotatingSink of form f which sends up to
+// frames frames to each sink, obtaining the i'th sink from open(i).  If
+// frames is 0, all frames are sent to a single sink.
+func NewRotatingSink(f sound.Form, frames int, open func(i int) (sound.Sink, error)) *RotatingSink {
+	return &RotatingSink{Form: f, frames: frames, open: open}
+}
+
+// Send implements sound.Sink.
+func (r *RotatingSink) Send(d []float64) error {
+	nC := r.Channels()
+	N := len(d) / nC
+	for f := 0; f < N; {
+		if r.cur == nil {
+			if err := r.rotate(); err != nil {
+				return err
+			}
+		}
+		m := N - f
+		if rem := r.frames - r.n; r.frames > 0 && m > rem {
+			m = rem
+		}
+		buf := d
+		if m != N {
+			r.buf = buffer(r.buf, nC, m)
+			buf = r.buf
+			for c := 0; c < nC; c++ {
+				copy(buf[c*m:(c+1)*m], d[c*N+f:c*N+f+m])
+			}
+		}
+		if err := r.cur.Send(buf); err != nil {
+			return err
+		}
+		r.n += m
+		f += m
+		if r.frames > 0 && r.n == r.frames {
+			if err := r.Close(); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// rotate opens the next sink.
+func (r *RotatingSink) rotate() error {
+	snk, err := r.open(r.i)
+	if err != nil {
+		return err
+	}
+	r.cur = snk
+	r.i++
+	r.n = 0
+	return nil
+}
+
+// Close implements sound.Sink, closing the current sink.
+func (r *RotatingSink) Close() error {
+	if r.cur == nil {
+		return nil
+	}
+	err := r.cur.Close()
+	r.cur = nil
+	return err
+}
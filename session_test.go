@@ -0,0 +1,178 @@
+// Copyright 2018 The ZikiChombo Authors. All rights reserved.  Use of this source
+// code is governed by a license that can be found in the License file.
+
+package plug
+
+import (
+	"testing"
+	"time"
+
+	"zikichombo.org/sound"
+)
+
+// breaking is a ramp which fails with ErrInjected once at frame fail, if
+// not 0, and sleeps delay per Receive.
+type breaking struct {
+	ramp
+	fail  int64
+	delay time.Duration
+}
+
+func (s *breaking) Receive(d []float64) (int, error) {
+	time.Sleep(s.delay)
+	if s.fail > 0 && s.pos >= s.fail {
+		s.fail = 0
+		return 0, ErrInjected
+	}
+	return s.ramp.Receive(d)
+}
+
+// sessionBuild returns a SessionConfig.Build function building a graph
+// whose source fails at frame 5000 for the first fails builds.
+func sessionBuild(fails int) func(cp *Checkpoint) (*Graph, error) {
+	built := 0
+	return func(cp *Checkpoint) (*Graph, error) {
+		mono := sound.MonoCd()
+		g := &Graph{}
+		u := g.New(mono, mono, PassThrough)
+		src := &breaking{ramp: ramp{Form: mono, n: 10000}}
+		if built < fails {
+			src.fail = 5000
+		}
+		built++
+		u.SetInput(src)
+		u.AddOutput(&countSink{Form: mono})
+		return g, nil
+	}
+}
+
+func TestSessionFailures(t *testing.T) {
+	for _, e := range []struct {
+		name     string
+		max      int
+		window   time.Duration
+		backoff  time.Duration
+		fails    int
+		ok       bool
+		restarts int
+	}{
+		{"within budget", 2, 0, 0, 2, true, 2},
+		// MaxFailures of 0 gives up on the first failure.
+		{"no restarts", 0, 0, 0, 1, false, 0},
+		// FailureWindow of 0 counts the failures of the whole session.
+		{"over the session", 2, 0, 0, 3, false, 2},
+		// failures further apart than the window do not add up.
+		{"outside the window", 1, time.Millisecond, 5 * time.Millisecond, 3, true, 3},
+		{"inside the window", 1, time.Minute, 0, 3, false, 1},
+	} {
+		s := NewSession(SessionConfig{
+			Build:         sessionBuild(e.fails),
+			MaxFailures:   e.max,
+			FailureWindow: e.window,
+			Backoff:       e.backoff})
+		err := s.Run()
+		if (err == nil) != e.ok {
+			t.Errorf("%s: got %v", e.name, err)
+		}
+		if s.Restarts() != e.restarts {
+			t.Errorf("%s: restarted %d times not %d", e.name, s.Restarts(), e.restarts)
+		}
+	}
+}
+
+func TestSessionCheckpoint(t *testing.T) {
+	mono := sound.MonoCd()
+	var cps []*Checkpoint
+	var rec *record
+	s := NewSession(SessionConfig{
+		Build: func(cp *Checkpoint) (*Graph, error) {
+			cps = append(cps, cp)
+			g := &Graph{}
+			u := g.New(mono, mono, &integrator{})
+			src := &breaking{ramp: ramp{Form: mono, n: 30000}, delay: time.Millisecond}
+			if cp == nil {
+				src.fail = 15000
+			} else {
+				src.pos = cp.Nodes[0].Frames
+			}
+			u.SetInput(src)
+			rec = &record{Form: mono}
+			u.AddOutput(rec)
+			return g, nil
+		},
+		CheckpointEvery: 5 * time.Millisecond,
+		MaxFailures:     1})
+	if err := s.Run(); err != nil {
+		t.Fatal(err)
+	}
+	if len(cps) != 2 || cps[0] != nil || cps[1] == nil || cps[1].Nodes[0].Frames == 0 {
+		t.Fatalf("got checkpoints %v", cps)
+	}
+	// the restored integrator sums the whole ramp.
+	out := rec.chs[0]
+	if last := out[len(out)-1]; last != 29999*30000/2 {
+		t.Errorf("got sum %g not %d", last, 29999*30000/2)
+	}
+}
+
+// rotated is a sink recording the frames sent to it and whether it is
+// closed.
+type rotated struct {
+	record
+	closed bool
+}
+
+func (r *rotated) Close() error {
+	r.closed = true
+	return nil
+}
+
+func TestRotatingSink(t *testing.T) {
+	mono := sound.MonoCd()
+	var sinks []*rotated
+	open := func(i int) (sound.Sink, error) {
+		if i != len(sinks) {
+			t.Errorf("opened sink %d after %d sinks", i, len(sinks))
+		}
+		r := &rotated{record: record{Form: mono}}
+		sinks = append(sinks, r)
+		return r, nil
+	}
+	r := NewRotatingSink(mono, 1000, open)
+	u := New(mono, mono, PassThrough)
+	u.SetInput(&ramp{Form: mono, n: 3500})
+	u.AddOutput(r)
+	if err := u.Run(); err != nil {
+		t.Fatal(err)
+	}
+	if len(sinks) != 4 {
+		t.Fatalf("got %d sinks", len(sinks))
+	}
+	for i, s := range sinks {
+		exp := 1000
+		if i == 3 {
+			exp = 500
+		}
+		if s.frames() != exp || !s.closed {
+			t.Errorf("sink %d: got %d frames, closed %t", i, s.frames(), s.closed)
+		}
+		for j, v := range s.chs[0] {
+			if v != float64(1000*i+j) {
+				t.Fatalf("sink %d frame %d: got %g", i, j, v)
+			}
+		}
+	}
+
+	// with no limit, all frames go to one sink.
+	sinks = nil
+	r = NewRotatingSink(mono, 0, open)
+	u = New(mono, mono, PassThrough)
+	u.SetInput(&ramp{Form: mono, n: 3500})
+	u.AddOutput(r)
+	if err := u.Run(); err != nil {
+		t.Fatal(err)
+	}
+	if len(sinks) != 1 || sinks[0].frames() != 3500 || !sinks[0].closed {
+		t.Errorf("got %d sinks", len(sinks))
+	}
+}
@@ -0,0 +1,40 @@
+// Copyright 2018 The ZikiChombo Authors. All rights reserved.  Use of this source
+// code is governed by a license that can be found in the License file.
+
+package plug
+
+// SilenceSkipper is implemented by processors which can skip processing
+// blocks of digital silence, saving CPU in mostly idle graphs.
+type SilenceSkipper interface {
+	// FastSilence is called instead of Process when every input sample of
+	// the block is zero.  If FastSilence returns true, Process is not
+	// called and the IO outputs silence, as many frames as it received in
+	// proportion to NextFrames.  A processor with a tail, such as a reverb,
+	// returns false until its tail has decayed, so that Process outputs the
+	// tail.
+	FastSilence() bool
+}
+
+// silent returns whether the first frms frames of b are all zero.
+func silent(b *Block, frms int) bool {
+	for _, v := range b.Samples[:b.Channels*frms] {
+		if v != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// skipSilence outputs into oBlock the silence corresponding to nFrms
+// silent input frames of a block of shape iFrms, oFrms, if proc may skip
+// them, and returns whether it did.
+func skipSilence(proc Processor, iBlock, oBlock *Block, nFrms, iFrms, oFrms int) bool {
+	fs, ok := proc.(SilenceSkipper)
+	if !ok || !silent(iBlock, nFrms) || !fs.FastSilence() {
+		return false
+	}
+	m := oFrms * nFrms / iFrms
+	zero(oBlock.Samples[:oBlock.Channels*m])
+	oBlock.Frames = m
+	return true
+}
@@ -0,0 +1,49 @@
+// Copyright 2018 The ZikiChombo Authors. All rights reserved.  Use of this source
+// code is governed by a license that can be found in the License file.
+
+package plug
+
+import (
+	"testing"
+
+	"zikichombo.org/sound"
+)
+
+// quiet is a processor skipping silence, counting its calls to Process.
+type quiet struct {
+	Processor
+	calls int
+}
+
+func (q *quiet) FastSilence() bool { return true }
+
+func (q *quiet) Process(dst, src *Block) error {
+	q.calls++
+	return q.Processor.Process(dst, src)
+}
+
+func TestIOFastSilence(t *testing.T) {
+	mono := sound.MonoCd()
+	p := &quiet{Processor: PassThrough}
+	u := New(mono, mono, p)
+	u.SetInput(&ramp{Form: mono, n: 4096})
+	snk := &capture{Form: mono}
+	u.AddOutput(snk)
+	if err := u.Run(); err != nil {
+		t.Fatal(err)
+	}
+	if snk.frames != 4096 || p.calls != 4 {
+		t.Errorf("got %d frames, %d calls", snk.frames, p.calls)
+	}
+	p = &quiet{Processor: PassThrough}
+	u = New(mono, mono, p)
+	u.SetInput(&tone{Form: mono, end: 4096})
+	snk = &capture{Form: mono}
+	u.AddOutput(snk)
+	if err := u.Run(); err != nil {
+		t.Fatal(err)
+	}
+	if snk.frames != 4096 || p.calls != 0 {
+		t.Errorf("got %d frames, %d calls", snk.frames, p.calls)
+	}
+}
@@ -0,0 +1,158 @@
+// Copyright 2018 The ZikiChombo Authors. All rights reserved.  Use of this source
+// code is governed by a license that can be found in the License file.
+
+package plug
+
+import (
+	"io"
+	"math"
+	"sync"
+	"time"
+
+	"zikichombo.org/sound"
+	"zikichombo.org/sound/freq"
+)
+
+const (
+	// SpeechRate is the sample rate of audio sent to a Recognizer.
+	SpeechRate = 16000 * freq.Hertz
+
+	// SpeechChunk is the number of frames in each chunk of audio sent to a
+	// Recognizer, 20ms at SpeechRate.
+	SpeechChunk = 320
+)
+
+// Transcript is a piece of text recognized in a stream of speech.  Start
+// and End are relative to the beginning of the stream.
+type Transcript struct {
+	Text       string
+	Start, End time.Duration
+	Final      bool // whether the text is final rather than a hypothesis.
+}
+
+// Recognizer is a streaming speech to text backend, typically a client of
+// a gRPC or websocket transcription service.  SendAudio and CloseSend are
+// called from one goroutine and Recv from another.
+type Recognizer interface {
+	// SendAudio sends a chunk of mono 16 bit PCM audio at SpeechRate.  pcm
+	// is reused once SendAudio returns.
+	SendAudio(pcm []int16) error
+
+	// CloseSend signals the end of the audio.
+	CloseSend() error
+
+	// Recv returns the next transcript, or io.EOF once the backend has
+	// finished after CloseSend.
+	Recv() (Transcript, error)
+}
+
+// SpeechSink is a sound.Sink which streams audio to a Recognizer in chunks
+// of SpeechChunk frames and delivers its transcripts.  The audio must be
+// mono at SpeechRate.
+type SpeechSink struct {
+	sound.Form
+	r     Recognizer
+	chunk []int16
+	c     chan Transcript
+	done  chan struct{}
+	mu    sync.Mutex
+	err   error
+}
+
+// NewSpeechSink creates a new SpeechSink sending to r.
+func NewSpeechSink(r Recognizer) *SpeechSink {
+	res := &SpeechSink{
+		Form:  sound.NewForm(SpeechRate, 1),
+		r:     r,
+		chunk: make([]int16, 0, SpeechChunk),
+		c:     make(chan Transcript, 16),
+		done:  make(chan struct{})}
+	go res.recv()
+	return res
+}
+
+// Transcripts returns the channel on which transcripts are delivered.  It
+// is closed once the recognizer has finished after Close.  Transcripts
+// should be read promptly, as reading them is what lets the recognizer
+// proceed.
+func (s *SpeechSink) Transcripts() <-chan Transcript {
+	return s.c
+}
+
+// Err returns the first error the recognizer returned, if any.
+func (s *SpeechSink) Err() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.err
+}
+
+// Send implements sound.Sink.
+func (s *SpeechSink) Send(d []float64) error {
+	if err := s.Err(); err != nil {
+		return err
+	}
+	for _, v := range d {
+		s.chunk = append(s.chunk, pcm16(v))
+		if len(s.chunk) < SpeechChunk {
+			continue
+		}
+		if err := s.r.SendAudio(s.chunk); err != nil {
+			s.fail(err)
+			return err
+		}
+		s.chunk = s.chunk[:0]
+	}
+	return nil
+}
+
+// Close implements sound.Sink, sending any partial chunk and waiting for
+// the recognizer to finish.
+func (s *SpeechSink) Close() error {
+	if len(s.chunk) > 0 {
+		if err := s.r.SendAudio(s.chunk); err != nil {
+			s.fail(err)
+		}
+		s.chunk = s.chunk[:0]
+	}
+	if err := s.r.CloseSend(); err != nil {
+		s.fail(err)
+	}
+	<-s.done
+	return s.Err()
+}
+
+func (s *SpeechSink) recv() {
+	defer close(s.done)
+	defer close(s.c)
+	for {
+		t, err := s.r.Recv()
+		if err == io.EOF {
+			return
+		}
+		if err != nil {
+			s.fail(err)
+			return
+		}
+		s.c <- t
+	}
+}
+
+func (s *SpeechSink) fail(err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.err == nil {
+		s.err = err
+	}
+}
+
+// pcm16 converts v to 16 bit PCM, saturating.
+func pcm16(v float64) int16 {
+	v = math.Floor(v*32768 + 0.5)
+	if v > math.MaxInt16 {
+		return math.MaxInt16
+	}
+	if v < math.MinInt16 {
+		return math.MinInt16
+	}
+	return int16(v)
+}
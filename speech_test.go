@@ -0,0 +1,117 @@
+// Copyright 2018 The ZikiChombo Authors. All rights reserved.  Use of this source
+// code is governed by a license that can be found in the License file.
+
+package plug
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"testing"
+	"time"
+)
+
+// fakeRecognizer transcribes every chunk as its number and length, and
+// fails sending from chunk failAt if not 0.
+type fakeRecognizer struct {
+	chunks [][]int16
+	failAt int
+	ts     chan Transcript
+}
+
+func newFakeRecognizer(failAt int) *fakeRecognizer {
+	return &fakeRecognizer{failAt: failAt, ts: make(chan Transcript, 100)}
+}
+
+func (r *fakeRecognizer) SendAudio(pcm []int16) error {
+	if r.failAt > 0 && len(r.chunks)+1 == r.failAt {
+		return ErrInjected
+	}
+	r.chunks = append(r.chunks, append([]int16(nil), pcm...))
+	i := len(r.chunks)
+	r.ts <- Transcript{
+		Text:  fmt.Sprintf("chunk %d of %d", i, len(pcm)),
+		Start: time.Duration(i-1) * 20 * time.Millisecond,
+		End:   time.Duration(i) * 20 * time.Millisecond,
+		Final: true}
+	return nil
+}
+
+func (r *fakeRecognizer) CloseSend() error {
+	close(r.ts)
+	return nil
+}
+
+func (r *fakeRecognizer) Recv() (Transcript, error) {
+	t, ok := <-r.ts
+	if !ok {
+		return Transcript{}, io.EOF
+	}
+	return t, nil
+}
+
+func TestSpeechSink(t *testing.T) {
+	r := newFakeRecognizer(0)
+	s := NewSpeechSink(r)
+	if s.SampleRate() != SpeechRate || s.Channels() != 1 {
+		t.Fatalf("got form %d channels at %s", s.Channels(), s.SampleRate())
+	}
+	var got []Transcript
+	done := make(chan struct{})
+	go func() {
+		for tr := range s.Transcripts() {
+			got = append(got, tr)
+		}
+		close(done)
+	}()
+	d := make([]float64, 500)
+	for i := range d {
+		d[i] = math.Sin(float64(i))
+	}
+	d[0], d[1] = 2, -2
+	for i := 0; i < 3; i++ {
+		if err := s.Send(d); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+	<-done
+	// 1500 frames make 4 full chunks and one of 220 frames.
+	if len(r.chunks) != 5 || len(got) != 5 {
+		t.Fatalf("got %d chunks and %d transcripts", len(r.chunks), len(got))
+	}
+	for i, c := range r.chunks {
+		if exp := SpeechChunk - 100*(i/4); len(c) != exp {
+			t.Errorf("chunk %d: got %d frames not %d", i, len(c), exp)
+		}
+	}
+	if c := r.chunks[0]; c[0] != math.MaxInt16 || c[1] != math.MinInt16 || c[2] != pcm16(math.Sin(2)) {
+		t.Errorf("got samples %v", c[:3])
+	}
+	if tr := got[4]; tr.Text != "chunk 5 of 220" || tr.End != 100*time.Millisecond {
+		t.Errorf("got transcript %+v", tr)
+	}
+}
+
+func TestSpeechSinkFails(t *testing.T) {
+	s := NewSpeechSink(newFakeRecognizer(2))
+	go func() {
+		for range s.Transcripts() {
+		}
+	}()
+	d := make([]float64, SpeechChunk)
+	if err := s.Send(d); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Send(d); err != ErrInjected {
+		t.Errorf("got %v not %v", err, ErrInjected)
+	}
+	if err := s.Send(d); err != ErrInjected {
+		t.Errorf("after failing: got %v not %v", err, ErrInjected)
+	}
+	if err := s.Close(); err != ErrInjected {
+		t.Errorf("closing: got %v not %v", err, ErrInjected)
+	}
+}
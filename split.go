@@ -0,0 +1,163 @@
+// Copyright 2018 The ZikiChombo Authors. All rights reserved.  Use of this source
+// code is governed by a license that can be found in the License file.
+
+package plug
+
+import (
+	"math"
+	"sort"
+	"sync"
+
+	"zikichombo.org/sound"
+)
+
+// SplitSink is a sound.Sink which splits its input into segments at
+// marker frames, sending each segment to its own sink, for example to rip
+// a recording into tracks or a stream of speech into utterances.
+//
+// Markers are given by Mark, typically from a function registered with
+// Notifier.OnEvent, or found by a silence detector set with SplitOnSilence.
+// Frames are counted from the first frame sent to the SplitSink, and a
+// segment starts exactly at its marker frame.
+type SplitSink struct {
+	sound.Form
+	open func(i int, start int64) (sound.Sink, error)
+	cur  sound.Sink
+	i    int
+	pos  int64
+
+	// silence detection
+	threshold float64
+	minGap    int
+	gap       int
+
+	mu    sync.Mutex
+	marks []int64 // sorted
+}
+
+// NewSplitSink creates a new SplitSink of form f, which obtains the sink
+// of the i'th segment, starting at frame start, from open(i, start).
+func NewSplitSink(f sound.Form, open func(i int, start int64) (sound.Sink, error)) *SplitSink {
+	return &SplitSink{Form: f, open: open}
+}
+
+// Mark marks frame as the start of a new segment.  Mark may be called
+// from any goroutine, and takes effect from the next call to Send.  A
+// frame already sent starts a new segment at the next frame sent.
+func (s *SplitSink) Mark(frame int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	i := sort.Search(len(s.marks), func(i int) bool { return s.marks[i] >= frame })
+	if i < len(s.marks) && s.marks[i] == frame {
+		return
+	}
+	s.marks = append(s.marks, 0)
+	copy(s.marks[i+1:], s.marks[i:])
+	s.marks[i] = frame
+}
+
+// SplitOnSilence makes s start a new segment at the first frame following
+// at least minGap frames whose samples are all of magnitude at most
+// threshold.  The silent frames end the previous segment.  SplitOnSilence
+// should be called before the first Send.
+func (s *SplitSink) SplitOnSilence(threshold float64, minGap int) {
+	s.threshold = threshold
+	s.minGap = minGap
+}
+
+// Send implements sound.Sink.
+func (s *SplitSink) Send(d []float64) error {
+	N := len(d) / s.Channels()
+	start := 0
+	mark := s.firstMark()
+	for f := 0; f < N; f++ {
+		if s.detect(d, N, f) || s.pos >= mark || s.cur == nil {
+			if err := s.send(d, N, start, f); err != nil {
+				return err
+			}
+			start = f
+			if err := s.next(); err != nil {
+				return err
+			}
+			mark = s.firstMark()
+		}
+		s.pos++
+	}
+	return s.send(d, N, start, N)
+}
+
+// firstMark returns the first marker, or math.MaxInt64 if there is none.
+func (s *SplitSink) firstMark() int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.marks) == 0 {
+		return math.MaxInt64
+	}
+	return s.marks[0]
+}
+
+// detect returns whether frame f of d, which has N frames per channel,
+// starts a new segment as per the silence detector.
+func (s *SplitSink) detect(d []float64, N, f int) bool {
+	if s.minGap == 0 {
+		return false
+	}
+	for c := 0; c < s.Channels(); c++ {
+		if math.Abs(d[c*N+f]) > s.threshold {
+			split := s.gap >= s.minGap && s.cur != nil
+			s.gap = 0
+			return split
+		}
+	}
+	if s.gap < s.minGap {
+		s.gap++
+	}
+	return false
+}
+
+// send sends frames [start, end) of d, which has N frames per channel, to
+// the current sink.
+func (s *SplitSink) send(d []float64, N, start, end int) error {
+	if end == start {
+		return nil
+	}
+	buf := d
+	if end-start != N {
+		m := end - start
+		buf = make([]float64, m*s.Channels())
+		for c := 0; c < s.Channels(); c++ {
+			copy(buf[c*m:(c+1)*m], d[c*N+start:c*N+end])
+		}
+	}
+	return s.cur.Send(buf)
+}
+
+// next closes the current segment and opens the next one at the current
+// frame, consuming the markers up to it.
+func (s *SplitSink) next() error {
+	s.mu.Lock()
+	for len(s.marks) > 0 && s.marks[0] <= s.pos {
+		s.marks = s.marks[1:]
+	}
+	s.mu.Unlock()
+	if err := s.Close(); err != nil {
+		return err
+	}
+	snk, err := s.open(s.i, s.pos)
+	if err != nil {
+		return err
+	}
+	s.cur = snk
+	s.i++
+	return nil
+}
+
+// Close implements sound.Sink, closing the current segment.
+func (s *SplitSink) Close() error {
+	if s.cur == nil {
+		return nil
+	}
+	err := s.cur.Close()
+	s.cur = nil
+	return err
+}
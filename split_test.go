@@ -0,0 +1,48 @@
+// Copyright 2018 The ZikiChombo Authors. All rights reserved.  Use of this source
+// code is governed by a license that can be found in the License file.
+
+package plug
+
+import (
+	"testing"
+
+	"zikichombo.org/sound"
+)
+
+func TestSplitSink(t *testing.T) {
+	mono := sound.MonoCd()
+	var segs []*capture
+	var starts []int64
+	s := NewSplitSink(mono, func(i int, start int64) (sound.Sink, error) {
+		segs = append(segs, &capture{Form: mono})
+		starts = append(starts, start)
+		return segs[i], nil
+	})
+	s.SplitOnSilence(0, 100)
+	s.Mark(300)
+	d := make([]float64, 1000)
+	for i := range d {
+		if i < 500 || i >= 700 {
+			d[i] = 1
+		}
+	}
+	if err := s.Send(d[:400]); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Send(d[400:]); err != nil {
+		t.Fatal(err)
+	}
+	s.Close()
+	exp := []int64{0, 300, 700}
+	if len(starts) != len(exp) {
+		t.Fatalf("got segments at %v not %v", starts, exp)
+	}
+	for i := range exp {
+		if starts[i] != exp[i] {
+			t.Fatalf("got segments at %v not %v", starts, exp)
+		}
+	}
+	if segs[1].frames != 400 || segs[2].frames != 300 {
+		t.Errorf("got %d and %d frames", segs[1].frames, segs[2].frames)
+	}
+}
@@ -0,0 +1,88 @@
+// Copyright 2018 The ZikiChombo Authors. All rights reserved.  Use of this source
+// code is governed by a license that can be found in the License file.
+
+package plug
+
+import "sync"
+
+// RunState is the state of an IO as reported by Instrumented.Stats.
+type RunState int
+
+const (
+	Idle     RunState = iota // Run has not been called
+	Running                  // Run is processing blocks
+	Paused                   // Running, but paused by Pause
+	Quiesced                 // Running, but quiesced by Quiesce
+	Finished                 // Run has returned
+)
+
+func (s RunState) String() string {
+	switch s {
+	case Idle:
+		return "idle"
+	case Running:
+		return "running"
+	case Paused:
+		return "paused"
+	case Quiesced:
+		return "quiesced"
+	case Finished:
+		return "finished"
+	}
+	return "unknown"
+}
+
+// Stats gives the state and progress of an IO.
+type Stats struct {
+	State     RunState
+	FramesIn  int64 // input frames consumed
+	FramesOut int64 // output frames produced
+	Blocks    int64 // blocks processed
+	InFrames  int   // input frames per block, as last processed
+	OutFrames int   // output frames per block, as last processed
+}
+
+// ioStats holds the Stats of a node apart from n.mu, which is held while
+// a node waits for its input.
+type ioStats struct {
+	mu sync.Mutex
+	Stats
+}
+
+// Stats implements Instrumented.
+func (n *node) Stats() Stats {
+	n.stats.mu.Lock()
+	st := n.stats.Stats
+	n.stats.mu.Unlock()
+	if st.State != Running {
+		return st
+	}
+	n.qmu.Lock()
+	defer n.qmu.Unlock()
+	switch {
+	case n.quiesced:
+		st.State = Quiesced
+	case n.paused:
+		st.State = Paused
+	}
+	return st
+}
+
+// setState sets the state reported by Stats.
+func (n *node) setState(s RunState) {
+	n.stats.mu.Lock()
+	defer n.stats.mu.Unlock()
+	n.stats.State = s
+}
+
+// count counts a block of iFrms, oFrms frames which consumed nFrms input
+// frames and produced oFrms output frames.
+func (n *node) count(iFrms, oFrms, nFrms, produced int) {
+	n.stats.mu.Lock()
+	defer n.stats.mu.Unlock()
+	n.stats.FramesIn += int64(nFrms)
+	n.stats.FramesOut += int64(produced)
+	n.stats.Blocks++
+	n.stats.InFrames = iFrms
+	n.stats.OutFrames = oFrms
+}
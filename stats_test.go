@@ -0,0 +1,30 @@
+// Copyright 2018 The ZikiChombo Authors. All rights reserved.  Use of this source
+// code is governed by a license that can be found in the License file.
+
+package plug
+
+import (
+	"testing"
+
+	"zikichombo.org/sound"
+)
+
+func TestIOStats(t *testing.T) {
+	mono := sound.MonoCd()
+	u := New(mono, mono, PassThrough)
+	if st := u.Stats(); st.State != Idle {
+		t.Errorf("got state %s before Run", st.State)
+	}
+	u.SetInput(&ramp{Form: mono, n: 3000})
+	u.AddOutput(&capture{Form: mono})
+	if err := u.Run(); err != nil {
+		t.Fatal(err)
+	}
+	st := u.Stats()
+	if st.State != Finished || st.FramesIn != 3000 || st.FramesOut != 3000 {
+		t.Errorf("got %+v", st)
+	}
+	if st.Blocks != 3 || st.InFrames != 1024 || st.OutFrames != 1024 {
+		t.Errorf("got %+v", st)
+	}
+}
@@ -0,0 +1,72 @@
+// Copyright 2018 The ZikiChombo Authors. All rights reserved.  Use of this source
+// code is governed by a license that can be found in the License file.
+
+package plug
+
+import "math"
+
+// stft performs streaming short time Fourier analysis, spectral
+// modification and overlap-add resynthesis on one channel.  Each sample in
+// gives one sample out, with a latency of size frames.
+type stft struct {
+	size, hop int
+	win       []float64
+	scale     float64
+	in        []float64
+	acc       []float64
+	out       []float64
+	k         int
+	bins      []complex128
+}
+
+// newSTFT creates a new stft with frame size size (a power of 2) and hop
+// size hop, using a square root periodic Hann window for both analysis and
+// synthesis.
+func newSTFT(size, hop int) *stft {
+	res := &stft{
+		size: size,
+		hop:  hop,
+		win:  make([]float64, size),
+		in:   make([]float64, size),
+		acc:  make([]float64, size),
+		out:  make([]float64, hop),
+		bins: make([]complex128, size)}
+	ss := 0.0
+	for i := range res.win {
+		w := math.Sqrt(0.5 - 0.5*math.Cos(2*math.Pi*float64(i)/float64(size)))
+		res.win[i] = w
+		ss += w * w
+	}
+	res.scale = float64(hop) / ss
+	return res
+}
+
+// run streams src through the stft into dst, calling fn on the spectrum of
+// each analysis frame.  dst and src have the same length.
+func (s *stft) run(dst, src []float64, fn func(bins []complex128)) {
+	off := s.size - s.hop
+	for i, x := range src {
+		s.in[off+s.k] = x
+		dst[i] = s.out[s.k]
+		s.k++
+		if s.k < s.hop {
+			continue
+		}
+		s.k = 0
+		for j, v := range s.in {
+			s.bins[j] = complex(v*s.win[j], 0)
+		}
+		fft(s.bins, false)
+		fn(s.bins)
+		fft(s.bins, true)
+		for j, b := range s.bins {
+			s.acc[j] += real(b) * s.win[j] * s.scale
+		}
+		copy(s.out, s.acc[:s.hop])
+		copy(s.acc, s.acc[s.hop:])
+		for j := off; j < s.size; j++ {
+			s.acc[j] = 0
+		}
+		copy(s.in, s.in[s.hop:])
+	}
+}
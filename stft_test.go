@@ -0,0 +1,50 @@
+// Copyright 2018 The ZikiChombo Authors. All rights reserved.  Use of this source
+// code is governed by a license that can be found in the License file.
+
+package plug
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+)
+
+func TestFFTInverse(t *testing.T) {
+	x := make([]complex128, 64)
+	y := make([]complex128, 64)
+	for i := range x {
+		x[i] = complex(rand.Float64(), rand.Float64())
+	}
+	copy(y, x)
+	fft(y, false)
+	fft(y, true)
+	for i := range x {
+		if d := x[i] - y[i]; math.Hypot(real(d), imag(d)) > 1e-9 {
+			t.Errorf("%d got %v not %v", i, y[i], x[i])
+		}
+	}
+}
+
+func TestSTFTIdentity(t *testing.T) {
+	for _, hop := range []int{128, 64} {
+		s := newSTFT(256, hop)
+		N := 4096
+		src := make([]float64, N)
+		dst := make([]float64, N)
+		for i := range src {
+			src[i] = rand.Float64()*2 - 1
+		}
+		for i := 0; i < N; i += 100 {
+			end := i + 100
+			if end > N {
+				end = N
+			}
+			s.run(dst[i:end], src[i:end], func([]complex128) {})
+		}
+		for i := 256; i < N; i++ {
+			if math.Abs(dst[i]-src[i-256]) > 1e-9 {
+				t.Fatalf("hop %d: %d got %f not %f", hop, i, dst[i], src[i-256])
+			}
+		}
+	}
+}
@@ -0,0 +1,98 @@
+// Copyright 2018 The ZikiChombo Authors. All rights reserved.  Use of this source
+// code is governed by a license that can be found in the License file.
+
+package plug
+
+import "fmt"
+
+// STFTProcessor is a FullMode processor which applies a spectral transform
+// to each channel of its input by short time Fourier analysis, windowed
+// with a square root Hann window, and overlap-add resynthesis.
+//
+// The transform is called with the spectrum of each analysis frame, of
+// window bins, which it modifies in place.  The spectrum is that of a real
+// signal, so the transform should keep bins[window-i] the complex conjugate
+// of bins[i] to avoid losing energy to the discarded imaginary part.
+//
+// An STFTProcessor has a latency of window frames, which it outputs as its
+// tail once the input has ended.  Its blocks are multiples of the hop size
+// so that each block completes whole analysis frames.
+type STFTProcessor struct {
+	window, hop int
+	fn          func(bins []complex128)
+	chs         []*stft
+	left        int // frames of tail left, or -1 before the input ends
+}
+
+// NewSTFTProcessor creates a new STFTProcessor with analysis frames of
+// window frames, a power of 2, every hop frames, and the spectral transform
+// fn.  hop must divide window at least twice.
+func NewSTFTProcessor(window, hop int, fn func(bins []complex128)) (*STFTProcessor, error) {
+	if !isPow2(window) {
+		return nil, fmt.Errorf("stft: window size %d not a power of 2", window)
+	}
+	if hop <= 0 || window%hop != 0 || window/hop < 2 {
+		return nil, fmt.Errorf("stft: hop size %d does not divide window size %d at least twice", hop, window)
+	}
+	return &STFTProcessor{window: window, hop: hop, fn: fn, left: -1}, nil
+}
+
+// Latency implements LatencyReporter.
+func (s *STFTProcessor) Latency() int {
+	return s.window
+}
+
+// Tail implements Tailer.
+func (s *STFTProcessor) Tail() bool {
+	return s.left != 0
+}
+
+// Reset implements Resetter.
+func (s *STFTProcessor) Reset() {
+	s.chs = nil
+	s.left = -1
+}
+
+// ChannelMode implements Processor.
+func (s *STFTProcessor) ChannelMode() ChannelMode {
+	return FullMode
+}
+
+// NextFrames implements Processor.
+func (s *STFTProcessor) NextFrames() (int, int) {
+	n := DefaultInFrames / s.hop * s.hop
+	if n == 0 {
+		n = s.hop
+	}
+	return n, n
+}
+
+// Process implements Processor.
+func (s *STFTProcessor) Process(dst, src *Block) error {
+	nC := src.Channels
+	if nC != dst.Channels {
+		return fmt.Errorf("stft: cannot map %d channels to %d", nC, dst.Channels)
+	}
+	for len(s.chs) < nC {
+		s.chs = append(s.chs, newSTFT(s.window, s.hop))
+	}
+	N := src.Frames
+	in := src.Samples
+	if N == 0 {
+		if s.left < 0 {
+			s.left = s.window
+		}
+		N = dst.Frames
+		if N > s.left {
+			N = s.left
+		}
+		s.left -= N
+		in = dst.Samples[:nC*N]
+		zero(in)
+	}
+	for c, st := range s.chs[:nC] {
+		st.run(dst.Samples[c*N:(c+1)*N], in[c*N:(c+1)*N], s.fn)
+	}
+	dst.Frames = N
+	return nil
+}
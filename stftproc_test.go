@@ -0,0 +1,48 @@
+// Copyright 2018 The ZikiChombo Authors. All rights reserved.  Use of this source
+// code is governed by a license that can be found in the License file.
+
+package plug
+
+import (
+	"math"
+	"testing"
+
+	"zikichombo.org/sound"
+)
+
+func TestSTFTProcessor(t *testing.T) {
+	if _, err := NewSTFTProcessor(256, 100, nil); err == nil {
+		t.Errorf("hop of 100 accepted")
+	}
+	mono := sound.MonoCd()
+	frames := 0
+	s, err := NewSTFTProcessor(256, 64, func(bins []complex128) {
+		// halve every bin.
+		for i := range bins {
+			bins[i] *= 0.5
+		}
+		frames++
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m, n := s.NextFrames(); m%64 != 0 || n != m {
+		t.Errorf("got next frames %d, %d", m, n)
+	}
+	var g Graph
+	u := g.New(mono, mono, s)
+	u.SetInput(&ramp{Form: mono, n: 1500})
+	snk := &capture{Form: mono}
+	u.AddOutput(snk)
+	if err := u.Run(); err != nil {
+		t.Fatal(err)
+	}
+	if snk.frames != 1500+256 || frames != (1500+256)/64 {
+		t.Fatalf("got %d frames, %d analysis frames", snk.frames, frames)
+	}
+	for i := 0; i < 1500; i++ {
+		if got := snk.mix[i+256]; math.Abs(got-0.5*float64(i)) > 1e-6 {
+			t.Fatalf("frame %d: got %f not %f", i, got, 0.5*float64(i))
+		}
+	}
+}
@@ -0,0 +1,56 @@
+// Copyright 2018 The ZikiChombo Authors. All rights reserved.  Use of this source
+// code is governed by a license that can be found in the License file.
+
+package plug
+
+// Stop implements Stopper.
+func (n *node) Stop() {
+	n.stopOnce.Do(func() {
+		close(n.stopC)
+		n.qmu.Lock()
+		n.qc.Broadcast()
+		n.wakeSeq()
+		n.qmu.Unlock()
+	})
+}
+
+// stopped returns whether Stop has been called.
+func (n *node) stopped() bool {
+	select {
+	case <-n.stopC:
+		return true
+	default:
+		return false
+	}
+}
+
+// Stop stops the graph gracefully: the nodes of the graph which do not
+// read from other nodes are stopped as per Stopper.Stop, and the nodes
+// downstream of them end in turn as their inputs end, once they have
+// processed and sent all blocks in flight.  Sinks are thus closed in
+// dependency order.  Stop does not wait; the channel returned by Run is
+// closed once all nodes have ended.
+func (g *Graph) Stop() {
+	for _, n := range g.nodes {
+		if s, ok := n.(Stopper); ok && !g.fed(n) {
+			s.Stop()
+		}
+	}
+}
+
+// fed returns whether n reads from another node of g.  n.mu is held while
+// n processes, so fed only takes n.wmu, as inputs may be set while n runs.
+func (g *Graph) fed(n IO) bool {
+	nd, ok := n.(*node)
+	if !ok {
+		return false
+	}
+	nd.wmu.Lock()
+	defer nd.wmu.Unlock()
+	for i := range nd.iPkts {
+		if ns, ok := nd.iPkts[i].src.(*nodeSource); ok && g.has(ns.n) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,44 @@
+// Copyright 2018 The ZikiChombo Authors. All rights reserved.  Use of this source
+// code is governed by a license that can be found in the License file.
+
+package plug
+
+import (
+	"io"
+	"testing"
+
+	"zikichombo.org/sound"
+	"zikichombo.org/sound/gen"
+)
+
+func TestGraphStop(t *testing.T) {
+	valve := sound.MonoCd()
+	var g Graph
+	u0 := g.New(valve, valve, PassThrough)
+	u1 := g.New(valve, valve, PassThrough)
+	u0.SetInput(gen.Noise())
+	if err := g.Connect(u0, nil, u1, nil); err != nil {
+		t.Fatal(err)
+	}
+	out := u1.Output()
+	ec := g.Run()
+	buf := make([]float64, 1024)
+	for i := 0; i < 10; i++ {
+		if _, err := out.Receive(buf); err != nil {
+			t.Fatal(err)
+		}
+	}
+	g.Stop()
+	for {
+		_, err := out.Receive(buf)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	for err := range ec {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,236 @@
+// Copyright 2018 The ZikiChombo Authors. All rights reserved.  Use of this source
+// code is governed by a license that can be found in the License file.
+
+package plug
+
+import (
+	"math"
+	"sync"
+)
+
+const (
+	tsFrame = 1024
+	tsHop   = tsFrame / 2
+	tsTol   = 256
+)
+
+// TimeStretch is a FullMode processor which changes the playback speed
+// of its input without changing its pitch, using WSOLA (waveform similarity
+// overlap-add).
+//
+// The speed may be changed while the processor is running.  A speed of 2
+// plays back twice as fast, consuming 2 input frames for every output frame,
+// and a speed of 0.5 half as fast.  Accordingly, NextFrames requests a
+// number of input frames which depends on the current speed.
+//
+// Since a TimeStretch outputs a different number of frames than it takes
+// as input, it buffers both.  Once the input has ended, the buffered input
+// is output as its tail, so that the output lasts as long as the input
+// at the speeds it was played back at.
+//
+// The input and output forms of a node running a TimeStretch should have
+// the same number of channels.
+type TimeStretch struct {
+	mu      sync.Mutex
+	speed   float64
+	win     []float64
+	in      [][]float64
+	out     [][]float64
+	acc     [][]float64
+	pa      float64
+	prev    int
+	started bool    // whether prev is set
+	nIn     int64   // input frames
+	apos    float64 // input position of the next output frame
+	done    bool    // whether the tail has been produced
+}
+
+// NewTimeStretch creates a new TimeStretch with initial speed speed.
+func NewTimeStretch(speed float64) *TimeStretch {
+	res := &TimeStretch{
+		speed: clampSpeed(speed),
+		win:   make([]float64, tsFrame)}
+	for i := range res.win {
+		res.win[i] = 0.5 - 0.5*math.Cos(2*math.Pi*float64(i)/tsFrame)
+	}
+	return res
+}
+
+func clampSpeed(s float64) float64 {
+	return math.Max(0.25, math.Min(4, s))
+}
+
+// SetSpeed sets the speed, which is clamped to [0.25..4].
+func (t *TimeStretch) SetSpeed(s float64) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.speed = clampSpeed(s)
+}
+
+// Speed returns the current speed.
+func (t *TimeStretch) Speed() float64 {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.speed
+}
+
+// Tail implements Tailer.
+func (t *TimeStretch) Tail() bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return !t.done || (len(t.out) > 0 && len(t.out[0]) > 0)
+}
+
+// Reset implements Resetter, discarding the buffered input and output.
+// The speed is kept.
+func (t *TimeStretch) Reset() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.in, t.out, t.acc = nil, nil, nil
+	t.pa, t.prev, t.started = 0, 0, false
+	t.nIn, t.apos, t.done = 0, 0, false
+}
+
+// ChannelMode implements Processor.
+func (t *TimeStretch) ChannelMode() ChannelMode {
+	return FullMode
+}
+
+// NextFrames implements Processor.
+func (t *TimeStretch) NextFrames() (int, int) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return int(math.Ceil(DefaultOutFrames * t.speed)), DefaultOutFrames
+}
+
+// Process implements Processor.
+func (t *TimeStretch) Process(dst, src *Block) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	nC := src.Channels
+	for len(t.in) < nC {
+		t.in = append(t.in, nil)
+		t.out = append(t.out, nil)
+		t.acc = append(t.acc, make([]float64, tsFrame))
+	}
+	N := src.Frames
+	for c := 0; c < nC; c++ {
+		t.in[c] = append(t.in[c], src.Samples[c*N:(c+1)*N]...)
+	}
+	t.nIn += int64(N)
+	if N == 0 {
+		t.flush()
+	}
+	for t.step() {
+	}
+	M := dst.Frames
+	if avail := len(t.out[0]); avail < M {
+		M = avail
+	}
+	for c := 0; c < nC; c++ {
+		copy(dst.Samples[c*M:(c+1)*M], t.out[c][:M])
+		t.out[c] = t.out[c][:copy(t.out[c], t.out[c][M:])]
+	}
+	dst.Frames = M
+	return nil
+}
+
+// flush produces the output up to the end of the input, padding the
+// input with silence.
+func (t *TimeStretch) flush() {
+	if t.done {
+		return
+	}
+	t.done = true
+	for t.apos < float64(t.nIn) {
+		if t.step() {
+			continue
+		}
+		for c := range t.in {
+			t.in[c] = append(t.in[c], make([]float64, tsFrame+2*tsTol)...)
+		}
+	}
+	// trim the output of the last step past the end of the input.
+	if excess := int((t.apos - float64(t.nIn)) / t.speed); excess > 0 {
+		for c, out := range t.out {
+			t.out[c] = out[:len(out)-excess]
+		}
+	}
+}
+
+// step produces tsHop output frames if there is enough input,
+// returning whether it did so.
+func (t *TimeStretch) step() bool {
+	if t.done && t.apos >= float64(t.nIn) {
+		return false
+	}
+	avail := len(t.in[0])
+	nom := int(t.pa)
+	pos := nom
+	if !t.started {
+		if avail < nom+tsFrame {
+			return false
+		}
+	} else {
+		// the natural continuation is at most tsTol+tsHop past nom: only
+		// depending on nom keeps the block schedule independent of the
+		// input.
+		nat := t.prev + tsHop
+		if avail < nom+tsTol+tsHop+tsFrame {
+			return false
+		}
+		lo := nom - tsTol
+		if lo < 0 {
+			lo = 0
+		}
+		best := math.Inf(-1)
+		for p := lo; p <= nom+tsTol; p++ {
+			if v := t.similarity(p, nat); v > best {
+				best, pos = v, p
+			}
+		}
+	}
+	for c, in := range t.in {
+		acc := t.acc[c]
+		for i, w := range t.win {
+			acc[i] += in[pos+i] * w
+		}
+		t.out[c] = append(t.out[c], acc[:tsHop]...)
+		copy(acc, acc[tsHop:])
+		for i := tsFrame - tsHop; i < tsFrame; i++ {
+			acc[i] = 0
+		}
+	}
+	t.prev, t.started = pos, true
+	t.pa += t.speed * tsHop
+	t.apos += t.speed * tsHop
+
+	// discard input which is no longer needed.
+	cut := int(t.pa) - tsTol
+	if nat := t.prev + tsHop; nat < cut {
+		cut = nat
+	}
+	if cut > 0 {
+		for c, in := range t.in {
+			t.in[c] = in[:copy(in, in[cut:])]
+		}
+		t.pa -= float64(cut)
+		t.prev -= cut
+	}
+	return true
+}
+
+// similarity gives the cross correlation of the channel sum of the frames
+// starting at p and q, subsampled by 4.
+func (t *TimeStretch) similarity(p, q int) float64 {
+	acc := 0.0
+	for i := 0; i < tsFrame; i += 4 {
+		a, b := 0.0, 0.0
+		for _, in := range t.in {
+			a += in[p+i]
+			b += in[q+i]
+		}
+		acc += a * b
+	}
+	return acc
+}
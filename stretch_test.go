@@ -0,0 +1,72 @@
+// Copyright 2018 The ZikiChombo Authors. All rights reserved.  Use of this source
+// code is governed by a license that can be found in the License file.
+
+package plug
+
+import (
+	"io"
+	"testing"
+
+	"zikichombo.org/sound"
+	"zikichombo.org/sound/gen"
+	"zikichombo.org/sound/ops"
+)
+
+func TestTimeStretchIO(t *testing.T) {
+	for _, speed := range []float64{0.5, 1, 2} {
+		valve := sound.MonoCd()
+		u := New(valve, valve, NewTimeStretch(speed))
+		u.SetInput(ops.Limit(gen.Noise(), 44100))
+		out := u.Output()
+		go u.Run()
+		buf := make([]float64, 1024)
+		ttl := 0
+		for {
+			n, err := out.Receive(buf)
+			ttl += n
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+		exp := int(44100 / speed)
+		if ttl > exp+1 || ttl < exp-1 {
+			t.Errorf("speed %f: got %d frames, expected about %d", speed, ttl, exp)
+		}
+	}
+}
+
+func TestTimeStretchGraph(t *testing.T) {
+	valve := sound.MonoCd()
+	for _, speed := range []float64{0.5, 2, 3.7} {
+		for _, seq := range []bool{false, true} {
+			var g Graph
+			u := g.New(valve, valve, NewTimeStretch(speed))
+			v := g.New(valve, valve, PassThrough)
+			u.SetInput(ops.Limit(gen.Noise(), 20000))
+			if err := g.Connect(u, nil, v, nil); err != nil {
+				t.Fatal(err)
+			}
+			snk := &capture{Form: valve}
+			v.AddOutput(snk)
+			if seq {
+				if err := g.RunSequential(); err != nil {
+					t.Fatal(err)
+				}
+			} else {
+				for err := range g.Run() {
+					t.Fatal(err)
+				}
+			}
+			exp := int64(20000 / speed)
+			if snk.frames > exp+1 || snk.frames < exp-1 {
+				t.Errorf("speed %f seq=%t: got %d frames, expected %d", speed, seq, snk.frames, exp)
+			}
+			if st := u.Stats(); st.FramesIn != 20000 || st.FramesOut != snk.frames {
+				t.Errorf("speed %f seq=%t: got stats %+v", speed, seq, st)
+			}
+		}
+	}
+}
@@ -0,0 +1,77 @@
+// Copyright 2018 The ZikiChombo Authors. All rights reserved.  Use of this source
+// code is governed by a license that can be found in the License file.
+
+package plug
+
+// SwapProcessor implements ProcessorController.
+func (n *node) SwapProcessor(p Processor, fade int) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if n.started {
+		if err := n.start(p); err != nil {
+			return err
+		}
+	}
+	old := n.proc
+	if fade <= 0 {
+		n.setProc(p)
+		n.retire(old)
+		return nil
+	}
+	if sf, ok := old.(*swapFade); ok {
+		// swapping during a fade: fade from the processor being faded in.
+		n.retire(sf.a)
+		old = sf.b
+	}
+	n.setProc(&swapFade{a: old, b: p, n: fade})
+	return nil
+}
+
+// swapFade crossfades from the output of processor a to that of processor
+// b over n frames.
+type swapFade struct {
+	a, b Processor
+	n, i int
+	tmp  Block
+}
+
+func (s *swapFade) ChannelMode() ChannelMode {
+	return FullMode
+}
+
+func (s *swapFade) NextFrames() (int, int) {
+	s.a.NextFrames()
+	return s.b.NextFrames()
+}
+
+func (s *swapFade) done() bool {
+	return s.i >= s.n
+}
+
+func (s *swapFade) Process(dst, src *Block) error {
+	t := &s.tmp
+	t.SampleRate, t.Channels, t.Frames = dst.SampleRate, dst.Channels, dst.Frames
+	t.Samples = buffer(t.Samples, dst.Channels, dst.Frames)
+	if err := runFull(s.a, t, src); err != nil {
+		return err
+	}
+	if err := runFull(s.b, dst, src); err != nil {
+		return err
+	}
+	M := dst.Frames
+	if t.Frames < M {
+		M = t.Frames
+	}
+	dst.EachChannel(func(c int, d []float64) {
+		old := t.Channel(c)
+		for f := 0; f < M; f++ {
+			g := 1.0
+			if s.i+f < s.n {
+				g = float64(s.i+f) / float64(s.n)
+			}
+			d[f] = g*d[f] + (1-g)*old[f]
+		}
+	})
+	s.i += M
+	return nil
+}
@@ -0,0 +1,105 @@
+// Copyright 2018 The ZikiChombo Authors. All rights reserved.  Use of this source
+// code is governed by a license that can be found in the License file.
+
+package plug
+
+import (
+	"testing"
+
+	"zikichombo.org/sound"
+)
+
+// pausing is a ramp which pauses p once it has given each frame of at,
+// signalling c.
+type pausing struct {
+	ramp
+	at []int64
+	p  Pauser
+	c  chan struct{}
+}
+
+func (s *pausing) Receive(d []float64) (int, error) {
+	n, err := s.ramp.Receive(d)
+	if len(s.at) > 0 && s.pos >= s.at[0] {
+		s.at = s.at[1:]
+		s.p.Pause()
+		s.c <- struct{}{}
+	}
+	return n, err
+}
+
+// scaler multiplies its input by k and counts the times it is closed.
+type scaler struct {
+	k      float64
+	closed int
+}
+
+func (s *scaler) ChannelMode() ChannelMode { return MonoMode }
+func (s *scaler) NextFrames() (int, int)   { return 1024, 1024 }
+func (s *scaler) Close() error             { s.closed++; return nil }
+
+func (s *scaler) Process(dst, src *Block) error {
+	for i, v := range src.Samples[:src.Frames] {
+		dst.Samples[i] = s.k * v
+	}
+	dst.Frames = src.Frames
+	return nil
+}
+
+func TestIOSwapProcessorFade(t *testing.T) {
+	mono := sound.MonoCd()
+	a, b, c := &scaler{k: 1}, &scaler{k: 3}, &scaler{k: 5}
+	u := New(mono, mono, a)
+	src := &pausing{ramp: ramp{Form: mono, n: 20000}, at: []int64{3072, 6144}, p: u, c: make(chan struct{}, 2)}
+	u.SetInput(src)
+	rec := &record{Form: mono}
+	u.AddOutput(rec)
+	errC := make(chan error)
+	go func() {
+		errC <- u.Run()
+	}()
+	// swap while paused, so that the fades start at known frames.
+	<-src.c
+	if err := u.SwapProcessor(b, 4096); err != nil {
+		t.Fatal(err)
+	}
+	b1 := rec.frames()
+	u.Resume()
+	<-src.c
+	// a is retired when swapping mid-fade, which then fades from b.
+	if err := u.SwapProcessor(c, 2048); err != nil {
+		t.Fatal(err)
+	}
+	b2 := rec.frames()
+	u.Resume()
+	if err := <-errC; err != nil {
+		t.Fatal(err)
+	}
+	if b1 == 0 || b2-b1 <= 0 || b2-b1 >= 4096 {
+		t.Fatalf("swapped at frames %d and %d, not mid-fade", b1, b2)
+	}
+	out := rec.chs[0]
+	if len(out) != 20000 {
+		t.Fatalf("got %d frames", len(out))
+	}
+	for i, v := range out {
+		x := float64(i)
+		exp := x
+		switch {
+		case i >= b2+2048:
+			exp = 5 * x
+		case i >= b2:
+			g := float64(i-b2) / 2048
+			exp = g*5*x + (1-g)*3*x
+		case i >= b1:
+			g := float64(i-b1) / 4096
+			exp = g*3*x + (1-g)*x
+		}
+		if d := v - exp; d > 1e-9*exp || d < -1e-9*exp {
+			t.Fatalf("frame %d: got %g not %g (swaps at %d and %d)", i, v, exp, b1, b2)
+		}
+	}
+	if a.closed != 1 || b.closed != 1 || c.closed != 1 {
+		t.Errorf("closed %d, %d and %d times", a.closed, b.closed, c.closed)
+	}
+}
@@ -0,0 +1,33 @@
+// Copyright 2018 The ZikiChombo Authors. All rights reserved.  Use of this source
+// code is governed by a license that can be found in the License file.
+
+package plug
+
+import "io"
+
+// Tailer is implemented by FullMode processors which produce output after
+// their input has ended, such as reverbs, delays and resamplers.
+//
+// Once the input of an IO has ended, and any run-out set by SetRunOut has
+// been processed, the IO calls Tail before every further block, and while
+// Tail returns true, calls Process with src.Frames == 0 for the processor
+// to output its tail.  Tail should thus return false once the processor
+// is drained, and Process should then make progress towards this.
+type Tailer interface {
+	// Tail returns whether the processor has more output to produce
+	// without further input.
+	Tail() bool
+}
+
+// tailBlock prepares iBlock for a block of the tail of proc after the
+// input has ended, returning io.EOF once proc has no more tail.
+func (n *node) tailBlock(proc Processor, iBlock *Block) (int, error) {
+	t, ok := proc.(Tailer)
+	if !ok || n.bypass || proc.ChannelMode() != FullMode || !t.Tail() {
+		return 0, io.EOF
+	}
+	n.ended = true
+	zero(iBlock.Samples)
+	iBlock.Frames = 0
+	return 0, nil
+}
@@ -0,0 +1,37 @@
+// Copyright 2018 The ZikiChombo Authors. All rights reserved.  Use of this source
+// code is governed by a license that can be found in the License file.
+
+package plug
+
+import (
+	"testing"
+
+	"zikichombo.org/sound"
+)
+
+func TestIOTail(t *testing.T) {
+	mono := sound.MonoCd()
+	for _, seq := range []bool{false, true} {
+		var g Graph
+		e := &echo{tail: 2}
+		u := g.New(mono, mono, e)
+		u.SetInput(&ramp{Form: mono, n: 1500})
+		snk := &capture{Form: mono}
+		u.AddOutput(snk)
+		var err error
+		if seq {
+			err = g.RunSequential()
+		} else {
+			err = u.Run()
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		if snk.frames != 1500+2048 || e.tail != 0 {
+			t.Errorf("seq=%t: got %d frames, tail %d", seq, snk.frames, e.tail)
+		}
+		if snk.mix[1499] != 1499 || snk.mix[1500] != 1 || snk.mix[1500+2047] != 1 {
+			t.Errorf("seq=%t: got %v", seq, snk.mix[1498:1502])
+		}
+	}
+}
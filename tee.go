@@ -0,0 +1,25 @@
+// Copyright 2018 The ZikiChombo Authors. All rights reserved.  Use of this source
+// code is governed by a license that can be found in the License file.
+
+package plug
+
+import "zikichombo.org/sound"
+
+// Tee duplicates src into n sources, for example to feed an external
+// source to several graphs.  Each of the returned sources gives every
+// frame of src.
+//
+// Tee is a PassThrough IO with n outputs, which is returned to be run, or
+// added to a graph with Graph.Add.  As the frames of src are sent to the
+// returned sources in turn, every one of them must be received from for
+// the others to make progress.
+func Tee(src sound.Source, n int) ([]sound.Source, IO) {
+	u := New(src, src, PassThrough)
+	// cannot fail: u takes the form of src.
+	u.SetInput(src)
+	res := make([]sound.Source, n)
+	for i := range res {
+		res[i] = u.Output()
+	}
+	return res, u
+}
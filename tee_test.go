@@ -0,0 +1,51 @@
+// Copyright 2018 The ZikiChombo Authors. All rights reserved.  Use of this source
+// code is governed by a license that can be found in the License file.
+
+package plug
+
+import (
+	"testing"
+
+	"zikichombo.org/sound"
+)
+
+func TestTee(t *testing.T) {
+	mono := sound.MonoCd()
+	srcs, u := Tee(&ramp{Form: mono, n: 3000}, 3)
+	if len(srcs) != 3 {
+		t.Fatalf("got %d sources", len(srcs))
+	}
+	var gs [3]Graph
+	snks := make([]*capture, 3)
+	for i := range gs {
+		v := gs[i].New(mono, mono, PassThrough)
+		if err := v.SetInput(srcs[i]); err != nil {
+			t.Fatal(err)
+		}
+		snks[i] = &capture{Form: mono}
+		v.AddOutput(snks[i])
+	}
+	errs := make(chan error, 4)
+	go func() { errs <- u.Run() }()
+	for i := range gs {
+		go func(g *Graph) {
+			var err error
+			for e := range g.Run() {
+				if err == nil {
+					err = e
+				}
+			}
+			errs <- err
+		}(&gs[i])
+	}
+	for i := 0; i < 4; i++ {
+		if err := <-errs; err != nil {
+			t.Fatal(err)
+		}
+	}
+	for i, snk := range snks {
+		if snk.frames != 3000 || snk.mix[2999] != 2999 {
+			t.Errorf("source %d: got %d frames", i, snk.frames)
+		}
+	}
+}
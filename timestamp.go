@@ -0,0 +1,20 @@
+// Copyright 2018 The ZikiChombo Authors. All rights reserved.  Use of this source
+// code is governed by a license that can be found in the License file.
+
+package plug
+
+import "time"
+
+// stamp sets the StartFrame and Time of the blocks about to be processed,
+// iBlock starting at input frame frame.  n.mu is held.
+func (n *node) stamp(iBlock, oBlock *Block, frame int64) {
+	iBlock.StartFrame = frame
+	n.stats.mu.Lock()
+	oBlock.StartFrame = n.stats.FramesOut
+	n.stats.mu.Unlock()
+	iBlock.Time = time.Time{}
+	if n.clock != nil {
+		iBlock.Time = n.clock.Wall(n.clock.Position())
+	}
+	oBlock.Time = iBlock.Time
+}
@@ -0,0 +1,44 @@
+// Copyright 2018 The ZikiChombo Authors. All rights reserved.  Use of this source
+// code is governed by a license that can be found in the License file.
+
+package plug
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"zikichombo.org/sound"
+)
+
+func TestBlockStartFrame(t *testing.T) {
+	mono := sound.MonoCd()
+	var starts, outs []int64
+	var times []time.Time
+	p := NewProcessorFrames(FullMode, func(dst, src *Block) error {
+		starts = append(starts, src.StartFrame)
+		outs = append(outs, dst.StartFrame)
+		times = append(times, src.Time)
+		// produce half the frames.
+		N := src.Frames / 2
+		copy(dst.Samples, src.Samples[:N])
+		dst.Frames = N
+		return nil
+	}, 1000, 1000)
+	var g Graph
+	u := g.New(mono, mono, p)
+	u.SetInput(&ramp{Form: mono, n: 3000})
+	u.AddOutput(&capture{Form: mono})
+	g.SetClockMaster(u)
+	if err := u.Run(); err != nil {
+		t.Fatal(err)
+	}
+	if fmt.Sprint(starts) != "[0 1000 2000]" || fmt.Sprint(outs) != "[0 500 1000]" {
+		t.Errorf("got starts %v, %v", starts, outs)
+	}
+	for i, tm := range times {
+		if tm.IsZero() || (i > 0 && tm.Before(times[i-1])) {
+			t.Errorf("block %d: got time %v", i, tm)
+		}
+	}
+}
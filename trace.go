@@ -0,0 +1,185 @@
+// Copyright 2018 The ZikiChombo Authors. All rights reserved.  Use of this source
+// code is governed by a license that can be found in the License file.
+
+package plug
+
+import (
+	"fmt"
+	"io"
+	"sync"
+
+	"zikichombo.org/sound"
+)
+
+// TraceBlock records one processing block of an IO.
+type TraceBlock struct {
+	In, Out  int  // input and output frames requested by the processor.
+	Frames   int  // input frames received.
+	Produced int  // output frames produced.
+	EOF      bool // whether the inputs ended at this block.
+}
+
+// Trace records the sequence of processing blocks an IO experienced: their
+// sizes, frame counts and the point at which the input ended.  A Trace may
+// then be used to replay the same schedule, which is useful for reproducing
+// timing dependent problems offline.
+type Trace struct {
+	mu     sync.Mutex
+	blocks []TraceBlock
+}
+
+// NewTrace creates a new empty Trace.
+func NewTrace() *Trace {
+	return &Trace{}
+}
+
+// Blocks returns a copy of the recorded blocks.
+func (t *Trace) Blocks() []TraceBlock {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return append([]TraceBlock(nil), t.blocks...)
+}
+
+func (t *Trace) record(b TraceBlock) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.blocks = append(t.blocks, b)
+}
+
+// Processor returns a processor which behaves like p, except that its
+// NextFrames method follows the recorded schedule.  Once the schedule is
+// exhausted, p.NextFrames is used.
+func (t *Trace) Processor(p Processor) Processor {
+	return &traceProc{Processor: p, blocks: t.Blocks()}
+}
+
+// traceProc follows the schedule in NextFrames, which IOs call once per
+// block.
+type traceProc struct {
+	Processor
+	blocks []TraceBlock
+	i      int
+}
+
+func (p *traceProc) NextFrames() (int, int) {
+	if p.i >= len(p.blocks) {
+		return p.Processor.NextFrames()
+	}
+	b := p.blocks[p.i]
+	p.i++
+	return b.In, b.Out
+}
+
+// Tail implements Tailer, so that the tail of p is replayed.
+func (p *traceProc) Tail() bool {
+	t, ok := p.Processor.(Tailer)
+	return ok && t.Tail()
+}
+
+// Latency implements LatencyReporter.
+func (p *traceProc) Latency() int {
+	return latency(p.Processor)
+}
+
+// Source returns a source which reads from src, but returns the recorded
+// number of frames for each block and io.EOF at the recorded point.
+func (t *Trace) Source(src sound.Source) sound.Source {
+	return &traceSrc{Source: src, blocks: t.Blocks()}
+}
+
+type traceSrc struct {
+	sound.Source
+	blocks []TraceBlock
+	i      int
+	buf    []float64
+}
+
+func (s *traceSrc) Receive(dst []float64) (int, error) {
+	if s.i >= len(s.blocks) {
+		return s.Source.Receive(dst)
+	}
+	b := s.blocks[s.i]
+	s.i++
+	if b.EOF {
+		return 0, io.EOF
+	}
+	nC := s.Channels()
+	want := b.Frames
+	if max := len(dst) / nC; want > max {
+		want = max
+	}
+	// gather exactly want frames, which may take several reads.
+	got := 0
+	for got < want {
+		s.buf = buffer(s.buf, nC, want-got)
+		n, err := s.Source.Receive(s.buf)
+		for c := 0; c < nC; c++ {
+			copy(dst[c*want+got:c*want+got+n], s.buf[c*n:(c+1)*n])
+		}
+		got += n
+		if err != nil {
+			if got == 0 {
+				return 0, err
+			}
+			break
+		}
+	}
+	if got < want {
+		// compact the channels.
+		for c := 1; c < nC; c++ {
+			copy(dst[c*got:(c+1)*got], dst[c*want:c*want+got])
+		}
+	}
+	return got, nil
+}
+
+// SetTrace implements Instrumented.
+func (n *node) SetTrace(t *Trace) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.trace = t
+}
+
+// Trace sets a new Trace on every node of the graph and returns them, in
+// the order in which the nodes were created.  The traces of nodes not
+// implementing Instrumented remain empty.
+func (g *Graph) Trace() []*Trace {
+	res := make([]*Trace, len(g.nodes))
+	for i, n := range g.nodes {
+		res[i] = NewTrace()
+		if in, ok := n.(Instrumented); ok {
+			in.SetTrace(res[i])
+		}
+	}
+	return res
+}
+
+// Replay arranges for every node of the graph to follow the schedule
+// recorded in the corresponding trace of ts, as returned by Trace.  Every
+// processor of the graph is wrapped as by Trace.Processor, and every input
+// not read from another node of the graph as by Trace.Source: those are
+// received whole, as replayed by the sending node.  Replay must be called
+// before Run.
+func (g *Graph) Replay(ts []*Trace) error {
+	if len(ts) != len(g.nodes) {
+		return fmt.Errorf("got %d traces for %d nodes", len(ts), len(g.nodes))
+	}
+	for i, n := range g.nodes {
+		nd, ok := n.(*node)
+		if !ok {
+			return fmt.Errorf("node %d cannot be replayed", i)
+		}
+		nd.mu.Lock()
+		nd.setProc(ts[i].Processor(nd.proc))
+		nd.wmu.Lock()
+		for j := range nd.iPkts {
+			if ns, ok := nd.iPkts[j].src.(*nodeSource); ok && g.has(ns.n) {
+				continue
+			}
+			nd.iPkts[j].src = ts[i].Source(nd.iPkts[j].src)
+		}
+		nd.wmu.Unlock()
+		nd.mu.Unlock()
+	}
+	return nil
+}
@@ -0,0 +1,104 @@
+// Copyright 2018 The ZikiChombo Authors. All rights reserved.  Use of this source
+// code is governed by a license that can be found in the License file.
+
+package plug
+
+import (
+	"reflect"
+	"testing"
+
+	"zikichombo.org/sound"
+	"zikichombo.org/sound/gen"
+	"zikichombo.org/sound/ops"
+)
+
+func runTraced(t *testing.T, replay []*Trace) []*Trace {
+	valve := sound.MonoCd()
+	g := &Graph{}
+	u0 := g.New(valve, valve, NewTimeStretch(0.7))
+	u1 := g.New(valve, valve, PassThrough)
+	u0.SetInput(ops.Limit(gen.Noise(), 10000))
+	u1.SetInput(u0.Output())
+	out := u1.Output()
+	if replay != nil {
+		if err := g.Replay(replay); err != nil {
+			t.Fatal(err)
+		}
+	}
+	ts := g.Trace()
+	errs := g.Run()
+	buf := make([]float64, 1000)
+	for {
+		if _, err := out.Receive(buf); err != nil {
+			break
+		}
+	}
+	for err := range errs {
+		t.Error(err)
+	}
+	return ts
+}
+
+func TestTraceReplay(t *testing.T) {
+	ts := runTraced(t, nil)
+	rs := runTraced(t, ts)
+	for i := range ts {
+		if len(ts[i].Blocks()) == 0 {
+			t.Errorf("node %d: no blocks recorded", i)
+		}
+		if !reflect.DeepEqual(ts[i].Blocks(), rs[i].Blocks()) {
+			t.Errorf("node %d: replay differs:\n%v\n%v", i, ts[i].Blocks(), rs[i].Blocks())
+		}
+	}
+}
+
+// cycler passes its input through in blocks whose size changes with
+// every call to NextFrames.
+type cycler struct {
+	i int
+}
+
+func (c *cycler) ChannelMode() ChannelMode { return FullMode }
+
+func (c *cycler) NextFrames() (int, int) {
+	c.i++
+	n := 100 * (1 + c.i%3)
+	return n, n
+}
+
+func (c *cycler) Process(dst, src *Block) error {
+	return PassThrough.Process(dst, src)
+}
+
+func runTracedSeq(t *testing.T, replay []*Trace) []*Trace {
+	valve := sound.MonoCd()
+	g := &Graph{}
+	u0 := g.New(valve, valve, NewTimeStretch(0.7))
+	u1 := g.New(valve, valve, &cycler{})
+	u0.SetInput(ops.Limit(gen.Noise(), 10000))
+	if err := g.Connect(u0, nil, u1, nil); err != nil {
+		t.Fatal(err)
+	}
+	u1.AddOutput(&countSink{Form: valve})
+	if replay != nil {
+		if err := g.Replay(replay); err != nil {
+			t.Fatal(err)
+		}
+	}
+	ts := g.Trace()
+	if err := g.RunSequential(); err != nil {
+		t.Fatal(err)
+	}
+	return ts
+}
+
+func TestTraceReplaySequential(t *testing.T) {
+	// the scheduler asks for the frames of a node on every pass.
+	ts := runTracedSeq(t, nil)
+	rs := runTracedSeq(t, ts)
+	for i := range ts {
+		if !reflect.DeepEqual(ts[i].Blocks(), rs[i].Blocks()) {
+			t.Errorf("node %d: replay differs:\n%v\n%v", i, ts[i].Blocks(), rs[i].Blocks())
+		}
+	}
+}
@@ -0,0 +1,412 @@
+// Copyright 2018 The ZikiChombo Authors. All rights reserved.  Use of this source
+// code is governed by a license that can be found in the License file.
+
+package plug
+
+import (
+	"bufio"
+	"crypto/subtle"
+	"crypto/tls"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net"
+	"sync"
+	"time"
+
+	"zikichombo.org/sound"
+	"zikichombo.org/sound/freq"
+)
+
+// transportMagic starts the handshake of a transport connection.
+const transportMagic = "plug/1"
+
+const (
+	// transportMaxChannels bounds the channels of a stream.
+	transportMaxChannels = 256
+	// transportMaxSamples bounds the samples of a message, so that a peer
+	// cannot make a source allocate more.  Sinks split larger sends.
+	transportMaxSamples = 1 << 16
+)
+
+// DefaultHandshakeTimeout is the time allowed for the handshake of a
+// transport connection when TransportConfig.HandshakeTimeout is 0.
+const DefaultHandshakeTimeout = 10 * time.Second
+
+// ErrUnauthorized is returned by Dial when the listener refuses the token or
+// the stream.
+var ErrUnauthorized = errors.New("plug: transport unauthorized")
+
+// TransportConfig configures the security of a network transport, which
+// carries a stream of audio from a sink on one machine to a source on
+// another over TCP.
+type TransportConfig struct {
+	// TLS, if non-nil, encrypts the connection.  Listeners need a
+	// certificate, and dialers a ServerName or RootCAs as usual.  If nil,
+	// the connection, including the Token, is sent in cleartext, which is
+	// only suitable on trusted networks such as the loopback interface.
+	TLS *tls.Config
+
+	// Token is sent by the dialer to authenticate.  It is sent in cleartext
+	// unless TLS is set.
+	Token string
+
+	// Auth, for listeners, authorizes a stream given its identifier and
+	// token.  If nil, the listener accepts streams whose token is Token,
+	// which must then not be empty.
+	Auth func(stream, token string) bool
+
+	// HandshakeTimeout bounds the time taken to connect and handshake.  If
+	// 0, DefaultHandshakeTimeout is used.
+	HandshakeTimeout time.Duration
+}
+
+// deadline returns the deadline of a handshake starting now.
+func (cfg *TransportConfig) deadline() time.Time {
+	d := cfg.HandshakeTimeout
+	if d == 0 {
+		d = DefaultHandshakeTimeout
+	}
+	return time.Now().Add(d)
+}
+
+// authorize checks the stream and token of a handshake.
+func (cfg *TransportConfig) authorize(stream, token string) bool {
+	if cfg.Auth != nil {
+		return cfg.Auth(stream, token)
+	}
+	return subtle.ConstantTimeCompare([]byte(token), []byte(cfg.Token)) == 1
+}
+
+// Dial connects to the listener at addr and returns a sink sending audio
+// of form f to it under the stream identifier stream.
+func Dial(addr, stream string, f sound.Form, cfg TransportConfig) (sound.Sink, error) {
+	if f.Channels() > transportMaxChannels {
+		return nil, fmt.Errorf("plug: %d channels exceed the transport's %d", f.Channels(), transportMaxChannels)
+	}
+	dl := cfg.deadline()
+	d := &net.Dialer{Deadline: dl}
+	var c net.Conn
+	var err error
+	if cfg.TLS != nil {
+		c, err = tls.DialWithDialer(d, "tcp", addr, cfg.TLS)
+	} else {
+		c, err = d.Dial("tcp", addr)
+	}
+	if err != nil {
+		return nil, err
+	}
+	c.SetDeadline(dl)
+	w := bufio.NewWriter(c)
+	w.WriteString(transportMagic)
+	writeString(w, stream)
+	writeString(w, cfg.Token)
+	binary.Write(w, binary.BigEndian, uint32(f.Channels()))
+	binary.Write(w, binary.BigEndian, int64(f.SampleRate()))
+	if err := w.Flush(); err != nil {
+		c.Close()
+		return nil, err
+	}
+	var ack [1]byte
+	if _, err := io.ReadFull(c, ack[:]); err != nil {
+		c.Close()
+		return nil, err
+	}
+	if ack[0] != 1 {
+		c.Close()
+		return nil, ErrUnauthorized
+	}
+	c.SetDeadline(time.Time{})
+	return &RemoteSink{Form: f, c: c, w: w}, nil
+}
+
+// RemoteSink is a sound.Sink sending audio over a transport connection.
+type RemoteSink struct {
+	sound.Form
+	c   net.Conn
+	w   *bufio.Writer
+	buf []byte
+}
+
+// Send sends src, which is in the channel-planar layout of sound.Sink.
+func (s *RemoteSink) Send(src []float64) error {
+	nC := s.Channels()
+	if len(src)%nC != 0 {
+		return sound.ErrChannelAlignment
+	}
+	frms := len(src) / nC
+	max := transportMaxSamples / nC
+	for off := 0; off < frms; off += max {
+		n := frms - off
+		if n > max {
+			n = max
+		}
+		if err := s.send(src, frms, off, n); err != nil {
+			return err
+		}
+	}
+	return s.w.Flush()
+}
+
+// send writes a message of the n frames of src, of frms frames, starting
+// at frame off.
+func (s *RemoteSink) send(src []float64, frms, off, n int) error {
+	nC := s.Channels()
+	if cap(s.buf) < 4+8*n*nC {
+		s.buf = make([]byte, 4+8*n*nC)
+	}
+	buf := s.buf[:4+8*n*nC]
+	binary.BigEndian.PutUint32(buf, uint32(n))
+	i := 4
+	for c := 0; c < nC; c++ {
+		for _, v := range src[c*frms+off : c*frms+off+n] {
+			binary.BigEndian.PutUint64(buf[i:], math.Float64bits(v))
+			i += 8
+		}
+	}
+	_, err := s.w.Write(buf)
+	return err
+}
+
+// Close closes the connection, ending the stream at the remote source.
+func (s *RemoteSink) Close() error {
+	return s.c.Close()
+}
+
+// Listener accepts transport connections.  Connections are accepted and
+// their handshakes run concurrently, so that a peer which does not
+// complete its handshake does not delay others.
+type Listener struct {
+	l    net.Listener
+	cfg  TransportConfig
+	srcs chan *RemoteSource
+	done chan struct{} // closed by Close
+	dead chan struct{} // closed when accepting fails, with err
+	err  error
+	once sync.Once
+}
+
+// Listen listens for transport connections on addr.  cfg must give an
+// Auth function or a non-empty Token.
+func Listen(addr string, cfg TransportConfig) (*Listener, error) {
+	if cfg.Auth == nil && cfg.Token == "" {
+		return nil, errors.New("plug: transport listener needs a Token or Auth")
+	}
+	var l net.Listener
+	var err error
+	if cfg.TLS != nil {
+		l, err = tls.Listen("tcp", addr, cfg.TLS)
+	} else {
+		l, err = net.Listen("tcp", addr)
+	}
+	if err != nil {
+		return nil, err
+	}
+	res := &Listener{
+		l:    l,
+		cfg:  cfg,
+		srcs: make(chan *RemoteSource),
+		done: make(chan struct{}),
+		dead: make(chan struct{})}
+	go res.serve()
+	return res, nil
+}
+
+// serve accepts connections, handshaking each in its own goroutine.
+func (l *Listener) serve() {
+	for {
+		c, err := l.l.Accept()
+		if err != nil {
+			l.err = err
+			close(l.dead)
+			return
+		}
+		go func() {
+			s, err := l.handshake(c)
+			if err != nil {
+				c.Close()
+				return
+			}
+			select {
+			case l.srcs <- s:
+			case <-l.done:
+				s.Close()
+			}
+		}()
+	}
+}
+
+// Addr returns the address of the listener.
+func (l *Listener) Addr() net.Addr {
+	return l.l.Addr()
+}
+
+// Close closes the listener.  Accepted sources remain open.
+func (l *Listener) Close() error {
+	l.once.Do(func() { close(l.done) })
+	return l.l.Close()
+}
+
+// Accept waits for an authorized connection and returns a source of its
+// audio.  Connections failing the handshake or authorization, or not
+// completing it within the handshake timeout, are refused and closed, and
+// Accept continues waiting.
+func (l *Listener) Accept() (*RemoteSource, error) {
+	select {
+	case s := <-l.srcs:
+		return s, nil
+	case <-l.dead:
+		return nil, l.err
+	}
+}
+
+// handshake reads and authorizes the handshake of c.
+func (l *Listener) handshake(c net.Conn) (*RemoteSource, error) {
+	c.SetDeadline(l.cfg.deadline())
+	r := bufio.NewReader(c)
+	magic := make([]byte, len(transportMagic))
+	if _, err := io.ReadFull(r, magic); err != nil {
+		return nil, err
+	}
+	if string(magic) != transportMagic {
+		return nil, fmt.Errorf("plug: bad transport magic %q", magic)
+	}
+	stream, err := readString(r)
+	if err != nil {
+		return nil, err
+	}
+	token, err := readString(r)
+	if err != nil {
+		return nil, err
+	}
+	var nC uint32
+	var sr int64
+	if err := binary.Read(r, binary.BigEndian, &nC); err != nil {
+		return nil, err
+	}
+	if err := binary.Read(r, binary.BigEndian, &sr); err != nil {
+		return nil, err
+	}
+	if nC == 0 || nC > transportMaxChannels || !l.cfg.authorize(stream, token) {
+		c.Write([]byte{0})
+		return nil, ErrUnauthorized
+	}
+	if _, err := c.Write([]byte{1}); err != nil {
+		return nil, err
+	}
+	c.SetDeadline(time.Time{})
+	return &RemoteSource{
+		Form:   sound.NewForm(freq.T(sr), int(nC)),
+		stream: stream,
+		c:      c,
+		r:      r}, nil
+}
+
+// RemoteSource is a sound.Source receiving the audio sent by a
+// RemoteSink.
+type RemoteSource struct {
+	sound.Form
+	stream string
+	c      net.Conn
+	r      *bufio.Reader
+	buf    []byte    // the last message
+	pend   []float64 // pending channel-planar frames of the last message
+	off    int       // frames of pend already received
+}
+
+// Stream returns the identifier of the stream.
+func (s *RemoteSource) Stream() string {
+	return s.stream
+}
+
+// Receive receives audio into dst, in the channel-planar layout of
+// sound.Source.
+func (s *RemoteSource) Receive(dst []float64) (int, error) {
+	nC := s.Channels()
+	if len(dst)%nC != 0 {
+		return 0, sound.ErrChannelAlignment
+	}
+	frms := len(dst) / nC
+	got := 0
+	for got < frms {
+		if s.pend == nil || s.off == len(s.pend)/nC {
+			if err := s.next(); err != nil {
+				if got == 0 {
+					return 0, err
+				}
+				for c := 1; c < nC; c++ {
+					copy(dst[c*got:(c+1)*got], dst[c*frms:c*frms+got])
+				}
+				return got, nil
+			}
+			continue
+		}
+		pF := len(s.pend) / nC
+		n := pF - s.off
+		if n > frms-got {
+			n = frms - got
+		}
+		for c := 0; c < nC; c++ {
+			copy(dst[c*frms+got:c*frms+got+n], s.pend[c*pF+s.off:c*pF+s.off+n])
+		}
+		s.off += n
+		got += n
+	}
+	return got, nil
+}
+
+// next reads the next message into s.pend.
+func (s *RemoteSource) next() error {
+	var hdr [4]byte
+	if _, err := io.ReadFull(s.r, hdr[:]); err != nil {
+		if err == io.ErrUnexpectedEOF {
+			return io.EOF
+		}
+		return err
+	}
+	frms := binary.BigEndian.Uint32(hdr[:])
+	if frms > uint32(transportMaxSamples/s.Channels()) {
+		return fmt.Errorf("plug: transport message of %d frames too long", frms)
+	}
+	n := int(frms) * s.Channels()
+	if cap(s.buf) < 8*n {
+		s.buf = make([]byte, 8*n)
+	}
+	buf := s.buf[:8*n]
+	if _, err := io.ReadFull(s.r, buf); err != nil {
+		return err
+	}
+	if cap(s.pend) < n {
+		s.pend = make([]float64, n)
+	}
+	s.pend = s.pend[:n]
+	for i := range s.pend {
+		s.pend[i] = math.Float64frombits(binary.BigEndian.Uint64(buf[8*i:]))
+	}
+	s.off = 0
+	return nil
+}
+
+// Close closes the connection.
+func (s *RemoteSource) Close() error {
+	return s.c.Close()
+}
+
+func writeString(w *bufio.Writer, s string) {
+	binary.Write(w, binary.BigEndian, uint16(len(s)))
+	w.WriteString(s)
+}
+
+func readString(r *bufio.Reader) (string, error) {
+	var n uint16
+	if err := binary.Read(r, binary.BigEndian, &n); err != nil {
+		return "", err
+	}
+	b := make([]byte, n)
+	if _, err := io.ReadFull(r, b); err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
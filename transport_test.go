@@ -0,0 +1,148 @@
+// Copyright 2018 The ZikiChombo Authors. All rights reserved.  Use of this source
+// code is governed by a license that can be found in the License file.
+
+package plug
+
+import (
+	"encoding/binary"
+	"io"
+	"math"
+	"net"
+	"testing"
+	"time"
+
+	"zikichombo.org/sound"
+)
+
+func TestTransport(t *testing.T) {
+	l, err := Listen("127.0.0.1:0", TransportConfig{Token: "secret"})
+	if err != nil {
+		t.Skip(err)
+	}
+	defer l.Close()
+	srcs := make(chan *RemoteSource, 1)
+	go func() {
+		s, err := l.Accept()
+		if err == nil {
+			srcs <- s
+		}
+	}()
+	if _, err := Dial(l.Addr().String(), "a", sound.StereoCd(), TransportConfig{Token: "wrong"}); err != ErrUnauthorized {
+		t.Fatalf("got %v not %v", err, ErrUnauthorized)
+	}
+	snk, err := Dial(l.Addr().String(), "b", sound.StereoCd(), TransportConfig{Token: "secret"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	src := <-srcs
+	if src.Stream() != "b" || src.Channels() != 2 {
+		t.Fatalf("got stream %q channels %d", src.Stream(), src.Channels())
+	}
+	go func() {
+		snk.Send([]float64{1, 2, 3, 4, 5, 6})
+		snk.Send([]float64{7, 8})
+		snk.Close()
+	}()
+	d := make([]float64, 8)
+	n, err := src.Receive(d)
+	if err != nil || n != 4 {
+		t.Fatalf("got %d, %v", n, err)
+	}
+	exp := []float64{1, 2, 3, 7, 4, 5, 6, 8}
+	for i := range exp {
+		if d[i] != exp[i] {
+			t.Fatalf("got %v not %v", d, exp)
+		}
+	}
+	if _, err := src.Receive(d); err != io.EOF {
+		t.Fatalf("got %v not EOF", err)
+	}
+}
+
+func TestTransportStalledHandshake(t *testing.T) {
+	l, err := Listen("127.0.0.1:0", TransportConfig{Token: "secret", HandshakeTimeout: time.Second})
+	if err != nil {
+		t.Skip(err)
+	}
+	defer l.Close()
+	// a peer which connects and sends nothing.
+	stalled, err := net.Dial("tcp", l.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stalled.Close()
+	go func() {
+		snk, err := Dial(l.Addr().String(), "b", sound.MonoCd(), TransportConfig{Token: "secret"})
+		if err == nil {
+			snk.Close()
+		}
+	}()
+	srcs := make(chan *RemoteSource, 1)
+	go func() {
+		s, err := l.Accept()
+		if err == nil {
+			srcs <- s
+		}
+	}()
+	select {
+	case src := <-srcs:
+		src.Close()
+	case <-time.After(500 * time.Millisecond):
+		t.Fatal("accepting blocked by a stalled handshake")
+	}
+	stalled.SetReadDeadline(time.Now().Add(5 * time.Second))
+	if _, err := stalled.Read(make([]byte, 1)); err != io.EOF {
+		t.Errorf("stalled connection got %v not EOF", err)
+	}
+}
+
+func TestTransportLongMessage(t *testing.T) {
+	l, err := Listen("127.0.0.1:0", TransportConfig{Token: "secret"})
+	if err != nil {
+		t.Skip(err)
+	}
+	defer l.Close()
+	srcs := make(chan *RemoteSource, 1)
+	go func() {
+		s, err := l.Accept()
+		if err == nil {
+			srcs <- s
+		}
+	}()
+	snk, err := Dial(l.Addr().String(), "b", sound.MonoCd(), TransportConfig{Token: "secret"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	src := <-srcs
+	defer src.Close()
+	// sends longer than a message are split.
+	N := 3*transportMaxSamples + 5
+	sent := make(chan error, 1)
+	go func() { sent <- snk.Send(make([]float64, N)) }()
+	d := make([]float64, N)
+	got := 0
+	for got < N {
+		n, err := src.Receive(d[got:])
+		if err != nil {
+			t.Fatal(err)
+		}
+		got += n
+	}
+	if err := <-sent; err != nil {
+		t.Fatal(err)
+	}
+	// a peer announcing a message too long to allocate.
+	rs := snk.(*RemoteSink)
+	binary.Write(rs.w, binary.BigEndian, uint32(math.MaxUint32))
+	rs.w.Flush()
+	if _, err := src.Receive(d); err == nil || err == io.EOF {
+		t.Errorf("got %v receiving a message too long", err)
+	}
+	snk.Close()
+}
+
+func TestTransportNoToken(t *testing.T) {
+	if _, err := Listen("127.0.0.1:0", TransportConfig{}); err == nil {
+		t.Error("listened without Token or Auth")
+	}
+}
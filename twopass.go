@@ -0,0 +1,125 @@
+// Copyright 2018 The ZikiChombo Authors. All rights reserved.  Use of this source
+// code is governed by a license that can be found in the License file.
+
+package plug
+
+import (
+	"io"
+	"sync"
+
+	"zikichombo.org/sound"
+)
+
+// PassResults carries named results from the analysis pass to the render
+// pass of a two pass execution.
+type PassResults struct {
+	mu sync.Mutex
+	m  map[string]float64
+}
+
+// Set sets the result name to v.
+func (r *PassResults) Set(name string, v float64) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.m == nil {
+		r.m = make(map[string]float64)
+	}
+	r.m[name] = v
+}
+
+// Get returns the result name, and whether it has been set.
+func (r *PassResults) Get(name string) (float64, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	v, ok := r.m[name]
+	return v, ok
+}
+
+// Pass describes one pass of a two pass execution.
+type Pass struct {
+	// Build wires src, the input from its start, into the graph g.  Build
+	// may return outputs of g which are to be read and discarded by the
+	// pass, such as the output of an analyzer.  Results of a previous pass
+	// are available in r.
+	Build func(g *Graph, src sound.Source, r *PassResults) ([]sound.Source, error)
+
+	// Done, if non-nil, is called once the graph has run, for example to
+	// record the findings of analyzers in r.
+	Done func(r *PassResults) error
+}
+
+// RunTwoPass runs an analysis pass and then a render pass over the same
+// input, which open provides from its start on each call.  The results
+// recorded in the analysis pass parameterize the render pass, which is the
+// pattern of, for example, loudness normalization of a file: measure first,
+// then apply the gain.
+//
+// RunTwoPass returns the results of both passes.
+func RunTwoPass(open func() (sound.Source, error), analysis, render Pass) (*PassResults, error) {
+	r := &PassResults{}
+	for _, p := range []Pass{analysis, render} {
+		if err := runPass(open, p, r); err != nil {
+			return nil, err
+		}
+	}
+	return r, nil
+}
+
+func runPass(open func() (sound.Source, error), p Pass, r *PassResults) error {
+	src, err := open()
+	if err != nil {
+		return err
+	}
+	g := &Graph{}
+	outs, err := p.Build(g, src, r)
+	if err != nil {
+		src.Close()
+		return err
+	}
+	errs := g.Run()
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	var first error
+	for _, out := range outs {
+		wg.Add(1)
+		go func(out sound.Source) {
+			defer wg.Done()
+			if err := discard(out); err != nil {
+				mu.Lock()
+				if first == nil {
+					first = err
+				}
+				mu.Unlock()
+			}
+		}(out)
+	}
+	for e := range errs {
+		mu.Lock()
+		if first == nil {
+			first = e
+		}
+		mu.Unlock()
+	}
+	wg.Wait()
+	if first != nil {
+		return first
+	}
+	if p.Done != nil {
+		return p.Done(r)
+	}
+	return nil
+}
+
+// discard reads src until it ends, returning any error other than io.EOF.
+func discard(src sound.Source) error {
+	buf := make([]float64, DefaultOutFrames*src.Channels())
+	for {
+		_, err := src.Receive(buf)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
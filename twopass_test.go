@@ -0,0 +1,82 @@
+// Copyright 2018 The ZikiChombo Authors. All rights reserved.  Use of this source
+// code is governed by a license that can be found in the License file.
+
+package plug
+
+import (
+	"errors"
+	"math"
+	"testing"
+
+	"zikichombo.org/sound"
+)
+
+func TestRunTwoPass(t *testing.T) {
+	mono := sound.MonoCd()
+	opened := 0
+	open := func() (sound.Source, error) {
+		opened++
+		return &ramp{Form: mono, n: 5000}, nil
+	}
+	peak := 0.0
+	analysis := Pass{
+		Build: func(g *Graph, src sound.Source, r *PassResults) ([]sound.Source, error) {
+			u := g.New(mono, mono, NewProcessor(MonoMode, func(dst, src *Block) error {
+				for i, v := range src.Samples[:src.Frames] {
+					peak = math.Max(peak, math.Abs(v))
+					dst.Samples[i] = v
+				}
+				dst.Frames = src.Frames
+				return nil
+			}))
+			u.SetInput(src)
+			return []sound.Source{u.Output()}, nil
+		},
+		Done: func(r *PassResults) error {
+			r.Set("peak", peak)
+			return nil
+		},
+	}
+	rec := &record{Form: mono}
+	render := Pass{
+		Build: func(g *Graph, src sound.Source, r *PassResults) ([]sound.Source, error) {
+			pk, ok := r.Get("peak")
+			if !ok {
+				return nil, errors.New("no peak")
+			}
+			u := g.New(mono, mono, NewProcessor(MonoMode, func(dst, src *Block) error {
+				for i, v := range src.Samples[:src.Frames] {
+					dst.Samples[i] = v / pk
+				}
+				dst.Frames = src.Frames
+				return nil
+			}))
+			u.SetInput(src)
+			u.AddOutput(rec)
+			return nil, nil
+		},
+	}
+	r, err := RunTwoPass(open, analysis, render)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if opened != 2 {
+		t.Errorf("opened the input %d times", opened)
+	}
+	if pk, ok := r.Get("peak"); !ok || pk != 4999 {
+		t.Errorf("got peak %g, %t", pk, ok)
+	}
+	if len(rec.chs[0]) != 5000 || rec.chs[0][4999] != 1 {
+		t.Fatalf("rendered %d frames", len(rec.chs[0]))
+	}
+
+	// a failing analysis ends the run before rendering.
+	opened = 0
+	fail := Pass{Done: func(r *PassResults) error { return ErrInjected }, Build: analysis.Build}
+	if _, err := RunTwoPass(open, fail, render); err != ErrInjected {
+		t.Errorf("got %v not %v", err, ErrInjected)
+	}
+	if opened != 1 {
+		t.Errorf("opened the input %d times", opened)
+	}
+}
@@ -0,0 +1,109 @@
+// Copyright 2018 The ZikiChombo Authors. All rights reserved.  Use of this source
+// code is governed by a license that can be found in the License file.
+
+//go:build go1.18
+
+package plug
+
+import (
+	"fmt"
+
+	"zikichombo.org/sound"
+	"zikichombo.org/sound/freq"
+)
+
+// FormType is implemented by types which give a form statically, so that
+// Ports and Nodes may carry their forms in their types, and wiring them
+// incompatibly fails to compile rather than at Run time.
+//
+// Applications may define their own FormTypes, for example
+//
+//	type Quad96k struct{}
+//
+//	func (Quad96k) Form() sound.Form { return sound.NewForm(96000*freq.Hertz, 4) }
+type FormType interface {
+	Form() sound.Form
+}
+
+// Common FormTypes.
+type (
+	Mono44k   struct{}
+	Stereo44k struct{}
+	Mono48k   struct{}
+	Stereo48k struct{}
+)
+
+func (Mono44k) Form() sound.Form   { return sound.MonoCd() }
+func (Stereo44k) Form() sound.Form { return sound.StereoCd() }
+func (Mono48k) Form() sound.Form   { return sound.NewForm(48000*freq.Hertz, 1) }
+func (Stereo48k) Form() sound.Form { return sound.NewForm(48000*freq.Hertz, 2) }
+
+// Port is a sound.Source whose form is given by the type F.
+type Port[F FormType] struct {
+	sound.Source
+}
+
+// NewPort checks at run time that s has the form given by F, returning
+// it as a Port if so.  NewPort lets sources of the dynamic API be wired
+// to Nodes.
+func NewPort[F FormType](s sound.Source) (Port[F], error) {
+	if err := ckFormType[F](s, "port"); err != nil {
+		return Port[F]{}, err
+	}
+	return Port[F]{Source: s}, nil
+}
+
+// Node is an IO whose input and output forms are given by the types I and
+// O.  The IO remains available for the dynamic API.
+type Node[I, O FormType] struct {
+	IO
+}
+
+// NewNode creates a Node with processor p in g, or outside of any graph
+// if g is nil.
+func NewNode[I, O FormType](g *Graph, p Processor) Node[I, O] {
+	var i I
+	var o O
+	if g == nil {
+		return Node[I, O]{IO: New(i.Form(), o.Form(), p)}
+	}
+	return Node[I, O]{IO: g.New(i.Form(), o.Form(), p)}
+}
+
+// AsNode checks at run time that io has the input and output forms given
+// by I and O, returning it as a Node if so.
+func AsNode[I, O FormType](io IO) (Node[I, O], error) {
+	if err := ckFormType[I](io.InForm(), "input"); err != nil {
+		return Node[I, O]{}, err
+	}
+	if err := ckFormType[O](io.OutForm(), "output"); err != nil {
+		return Node[I, O]{}, err
+	}
+	return Node[I, O]{IO: io}, nil
+}
+
+// In sets the input of n to p.
+func (n Node[I, O]) In(p Port[I]) error {
+	return n.IO.SetInput(p.Source)
+}
+
+// Out returns the output of n as a Port.
+func (n Node[I, O]) Out() Port[O] {
+	return Port[O]{Source: n.IO.Output()}
+}
+
+// Connect connects the output of from to the input of to in g, whose
+// forms are the same by construction.
+func Connect[A, B, C FormType](g *Graph, from Node[A, B], to Node[B, C]) error {
+	return g.Connect(from.IO, nil, to.IO, nil)
+}
+
+func ckFormType[F FormType](f sound.Form, what string) error {
+	var t F
+	want := t.Form()
+	if f.SampleRate() != want.SampleRate() || f.Channels() != want.Channels() {
+		return fmt.Errorf("%s form mismatch: got %d channels at %s not %d channels at %s",
+			what, f.Channels(), f.SampleRate(), want.Channels(), want.SampleRate())
+	}
+	return nil
+}
@@ -0,0 +1,42 @@
+// Copyright 2018 The ZikiChombo Authors. All rights reserved.  Use of this source
+// code is governed by a license that can be found in the License file.
+
+//go:build go1.18
+
+package plug
+
+import (
+	"testing"
+
+	"zikichombo.org/sound"
+)
+
+func TestTypedWiring(t *testing.T) {
+	g := &Graph{}
+	a := NewNode[Mono44k, Mono44k](g, PassThrough)
+	b := NewNode[Mono44k, Mono44k](g, PassThrough)
+	in, err := NewPort[Mono44k](&ramp{Form: sound.MonoCd(), n: 3000})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := a.In(in); err != nil {
+		t.Fatal(err)
+	}
+	if err := Connect(g, a, b); err != nil {
+		t.Fatal(err)
+	}
+	snk := &capture{Form: sound.MonoCd()}
+	b.AddOutput(snk)
+	for err := range g.Run() {
+		t.Fatal(err)
+	}
+	if snk.frames != 3000 {
+		t.Errorf("got %d frames", snk.frames)
+	}
+	if _, err := NewPort[Stereo48k](&ramp{Form: sound.MonoCd()}); err == nil {
+		t.Errorf("mono 44.1kHz source accepted as stereo 48kHz port")
+	}
+	if _, err := AsNode[Mono44k, Stereo44k](New(sound.MonoCd(), sound.MonoCd(), PassThrough)); err == nil {
+		t.Errorf("mono node accepted as stereo node")
+	}
+}
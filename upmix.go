@@ -0,0 +1,51 @@
+// Copyright 2018 The ZikiChombo Authors. All rights reserved.  Use of this source
+// code is governed by a license that can be found in the License file.
+
+package plug
+
+import (
+	"fmt"
+	"math"
+)
+
+// Duplicate is a mono upmixer, copying its mono input to every output
+// channel.
+var Duplicate = NewProcessor(FullMode, func(dst, src *Block) error {
+	return upmix(dst, src, 1)
+})
+
+// Spread is a constant power mono upmixer, copying its mono input to
+// every one of N output channels at gain 1/sqrt(N), so that the total
+// power is that of the input.  For stereo, each channel is 3dB down.
+var Spread = NewProcessor(FullMode, func(dst, src *Block) error {
+	return upmix(dst, src, 1/math.Sqrt(float64(dst.Channels)))
+})
+
+func upmix(dst, src *Block, g float64) error {
+	if src.Channels != 1 {
+		return fmt.Errorf("cannot upmix %d channel src", src.Channels)
+	}
+	N := src.Frames
+	for c := 0; c < dst.Channels; c++ {
+		d := dst.Samples[c*N : (c+1)*N]
+		for f, v := range src.Samples[:N] {
+			d[f] = g * v
+		}
+	}
+	dst.Frames = N
+	return nil
+}
+
+// ToSurround is a stereo to 5.1 upmixer using a simple passive matrix.
+// The output channels are, in order, front left, front right, centre,
+// LFE, surround left and surround right.  The front channels are the
+// input, the centre is the average of the input channels, and the
+// surrounds are half their difference, in opposite phase.  The LFE channel
+// is silent.
+var ToSurround = Matrix([][]float64{
+	{1, 0},
+	{0, 1},
+	{0.5, 0.5},
+	{0, 0},
+	{0.5, -0.5},
+	{-0.5, 0.5}})
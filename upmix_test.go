@@ -0,0 +1,49 @@
+// Copyright 2018 The ZikiChombo Authors. All rights reserved.  Use of this source
+// code is governed by a license that can be found in the License file.
+
+package plug
+
+import (
+	"fmt"
+	"math"
+	"testing"
+
+	"zikichombo.org/sound"
+)
+
+func TestUpmix(t *testing.T) {
+	mono := sound.MonoCd()
+	stereo := sound.StereoCd()
+	u := New(mono, stereo, Spread)
+	u.SetInput(&ramp{Form: mono, n: 100})
+	snk := &capture{Form: stereo}
+	u.AddOutput(snk)
+	if err := u.Run(); err != nil {
+		t.Fatal(err)
+	}
+	// capture mixes by averaging the channels.
+	if snk.frames != 100 || math.Abs(snk.mix[99]-99*math.Sqrt(0.5)) > 1e-9 {
+		t.Errorf("spread: got %d frames, %f", snk.frames, snk.mix[99])
+	}
+
+	src := &Block{Channels: 1, Frames: 2, Samples: []float64{1, 2}}
+	dst := &Block{Channels: 3, Frames: 2, Samples: make([]float64, 6)}
+	if err := Duplicate.Process(dst, src); err != nil {
+		t.Fatal(err)
+	}
+	if exp := []float64{1, 2, 1, 2, 1, 2}; fmt.Sprint(dst.Samples) != fmt.Sprint(exp) {
+		t.Errorf("duplicate: got %v not %v", dst.Samples, exp)
+	}
+	if err := Duplicate.Process(dst, &Block{Channels: 2, Frames: 1, Samples: make([]float64, 2)}); err == nil {
+		t.Errorf("duplicated stereo")
+	}
+
+	src = &Block{Channels: 2, Frames: 1, Samples: []float64{1, 0.5}}
+	dst = &Block{Channels: 6, Frames: 1, Samples: make([]float64, 6)}
+	if err := ToSurround.Process(dst, src); err != nil {
+		t.Fatal(err)
+	}
+	if exp := []float64{1, 0.5, 0.75, 0, 0.25, -0.25}; fmt.Sprint(dst.Samples) != fmt.Sprint(exp) {
+		t.Errorf("surround: got %v not %v", dst.Samples, exp)
+	}
+}
@@ -0,0 +1,79 @@
+// Copyright 2018 The ZikiChombo Authors. All rights reserved.  Use of this source
+// code is governed by a license that can be found in the License file.
+
+package plug
+
+import "fmt"
+
+// Validate implements ProcessorController.
+func (n *node) Validate() error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if err := n.checkConns(); err != nil {
+		return err
+	}
+	return n.probe()
+}
+
+// probe checks the shape of the processor and, if it can be reset, calls
+// it once on a block of silence before resetting it.
+func (n *node) probe() error {
+	proc := n.proc
+	iFrms, oFrms := n.nextFrames()
+	if iFrms < 1 || oFrms < 0 {
+		return fmt.Errorf("invalid processor frames: %d in, %d out", iFrms, oFrms)
+	}
+	iC := n.iForm.Channels()
+	oC := n.oForm.Channels()
+	src := &Block{
+		SampleRate: n.iForm.SampleRate(),
+		Channels:   iC,
+		Frames:     iFrms,
+		Samples:    make([]float64, iC*iFrms)}
+	dst := &Block{
+		SampleRate: n.oForm.SampleRate(),
+		Channels:   oC,
+		Frames:     oFrms,
+		Samples:    make([]float64, oC*oFrms)}
+	switch proc.ChannelMode() {
+	case MonoMode:
+		if iC != oC {
+			return fmt.Errorf("mono mode processor cannot map %d input channels to %d output channels", iC, oC)
+		}
+		src.Channels, dst.Channels = 1, 1
+		src.Samples = src.Samples[:iFrms]
+		dst.Samples = dst.Samples[:oFrms]
+	case FullMode:
+	default:
+		return fmt.Errorf("invalid channel mode %d", proc.ChannelMode())
+	}
+	r, ok := proc.(Resetter)
+	if !ok {
+		return nil
+	}
+	err := proc.Process(dst, src)
+	r.Reset()
+	if err != nil {
+		return err
+	}
+	if dst.Frames < 0 || dst.Frames > oFrms {
+		return fmt.Errorf("processor produced %d frames, expected at most %d", dst.Frames, oFrms)
+	}
+	return nil
+}
+
+// Validate checks that all the nodes of the graph implementing
+// ProcessorController are valid as per ProcessorController.Validate,
+// returning the first error found.
+func (g *Graph) Validate() error {
+	for i, n := range g.nodes {
+		pc, ok := n.(ProcessorController)
+		if !ok {
+			continue
+		}
+		if err := pc.Validate(); err != nil {
+			return fmt.Errorf("node %d: %s", i, err)
+		}
+	}
+	return nil
+}
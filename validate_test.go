@@ -0,0 +1,66 @@
+// Copyright 2018 The ZikiChombo Authors. All rights reserved.  Use of this source
+// code is governed by a license that can be found in the License file.
+
+package plug
+
+import (
+	"testing"
+
+	"zikichombo.org/sound"
+	"zikichombo.org/sound/gen"
+	"zikichombo.org/sound/ops"
+)
+
+func TestIOValidate(t *testing.T) {
+	u0 := New(sound.StereoCd(), sound.MonoCd(), PassThrough)
+	if err := u0.Validate(); err == nil {
+		t.Errorf("unconnected node validated")
+	}
+	u0.SetInput(ops.Limit(gen.Noise(), 44100), 0)
+	u0.SetInput(ops.Limit(gen.Noise(), 44100), 1)
+	u0.Output()
+	if err := u0.Validate(); err == nil {
+		t.Errorf("mono mode stereo to mono node validated")
+	}
+	u1 := New(sound.StereoCd(), sound.MonoCd(), ToMono)
+	u1.SetInput(u0.Output())
+	u1.Output()
+	if err := u1.Validate(); err != nil {
+		t.Error(err)
+	}
+}
+
+// procCount counts the blocks processed.
+type procCount struct {
+	Processor
+	n int
+}
+
+func (p *procCount) Process(dst, src *Block) error {
+	p.n++
+	return p.Processor.Process(dst, src)
+}
+
+func TestIOValidateState(t *testing.T) {
+	mono := sound.MonoCd()
+	p := &procCount{Processor: PassThrough}
+	u := New(mono, mono, p)
+	u.SetInput(ops.Limit(gen.Noise(), 44100))
+	u.Output()
+	if err := u.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	if p.n != 0 {
+		t.Errorf("processor without Reset called %d times", p.n)
+	}
+	r := &resetCount{Processor: &procCount{Processor: PassThrough}}
+	u = New(mono, mono, r)
+	u.SetInput(ops.Limit(gen.Noise(), 44100))
+	u.Output()
+	if err := u.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	if n := r.Processor.(*procCount).n; n != 1 || r.resets != 1 {
+		t.Errorf("resettable processor called %d times, reset %d times", n, r.resets)
+	}
+}
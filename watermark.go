@@ -0,0 +1,188 @@
+// Copyright 2018 The ZikiChombo Authors. All rights reserved.  Use of this source
+// code is governed by a license that can be found in the License file.
+
+package plug
+
+import (
+	"math"
+	"math/cmplx"
+	"sync"
+	"time"
+
+	"zikichombo.org/sound"
+)
+
+// length of the latency probe marker in frames, a power of 2.
+const markerLen = 4096
+
+// LatencyProbe measures the end-to-end latency of a processing chain,
+// including devices, by injecting a marker in its input and detecting it
+// in its output.
+//
+// The marker is a burst of low level pseudo-random noise, inaudible or
+// nearly so, added to channel 0 of the source returned by Source.  The sink
+// returned by Sink looks for the marker in its channel 0 by cross
+// correlation.  Both the source and the sink count frames from the start of
+// their respective streams.
+type LatencyProbe struct {
+	at        int64
+	amp       float64
+	threshold float64
+	marker    []float64
+	spec      []complex128
+
+	mu    sync.Mutex
+	found bool
+	lat   int64
+	rate  float64
+}
+
+// NewLatencyProbe creates a new LatencyProbe which injects a marker at
+// frame at of the source, with level db dBFS (for example -40).
+func NewLatencyProbe(at int64, db float64) *LatencyProbe {
+	res := &LatencyProbe{
+		at:        at,
+		amp:       math.Pow(10, db/20),
+		threshold: 0.2,
+		marker:    make([]float64, markerLen),
+		spec:      make([]complex128, 2*markerLen)}
+	// a fixed pseudo-random sequence of +/-1.
+	x := uint32(0x2545f491)
+	for i := range res.marker {
+		x ^= x << 13
+		x ^= x >> 17
+		x ^= x << 5
+		if x&1 == 0 {
+			res.marker[i] = 1
+		} else {
+			res.marker[i] = -1
+		}
+		res.spec[i] = complex(res.marker[i], 0)
+	}
+	fft(res.spec, false)
+	return res
+}
+
+// SetThreshold sets the detection threshold, the minimum normalized
+// correlation in (0..1] between the output and the marker.  Lower values
+// find the marker under louder program material at the risk of false
+// detections.  The default is 0.2.
+func (p *LatencyProbe) SetThreshold(v float64) {
+	p.threshold = v
+}
+
+// Latency returns the measured latency in frames, and whether the marker
+// has been detected.
+func (p *LatencyProbe) Latency() (int64, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.lat, p.found
+}
+
+// Duration returns the measured latency as a duration, and whether the
+// marker has been detected.  The duration is computed with the sample rate
+// of the sink.
+func (p *LatencyProbe) Duration() (time.Duration, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if !p.found {
+		return 0, false
+	}
+	return time.Duration(float64(p.lat) / p.rate * float64(time.Second)), true
+}
+
+// Source returns a source which reads from src and adds the marker.
+func (p *LatencyProbe) Source(src sound.Source) sound.Source {
+	return &markSrc{Source: src, p: p}
+}
+
+type markSrc struct {
+	sound.Source
+	p   *LatencyProbe
+	pos int64
+}
+
+func (s *markSrc) Receive(dst []float64) (int, error) {
+	n, err := s.Source.Receive(dst)
+	p := s.p
+	for i := 0; i < n; i++ {
+		j := s.pos + int64(i) - p.at
+		if j >= 0 && j < markerLen {
+			dst[i] += p.amp * p.marker[j]
+		}
+	}
+	s.pos += int64(n)
+	return n, err
+}
+
+// Sink returns a sink which looks for the marker in what is sent to it and
+// passes it on to snk.
+func (p *LatencyProbe) Sink(snk sound.Sink) sound.Sink {
+	p.mu.Lock()
+	p.rate = snk.SampleRate().Float64()
+	p.mu.Unlock()
+	return &markSnk{
+		Sink: snk,
+		p:    p,
+		x:    make([]complex128, 2*markerLen)}
+}
+
+type markSnk struct {
+	sound.Sink
+	p     *LatencyProbe
+	buf   []float64
+	start int64
+	x     []complex128
+	done  bool
+}
+
+func (s *markSnk) Send(src []float64) error {
+	if !s.done {
+		n := len(src) / s.Channels()
+		s.buf = append(s.buf, src[:n]...)
+		for !s.done && len(s.buf) >= 2*markerLen {
+			s.done = s.detect()
+			s.buf = s.buf[:copy(s.buf, s.buf[markerLen:])]
+			s.start += markerLen
+		}
+	}
+	return s.Sink.Send(src)
+}
+
+// detect looks for the marker starting in the first markerLen frames of
+// s.buf.
+func (s *markSnk) detect() bool {
+	p := s.p
+	L := markerLen
+	for i := range s.x {
+		s.x[i] = complex(s.buf[i], 0)
+	}
+	fft(s.x, false)
+	for i := range s.x {
+		s.x[i] *= cmplx.Conj(p.spec[i])
+	}
+	fft(s.x, true)
+	// energy of the window at each lag, by running sum.
+	e := 0.0
+	for _, v := range s.buf[:L] {
+		e += v * v
+	}
+	norm := math.Sqrt(float64(L))
+	for k := 0; k <= L; k++ {
+		if k > 0 {
+			e += s.buf[k+L-1]*s.buf[k+L-1] - s.buf[k-1]*s.buf[k-1]
+		}
+		if e <= 0 {
+			continue
+		}
+		c := real(s.x[k]) / (norm * math.Sqrt(e))
+		if c >= p.threshold {
+			p.mu.Lock()
+			p.found = true
+			p.lat = s.start + int64(k) - p.at
+			p.mu.Unlock()
+			return true
+		}
+	}
+	return false
+}
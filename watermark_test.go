@@ -0,0 +1,56 @@
+// Copyright 2018 The ZikiChombo Authors. All rights reserved.  Use of this source
+// code is governed by a license that can be found in the License file.
+
+package plug
+
+import (
+	"testing"
+	"time"
+
+	"zikichombo.org/sound"
+)
+
+func TestLatencyProbe(t *testing.T) {
+	mono := sound.MonoCd()
+	for _, e := range []struct {
+		name  string
+		hz    float64 // of the program, 0 for silence
+		db    float64
+		at    int64
+		found bool
+	}{
+		{"silence", 0, -40, 10000, true},
+		{"tone", 441, -6, 20000, true},
+		{"after the end", 0, -40, 60000, false},
+	} {
+		p := NewLatencyProbe(e.at, e.db)
+		u := New(mono, mono, &lag{delayLine: newDelayLine(1234), d: 1234})
+		u.SetInput(p.Source(&tone{Form: mono, f: e.hz, end: 50000}))
+		snk := &countSink{Form: mono}
+		u.AddOutput(p.Sink(snk))
+		if err := u.Run(); err != nil {
+			t.Fatal(err)
+		}
+		if snk.n != 50000 {
+			t.Errorf("%s: sink got %d frames not 50000", e.name, snk.n)
+		}
+		lat, ok := p.Latency()
+		if ok != e.found {
+			t.Errorf("%s: got found %t", e.name, ok)
+			continue
+		}
+		d, dok := p.Duration()
+		if dok != ok {
+			t.Errorf("%s: got found %t from Duration", e.name, dok)
+		}
+		if !ok {
+			continue
+		}
+		if lat != 1234 {
+			t.Errorf("%s: got latency %d not 1234", e.name, lat)
+		}
+		if exp := 1234 * time.Second / 44100; d-exp > time.Microsecond || exp-d > time.Microsecond {
+			t.Errorf("%s: got duration %s not %s", e.name, d, exp)
+		}
+	}
+}
@@ -0,0 +1,106 @@
+// Copyright 2018 The ZikiChombo Authors. All rights reserved.  Use of this source
+// code is governed by a license that can be found in the License file.
+
+package plug
+
+import (
+	"fmt"
+	"math"
+)
+
+type wetDry struct {
+	p     Processor
+	mix   float64
+	lines []*delayLine
+	dry   []float64
+}
+
+// WetDry creates a FullMode processor which mixes the output of p (wet) with
+// its input (dry).  mix gives the proportion of wet signal, from 0 (dry
+// only) to 1 (wet only), and is clamped to [0..1] as by Mix.SetWet.  If p implements LatencyReporter, the dry signal is
+// delayed accordingly so that it is aligned with the wet signal.
+//
+// p should not change the number of channels nor the number of frames.
+func WetDry(p Processor, mix float64) Processor {
+	return &wetDry{p: p, mix: clampMix(mix)}
+}
+
+func (w *wetDry) ChannelMode() ChannelMode {
+	return FullMode
+}
+
+func (w *wetDry) NextFrames() (int, int) {
+	return w.p.NextFrames()
+}
+
+func (w *wetDry) Latency() int {
+	return latency(w.p)
+}
+
+func (w *wetDry) Process(dst, src *Block) error {
+	if err := runFull(w.p, dst, src); err != nil {
+		return err
+	}
+	if dst.Channels != src.Channels || dst.Frames != src.Frames {
+		return fmt.Errorf("wet/dry: processor mapped %d frames of %d channels to %d frames of %d channels",
+			src.Frames, src.Channels, dst.Frames, dst.Channels)
+	}
+	L := latency(w.p)
+	for len(w.lines) < src.Channels {
+		w.lines = append(w.lines, newDelayLine(L))
+	}
+	N := src.Frames
+	w.dry = buffer(w.dry, 1, N)
+	for c := 0; c < src.Channels; c++ {
+		w.lines[c].run(w.dry, src.Samples[c*N:(c+1)*N])
+		wet := dst.Samples[c*N : (c+1)*N]
+		for i, d := range w.dry {
+			wet[i] = w.mix*wet[i] + (1-w.mix)*d
+		}
+	}
+	return nil
+}
+
+// Mix is a processor mixing the output of a processor with its input, like
+// WetDry, whose mix may be changed while running.  The proportion of wet
+// signal is the parameter "wet".
+type Mix struct {
+	ParamTable
+	wetDry
+}
+
+// WithMix wraps p in a Mix processor, making p a parallel (send style)
+// effect: the output of p is mixed with its input, delayed by the latency
+// of p, in the proportion wet, from 0 (dry only) to 1 (wet only).
+//
+// p should not change the number of channels nor the number of frames.
+func WithMix(p Processor, wet float64) *Mix {
+	m := &Mix{wetDry: wetDry{p: p}}
+	m.SetWet(wet)
+	m.Add(ParamInfo{Name: "wet", Max: 1, Default: m.mix}, m.Wet, m.SetWet)
+	return m
+}
+
+// SetWet sets the proportion of wet signal, which is clamped to [0..1].
+// It is meant to be called through Parameterized.Apply.
+func (m *Mix) SetWet(wet float64) {
+	m.mix = clampMix(wet)
+}
+
+func clampMix(v float64) float64 {
+	return math.Max(0, math.Min(1, v))
+}
+
+// Wet returns the proportion of wet signal.
+func (m *Mix) Wet() float64 {
+	return m.mix
+}
+
+// Reset implements Resetter, clearing the delayed dry signal and
+// resetting the wrapped processor if it implements Resetter.
+func (m *Mix) Reset() {
+	m.lines = nil
+	if r, ok := m.p.(Resetter); ok {
+		r.Reset()
+	}
+}
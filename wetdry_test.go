@@ -0,0 +1,108 @@
+// Copyright 2018 The ZikiChombo Authors. All rights reserved.  Use of this source
+// code is governed by a license that can be found in the License file.
+
+package plug
+
+import (
+	"testing"
+
+	"zikichombo.org/sound"
+)
+
+func TestWithMix(t *testing.T) {
+	mono := sound.MonoCd()
+	m := WithMix(&lag{delayLine: newDelayLine(100), d: 100}, 2)
+	if m.Wet() != 1 || m.Latency() != 100 {
+		t.Errorf("got wet %f, latency %d", m.Wet(), m.Latency())
+	}
+	triple := NewProcessor(FullMode, func(dst, src *Block) error {
+		for i, v := range src.Samples[:src.Frames] {
+			dst.Samples[i] = 3 * v
+		}
+		dst.Frames = src.Frames
+		return nil
+	})
+	for _, e := range []struct {
+		p    Processor
+		i    int
+		want float64
+	}{{&lag{delayLine: newDelayLine(100), d: 100}, 2999, 2899}, {triple, 2999, 1.5 * 2999}} {
+		m := WithMix(e.p, 1)
+		u := New(mono, mono, m)
+		u.Apply("wet", 0.25, m.SetWet)
+		u.SetInput(&ramp{Form: mono, n: 3000})
+		snk := &capture{Form: mono}
+		u.AddOutput(snk)
+		if err := u.Run(); err != nil {
+			t.Fatal(err)
+		}
+		if snk.mix[e.i] != e.want {
+			t.Errorf("got %f not %f", snk.mix[e.i], e.want)
+		}
+	}
+}
+
+func TestWetDry(t *testing.T) {
+	mono := sound.MonoCd()
+	for _, e := range []struct {
+		mix  float64
+		i    int
+		want float64
+	}{{0, 50, 0}, {0, 2999, 2899}, {0.5, 2999, 2899}, {1, 2999, 2899}, {-1, 50, 0}, {2, 150, 50}} {
+		// the dry signal is delayed by the latency of the wet one.
+		w := WetDry(&lag{delayLine: newDelayLine(100), d: 100}, e.mix)
+		if l := latency(w); l != 100 {
+			t.Errorf("got latency %d not 100", l)
+		}
+		u := New(mono, mono, w)
+		u.SetInput(&ramp{Form: mono, n: 3000})
+		snk := &capture{Form: mono}
+		u.AddOutput(snk)
+		if err := u.Run(); err != nil {
+			t.Fatal(err)
+		}
+		if snk.mix[e.i] != e.want {
+			t.Errorf("mix %g: got %f not %f at %d", e.mix, snk.mix[e.i], e.want, e.i)
+		}
+	}
+	triple := NewProcessor(FullMode, func(dst, src *Block) error {
+		for i, v := range src.Samples[:src.Frames] {
+			dst.Samples[i] = 3 * v
+		}
+		dst.Frames = src.Frames
+		return nil
+	})
+	u := New(mono, mono, WetDry(triple, 0.25))
+	u.SetInput(&ramp{Form: mono, n: 3000})
+	snk := &capture{Form: mono}
+	u.AddOutput(snk)
+	if err := u.Run(); err != nil {
+		t.Fatal(err)
+	}
+	if snk.mix[1000] != 1500 {
+		t.Errorf("got %f not 1500", snk.mix[1000])
+	}
+	// mix is clamped as by Mix.SetWet.
+	for _, e := range [][2]float64{{-1, 1000}, {2, 3000}} {
+		u := New(mono, mono, WetDry(triple, e[0]))
+		u.SetInput(&ramp{Form: mono, n: 3000})
+		snk := &capture{Form: mono}
+		u.AddOutput(snk)
+		if err := u.Run(); err != nil {
+			t.Fatal(err)
+		}
+		if snk.mix[1000] != e[1] {
+			t.Errorf("mix %g: got %f not %f", e[0], snk.mix[1000], e[1])
+		}
+	}
+	drop := NewProcessor(FullMode, func(dst, src *Block) error {
+		dst.Frames = src.Frames / 2
+		return nil
+	})
+	u = New(mono, mono, WetDry(drop, 0.5))
+	u.SetInput(&ramp{Form: mono, n: 3000})
+	u.AddOutput(&capture{Form: mono})
+	if err := u.Run(); err == nil {
+		t.Error("mixed a processor changing the number of frames")
+	}
+}
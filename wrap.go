@@ -0,0 +1,99 @@
+// Copyright 2018 The ZikiChombo Authors. All rights reserved.  Use of this source
+// code is governed by a license that can be found in the License file.
+
+package plug
+
+import "fmt"
+
+// runFull runs p on blocks in FullMode layout, whatever the channel mode of
+// p.  This allows processors to wrap other processors.
+//
+// In MonoMode, dst and src must have the same number of channels, and p
+// must produce the same number of frames for every channel.
+func runFull(p Processor, dst, src *Block) error {
+	if p.ChannelMode() == FullMode {
+		return runLayout(p, dst, src, nil)
+	}
+	return runChannels(monoPick(p), dst, src)
+}
+
+// runChannels runs the processor pick(c) on every channel c of blocks in
+// FullMode layout.  dst and src must have the same number of channels, and
+// the processors must produce the same number of frames for every channel.
+func runChannels(pick func(c int) Processor, dst, src *Block) error {
+	if src.Channels != dst.Channels {
+		return fmt.Errorf("per channel processing cannot map %d channels to %d", src.Channels, dst.Channels)
+	}
+	N, M := src.Frames, dst.Frames
+	bs := getBlocks()
+	defer putBlocks(bs, [2][]float64{bs[0].Samples, bs[1].Samples})
+	sb, db := &bs[0], &bs[1]
+	*sb = Block{SampleRate: src.SampleRate, Channels: 1, Frames: N, StartFrame: src.StartFrame, Time: src.Time,
+		Annotations: src.Annotations}
+	*db = Block{SampleRate: dst.SampleRate, Channels: 1, StartFrame: dst.StartFrame, Time: dst.Time,
+		Annotations: dst.Annotations}
+	m := -1
+	for c := 0; c < src.Channels; c++ {
+		sb.Samples = src.Samples[c*N : (c+1)*N]
+		sb.Frames = N
+		db.Samples = dst.Samples[c*M : (c+1)*M]
+		db.Frames = M
+		if err := pick(c).Process(db, sb); err != nil {
+			return err
+		}
+		if m == -1 {
+			m = db.Frames
+		}
+		if db.Frames != m {
+			return fmt.Errorf("processor produced %d frames on channel %d, %d on channel 0", db.Frames, c, m)
+		}
+	}
+	if m == -1 {
+		m = 0
+	}
+	dst.Annotations = db.Annotations
+	if m < M {
+		for c := 1; c < dst.Channels; c++ {
+			copy(dst.Samples[c*m:(c+1)*m], dst.Samples[c*M:c*M+m])
+		}
+	}
+	dst.Frames = m
+	return nil
+}
+
+type perChannel struct {
+	factory func() Processor
+	procs   []Processor
+}
+
+// PerChannel creates a FullMode processor which processes every channel
+// with its own processor instance created by factory, so that stateful
+// processors such as filters do not share state across channels.  The
+// instances are called with single channel blocks, like MonoMode
+// processors, and should all have the same shape.
+func PerChannel(factory func() Processor) Processor {
+	return &perChannel{
+		factory: factory,
+		procs:   []Processor{factory()}}
+}
+
+func (p *perChannel) ChannelMode() ChannelMode {
+	return FullMode
+}
+
+func (p *perChannel) NextFrames() (int, int) {
+	for _, q := range p.procs[1:] {
+		q.NextFrames()
+	}
+	return p.procs[0].NextFrames()
+}
+
+func (p *perChannel) Process(dst, src *Block) error {
+	for len(p.procs) < src.Channels {
+		q := p.factory()
+		// keep the new instance in step with the others.
+		q.NextFrames()
+		p.procs = append(p.procs, q)
+	}
+	return runChannels(func(c int) Processor { return p.procs[c] }, dst, src)
+}
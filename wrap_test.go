@@ -0,0 +1,56 @@
+// Copyright 2018 The ZikiChombo Authors. All rights reserved.  Use of this source
+// code is governed by a license that can be found in the License file.
+
+package plug
+
+import (
+	"testing"
+
+	"zikichombo.org/sound/freq"
+)
+
+func TestPerChannel(t *testing.T) {
+	made := 0
+	p := PerChannel(func() Processor {
+		made++
+		return &integrator{}
+	})
+	if p.ChannelMode() != FullMode {
+		t.Errorf("got channel mode %v", p.ChannelMode())
+	}
+	if i, o := p.NextFrames(); i != 300 || o != 300 {
+		t.Errorf("got next frames %d, %d", i, o)
+	}
+	N := 300
+	for b := 0; b < 2; b++ {
+		// channel c is constantly c+1.
+		src := &Block{SampleRate: 48000 * freq.Hertz, Channels: 3, Frames: N, Samples: make([]float64, 3*N)}
+		for c := 0; c < 3; c++ {
+			for f := 0; f < N; f++ {
+				src.Samples[c*N+f] = float64(c + 1)
+			}
+		}
+		dst := &Block{SampleRate: src.SampleRate, Channels: 3, Frames: N, Samples: make([]float64, 3*N)}
+		if err := p.Process(dst, src); err != nil {
+			t.Fatal(err)
+		}
+		if dst.Frames != N {
+			t.Fatalf("got %d frames not %d", dst.Frames, N)
+		}
+		// each channel is integrated on its own.
+		for c := 0; c < 3; c++ {
+			for f := 0; f < N; f++ {
+				if got, exp := dst.Samples[c*N+f], float64((c+1)*(b*N+f+1)); got != exp {
+					t.Fatalf("block %d channel %d frame %d: got %g not %g", b, c, f, got, exp)
+				}
+			}
+		}
+	}
+	if made != 3 {
+		t.Errorf("made %d instances for 3 channels", made)
+	}
+	src := &Block{SampleRate: 48000 * freq.Hertz, Channels: 2, Frames: N, Samples: make([]float64, 2*N)}
+	if err := p.Process(&Block{Channels: 1, Frames: N, Samples: make([]float64, N)}, src); err == nil {
+		t.Error("processed 2 channels to 1")
+	}
+}